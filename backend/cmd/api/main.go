@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,8 +11,12 @@ import (
 
 	"trader/internal/config"
 	"trader/internal/database"
+	"trader/internal/exchange/connectors"
 	"trader/internal/handlers"
+	"trader/internal/jobs"
+	"trader/internal/market"
 	"trader/internal/middleware"
+	"trader/internal/retention"
 	"trader/internal/services"
 	"trader/pkg/logger"
 
@@ -56,11 +61,93 @@ func main() {
 	// Initialize services
 	authService := services.NewAuthService(db.MySQL, redisClient, cfg)
 	userService := services.NewUserService(db.MySQL, cfg)
+	preferencesService := services.NewPreferencesService(db.MySQL, redisClient, cfg)
+	organizationService := services.NewOrganizationService(db.MySQL, cfg)
+	apiKeyService, err := services.NewAPIKeyService(db.MySQL, cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize API key encryption")
+	}
+	privacyService := services.NewPrivacyService(db.MySQL, cfg, preferencesService, organizationService, apiKeyService)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
-	systemHandler := handlers.NewSystemHandler(db)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService, authService)
+	preferencesHandler := handlers.NewPreferencesHandler(preferencesService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, authService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	systemHandler := handlers.NewSystemHandler(db, connectors.Health)
+
+	// Initialize background jobs
+	scheduler := jobs.NewScheduler(redisClient)
+	if cfg.Retention.Enabled {
+		archiveStore, err := retention.NewArchiveStore(cfg.Retention)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize retention archive store")
+		}
+		retentionService := retention.NewService(db.MySQL, redisClient, archiveStore, services.NewAuditService(db.MySQL, cfg), cfg)
+
+		scheduler.Register(jobs.Job{
+			Name:     "retention",
+			Interval: cfg.Retention.Interval,
+			Run: func(ctx context.Context) error {
+				_, err := retentionService.Run(ctx, "", false)
+				return err
+			},
+		})
+	}
+	marketService := market.NewService(db.MySQL, cfg)
+	if cfg.Market.PairSyncEnabled {
+		scheduler.Register(jobs.Job{
+			Name:       "pair_sync",
+			Interval:   cfg.Market.PairSyncInterval,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				_, err := marketService.SyncPairs(ctx, "", false)
+				return err
+			},
+		})
+	}
+	if cfg.Market.FeeSyncEnabled {
+		scheduler.Register(jobs.Job{
+			Name:       "fee_sync",
+			Interval:   cfg.Market.FeeSyncInterval,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				_, err := marketService.SyncFees(ctx, "")
+				_, tierErr := apiKeyService.SyncFeeTiers(ctx)
+				return errors.Join(err, tierErr)
+			},
+		})
+	}
+	if cfg.Market.MarketCapSyncEnabled {
+		marketCapProvider, err := market.NewMarketCapProvider(cfg.Market)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize market cap provider")
+		}
+
+		scheduler.Register(jobs.Job{
+			Name:       "market_cap_sync",
+			Interval:   cfg.Market.MarketCapSyncInterval,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				_, err := marketService.SyncMarketCaps(ctx, marketCapProvider)
+				return err
+			},
+		})
+	}
+	if cfg.Balances.SnapshotEnabled {
+		scheduler.Register(jobs.Job{
+			Name:       "balance_snapshots",
+			Interval:   cfg.Balances.SnapshotInterval,
+			RunOnStart: true,
+			Run: func(ctx context.Context) error {
+				_, err := apiKeyService.SnapshotBalances(ctx)
+				return err
+			},
+		})
+	}
+	scheduler.Start(context.Background())
 
 	// Create Fiber app with custom error handler
 	app := fiber.New(fiber.Config{
@@ -98,7 +185,7 @@ func main() {
 	}))
 
 	// Setup routes
-	setupRoutes(app, authHandler, userHandler, systemHandler, authService)
+	setupRoutes(app, authHandler, userHandler, privacyHandler, preferencesHandler, organizationHandler, apiKeyHandler, systemHandler, authService, organizationService)
 
 	// Start server in a goroutine
 	go func() {
@@ -117,6 +204,8 @@ func main() {
 	<-c
 	log.Info().Msg("Shutting down server...")
 
+	scheduler.Stop()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -131,8 +220,13 @@ func setupRoutes(
 	app *fiber.App,
 	authHandler *handlers.AuthHandler,
 	userHandler *handlers.UserHandler,
+	privacyHandler *handlers.PrivacyHandler,
+	preferencesHandler *handlers.PreferencesHandler,
+	organizationHandler *handlers.OrganizationHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
 	systemHandler *handlers.SystemHandler,
 	authService *services.AuthService,
+	organizationService *services.OrganizationService,
 ) {
 	// API v1 routes
 	api := app.Group("/api/v1")
@@ -161,6 +255,10 @@ func setupRoutes(
 	profile.Get("/", userHandler.GetProfile)
 	profile.Put("/", userHandler.UpdateProfile)
 	profile.Put("/password", userHandler.ChangePassword)
+	profile.Get("/preferences", preferencesHandler.GetPreferences)
+	profile.Put("/preferences", preferencesHandler.UpdatePreferences)
+	profile.Get("/export", privacyHandler.ExportData)
+	profile.Delete("/", privacyHandler.EraseAccount)
 
 	// User management routes (admin only)
 	users := api.Group("/users", middleware.AuthMiddleware(authService))
@@ -170,6 +268,9 @@ func setupRoutes(
 	users.Post("/",
 		middleware.RequirePermission("users:create"),
 		userHandler.CreateUser)
+	users.Get("/deleted",
+		middleware.RequirePermission("users:read"),
+		userHandler.GetDeletedUsers)
 	users.Get("/:id",
 		middleware.RequireOwnershipOrPermission("id", "users:read"),
 		userHandler.GetUser)
@@ -179,25 +280,75 @@ func setupRoutes(
 	users.Delete("/:id",
 		middleware.RequirePermission("users:delete"),
 		userHandler.DeleteUser)
+	users.Post("/:id/restore",
+		middleware.RequirePermission("users:update"),
+		userHandler.RestoreUser)
+	users.Delete("/:id/purge",
+		middleware.RequirePermission("users:delete"),
+		userHandler.PurgeUser)
 
 	// Helper endpoint for finding users by email (admin only)
 	users.Get("/search/by-email",
 		middleware.RequirePermission("users:read"),
 		userHandler.GetUserByEmail)
 
+	// Organization routes; /current acts on the workspace carried by the access token
+	organizations := api.Group("/organizations", middleware.AuthMiddleware(authService))
+	organizations.Get("/", organizationHandler.GetOrganizations)
+	organizations.Post("/", organizationHandler.CreateOrganization)
+
+	current := organizations.Group("/current", middleware.RequireWorkspace(organizationService))
+	current.Get("/", organizationHandler.GetCurrentOrganization)
+	current.Put("/",
+		middleware.RequireWorkspaceAction(services.OrgActionManageOrganization),
+		organizationHandler.UpdateCurrentOrganization)
+	current.Delete("/",
+		middleware.RequireWorkspaceAction(services.OrgActionManageOrganization),
+		organizationHandler.DeleteCurrentOrganization)
+	current.Get("/members", organizationHandler.GetMembers)
+	current.Post("/members",
+		middleware.RequireWorkspaceAction(services.OrgActionManageMembers),
+		organizationHandler.AddMember)
+	current.Put("/members/:userId",
+		middleware.RequireWorkspaceAction(services.OrgActionManageMembers),
+		organizationHandler.UpdateMember)
+	// Members may remove themselves, the service checks the role for everyone else
+	current.Delete("/members/:userId", organizationHandler.RemoveMember)
+
+	organizations.Post("/:id/switch", organizationHandler.SwitchOrganization)
+
 	// Future API endpoints can be added here:
 
-	// API keys routes (placeholder)
-	apiKeys := api.Group("/api-keys", middleware.AuthMiddleware(authService))
-	apiKeys.Get("/", func(c *fiber.Ctx) error {
-		return handlers.Success(c, fiber.Map{"message": "API keys endpoint - not implemented yet"})
-	})
-	apiKeys.Post("/", func(c *fiber.Ctx) error {
-		return handlers.Success(c, fiber.Map{"message": "Create API key endpoint - not implemented yet"})
-	})
+	// API keys routes, scoped to the active workspace
+	apiKeys := api.Group("/api-keys", middleware.AuthMiddleware(authService), middleware.RequireWorkspace(organizationService))
+	apiKeys.Get("/",
+		middleware.RequireAnyPermission("api_keys:read_own", "api_keys:read"),
+		apiKeyHandler.GetAPIKeys)
+	apiKeys.Post("/",
+		middleware.RequireAnyPermission("api_keys:create_own", "api_keys:create"),
+		middleware.RequireWorkspaceAction(services.OrgActionWrite),
+		apiKeyHandler.CreateAPIKey)
+	apiKeys.Get("/:id",
+		middleware.RequireOwnershipOrPermission("id", "api_keys:read"),
+		apiKeyHandler.GetAPIKey)
+	apiKeys.Get("/:id/balances",
+		middleware.RequireOwnershipOrPermission("id", "api_keys:read"),
+		apiKeyHandler.GetAPIKeyBalances)
+	apiKeys.Put("/:id",
+		middleware.RequireOwnershipOrPermission("id", "api_keys:update"),
+		middleware.RequireWorkspaceAction(services.OrgActionWrite),
+		apiKeyHandler.UpdateAPIKey)
+	apiKeys.Delete("/:id",
+		middleware.RequireOwnershipOrPermission("id", "api_keys:delete"),
+		middleware.RequireWorkspaceAction(services.OrgActionWrite),
+		apiKeyHandler.DeleteAPIKey)
+	apiKeys.Post("/:id/test",
+		middleware.RequireOwnershipOrPermission("id", "api_keys:update"),
+		middleware.RequireWorkspaceAction(services.OrgActionWrite),
+		apiKeyHandler.TestAPIKey)
 
 	// Positions routes (placeholder)
-	positions := api.Group("/positions", middleware.AuthMiddleware(authService))
+	positions := api.Group("/positions", middleware.AuthMiddleware(authService), middleware.RequireWorkspace(organizationService))
 	positions.Get("/", func(c *fiber.Ctx) error {
 		return handlers.Success(c, fiber.Map{"message": "Positions endpoint - not implemented yet"})
 	})
@@ -206,13 +357,13 @@ func setupRoutes(
 	})
 
 	// Strategies routes (placeholder)
-	strategies := api.Group("/strategies", middleware.AuthMiddleware(authService))
+	strategies := api.Group("/strategies", middleware.AuthMiddleware(authService), middleware.RequireWorkspace(organizationService))
 	strategies.Get("/", func(c *fiber.Ctx) error {
 		return handlers.Success(c, fiber.Map{"message": "Strategies endpoint - not implemented yet"})
 	})
 
 	// Analytics routes (placeholder)
-	analytics := api.Group("/analytics", middleware.AuthMiddleware(authService))
+	analytics := api.Group("/analytics", middleware.AuthMiddleware(authService), middleware.RequireWorkspace(organizationService))
 	analytics.Get("/positions/performance", func(c *fiber.Ctx) error {
 		return handlers.Success(c, fiber.Map{"message": "Analytics endpoint - not implemented yet"})
 	})
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"trader/internal/config"
+	"trader/internal/database"
+	"trader/internal/secrets"
+	"trader/internal/services"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cfg *config.Config
+	db  *database.Database
+)
+
+func main() {
+	// Initialize configuration
+	cfg = config.Load()
+
+	// Connect to database
+	var err error
+	db, err = database.NewDatabase(cfg)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Setup root command
+	var rootCmd = &cobra.Command{
+		Use:   "keys",
+		Short: "Encryption key tool for the trading bot",
+		Long:  `Console tool for inspecting and rotating the master keys that protect stored exchange credentials.`,
+	}
+
+	// Add commands
+	rootCmd.AddCommand(
+		statusCmd(),
+		rotateCmd(),
+		keystoreCmd(),
+	)
+
+	// Execute command
+	if err := rootCmd.Execute(); err != nil {
+		slog.Error("command execution failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// Key status command
+func statusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show master key usage",
+		Long:  `Display the loaded master key versions and how many API keys each version still wraps.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showStatus()
+		},
+	}
+
+	return cmd
+}
+
+// Rotate command
+func rotateCmd() *cobra.Command {
+	var batchSize int
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Re-wrap data keys with the active master key",
+		Long: `Re-wrap every API key data key with the active master key version (API_ENCRYPTION_KEY_VERSION,
+or the latest transit key version with Vault).
+Batches commit independently and are recorded in the audit log, so an interrupted
+rotation continues where it stopped when run again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotateKeys(batchSize)
+		},
+	}
+
+	cmd.Flags().IntVar(&batchSize, "batch-size", services.DefaultKeyRotationBatchSize, "API keys per batch")
+
+	return cmd
+}
+
+// Keystore command
+func keystoreCmd() *cobra.Command {
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "keystore",
+		Short: "Manage the passphrase protected keystore",
+		Long: `Manage the keystore used by API_ENCRYPTION_PROVIDER=keystore. The passphrase is read
+from API_KEYSTORE_PASSPHRASE_FILE or API_KEYSTORE_PASSPHRASE.`,
+	}
+
+	cmd.PersistentFlags().StringVar(&path, "path", "", "Keystore file (default: API_KEYSTORE_PATH)")
+
+	addCmd := &cobra.Command{
+		Use:   "add",
+		Short: "Generate a new master key version",
+		Long:  `Generate a random master key as the next version, creating the keystore if it does not exist.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addKeystoreKey(path)
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import the key ring from the environment",
+		Long: `Copy the key ring configured in API_ENCRYPTION_KEYS (or API_ENCRYPTION_KEY as version 1)
+into the keystore, so stored API keys stay readable after switching to the keystore provider.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importKeystoreKeys(path)
+		},
+	}
+
+	cmd.AddCommand(addCmd, importCmd)
+	return cmd
+}
+
+func showStatus() error {
+	apiKeyService, err := services.NewAPIKeyService(db.MySQL, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize API key encryption: %w", err)
+	}
+
+	status, err := apiKeyService.RotationStatus(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Provider: %s\n\n", cfg.Encryption.Provider)
+	fmt.Printf("%-10s %-10s %s\n", "VERSION", "KEYS", "STATE")
+	fmt.Printf("%-10s %-10s %s\n", "-------", "----", "-----")
+
+	loaded := make(map[int]bool, len(status.LoadedVersions))
+	for _, version := range status.LoadedVersions {
+		loaded[version] = true
+		state := "unused, can be removed"
+		switch {
+		case version == status.ActiveVersion:
+			state = "active"
+		case status.KeysByVersion[version] > 0:
+			state = "pending rotation"
+		}
+		fmt.Printf("%-10d %-10d %s\n", version, status.KeysByVersion[version], state)
+	}
+	for version, count := range status.KeysByVersion {
+		if !loaded[version] {
+			fmt.Printf("%-10d %-10d %s\n", version, count, "MISSING from key provider")
+		}
+	}
+
+	fmt.Printf("\nPending: %d\n", status.Pending)
+	return nil
+}
+
+func rotateKeys(batchSize int) error {
+	apiKeyService, err := services.NewAPIKeyService(db.MySQL, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize API key encryption: %w", err)
+	}
+
+	// Stop between batches on Ctrl+C; committed batches are kept
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := apiKeyService.RotateMasterKey(ctx, batchSize, func(progress *services.KeyRotationResult) {
+		fmt.Printf("batch %-6d rewrapped: %-8d failed: %d\n", progress.Batches, progress.Rewrapped, len(progress.Failed))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, failure := range result.Failed {
+		fmt.Printf("   API key %d (version %d): %s\n", failure.APIKeyID, failure.Version, failure.Error)
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d API keys could not be re-wrapped", len(result.Failed))
+	}
+
+	if result.Resumed {
+		fmt.Println("Resumed an interrupted rotation")
+	}
+	fmt.Printf("✅ Re-wrapped %d API keys with master key version %d\n", result.Rewrapped, result.TargetVersion)
+	return nil
+}
+
+func addKeystoreKey(path string) error {
+	path, passphrase, keys, err := loadKeystore(path)
+	if err != nil {
+		return err
+	}
+
+	version := 1
+	for existing := range keys {
+		if existing >= version {
+			version = existing + 1
+		}
+	}
+	key, err := secrets.GenerateMasterKey()
+	if err != nil {
+		return err
+	}
+	keys[version] = key
+
+	if err := secrets.WriteKeystore(path, passphrase, keys); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Added master key version %d to %s\n", version, path)
+	if version > 1 {
+		fmt.Println("Run `keys rotate` to re-wrap stored API keys with the new version")
+	}
+	return nil
+}
+
+func importKeystoreKeys(path string) error {
+	imported, err := secrets.ParseMasterKeys(cfg.Encryption.KeyRing())
+	if err != nil {
+		return fmt.Errorf("failed to parse key ring: %w", err)
+	}
+
+	path, passphrase, keys, err := loadKeystore(path)
+	if err != nil {
+		return err
+	}
+	for version, key := range imported {
+		if existing, ok := keys[version]; ok && !bytes.Equal(existing, key) {
+			return fmt.Errorf("keystore already holds a different key as version %d", version)
+		}
+		keys[version] = key
+	}
+
+	if err := secrets.WriteKeystore(path, passphrase, keys); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Imported %d master key versions into %s\n", len(imported), path)
+	return nil
+}
+
+// loadKeystore reads the keystore, or returns no keys if it does not exist yet
+func loadKeystore(path string) (string, []byte, map[int][]byte, error) {
+	if path == "" {
+		path = cfg.Encryption.KeystorePath
+	}
+	passphrase, err := secrets.ReadSecret(cfg.Encryption.KeystorePassphrase, cfg.Encryption.KeystorePassphraseFile)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read keystore passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", nil, nil, fmt.Errorf("API_KEYSTORE_PASSPHRASE or API_KEYSTORE_PASSPHRASE_FILE is required")
+	}
+
+	keys, err := secrets.ReadKeystore(path, []byte(passphrase))
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, []byte(passphrase), make(map[int][]byte), nil
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return path, []byte(passphrase), keys, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/database"
+	"trader/internal/market"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cfg *config.Config
+	db  *database.Database
+)
+
+func main() {
+	// Initialize configuration
+	cfg = config.Load()
+
+	// Connect to database
+	var err error
+	db, err = database.NewDatabase(cfg)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Setup root command
+	var rootCmd = &cobra.Command{
+		Use:   "market",
+		Short: "Market metadata tool for the trading bot",
+		Long:  `Console tool for syncing exchange and coin metadata.`,
+	}
+
+	// Add commands
+	rootCmd.AddCommand(
+		syncPairsCmd(),
+		syncFeesCmd(),
+		syncMarketCapsCmd(),
+	)
+
+	// Execute command
+	if err := rootCmd.Execute(); err != nil {
+		slog.Error("command execution failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// Sync trading pairs command
+func syncPairsCmd() *cobra.Command {
+	var (
+		exchangeCode string
+		dryRun       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sync-pairs",
+		Short: "Sync trading pairs from the exchanges",
+		Long: `Pull symbol metadata from every active exchange and upsert its trading pairs.
+Missing coins are created and delisted or halted pairs are deactivated.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return syncPairs(exchangeCode, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&exchangeCode, "exchange", "", "Sync only the exchange with this code")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report the changes without applying them")
+
+	return cmd
+}
+
+// Sync fees command
+func syncFeesCmd() *cobra.Command {
+	var exchangeCode string
+
+	cmd := &cobra.Command{
+		Use:   "sync-fees",
+		Short: "Sync fee schedules from the exchanges",
+		Long: `Pull the fees the exchanges list per symbol into their default fee tier.
+Schedules edited by hand are left alone. The fee tiers of the API keys are synced by the API server.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return syncFees(exchangeCode)
+		},
+	}
+
+	cmd.Flags().StringVar(&exchangeCode, "exchange", "", "Sync only the exchange with this code")
+
+	return cmd
+}
+
+// Sync market caps command
+func syncMarketCapsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync-market-caps",
+		Short: "Refresh the market cap ranking of the coins",
+		Long: `Refresh market cap, rank and sort order of every coin from the market cap provider
+and flag the coins that left the top coins.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return syncMarketCaps()
+		},
+	}
+
+	return cmd
+}
+
+func syncPairs(exchangeCode string, dryRun bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	marketService := market.NewService(db.MySQL, cfg)
+	results, err := marketService.SyncPairs(ctx, exchangeCode, dryRun)
+	for _, result := range results {
+		fmt.Printf("%s\n", result.Exchange)
+		if result.Error != "" {
+			fmt.Printf("   ❌ %s\n", result.Error)
+			continue
+		}
+		for _, change := range result.Changes {
+			fmt.Printf("   %s\n", change)
+		}
+		if len(result.CoinsCreated) > 0 {
+			fmt.Printf("   new coins: %v\n", result.CoinsCreated)
+		}
+		fmt.Printf("   created: %-6d updated: %-6d deactivated: %-6d unchanged: %d\n",
+			result.Count(market.PairCreated), result.Count(market.PairUpdated),
+			result.Count(market.PairDeactivated), result.Unchanged)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Println("✅ Dry run completed, nothing was changed")
+		return nil
+	}
+	fmt.Println("✅ Trading pairs synced successfully")
+	return nil
+}
+
+func syncFees(exchangeCode string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	marketService := market.NewService(db.MySQL, cfg)
+	results, err := marketService.SyncFees(ctx, exchangeCode)
+	for _, result := range results {
+		fmt.Printf("%s\n", result.Exchange)
+		if result.Error != "" {
+			fmt.Printf("   ❌ %s\n", result.Error)
+			continue
+		}
+		fmt.Printf("   created: %-6d updated: %-6d removed: %-6d manual: %-6d unchanged: %d\n",
+			result.Created, result.Updated, result.Removed, result.Manual, result.Unchanged)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✅ Fee schedules synced successfully")
+	return nil
+}
+
+func syncMarketCaps() error {
+	provider, err := market.NewMarketCapProvider(cfg.Market)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	marketService := market.NewService(db.MySQL, cfg)
+	result, err := marketService.SyncMarketCaps(ctx, provider)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ranked: %-6d unranked: %d\n", result.Ranked, len(result.Unranked))
+	for _, symbol := range result.Entered {
+		fmt.Printf("   + %s entered the top %d\n", symbol, cfg.Market.TopCoins)
+	}
+	for _, symbol := range result.DroppedOut {
+		fmt.Printf("   - %s dropped out of the top %d\n", symbol, cfg.Market.TopCoins)
+	}
+	if len(result.Unranked) > 0 {
+		fmt.Printf("   not ranked: %v\n", result.Unranked)
+	}
+
+	fmt.Println("✅ Market caps synced successfully")
+	return nil
+}
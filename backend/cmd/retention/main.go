@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/database"
+	"trader/internal/retention"
+	"trader/internal/services"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cfg *config.Config
+	db  *database.Database
+)
+
+func main() {
+	// Initialize configuration
+	cfg = config.Load()
+
+	// Connect to database
+	var err error
+	db, err = database.NewDatabase(cfg)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Setup root command
+	var rootCmd = &cobra.Command{
+		Use:   "retention",
+		Short: "Data retention tool for the trading bot",
+		Long:  `Console tool for archiving and purging expired audit and security data.`,
+	}
+
+	// Add commands
+	rootCmd.AddCommand(
+		runCmd(),
+		policiesCmd(),
+	)
+
+	// Execute command
+	if err := rootCmd.Execute(); err != nil {
+		slog.Error("command execution failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// Run retention command
+func runCmd() *cobra.Command {
+	var (
+		policy string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Apply retention policies",
+		Long:  `Archive expired rows to compressed NDJSON files and delete them in batches.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRetention(policy, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&policy, "policy", "", "Apply only the named policy")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be removed without changing anything")
+
+	return cmd
+}
+
+// List policies command
+func policiesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policies",
+		Short: "List retention policies",
+		Long:  `Display the configured retention policies.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listPolicies()
+		},
+	}
+
+	return cmd
+}
+
+func newRetentionService() (*retention.Service, error) {
+	store, err := retention.NewArchiveStore(cfg.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize archive store: %w", err)
+	}
+
+	auditService := services.NewAuditService(db.MySQL, cfg)
+	return retention.NewService(db.MySQL, db.Redis, store, auditService, cfg), nil
+}
+
+func runRetention(policy string, dryRun bool) error {
+	retentionService, err := newRetentionService()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	results, err := retentionService.Run(ctx, policy, dryRun)
+	for _, result := range results {
+		if dryRun {
+			fmt.Printf("%-25s would remove: %d\n", result.Policy, result.Archived+result.Deleted)
+			continue
+		}
+
+		fmt.Printf("%-25s archived: %-8d deleted: %d\n", result.Policy, result.Archived, result.Deleted)
+		for _, file := range result.Files {
+			fmt.Printf("   %s\n", file)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✅ Retention completed successfully")
+	return nil
+}
+
+func listPolicies() error {
+	retentionService, err := newRetentionService()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-25s %-12s %s\n", "POLICY", "MAX AGE", "ARCHIVE")
+	fmt.Printf("%-25s %-12s %s\n", "------", "-------", "-------")
+
+	for _, policy := range retentionService.Policies() {
+		maxAge := "token expiry"
+		if policy.MaxAge > 0 {
+			maxAge = policy.MaxAge.String()
+		}
+		fmt.Printf("%-25s %-12s %t\n", policy.Name, maxAge, policy.Archive)
+	}
+
+	return nil
+}
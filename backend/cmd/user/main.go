@@ -2,10 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,7 +23,9 @@ import (
 
 	"trader/internal/config"
 	"trader/internal/database"
+	"trader/internal/mail"
 	"trader/internal/models"
+	"trader/internal/services"
 
 	"github.com/spf13/cobra"
 )
@@ -58,6 +64,9 @@ func main() {
 		activateCmd(),
 		deactivateCmd(),
 		deleteCmd(),
+		deletedCmd(),
+		restoreCmd(),
+		purgeCmd(),
 		setRoleCmd(),
 		removeRoleCmd(),
 		rolesCmd(),
@@ -68,6 +77,10 @@ func main() {
 		unlockCmd(),
 		listRolesCmd(),
 		listPermissionsCmd(),
+		importCmd(),
+		exportCmd(),
+		exportDataCmd(),
+		eraseCmd(),
 	)
 
 	// Execute command
@@ -108,25 +121,168 @@ func createCmd() *cobra.Command {
 // List users command
 func listCmd() *cobra.Command {
 	var (
-		roleFilter   string
-		activeFilter string
-		limit        int
-		offset       int
+		search        string
+		roleFilter    string
+		activeFilter  string
+		lockedFilter  string
+		verified      string
+		lastLoginFrom string
+		lastLoginTo   string
+		sortBy        string
+		sortOrder     string
+		limit         int
+		cursor        string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List users",
-		Long:  `List users with optional filtering.`,
+		Long:  `List users with search, filtering, sorting and cursor pagination.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listUsers(roleFilter, activeFilter, limit, offset)
+			filter := &services.ListUsersFilter{
+				Search:    search,
+				Role:      roleFilter,
+				SortBy:    sortBy,
+				SortOrder: sortOrder,
+				Limit:     limit,
+				Cursor:    cursor,
+			}
+
+			var err error
+			if filter.Active, err = parseBoolFlag("active", activeFilter); err != nil {
+				return err
+			}
+			if filter.Locked, err = parseBoolFlag("locked", lockedFilter); err != nil {
+				return err
+			}
+			if filter.EmailVerified, err = parseBoolFlag("verified", verified); err != nil {
+				return err
+			}
+			if filter.LastLoginFrom, err = parseTimeFlag("last-login-from", lastLoginFrom, false); err != nil {
+				return err
+			}
+			if filter.LastLoginTo, err = parseTimeFlag("last-login-to", lastLoginTo, true); err != nil {
+				return err
+			}
+
+			return listUsers(filter)
 		},
 	}
 
+	cmd.Flags().StringVarP(&search, "search", "s", "", "Search email and name")
 	cmd.Flags().StringVar(&roleFilter, "role", "", "Filter by role")
 	cmd.Flags().StringVar(&activeFilter, "active", "", "Filter by active status (true/false)")
+	cmd.Flags().StringVar(&lockedFilter, "locked", "", "Filter by locked status (true/false)")
+	cmd.Flags().StringVar(&verified, "verified", "", "Filter by email verification (true/false)")
+	cmd.Flags().StringVar(&lastLoginFrom, "last-login-from", "", "Last login at or after (RFC3339 or YYYY-MM-DD)")
+	cmd.Flags().StringVar(&lastLoginTo, "last-login-to", "", "Last login at or before (RFC3339 or YYYY-MM-DD)")
+	cmd.Flags().StringVar(&sortBy, "sort", services.UserSortCreatedAt, "Sort field (created_at, email, first_name, last_name, last_login_at)")
+	cmd.Flags().StringVar(&sortOrder, "order", services.SortDesc, "Sort order (asc/desc)")
 	cmd.Flags().IntVar(&limit, "limit", 10, "Number of users to return")
-	cmd.Flags().IntVar(&offset, "offset", 0, "Number of users to skip")
+	cmd.Flags().StringVar(&cursor, "cursor", "", "Cursor from the previous page")
+
+	return cmd
+}
+
+// Import users command
+func importCmd() *cobra.Command {
+	var (
+		file         string
+		format       string
+		passwordMode string
+		defaultRole  string
+		reportFile   string
+		dryRun       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import users from CSV or JSON",
+		Long: `Import users from a CSV file with a header row (email, first_name, last_name, roles)
+or a JSON array. Every row is validated first and all users are created in one transaction.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importUsers(file, format, passwordMode, defaultRole, reportFile, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "File to import (required)")
+	cmd.Flags().StringVar(&format, "format", "", "File format (csv/json, default: from file extension)")
+	cmd.Flags().StringVar(&passwordMode, "passwords", services.ImportPasswordRandom, "Credential mode (random/invite)")
+	cmd.Flags().StringVar(&defaultRole, "default-role", "trader", "Role for rows without roles")
+	cmd.Flags().StringVar(&reportFile, "report", "", "Write the JSON report, including generated passwords, to this file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate the file without creating users")
+
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// Export users command
+func exportCmd() *cobra.Command {
+	var (
+		format string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export users",
+		Long:  `Export users with their roles and direct permissions as JSON or CSV.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportUsers(format, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", services.ImportFormatJSON, "Output format (json/csv)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: stdout)")
+
+	return cmd
+}
+
+// Export personal data command
+func exportDataCmd() *cobra.Command {
+	var (
+		userID uint64
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export-data",
+		Short: "Export a user's personal data",
+		Long:  `Write a zip archive with the user's profile, roles, permissions and audit entries (data subject access request).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportUserData(userID, output)
+		},
+	}
+
+	cmd.Flags().Uint64Var(&userID, "id", 0, "User ID (required)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: personal-data-<id>.zip)")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+// Erase user command
+func eraseCmd() *cobra.Command {
+	var (
+		userID uint64
+		yes    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "erase",
+		Short: "Erase a user's personal data",
+		Long: `Anonymize a user account: personal fields are replaced by a pseudonym, roles, permissions
+and credentials are removed and the account is deleted. Financial records keep the pseudonymous owner.
+This cannot be undone.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eraseUser(userID, yes)
+		},
+	}
+
+	cmd.Flags().Uint64Var(&userID, "id", 0, "User ID (required)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	cmd.MarkFlagRequired("id")
 
 	return cmd
 }
@@ -246,6 +402,89 @@ func deleteCmd() *cobra.Command {
 	return cmd
 }
 
+// List deleted users command
+func deletedCmd() *cobra.Command {
+	var (
+		search    string
+		purgeable bool
+		limit     int
+		cursor    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "deleted",
+		Short: "List deleted users",
+		Long:  `List soft-deleted users, most recently deleted first, with the date after which they can be purged.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listDeletedUsers(&services.ListDeletedUsersFilter{
+				Search:    search,
+				Purgeable: purgeable,
+				Limit:     limit,
+				Cursor:    cursor,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&search, "search", "s", "", "Search email and name")
+	cmd.Flags().BoolVar(&purgeable, "purgeable", false, "Only users past the grace period")
+	cmd.Flags().IntVar(&limit, "limit", 10, "Number of users to return")
+	cmd.Flags().StringVar(&cursor, "cursor", "", "Cursor from the previous page")
+
+	return cmd
+}
+
+// Restore user command
+func restoreCmd() *cobra.Command {
+	var userID uint64
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore deleted user",
+		Long:  `Restore a soft-deleted user together with its roles.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return restoreUser(userID)
+		},
+	}
+
+	cmd.Flags().Uint64Var(&userID, "id", 0, "User ID (required)")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+// Purge users command
+func purgeCmd() *cobra.Command {
+	var (
+		userID  uint64
+		expired bool
+		force   bool
+		yes     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Permanently remove deleted users",
+		Long: `Permanently remove a soft-deleted user (--id) or every user past the grace period (--expired).
+A single user can be purged before the grace period ends with --force. This cannot be undone.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (userID == 0) == !expired {
+				return fmt.Errorf("specify either --id or --expired")
+			}
+			if expired {
+				return purgeExpiredUsers(yes)
+			}
+			return purgeUser(userID, force, yes)
+		},
+	}
+
+	cmd.Flags().Uint64Var(&userID, "id", 0, "User ID")
+	cmd.Flags().BoolVar(&expired, "expired", false, "Purge all users past the grace period")
+	cmd.Flags().BoolVar(&force, "force", false, "Ignore the grace period")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
 // Set role command
 func setRoleCmd() *cobra.Command {
 	var (
@@ -510,6 +749,44 @@ func hashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// Dereference optional flag, treating nil as false
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+// Parse optional true/false filter flag
+func parseBoolFlag(name, value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s value %q, expected true or false", name, value)
+	}
+	return &parsed, nil
+}
+
+// Parse optional RFC3339 or YYYY-MM-DD flag; a plain date used as upper bound covers the whole day
+func parseTimeFlag(name, value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s value %q, expected RFC3339 or YYYY-MM-DD", name, value)
+	}
+	if endOfDay {
+		parsed = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &parsed, nil
+}
+
 // Validate role
 func validateRole(roleName string) error {
 	var role models.Role
@@ -594,12 +871,13 @@ func createUser(email, firstName, lastName, roleName string) error {
 		}
 	}()
 
+	isActive := true
 	user := models.User{
 		Email:        email,
 		FirstName:    firstName,
 		LastName:     lastName,
 		PasswordHash: hashedPassword,
-		IsActive:     true,
+		IsActive:     &isActive,
 	}
 
 	if err := tx.Create(&user).Error; err != nil {
@@ -632,57 +910,228 @@ func createUser(email, firstName, lastName, roleName string) error {
 	fmt.Printf("   Email: %s\n", user.Email)
 	fmt.Printf("   Name: %s %s\n", user.FirstName, user.LastName)
 	fmt.Printf("   Role: %s\n", roleName)
-	fmt.Printf("   Active: %t\n", user.IsActive)
+	fmt.Printf("   Active: %t\n", boolValue(user.IsActive))
 	fmt.Printf("   Created: %s\n", user.CreatedAt.Format(time.RFC3339))
 
 	return nil
 }
 
-func listUsers(roleFilter, activeFilter string, limit, offset int) error {
-	query := db.MySQL.Preload("Roles")
-
-	// Apply filters
-	if roleFilter != "" {
-		query = query.Joins("JOIN user_roles ON users.id = user_roles.user_id").
-			Joins("JOIN roles ON user_roles.role_id = roles.id").
-			Where("roles.name = ?", roleFilter)
-	}
+func listUsers(filter *services.ListUsersFilter) error {
+	userService := services.NewUserService(db.MySQL, cfg)
 
-	if activeFilter != "" {
-		if active, err := strconv.ParseBool(activeFilter); err == nil {
-			query = query.Where("is_active = ?", active)
-		}
-	}
-
-	var users []models.User
-	if err := query.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	response, err := userService.ListUsers(context.Background(), filter)
+	if err != nil {
 		return fmt.Errorf("failed to list users: %w", err)
 	}
 
-	if len(users) == 0 {
+	if len(response.Users) == 0 {
 		fmt.Println("No users found.")
 		return nil
 	}
 
-	fmt.Printf("Found %d users:\n\n", len(users))
-	for _, user := range users {
-		roleNames := make([]string, len(user.Roles))
-		for i, role := range user.Roles {
-			roleNames[i] = role.Name
-		}
-
+	fmt.Printf("Showing %d of %d users:\n\n", len(response.Users), response.Meta.Total)
+	for _, user := range response.Users {
 		fmt.Printf("ID: %d\n", user.ID)
 		fmt.Printf("Email: %s\n", user.Email)
 		fmt.Printf("Name: %s %s\n", user.FirstName, user.LastName)
-		fmt.Printf("Roles: %s\n", strings.Join(roleNames, ", "))
-		fmt.Printf("Active: %t\n", user.IsActive)
+		fmt.Printf("Roles: %s\n", strings.Join(user.Roles, ", "))
+		fmt.Printf("Active: %t\n", boolValue(user.IsActive))
+		fmt.Printf("Email Verified: %t\n", user.EmailVerified)
 		if user.LastLoginAt != nil {
 			fmt.Printf("Last Login: %s\n", user.LastLoginAt.Format(time.RFC3339))
 		}
-		fmt.Printf("Created: %s\n", user.CreatedAt.Format(time.RFC3339))
 		fmt.Println("---")
 	}
 
+	if response.Meta.NextCursor != "" {
+		fmt.Printf("\nNext page: --cursor %s\n", response.Meta.NextCursor)
+	}
+
+	return nil
+}
+
+func importUsers(file, format, passwordMode, defaultRole, reportFile string, dryRun bool) error {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer f.Close()
+
+	rows, err := services.ParseImportRows(f, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse import file: %w", err)
+	}
+	if len(rows) == 0 {
+		fmt.Println("No users found in file.")
+		return nil
+	}
+
+	bulkService := services.NewUserBulkService(db.MySQL, cfg, mail.NewSMTPMailer(cfg.Mail))
+	report, importErr := bulkService.ImportUsers(context.Background(), rows, &services.ImportOptions{
+		PasswordMode: passwordMode,
+		DefaultRole:  defaultRole,
+		DryRun:       dryRun,
+	})
+	if report == nil {
+		return fmt.Errorf("failed to import users: %w", importErr)
+	}
+
+	if reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		// The report may contain generated passwords
+		if err := os.WriteFile(reportFile, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	for _, row := range report.Rows {
+		fmt.Printf("Line %d: %s [%s]", row.Line, row.Email, row.Status)
+		if len(row.Roles) > 0 {
+			fmt.Printf(" roles=%s", strings.Join(row.Roles, ","))
+		}
+		if row.Password != "" && reportFile == "" {
+			fmt.Printf(" password=%s", row.Password)
+		}
+		if row.InviteSent {
+			fmt.Printf(" invite sent")
+		}
+		fmt.Println()
+		for _, rowErr := range row.Errors {
+			fmt.Printf("   ❌ %s\n", rowErr)
+		}
+	}
+	fmt.Println()
+
+	if importErr != nil {
+		fmt.Printf("❌ %d of %d rows are invalid, no users were imported\n", report.Invalid, len(report.Rows))
+		return importErr
+	}
+
+	if dryRun {
+		fmt.Printf("✅ All %d rows are valid\n", len(report.Rows))
+		return nil
+	}
+
+	fmt.Printf("✅ Imported %d users\n", report.Created)
+	if reportFile != "" {
+		fmt.Printf("   Report: %s\n", reportFile)
+	}
+	return nil
+}
+
+func exportUsers(format, output string) error {
+	bulkService := services.NewUserBulkService(db.MySQL, cfg, nil)
+
+	users, err := bulkService.ExportUsers(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to export users: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case services.ImportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(users)
+	case services.ImportFormatCSV:
+		err = services.WriteUsersCSV(w, users)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	if output != "" {
+		fmt.Printf("✅ Exported %d users to %s\n", len(users), output)
+	}
+	return nil
+}
+
+// newPrivacyService registers every personal data provider the API registers
+func newPrivacyService() (*services.PrivacyService, error) {
+	apiKeyService, err := services.NewAPIKeyService(db.MySQL, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize API key encryption: %w", err)
+	}
+
+	return services.NewPrivacyService(db.MySQL, cfg,
+		services.NewPreferencesService(db.MySQL, db.Redis, cfg),
+		services.NewOrganizationService(db.MySQL, cfg),
+		apiKeyService,
+	), nil
+}
+
+func exportUserData(userID uint64, output string) error {
+	privacyService, err := newPrivacyService()
+	if err != nil {
+		return err
+	}
+
+	archive, err := privacyService.ExportUserData(context.Background(), uint(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to export personal data: %w", err)
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("personal-data-%d.zip", userID)
+	}
+	if err := os.WriteFile(output, archive, 0o600); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	fmt.Printf("✅ Personal data of user %d exported to %s\n", userID, output)
+	return nil
+}
+
+func eraseUser(userID uint64, yes bool) error {
+	var user models.User
+	if err := db.MySQL.Unscoped().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !yes && !promptConfirmation(fmt.Sprintf("Erase all personal data of user '%s' (ID: %d)? This cannot be undone.", user.Email, userID)) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	privacyService, err := newPrivacyService()
+	if err != nil {
+		return err
+	}
+	result, err := privacyService.EraseUser(context.Background(), uint(userID), nil)
+	if err != nil {
+		if errors.Is(err, services.ErrUserErased) {
+			return fmt.Errorf("user data has already been erased")
+		}
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	fmt.Printf("✅ User %d erased (pseudonym: %s)\n", userID, result.Pseudonym)
+	fmt.Printf("   Roles removed: %d, permissions removed: %d, reset tokens removed: %d\n",
+		result.Roles, result.Permissions, result.Tokens)
 	return nil
 }
 
@@ -707,7 +1156,7 @@ func showUser(userID uint64, email string) error {
 	fmt.Printf("ID: %d\n", user.ID)
 	fmt.Printf("Email: %s\n", user.Email)
 	fmt.Printf("Name: %s %s\n", user.FirstName, user.LastName)
-	fmt.Printf("Active: %t\n", user.IsActive)
+	fmt.Printf("Active: %t\n", boolValue(user.IsActive))
 	fmt.Printf("Email Verified: %t\n", user.EmailVerified)
 	if user.LastLoginAt != nil {
 		fmt.Printf("Last Login: %s\n", user.LastLoginAt.Format(time.RFC3339))
@@ -833,6 +1282,117 @@ func deleteUser(userID uint64) error {
 	return nil
 }
 
+func listDeletedUsers(filter *services.ListDeletedUsersFilter) error {
+	userService := services.NewUserService(db.MySQL, cfg)
+
+	response, err := userService.ListDeletedUsers(context.Background(), filter)
+	if err != nil {
+		return fmt.Errorf("failed to list deleted users: %w", err)
+	}
+
+	if len(response.Users) == 0 {
+		fmt.Println("No deleted users found.")
+		return nil
+	}
+
+	fmt.Printf("Showing %d of %d deleted users:\n\n", len(response.Users), response.Meta.Total)
+	for _, user := range response.Users {
+		fmt.Printf("ID: %d\n", user.ID)
+		fmt.Printf("Email: %s\n", user.Email)
+		fmt.Printf("Name: %s %s\n", user.FirstName, user.LastName)
+		fmt.Printf("Roles: %s\n", strings.Join(user.Roles, ", "))
+		fmt.Printf("Deleted: %s\n", user.DeletedAt.Format(time.RFC3339))
+		if user.Erased {
+			fmt.Println("Erased: true (kept as pseudonym)")
+		} else {
+			fmt.Printf("Purge After: %s\n", user.PurgeAfter.Format(time.RFC3339))
+		}
+		fmt.Println("---")
+	}
+
+	if response.Meta.NextCursor != "" {
+		fmt.Printf("\nNext page: --cursor %s\n", response.Meta.NextCursor)
+	}
+
+	return nil
+}
+
+func restoreUser(userID uint64) error {
+	userService := services.NewUserService(db.MySQL, cfg)
+
+	user, err := userService.RestoreUser(context.Background(), uint(userID), nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return fmt.Errorf("user not found")
+		case errors.Is(err, services.ErrUserNotDeleted):
+			return fmt.Errorf("user is not deleted")
+		case errors.Is(err, services.ErrEmailExists):
+			return fmt.Errorf("email has been registered by another user; change that user's email first")
+		default:
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+	}
+
+	fmt.Printf("✅ User restored successfully (ID: %d)\n", user.ID)
+	fmt.Printf("   Email: %s\n", user.Email)
+	fmt.Printf("   Roles: %s\n", strings.Join(user.Roles, ", "))
+	return nil
+}
+
+func purgeUser(userID uint64, force, yes bool) error {
+	var user models.User
+	if err := db.MySQL.Unscoped().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !yes && !promptConfirmation(fmt.Sprintf("Permanently remove user '%s' (ID: %d)? This cannot be undone.", user.Email, userID)) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	userService := services.NewUserService(db.MySQL, cfg)
+	if err := userService.PurgeUser(context.Background(), uint(userID), force, nil); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotDeleted):
+			return fmt.Errorf("user is not deleted; delete it first")
+		case errors.Is(err, services.ErrPurgeGracePeriod):
+			return fmt.Errorf("user can be purged after %s; use --force to purge now",
+				user.DeletedAt.Time.Add(cfg.Security.DeletedUserGracePeriod).Format(time.RFC3339))
+		case errors.Is(err, services.ErrUserErased):
+			return fmt.Errorf("erased users are kept as pseudonyms and cannot be purged")
+		default:
+			return fmt.Errorf("failed to purge user: %w", err)
+		}
+	}
+
+	fmt.Printf("✅ User purged successfully (ID: %d)\n", userID)
+	return nil
+}
+
+func purgeExpiredUsers(yes bool) error {
+	if !yes && !promptConfirmation(fmt.Sprintf("Permanently remove all users deleted more than %s ago?", cfg.Security.DeletedUserGracePeriod)) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	userService := services.NewUserService(db.MySQL, cfg)
+	purged, err := userService.PurgeExpiredUsers(context.Background(), nil)
+	if len(purged) > 0 {
+		fmt.Printf("✅ Purged %d users\n", len(purged))
+	}
+	if err != nil {
+		return err
+	}
+	if len(purged) == 0 {
+		fmt.Println("No users past the grace period.")
+	}
+	return nil
+}
+
 func setUserRole(userID uint64, roleName string) error {
 	// Validate user exists
 	var user models.User
@@ -4,6 +4,7 @@ go 1.25
 
 require (
 	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/coder/websocket v1.8.14
 	github.com/go-sql-driver/mysql v1.9.3
 	github.com/go-testfixtures/testfixtures/v3 v3.18.0
 	github.com/gofiber/fiber/v2 v2.52.9
@@ -30,6 +30,8 @@ type Claims struct {
 	Email       string   `json:"email"`
 	Permissions []string `json:"permissions"`
 	TokenType   string   `json:"token_type"`
+	// OrganizationID is the active workspace; membership is verified on every request
+	OrganizationID uint `json:"org_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -55,18 +57,23 @@ func NewJWTManager(cfg *config.Config) *JWTManager {
 
 // GenerateTokenPair generates both access and refresh tokens
 func (j *JWTManager) GenerateTokenPair(userID uint, email string, permissions []string) (*TokenPair, error) {
+	return j.GenerateWorkspaceTokenPair(userID, email, permissions, 0)
+}
+
+// GenerateWorkspaceTokenPair generates both tokens with organizationID as the active workspace
+func (j *JWTManager) GenerateWorkspaceTokenPair(userID uint, email string, permissions []string, organizationID uint) (*TokenPair, error) {
 	now := time.Now()
 	accessExpiry := now.Add(j.cfg.JWT.AccessDuration)
 	refreshExpiry := now.Add(j.cfg.JWT.RefreshDuration)
 
 	// Generate access token
-	accessToken, err := j.generateToken(userID, email, permissions, string(AccessToken), accessExpiry, j.accessSecret)
+	accessToken, err := j.generateToken(userID, email, permissions, organizationID, string(AccessToken), accessExpiry, j.accessSecret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	refreshToken, err := j.generateToken(userID, email, permissions, string(RefreshToken), refreshExpiry, j.refreshSecret)
+	refreshToken, err := j.generateToken(userID, email, permissions, organizationID, string(RefreshToken), refreshExpiry, j.refreshSecret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -88,7 +95,7 @@ func (j *JWTManager) ValidateRefreshToken(tokenString string) (*Claims, error) {
 	return j.validateToken(tokenString, j.refreshSecret, string(RefreshToken))
 }
 
-func (j *JWTManager) generateToken(userID uint, email string, permissions []string, tokenType string, expiresAt time.Time, secret string) (string, error) {
+func (j *JWTManager) generateToken(userID uint, email string, permissions []string, organizationID uint, tokenType string, expiresAt time.Time, secret string) (string, error) {
 	// Generate unique JWT ID
 	jti, err := generateUniqueID()
 	if err != nil {
@@ -97,10 +104,11 @@ func (j *JWTManager) generateToken(userID uint, email string, permissions []stri
 
 	now := time.Now()
 	claims := &Claims{
-		UserID:      userID,
-		Email:       email,
-		Permissions: permissions,
-		TokenType:   tokenType,
+		UserID:         userID,
+		Email:          email,
+		Permissions:    permissions,
+		TokenType:      tokenType,
+		OrganizationID: organizationID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
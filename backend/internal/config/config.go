@@ -3,19 +3,27 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Logging  LoggingConfig
-	JWT      JWTConfig
-	Security SecurityConfig
-	Env      string
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	Logging    LoggingConfig
+	JWT        JWTConfig
+	Security   SecurityConfig
+	Audit      AuditConfig
+	Retention  RetentionConfig
+	Mail       MailConfig
+	Encryption EncryptionConfig
+	Exchange   ExchangeConfig
+	Market     MarketConfig
+	Balances   BalanceConfig
+	Env        string
 }
 
 type ServerConfig struct {
@@ -35,11 +43,11 @@ type DatabaseConfig struct {
 }
 
 type RedisConfig struct {
-	Host        string
-	Port        int
-	Password    string
-	DB          int
-	MaxRetries  int
+	Host       string
+	Port       int
+	Password   string
+	DB         int
+	MaxRetries int
 }
 
 type LoggingConfig struct {
@@ -48,19 +56,162 @@ type LoggingConfig struct {
 }
 
 type JWTConfig struct {
-	AccessSecret     string
-	RefreshSecret    string
-	AccessDuration   time.Duration
-	RefreshDuration  time.Duration
-	Issuer           string
+	AccessSecret    string
+	RefreshSecret   string
+	AccessDuration  time.Duration
+	RefreshDuration time.Duration
+	Issuer          string
 }
 
 type SecurityConfig struct {
-	BcryptCost           int
-	PasswordResetExpiry  time.Duration
-	MaxLoginAttempts     int
-	LockoutDuration      time.Duration
-	RequireEmailVerify   bool
+	BcryptCost          int
+	PasswordResetExpiry time.Duration
+	MaxLoginAttempts    int
+	LockoutDuration     time.Duration
+	RequireEmailVerify  bool
+	InviteExpiry        time.Duration
+	// DeletedUserGracePeriod is how long a soft-deleted user can be restored before it may be purged
+	DeletedUserGracePeriod time.Duration
+}
+
+type AuditConfig struct {
+	CheckpointSecret   string
+	CheckpointInterval int
+}
+
+type RetentionConfig struct {
+	Enabled             bool
+	Interval            time.Duration
+	BatchSize           int
+	AuditLogsMaxAge     time.Duration
+	PasswordResetMaxAge time.Duration
+	ArchiveDriver       string
+	ArchiveDir          string
+	ArchiveS3Endpoint   string
+	ArchiveS3Region     string
+	ArchiveS3Bucket     string
+	ArchiveS3Prefix     string
+	ArchiveS3AccessKey  string
+	ArchiveS3SecretKey  string
+}
+
+// BalanceConfig configures the snapshots of the account balances behind the API keys
+type BalanceConfig struct {
+	// SnapshotEnabled schedules the balance snapshots in the API server
+	SnapshotEnabled  bool
+	SnapshotInterval time.Duration
+	// ValuationCurrency is the currency balances are valued in, normally a USD stablecoin
+	ValuationCurrency string
+	// DropThreshold is the loss in value between snapshots, as a fraction,
+	// that is reported as an unexpected drop
+	DropThreshold float64
+}
+
+// MarketConfig configures the jobs that keep exchange and coin metadata current
+type MarketConfig struct {
+	// PairSyncEnabled schedules the trading pair sync in the API server
+	PairSyncEnabled  bool
+	PairSyncInterval time.Duration
+	// FeeSyncEnabled schedules the sync of the fee schedules and fee tiers
+	FeeSyncEnabled  bool
+	FeeSyncInterval time.Duration
+	// MarketCapSyncEnabled schedules the market cap ranking refresh
+	MarketCapSyncEnabled  bool
+	MarketCapSyncInterval time.Duration
+	// MarketCapProvider is "coingecko" or "file"; the file provider reads
+	// MarketCapFile, a saved CoinGecko /coins/markets response
+	MarketCapProvider string
+	MarketCapURL      string
+	MarketCapAPIKey   string
+	MarketCapFile     string
+	// TopCoins is the size of the universe the strategy trades
+	TopCoins int
+}
+
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// AppURL is the frontend base URL used to build links in emails
+	AppURL string
+}
+
+type EncryptionConfig struct {
+	// Provider selects where master keys live: env, file, keystore or vault
+	Provider string
+	// MasterKey wraps the data keys of stored exchange credentials, 32 characters or base64 of 32 bytes.
+	// It is key version 1 and only used when MasterKeys is empty.
+	MasterKey string
+	// MasterKeys is the versioned key ring as comma separated "version:key" pairs
+	MasterKeys string
+	// ActiveKeyVersion wraps new data keys; 0 selects the highest version.
+	// Vault always uses the latest version of its transit key.
+	ActiveKeyVersion int
+	// KeysFile holds the key ring of the file provider, e.g. a Docker secret
+	KeysFile string
+	// Keystore provider: a passphrase protected key file. The passphrase file
+	// takes precedence over the passphrase.
+	KeystorePath           string
+	KeystorePassphrase     string
+	KeystorePassphraseFile string
+	// Vault provider: a transit engine key. The token file takes precedence over the token.
+	VaultAddress   string
+	VaultToken     string
+	VaultTokenFile string
+	VaultNamespace string
+	VaultMount     string
+	VaultKeyName   string
+	VaultTimeout   time.Duration
+}
+
+type ExchangeConfig struct {
+	// RequestTimeout bounds every request to an exchange API
+	RequestTimeout time.Duration
+	// KeyErrorWindow is how far back errors count when choosing between API keys
+	KeyErrorWindow time.Duration
+	// KeyBreakerCooldown is how long an API key rejected by the exchange is skipped
+	KeyBreakerCooldown time.Duration
+	// HitBTCStreamURL is the base URL of the HitBTC WebSocket API
+	HitBTCStreamURL string
+	// BinanceStreamURL is the base URL of the Binance WebSocket streams
+	BinanceStreamURL string
+	// StreamHeartbeat is how often streams ping the exchange
+	StreamHeartbeat time.Duration
+	// StreamMaxReconnectDelay caps the backoff between stream reconnects
+	StreamMaxReconnectDelay time.Duration
+	// RetryAttempts is how often idempotent calls are tried in all, with a
+	// random delay up to RetryBaseDelay that doubles per attempt
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+	// BreakerThreshold consecutive failures stop calls to an exchange with a
+	// key for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RateLimitMaxWait is the longest a call waits for rate limit budget
+	RateLimitMaxWait time.Duration
+	// ClockSyncInterval is how often the offset to an exchange's clock is measured
+	ClockSyncInterval time.Duration
+	// PaperMarketExchange is the code of the exchange whose tickers paper orders fill against
+	PaperMarketExchange string
+	// PaperInitialBalances credits new paper accounts, e.g. "USDT:10000,BTC:0.5"
+	PaperInitialBalances string
+	// PaperMakerFee and PaperTakerFee are the paper exchange's fees as fractions
+	PaperMakerFee float64
+	PaperTakerFee float64
+	// PaperSlippage moves the price of paper takers against them, as a fraction
+	PaperSlippage float64
+	// PaperFillRatio is the share of a resting paper order that fills per quote
+	PaperFillRatio float64
+}
+
+// KeyRing returns the key ring specification, falling back to MasterKey as version 1
+func (c EncryptionConfig) KeyRing() string {
+	if c.MasterKeys != "" {
+		return c.MasterKeys
+	}
+	return "1:" + c.MasterKey
 }
 
 func Load() *Config {
@@ -98,11 +249,99 @@ func Load() *Config {
 			Issuer:          getEnv("JWT_ISSUER", "trading-bot"),
 		},
 		Security: SecurityConfig{
-			BcryptCost:          getEnvAsInt("BCRYPT_COST", 12),
-			PasswordResetExpiry: getEnvAsDuration("PASSWORD_RESET_EXPIRY", time.Hour),
-			MaxLoginAttempts:    getEnvAsInt("MAX_LOGIN_ATTEMPTS", 5),
-			LockoutDuration:     getEnvAsDuration("LOCKOUT_DURATION", 30*time.Minute),
-			RequireEmailVerify:  getEnvAsBool("REQUIRE_EMAIL_VERIFY", false),
+			BcryptCost:             getEnvAsInt("BCRYPT_COST", 12),
+			PasswordResetExpiry:    getEnvAsDuration("PASSWORD_RESET_EXPIRY", time.Hour),
+			MaxLoginAttempts:       getEnvAsInt("MAX_LOGIN_ATTEMPTS", 5),
+			LockoutDuration:        getEnvAsDuration("LOCKOUT_DURATION", 30*time.Minute),
+			RequireEmailVerify:     getEnvAsBool("REQUIRE_EMAIL_VERIFY", false),
+			InviteExpiry:           getEnvAsDuration("INVITE_EXPIRY", 72*time.Hour),
+			DeletedUserGracePeriod: getEnvAsDuration("DELETED_USER_GRACE_PERIOD", 30*24*time.Hour),
+		},
+		Audit: AuditConfig{
+			CheckpointSecret:   getEnv("AUDIT_CHECKPOINT_SECRET", "your-super-secret-audit-key-change-in-production"),
+			CheckpointInterval: getEnvAsInt("AUDIT_CHECKPOINT_INTERVAL", 1000),
+		},
+		Retention: RetentionConfig{
+			Enabled:             getEnvAsBool("RETENTION_ENABLED", false),
+			Interval:            getEnvAsDuration("RETENTION_INTERVAL", 24*time.Hour),
+			BatchSize:           getEnvAsInt("RETENTION_BATCH_SIZE", 1000),
+			AuditLogsMaxAge:     getEnvAsDuration("RETENTION_AUDIT_LOGS_MAX_AGE", 90*24*time.Hour),
+			PasswordResetMaxAge: getEnvAsDuration("RETENTION_PASSWORD_RESET_MAX_AGE", 30*24*time.Hour),
+			ArchiveDriver:       getEnv("RETENTION_ARCHIVE_DRIVER", "local"),
+			ArchiveDir:          getEnv("RETENTION_ARCHIVE_DIR", "archive"),
+			ArchiveS3Endpoint:   getEnv("RETENTION_ARCHIVE_S3_ENDPOINT", ""),
+			ArchiveS3Region:     getEnv("RETENTION_ARCHIVE_S3_REGION", "us-east-1"),
+			ArchiveS3Bucket:     getEnv("RETENTION_ARCHIVE_S3_BUCKET", ""),
+			ArchiveS3Prefix:     getEnv("RETENTION_ARCHIVE_S3_PREFIX", ""),
+			ArchiveS3AccessKey:  getEnv("RETENTION_ARCHIVE_S3_ACCESS_KEY", ""),
+			ArchiveS3SecretKey:  getEnv("RETENTION_ARCHIVE_S3_SECRET_KEY", ""),
+		},
+		Mail: MailConfig{
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     getEnvAsInt("SMTP_PORT", 1025),
+			Username: getEnv("SMTP_USER", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "noreply@trader.local"),
+			AppURL:   getEnv("APP_URL", "http://localhost:3000"),
+		},
+		Encryption: EncryptionConfig{
+			Provider:               getEnv("API_ENCRYPTION_PROVIDER", "env"),
+			MasterKey:              getEnv("API_ENCRYPTION_KEY", "dev-only-encryption-key-32-chars"),
+			MasterKeys:             getEnv("API_ENCRYPTION_KEYS", ""),
+			ActiveKeyVersion:       getEnvAsInt("API_ENCRYPTION_KEY_VERSION", 0),
+			KeysFile:               getEnv("API_ENCRYPTION_KEYS_FILE", "/run/secrets/api_encryption_keys"),
+			KeystorePath:           getEnv("API_KEYSTORE_PATH", "keystore.json"),
+			KeystorePassphrase:     getEnv("API_KEYSTORE_PASSPHRASE", ""),
+			KeystorePassphraseFile: getEnv("API_KEYSTORE_PASSPHRASE_FILE", ""),
+			VaultAddress:           getEnv("VAULT_ADDR", ""),
+			VaultToken:             getEnv("VAULT_TOKEN", ""),
+			VaultTokenFile:         getEnv("VAULT_TOKEN_FILE", ""),
+			VaultNamespace:         getEnv("VAULT_NAMESPACE", ""),
+			VaultMount:             getEnv("VAULT_TRANSIT_MOUNT", "transit"),
+			VaultKeyName:           getEnv("VAULT_TRANSIT_KEY", "trader-api-keys"),
+			VaultTimeout:           getEnvAsDuration("VAULT_TIMEOUT", 10*time.Second),
+		},
+		Exchange: ExchangeConfig{
+			RequestTimeout:          getEnvAsDuration("EXCHANGE_API_TIMEOUT", 30*time.Second),
+			KeyErrorWindow:          getEnvAsDuration("EXCHANGE_KEY_ERROR_WINDOW", 5*time.Minute),
+			KeyBreakerCooldown:      getEnvAsDuration("EXCHANGE_KEY_BREAKER_COOLDOWN", 5*time.Minute),
+			HitBTCStreamURL:         getEnv("HITBTC_WS_URL", "wss://api.hitbtc.com/api/3/ws"),
+			BinanceStreamURL:        getEnv("BINANCE_WS_URL", "wss://stream.binance.com:9443"),
+			StreamHeartbeat:         getEnvAsDuration("EXCHANGE_STREAM_HEARTBEAT", 15*time.Second),
+			StreamMaxReconnectDelay: getEnvAsDuration("EXCHANGE_STREAM_MAX_RECONNECT_DELAY", time.Minute),
+			RetryAttempts:           getEnvAsInt("EXCHANGE_RETRY_ATTEMPTS", 3),
+			RetryBaseDelay:          getEnvAsDuration("EXCHANGE_RETRY_BASE_DELAY", 250*time.Millisecond),
+			BreakerThreshold:        getEnvAsInt("EXCHANGE_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:         getEnvAsDuration("EXCHANGE_BREAKER_COOLDOWN", 30*time.Second),
+			RateLimitMaxWait:        getEnvAsDuration("EXCHANGE_RATE_LIMIT_MAX_WAIT", 10*time.Second),
+			ClockSyncInterval:       getEnvAsDuration("EXCHANGE_CLOCK_SYNC_INTERVAL", 10*time.Minute),
+			PaperMarketExchange:     getEnv("PAPER_MARKET_EXCHANGE", "binance"),
+			PaperInitialBalances:    getEnv("PAPER_INITIAL_BALANCES", "USDT:10000"),
+			PaperMakerFee:           getEnvAsFloat("PAPER_MAKER_FEE", 0.001),
+			PaperTakerFee:           getEnvAsFloat("PAPER_TAKER_FEE", 0.001),
+			PaperSlippage:           getEnvAsFloat("PAPER_SLIPPAGE", 0.0005),
+			PaperFillRatio:          getEnvAsFloat("PAPER_FILL_RATIO", 1),
+		},
+		Market: MarketConfig{
+			PairSyncEnabled:  getEnvAsBool("MARKET_PAIR_SYNC_ENABLED", true),
+			PairSyncInterval: getEnvAsDuration("MARKET_PAIR_SYNC_INTERVAL", 6*time.Hour),
+
+			FeeSyncEnabled:  getEnvAsBool("MARKET_FEE_SYNC_ENABLED", true),
+			FeeSyncInterval: getEnvAsDuration("MARKET_FEE_SYNC_INTERVAL", 24*time.Hour),
+
+			MarketCapSyncEnabled:  getEnvAsBool("MARKET_CAP_SYNC_ENABLED", true),
+			MarketCapSyncInterval: getEnvAsDuration("MARKET_CAP_SYNC_INTERVAL", time.Hour),
+			MarketCapProvider:     getEnv("MARKET_CAP_PROVIDER", "coingecko"),
+			MarketCapURL:          getEnv("MARKET_CAP_URL", "https://api.coingecko.com/api/v3"),
+			MarketCapAPIKey:       getEnv("MARKET_CAP_API_KEY", ""),
+			MarketCapFile:         getEnv("MARKET_CAP_FILE", ""),
+			TopCoins:              getEnvAsInt("MARKET_TOP_COINS", 100),
+		},
+		Balances: BalanceConfig{
+			SnapshotEnabled:   getEnvAsBool("BALANCE_SNAPSHOT_ENABLED", true),
+			SnapshotInterval:  getEnvAsDuration("BALANCE_SNAPSHOT_INTERVAL", 15*time.Minute),
+			ValuationCurrency: strings.ToUpper(getEnv("BALANCE_VALUATION_CURRENCY", "USDT")),
+			DropThreshold:     getEnvAsFloat("BALANCE_DROP_THRESHOLD", 0.05),
 		},
 		Env: getEnv("ENV", "development"),
 	}
@@ -134,6 +373,14 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := getEnv(key, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
@@ -158,6 +405,41 @@ func validateConfig(config *Config) {
 	if config.JWT.RefreshSecret == "your-super-secret-refresh-key-change-in-production" && config.Env == "production" {
 		log.Fatal().Msg("JWT refresh secret must be changed in production")
 	}
+	if config.Audit.CheckpointSecret == "your-super-secret-audit-key-change-in-production" && config.Env == "production" {
+		log.Fatal().Msg("Audit checkpoint secret must be changed in production")
+	}
+	switch config.Encryption.Provider {
+	case "env":
+		if config.Encryption.MasterKeys == "" && config.Encryption.MasterKey == "dev-only-encryption-key-32-chars" && config.Env == "production" {
+			log.Fatal().Msg("API encryption key must be changed in production")
+		}
+	case "file":
+	case "keystore":
+		if config.Encryption.KeystorePassphrase == "" && config.Encryption.KeystorePassphraseFile == "" {
+			log.Fatal().Msg("API keystore passphrase is required for the keystore provider")
+		}
+	case "vault":
+		if config.Encryption.VaultAddress == "" || (config.Encryption.VaultToken == "" && config.Encryption.VaultTokenFile == "") {
+			log.Fatal().Msg("Vault address and token are required for the vault provider")
+		}
+	default:
+		log.Fatal().Msg("API encryption provider must be env, file, keystore or vault")
+	}
+	if config.Audit.CheckpointInterval <= 0 {
+		log.Fatal().Msg("Audit checkpoint interval must be positive")
+	}
+	if config.Retention.BatchSize <= 0 {
+		log.Fatal().Msg("Retention batch size must be positive")
+	}
+	if config.Retention.ArchiveDriver != "local" && config.Retention.ArchiveDriver != "s3" {
+		log.Fatal().Msg("Retention archive driver must be local or s3")
+	}
+	if config.Retention.ArchiveDriver == "s3" && (config.Retention.ArchiveS3Endpoint == "" || config.Retention.ArchiveS3Bucket == "") {
+		log.Fatal().Msg("Retention S3 endpoint and bucket are required for the s3 archive driver")
+	}
+	if config.Balances.DropThreshold <= 0 || config.Balances.DropThreshold >= 1 {
+		log.Fatal().Msg("Balance drop threshold must be between 0 and 1")
+	}
 	if config.Security.BcryptCost < 10 || config.Security.BcryptCost > 15 {
 		log.Fatal().Msg("Bcrypt cost should be between 10 and 15")
 	}
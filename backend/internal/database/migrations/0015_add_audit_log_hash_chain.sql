@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_logs
+    ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '' AFTER user_agent,
+    ADD COLUMN hash CHAR(64) NOT NULL DEFAULT '' AFTER prev_hash,
+    ADD INDEX idx_audit_logs_hash (hash);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_chain_heads (
+    id BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+    last_log_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    last_hash CHAR(64) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- Single chain head row, locked by writers while appending
+INSERT INTO audit_chain_heads (id, last_log_id, last_hash) VALUES (1, 0, '');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    last_log_id BIGINT UNSIGNED NOT NULL,
+    hash CHAR(64) NOT NULL,
+    signature CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX idx_audit_checkpoints_last_log (last_log_id),
+    INDEX idx_audit_checkpoints_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_checkpoints;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_chain_heads;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE audit_logs
+    DROP INDEX idx_audit_logs_hash,
+    DROP COLUMN hash,
+    DROP COLUMN prev_hash;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_chain_heads
+    ADD COLUMN archived_log_id BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER last_hash,
+    ADD COLUMN archived_hash CHAR(64) NOT NULL DEFAULT '' AFTER archived_log_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE audit_chain_heads
+    DROP COLUMN archived_hash,
+    DROP COLUMN archived_log_id;
+-- +goose StatementEnd
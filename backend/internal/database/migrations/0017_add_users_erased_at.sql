@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Mark accounts whose personal data was erased; such accounts are kept only as pseudonyms
+ALTER TABLE users
+ADD COLUMN erased_at TIMESTAMP NULL AFTER locked_until;
+
+CREATE INDEX idx_users_erased_at ON users(erased_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_users_erased_at ON users;
+
+ALTER TABLE users
+DROP COLUMN erased_at;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only non-deleted users claim their email, so a soft-deleted account does not
+-- block re-registration. active_email is NULL for deleted rows and MySQL allows
+-- any number of NULLs in a unique index.
+ALTER TABLE users
+ADD COLUMN active_email VARCHAR(255) GENERATED ALWAYS AS (CASE WHEN deleted_at IS NULL THEN email END) VIRTUAL AFTER email,
+DROP INDEX email,
+ADD UNIQUE INDEX idx_users_active_email (active_email),
+ADD INDEX idx_users_email (email);
+
+-- Purging a user must not rewrite hash-chained audit entries through ON DELETE SET NULL
+ALTER TABLE audit_logs
+DROP FOREIGN KEY audit_logs_ibfk_1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Fails if a deleted and an active user share an email; purge or rename one first
+ALTER TABLE audit_logs
+ADD CONSTRAINT audit_logs_ibfk_1 FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+ALTER TABLE users
+DROP INDEX idx_users_active_email,
+DROP INDEX idx_users_email,
+DROP COLUMN active_email,
+ADD UNIQUE INDEX email (email);
+-- +goose StatementEnd
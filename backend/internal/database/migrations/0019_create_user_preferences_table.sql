@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One typed preference document per user. schema_version tracks the document
+-- layout, revision is incremented on every write for optimistic concurrency.
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+    schema_version INT NOT NULL DEFAULT 1,
+    revision INT NOT NULL DEFAULT 1,
+    data JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_preferences;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Exchange credentials of an organization. The key, secret and passphrase are
+-- sealed with a per-record data key, stored wrapped by the master key.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    organization_id BIGINT UNSIGNED NOT NULL,
+    exchange_id BIGINT UNSIGNED NOT NULL,
+    created_by BIGINT UNSIGNED NULL,
+    name VARCHAR(100) NOT NULL,
+    key_preview VARCHAR(32) NOT NULL,
+    encrypted_key VARBINARY(1024) NULL,
+    encrypted_secret BLOB NULL,
+    encrypted_passphrase VARBINARY(1024) NULL,
+    wrapped_data_key VARBINARY(128) NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (exchange_id) REFERENCES exchanges(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+
+    INDEX idx_api_keys_organization (organization_id, deleted_at),
+    INDEX idx_api_keys_exchange (exchange_id),
+    INDEX idx_api_keys_created_by (created_by),
+    INDEX idx_api_keys_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
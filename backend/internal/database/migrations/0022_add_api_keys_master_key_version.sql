@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Version of the master key that wraps the record's data key
+ALTER TABLE api_keys
+    ADD COLUMN master_key_version INT NOT NULL DEFAULT 1 AFTER wrapped_data_key,
+    ADD INDEX idx_api_keys_master_key_version (master_key_version);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys
+    DROP INDEX idx_api_keys_master_key_version,
+    DROP COLUMN master_key_version;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Result of the last connection test: detected permissions, status and latency
+ALTER TABLE api_keys
+    ADD COLUMN can_read BOOLEAN NOT NULL DEFAULT FALSE AFTER is_active,
+    ADD COLUMN can_trade BOOLEAN NOT NULL DEFAULT FALSE AFTER can_read,
+    ADD COLUMN can_withdraw BOOLEAN NOT NULL DEFAULT FALSE AFTER can_trade,
+    ADD COLUMN test_status VARCHAR(20) NOT NULL DEFAULT '' AFTER can_withdraw,
+    ADD COLUMN test_error VARCHAR(255) NOT NULL DEFAULT '' AFTER test_status,
+    ADD COLUMN test_latency_ms INT NULL AFTER test_error,
+    ADD COLUMN last_tested_at TIMESTAMP NULL AFTER test_latency_ms;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys
+    DROP COLUMN last_tested_at,
+    DROP COLUMN test_latency_ms,
+    DROP COLUMN test_error,
+    DROP COLUMN test_status,
+    DROP COLUMN can_withdraw,
+    DROP COLUMN can_trade,
+    DROP COLUMN can_read;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO exchanges (name, code, is_active, api_url, website_url) VALUES
+('Binance', 'binance', TRUE, 'https://api.binance.com', 'https://www.binance.com')
+ON DUPLICATE KEY UPDATE
+    name = VALUES(name),
+    is_active = VALUES(is_active),
+    api_url = VALUES(api_url),
+    website_url = VALUES(website_url);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM exchanges WHERE code = 'binance';
+-- +goose StatementEnd
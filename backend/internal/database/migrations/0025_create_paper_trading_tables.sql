@@ -0,0 +1,87 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounts of the simulated paper exchange, identified by a hash of their API key
+CREATE TABLE IF NOT EXISTS paper_accounts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    key_hash CHAR(64) NOT NULL,
+    secret_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+
+    UNIQUE KEY idx_paper_accounts_key_hash (key_hash),
+    INDEX idx_paper_accounts_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS paper_balances (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    account_id BIGINT UNSIGNED NOT NULL,
+    currency VARCHAR(20) NOT NULL,
+    available DECIMAL(32,12) NOT NULL DEFAULT 0,
+    reserved DECIMAL(32,12) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (account_id) REFERENCES paper_accounts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    UNIQUE KEY idx_paper_balances_currency (account_id, currency)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS paper_orders (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    account_id BIGINT UNSIGNED NOT NULL,
+    client_order_id VARCHAR(64) NOT NULL,
+    symbol VARCHAR(50) NOT NULL,
+    base VARCHAR(20) NOT NULL,
+    quote VARCHAR(20) NOT NULL,
+    side VARCHAR(10) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    time_in_force VARCHAR(10) NOT NULL DEFAULT '',
+    post_only BOOLEAN NOT NULL DEFAULT FALSE,
+    price DECIMAL(32,12) NOT NULL DEFAULT 0,
+    quantity DECIMAL(32,12) NOT NULL,
+    filled_quantity DECIMAL(32,12) NOT NULL DEFAULT 0,
+    filled_quote DECIMAL(32,12) NOT NULL DEFAULT 0,
+    fee DECIMAL(32,12) NOT NULL DEFAULT 0,
+    reserved DECIMAL(32,12) NOT NULL DEFAULT 0,
+    last_quote_at TIMESTAMP(3) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+
+    FOREIGN KEY (account_id) REFERENCES paper_accounts(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    UNIQUE KEY idx_paper_orders_client_order (account_id, client_order_id),
+    INDEX idx_paper_orders_status (status),
+    INDEX idx_paper_orders_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO exchanges (name, code, is_active, api_url, website_url) VALUES
+('Paper Trading', 'paper', TRUE, '', '')
+ON DUPLICATE KEY UPDATE
+    name = VALUES(name),
+    is_active = VALUES(is_active);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM exchanges WHERE code = 'paper';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS paper_orders;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS paper_balances;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS paper_accounts;
+-- +goose StatementEnd
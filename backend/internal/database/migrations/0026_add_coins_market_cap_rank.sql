@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Market cap ranking refreshed from the market cap provider. top_ranked marks
+-- the coins of the top-N universe; dropped_out_at is set when a coin leaves it.
+ALTER TABLE coins
+    ADD COLUMN market_cap_rank INT NOT NULL DEFAULT 0 AFTER market_cap,
+    ADD COLUMN top_ranked BOOLEAN NOT NULL DEFAULT FALSE AFTER market_cap_rank,
+    ADD COLUMN dropped_out_at TIMESTAMP NULL AFTER top_ranked,
+    ADD COLUMN market_cap_updated_at TIMESTAMP NULL AFTER dropped_out_at;
+
+CREATE INDEX idx_coins_top_ranked ON coins(top_ranked, sort_order);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- The seeded coins are all top-100 coins until the first refresh
+UPDATE coins SET top_ranked = TRUE
+WHERE symbol IN ('BTC', 'ETH', 'USDT', 'BNB', 'ADA', 'SOL', 'XRP', 'DOT', 'DOGE', 'AVAX');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_coins_top_ranked ON coins;
+
+ALTER TABLE coins
+    DROP COLUMN market_cap_updated_at,
+    DROP COLUMN dropped_out_at,
+    DROP COLUMN top_ranked,
+    DROP COLUMN market_cap_rank;
+-- +goose StatementEnd
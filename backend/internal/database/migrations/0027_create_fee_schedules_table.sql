@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Trading fees by exchange and fee tier. A row with an empty symbol applies to
+-- every symbol of the tier without its own row. fee_currency is base, quote,
+-- received (what the trade pays out) or native (the exchange's token, at
+-- native_discount off). Synced rows are refreshed from the exchanges; manual
+-- rows are never overwritten.
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    exchange_id BIGINT UNSIGNED NOT NULL,
+    tier VARCHAR(50) NOT NULL DEFAULT 'default',
+    symbol VARCHAR(50) NOT NULL DEFAULT '',
+    maker_rate DECIMAL(12,8) NOT NULL DEFAULT 0,
+    taker_rate DECIMAL(12,8) NOT NULL DEFAULT 0,
+    fee_currency VARCHAR(10) NOT NULL DEFAULT 'quote',
+    native_currency VARCHAR(20) NOT NULL DEFAULT '',
+    native_discount DECIMAL(6,4) NOT NULL DEFAULT 0,
+    source VARCHAR(10) NOT NULL DEFAULT 'manual',
+    synced_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (exchange_id) REFERENCES exchanges(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    UNIQUE KEY idx_fee_schedules_tier_symbol (exchange_id, tier, symbol)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- The fee tier of the account a key belongs to, synced from exchanges that report it
+ALTER TABLE api_keys
+    ADD COLUMN fee_tier VARCHAR(50) NOT NULL DEFAULT 'default' AFTER exchange_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Standard fees of the seeded exchanges until the first sync
+INSERT INTO fee_schedules (exchange_id, tier, symbol, maker_rate, taker_rate, fee_currency, native_currency, native_discount)
+SELECT id, 'default', '', 0.001, 0.001, 'received', 'BNB', 0.25 FROM exchanges WHERE code = 'binance'
+UNION ALL
+SELECT id, 'default', '', 0.0025, 0.0025, 'quote', '', 0 FROM exchanges WHERE code = 'hitbtc'
+ON DUPLICATE KEY UPDATE id = id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys DROP COLUMN fee_tier;
+
+DROP TABLE IF EXISTS fee_schedules;
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Balances of the account behind an API key. balances holds the non-zero
+-- currencies only. An unchanged account extends the last snapshot through
+-- last_seen_at instead of adding a row. value is in valuation_currency and
+-- NULL when no currency could be priced.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    api_key_id BIGINT UNSIGNED NOT NULL,
+    balances JSON NOT NULL,
+    value DECIMAL(32,8) NULL,
+    valuation_currency VARCHAR(20) NOT NULL,
+    taken_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    INDEX idx_balance_snapshots_key_taken (api_key_id, taken_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Losses in value between two snapshots that trades and price moves do not
+-- explain, such as withdrawals. changes lists the currencies that decreased.
+CREATE TABLE IF NOT EXISTS balance_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    api_key_id BIGINT UNSIGNED NOT NULL,
+    snapshot_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    valuation_currency VARCHAR(20) NOT NULL,
+    value_before DECIMAL(32,8) NOT NULL,
+    value_after DECIMAL(32,8) NOT NULL,
+    drop_percent DECIMAL(8,4) NOT NULL,
+    changes JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (snapshot_id) REFERENCES balance_snapshots(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    INDEX idx_balance_events_key_created (api_key_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS balance_events;
+
+DROP TABLE IF EXISTS balance_snapshots;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_chain_heads
+    ADD COLUMN archived_signature CHAR(64) NOT NULL DEFAULT '' AFTER archived_hash;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE audit_chain_heads
+    DROP COLUMN archived_signature;
+-- +goose StatementEnd
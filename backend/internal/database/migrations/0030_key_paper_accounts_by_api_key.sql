@@ -0,0 +1,36 @@
+-- +goose Up
+-- Paper accounts belong to a stored API key instead of the hash of its
+-- credentials, so workspaces entering the same key do not share an account.
+-- Accounts opened by hash cannot be matched to their key and start over.
+-- +goose StatementBegin
+DELETE FROM paper_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE paper_accounts
+    DROP INDEX idx_paper_accounts_key_hash,
+    DROP COLUMN key_hash,
+    DROP COLUMN secret_hash,
+    ADD COLUMN organization_id BIGINT UNSIGNED NOT NULL AFTER id,
+    ADD COLUMN api_key_id BIGINT UNSIGNED NOT NULL AFTER organization_id,
+    ADD UNIQUE KEY idx_paper_accounts_api_key (organization_id, api_key_id),
+    ADD CONSTRAINT fk_paper_accounts_organization FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    ADD CONSTRAINT fk_paper_accounts_api_key FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM paper_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE paper_accounts
+    DROP FOREIGN KEY fk_paper_accounts_api_key,
+    DROP FOREIGN KEY fk_paper_accounts_organization,
+    DROP INDEX idx_paper_accounts_api_key,
+    DROP COLUMN api_key_id,
+    DROP COLUMN organization_id,
+    ADD COLUMN key_hash CHAR(64) NOT NULL AFTER id,
+    ADD COLUMN secret_hash CHAR(64) NOT NULL AFTER key_hash,
+    ADD UNIQUE KEY idx_paper_accounts_key_hash (key_hash);
+-- +goose StatementEnd
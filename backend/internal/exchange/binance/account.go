@@ -0,0 +1,251 @@
+package binance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// orderStatuses maps Binance order statuses onto the normalized ones
+var orderStatuses = map[string]string{
+	"NEW":              exchange.OrderStatusNew,
+	"PARTIALLY_FILLED": exchange.OrderStatusPartiallyFilled,
+	"FILLED":           exchange.OrderStatusFilled,
+	"CANCELED":         exchange.OrderStatusCanceled,
+	"PENDING_CANCEL":   exchange.OrderStatusCanceled,
+	"REJECTED":         exchange.OrderStatusRejected,
+	"EXPIRED":          exchange.OrderStatusExpired,
+	"EXPIRED_IN_MATCH": exchange.OrderStatusExpired,
+}
+
+// orderTypes maps Binance order types onto the normalized ones; a post-only
+// limit order is a LIMIT_MAKER. Other types are passed on in lower case.
+var orderTypes = map[string]string{
+	"LIMIT":       exchange.OrderTypeLimit,
+	"LIMIT_MAKER": exchange.OrderTypeLimit,
+	"MARKET":      exchange.OrderTypeMarket,
+}
+
+type apiRestrictionsResponse struct {
+	EnableReading              bool `json:"enableReading"`
+	EnableSpotAndMarginTrading bool `json:"enableSpotAndMarginTrading"`
+	EnableWithdrawals          bool `json:"enableWithdrawals"`
+}
+
+type accountResponse struct {
+	CommissionRates struct {
+		Maker number `json:"maker"`
+		Taker number `json:"taker"`
+	} `json:"commissionRates"`
+	Balances []struct {
+		Asset  string `json:"asset"`
+		Free   number `json:"free"`
+		Locked number `json:"locked"`
+	} `json:"balances"`
+}
+
+type accountInfoResponse struct {
+	VIPLevel int `json:"vipLevel"`
+}
+
+type bnbBurnResponse struct {
+	SpotBNBBurn bool `json:"spotBNBBurn"`
+}
+
+type orderResponse struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	// OrigClientOrderID is set by cancelling, where ClientOrderID is the cancel's own id
+	OrigClientOrderID   string `json:"origClientOrderId"`
+	Price               number `json:"price"`
+	OrigQty             number `json:"origQty"`
+	ExecutedQty         number `json:"executedQty"`
+	CummulativeQuoteQty number `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	TimeInForce         string `json:"timeInForce"`
+	Type                string `json:"type"`
+	Side                string `json:"side"`
+	// Time is set by order queries, TransactTime by placing and cancelling
+	Time         int64 `json:"time"`
+	UpdateTime   int64 `json:"updateTime"`
+	TransactTime int64 `json:"transactTime"`
+}
+
+// Capabilities implements exchange.Connector. Binance reports the rights of a
+// key directly.
+func (c *Client) Capabilities(ctx context.Context) (*exchange.Capabilities, error) {
+	var response apiRestrictionsResponse
+	if err := c.do(ctx, http.MethodGet, "/sapi/v1/account/apiRestrictions", nil, signed, &response); err != nil {
+		return nil, err
+	}
+	return &exchange.Capabilities{
+		Read:     response.EnableReading,
+		Trade:    response.EnableSpotAndMarginTrading,
+		Withdraw: response.EnableWithdrawals,
+	}, nil
+}
+
+// Balances implements exchange.Connector. Assets without a balance are left out.
+func (c *Client) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	var response accountResponse
+	params := url.Values{"omitZeroBalances": {"true"}}
+	if err := c.do(ctx, http.MethodGet, "/api/v3/account", params, signed, &response); err != nil {
+		return nil, err
+	}
+
+	balances := make([]exchange.Balance, 0, len(response.Balances))
+	for _, balance := range response.Balances {
+		if balance.Free == 0 && balance.Locked == 0 {
+			continue
+		}
+		balances = append(balances, exchange.Balance{
+			Currency:  balance.Asset,
+			Available: float64(balance.Free),
+			Reserved:  float64(balance.Locked),
+		})
+	}
+	return balances, nil
+}
+
+// Fees implements exchange.FeeReporter. The tier is the account's VIP level.
+// Binance charges fees in the currency a trade pays out unless the account
+// pays them in BNB, at a discount.
+func (c *Client) Fees(ctx context.Context) (*exchange.AccountFees, error) {
+	var account accountResponse
+	params := url.Values{"omitZeroBalances": {"true"}}
+	if err := c.do(ctx, http.MethodGet, "/api/v3/account", params, signed, &account); err != nil {
+		return nil, err
+	}
+	var info accountInfoResponse
+	if err := c.do(ctx, http.MethodGet, "/sapi/v1/account/info", nil, signed, &info); err != nil {
+		return nil, err
+	}
+	var burn bnbBurnResponse
+	if err := c.do(ctx, http.MethodGet, "/sapi/v1/bnbBurn", nil, signed, &burn); err != nil {
+		return nil, err
+	}
+
+	fees := &exchange.AccountFees{
+		Tier:  fmt.Sprintf("vip%d", info.VIPLevel),
+		Maker: float64(account.CommissionRates.Maker),
+		Taker: float64(account.CommissionRates.Taker),
+	}
+	if burn.SpotBNBBurn {
+		fees.FeeCurrency = NativeCurrency
+	}
+	return fees, nil
+}
+
+// PlaceOrder implements exchange.Connector. Post-only orders are placed as
+// LIMIT_MAKER, which Binance rejects if they would fill immediately.
+func (c *Client) PlaceOrder(ctx context.Context, req *exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	clientOrderID := req.ClientOrderID
+	if clientOrderID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, fmt.Errorf("failed to generate client order id: %w", err)
+		}
+		clientOrderID = hex.EncodeToString(id)
+	}
+
+	params := url.Values{
+		"symbol":           {req.Symbol},
+		"side":             {strings.ToUpper(req.Side)},
+		"quantity":         {formatNumber(req.Quantity)},
+		"newClientOrderId": {clientOrderID},
+		"newOrderRespType": {"RESULT"},
+	}
+	switch {
+	case req.Type == exchange.OrderTypeMarket:
+		params.Set("type", "MARKET")
+	case req.PostOnly:
+		params.Set("type", "LIMIT_MAKER")
+		params.Set("price", formatNumber(req.Price))
+	default:
+		timeInForce := req.TimeInForce
+		if timeInForce == "" {
+			timeInForce = exchange.TimeInForceGTC
+		}
+		params.Set("type", "LIMIT")
+		params.Set("price", formatNumber(req.Price))
+		params.Set("timeInForce", timeInForce)
+	}
+
+	var response orderResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v3/order", params, signed, &response); err != nil {
+		return nil, err
+	}
+	return response.order()
+}
+
+// CancelOrder implements exchange.Connector
+func (c *Client) CancelOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	params := url.Values{"symbol": {symbol}, "origClientOrderId": {clientOrderID}}
+	var response orderResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/v3/order", params, signed, &response); err != nil {
+		return nil, err
+	}
+	return response.order()
+}
+
+// GetOrder implements exchange.Connector
+func (c *Client) GetOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	params := url.Values{"symbol": {symbol}, "origClientOrderId": {clientOrderID}}
+	var response orderResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/order", params, signed, &response); err != nil {
+		return nil, err
+	}
+	return response.order()
+}
+
+func (o orderResponse) order() (*exchange.Order, error) {
+	status, ok := orderStatuses[o.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: binance status %q of order %s", exchange.ErrUnknownOrderStatus, o.Status, o.ClientOrderID)
+	}
+	orderType, ok := orderTypes[o.Type]
+	if !ok {
+		orderType = strings.ToLower(o.Type)
+	}
+
+	clientOrderID := o.ClientOrderID
+	if o.OrigClientOrderID != "" {
+		clientOrderID = o.OrigClientOrderID
+	}
+	created, updated := o.Time, o.UpdateTime
+	if created == 0 {
+		created = o.TransactTime
+	}
+	if updated == 0 {
+		updated = o.TransactTime
+	}
+	order := &exchange.Order{
+		ID:             fmt.Sprint(o.OrderID),
+		ClientOrderID:  clientOrderID,
+		Symbol:         o.Symbol,
+		Side:           strings.ToLower(o.Side),
+		Type:           orderType,
+		Status:         status,
+		TimeInForce:    o.TimeInForce,
+		Price:          float64(o.Price),
+		Quantity:       float64(o.OrigQty),
+		FilledQuantity: float64(o.ExecutedQty),
+		CreatedAt:      time.UnixMilli(created).UTC(),
+		UpdatedAt:      time.UnixMilli(updated).UTC(),
+	}
+	if o.ExecutedQty > 0 {
+		order.AveragePrice = float64(o.CummulativeQuoteQty) / float64(o.ExecutedQty)
+	}
+	return order, nil
+}
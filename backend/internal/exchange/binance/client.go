@@ -0,0 +1,301 @@
+// Package binance implements the exchange connector for the Binance spot API.
+package binance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// Code is the models.Exchange code of Binance
+const Code = "binance"
+
+// NativeCurrency is the exchange's token, which fees can be paid in
+const NativeCurrency = "BNB"
+
+// DefaultBaseURL is used when the exchange row has no API URL
+const DefaultBaseURL = "https://api.binance.com"
+
+// DefaultStreamURL is the base URL of the WebSocket streams
+const DefaultStreamURL = "wss://stream.binance.com:9443"
+
+// defaultWeightLimit is the request weight per minute until exchangeInfo reports the limit
+const defaultWeightLimit = 6000
+
+// recvWindow is how long, in milliseconds, a signed request stays valid
+const recvWindow = "5000"
+
+// Binance error codes, see https://developers.binance.com/docs/binance-spot-api-docs/errors
+const (
+	errorCodeUnauthorized     = -1002
+	errorCodeTooManyRequests  = -1003
+	errorCodeTooManyOrders    = -1015
+	errorCodeTimestamp        = -1021
+	errorCodeInvalidSignature = -1022
+	errorCodeFilterFailure    = -1013
+	errorCodeBadPrecision     = -1111
+	errorCodeInvalidSymbol    = -1121
+	errorCodeNewOrderRejected = -2010
+	errorCodeCancelRejected   = -2011
+	errorCodeNoSuchOrder      = -2013
+	errorCodeBadAPIKeyFormat  = -2014
+	errorCodeRejectedAPIKey   = -2015
+)
+
+// errorCodes maps Binance error codes onto the errors of the exchange package
+var errorCodes = map[int]error{
+	errorCodeUnauthorized:     exchange.ErrAuthFailed,
+	errorCodeInvalidSignature: exchange.ErrAuthFailed,
+	errorCodeBadAPIKeyFormat:  exchange.ErrAuthFailed,
+	errorCodeRejectedAPIKey:   exchange.ErrAuthFailed,
+	errorCodeTooManyRequests:  exchange.ErrRateLimited,
+	errorCodeTooManyOrders:    exchange.ErrRateLimited,
+	errorCodeTimestamp:        exchange.ErrClockSkew,
+	errorCodeInvalidSymbol:    exchange.ErrInvalidSymbol,
+	errorCodeNoSuchOrder:      exchange.ErrOrderNotFound,
+	errorCodeFilterFailure:    exchange.ErrInvalidOrder,
+	errorCodeBadPrecision:     exchange.ErrInvalidOrder,
+	errorCodeNewOrderRejected: exchange.ErrInvalidOrder,
+}
+
+// rejectionMessages refine the errors of codes Binance uses for several
+// reasons, such as -2010 for every rejected order
+var rejectionMessages = map[int]map[string]error{
+	errorCodeNewOrderRejected: {
+		"Account has insufficient balance for requested action.": exchange.ErrInsufficientBalance,
+		"Order would trigger immediately.":                       exchange.ErrWouldTriggerImmediately,
+		"Order would immediately match and take.":                exchange.ErrWouldTriggerImmediately,
+		"Invalid symbol.": exchange.ErrInvalidSymbol,
+	},
+	errorCodeCancelRejected: {
+		"Unknown order sent.": exchange.ErrOrderNotFound,
+	},
+}
+
+// security is how a request is authenticated
+type security int
+
+const (
+	public security = iota
+	// apiKey requests carry the key but no signature
+	apiKey
+	signed
+)
+
+// Client is a Binance spot REST client. Signed requests carry an HMAC-SHA256
+// signature of the query string.
+type Client struct {
+	baseURL     string
+	credentials *exchange.Credentials
+	http        *http.Client
+	now         func() time.Time
+
+	streamURL         string
+	timeout           time.Duration
+	heartbeat         time.Duration
+	maxReconnectDelay time.Duration
+
+	mu    sync.Mutex
+	usage exchange.RateLimitUsage
+}
+
+func New(cfg exchange.Config) *Client {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	streamURL := strings.TrimRight(cfg.StreamURL, "/")
+	if streamURL == "" {
+		streamURL = DefaultStreamURL
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	heartbeat := cfg.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	maxReconnectDelay := cfg.MaxReconnectDelay
+	if maxReconnectDelay <= 0 {
+		maxReconnectDelay = time.Minute
+	}
+
+	return &Client{
+		baseURL:           baseURL,
+		credentials:       cfg.Credentials,
+		http:              cfg.Client(),
+		now:               cfg.Now(),
+		streamURL:         streamURL,
+		timeout:           timeout,
+		heartbeat:         heartbeat,
+		maxReconnectDelay: maxReconnectDelay,
+		usage:             exchange.RateLimitUsage{Limit: defaultWeightLimit},
+	}
+}
+
+// APIError is an error response of the Binance API
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("binance: %d %s", e.Code, e.Message)
+}
+
+// Unwrap maps Binance error codes onto the errors of the exchange package
+func (e *APIError) Unwrap() error {
+	if err, ok := rejectionMessages[e.Code][e.Message]; ok {
+		return err
+	}
+	return errorCodes[e.Code]
+}
+
+// HTTPStatus is the status code of the response
+func (e *APIError) HTTPStatus() int {
+	return e.Status
+}
+
+// RateLimitUsage implements exchange.RateLimited. Binance counts request
+// weight per IP and calendar minute.
+func (c *Client) RateLimitUsage() exchange.RateLimitUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	usage := c.usage
+	if !usage.ResetAt.IsZero() && !c.now().Before(usage.ResetAt) {
+		usage.Used = 0
+	}
+	return usage
+}
+
+// recordUsage reads the used weight from the response headers. A 429 or 418
+// response blocks the budget until Retry-After.
+func (c *Client) recordUsage(resp *http.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if used, err := strconv.Atoi(resp.Header.Get("X-Mbx-Used-Weight-1m")); err == nil {
+		c.usage.Used = used
+		c.usage.ResetAt = now.Truncate(time.Minute).Add(time.Minute)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		c.usage.Used = c.usage.Limit
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			c.usage.ResetAt = now.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+}
+
+func (c *Client) setWeightLimit(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage.Limit = limit
+}
+
+// do sends a request to path and decodes the JSON response into out. All
+// parameters go in the query string, which is what signed requests sign.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, auth security, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	if auth != public && c.credentials == nil {
+		return fmt.Errorf("%w: credentials are required", exchange.ErrAuthFailed)
+	}
+	query := params.Encode()
+	if auth == signed {
+		params.Set("timestamp", strconv.FormatInt(c.now().UnixMilli(), 10))
+		params.Set("recvWindow", recvWindow)
+		query = params.Encode()
+		mac := hmac.New(sha256.New, []byte(c.credentials.APISecret))
+		mac.Write([]byte(query))
+		query += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	target := c.baseURL + path
+	if query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create binance request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if auth != public {
+		req.Header.Set("X-MBX-APIKEY", c.credentials.APIKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("binance request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	c.recordUsage(resp)
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read binance response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{}
+		if err := json.Unmarshal(content, apiErr); err != nil || apiErr.Code == 0 {
+			apiErr = &APIError{Message: http.StatusText(resp.StatusCode)}
+		}
+		apiErr.Status = resp.StatusCode
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("failed to decode binance response: %w", err)
+	}
+	return nil
+}
+
+// number decodes the decimal strings Binance uses for prices and quantities
+type number float64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*n = 0
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid binance number %s: %w", data, err)
+	}
+	*n = number(parsed)
+	return nil
+}
+
+// decimals returns the number of decimal places of a step such as "0.00010000"
+func decimals(step string) int {
+	_, fraction, ok := strings.Cut(step, ".")
+	if !ok {
+		return 0
+	}
+	return len(strings.TrimRight(fraction, "0"))
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,281 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// symbolStatusTrading is the status of symbols open for trading
+const symbolStatusTrading = "TRADING"
+
+// intervals lists the candle intervals Binance knows under the same names
+var intervals = map[exchange.Interval]bool{
+	exchange.Interval1m:  true,
+	exchange.Interval5m:  true,
+	exchange.Interval15m: true,
+	exchange.Interval30m: true,
+	exchange.Interval1h:  true,
+	exchange.Interval4h:  true,
+	exchange.Interval1d:  true,
+	exchange.Interval1w:  true,
+}
+
+type exchangeInfoResponse struct {
+	RateLimits []struct {
+		RateLimitType string `json:"rateLimitType"`
+		Interval      string `json:"interval"`
+		IntervalNum   int    `json:"intervalNum"`
+		Limit         int    `json:"limit"`
+	} `json:"rateLimits"`
+	Symbols []symbolResponse `json:"symbols"`
+}
+
+type symbolResponse struct {
+	Symbol               string         `json:"symbol"`
+	Status               string         `json:"status"`
+	BaseAsset            string         `json:"baseAsset"`
+	QuoteAsset           string         `json:"quoteAsset"`
+	IsSpotTradingAllowed bool           `json:"isSpotTradingAllowed"`
+	Filters              []symbolFilter `json:"filters"`
+}
+
+// symbolFilter holds the fields of the filters the connector reads. Binance
+// replaced MIN_NOTIONAL with NOTIONAL; both are understood.
+type symbolFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	StepSize    string `json:"stepSize"`
+	MinNotional string `json:"minNotional"`
+}
+
+type tickerResponse struct {
+	Symbol      string `json:"symbol"`
+	BidPrice    number `json:"bidPrice"`
+	AskPrice    number `json:"askPrice"`
+	LastPrice   number `json:"lastPrice"`
+	OpenPrice   number `json:"openPrice"`
+	HighPrice   number `json:"highPrice"`
+	LowPrice    number `json:"lowPrice"`
+	Volume      number `json:"volume"`
+	QuoteVolume number `json:"quoteVolume"`
+	CloseTime   int64  `json:"closeTime"`
+}
+
+type depthResponse struct {
+	Bids [][2]number `json:"bids"`
+	Asks [][2]number `json:"asks"`
+}
+
+// ServerTime implements exchange.ServerTimer
+func (c *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	var response struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v3/time", nil, public, &response); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(response.ServerTime), nil
+}
+
+// Symbols implements exchange.Connector. Binance reports fees per account, so
+// MakerFee and TakerFee are left at zero. The exchange's request weight limit
+// is picked up on the way.
+func (c *Client) Symbols(ctx context.Context) ([]exchange.Symbol, error) {
+	var response exchangeInfoResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/exchangeInfo", nil, public, &response); err != nil {
+		return nil, err
+	}
+	for _, limit := range response.RateLimits {
+		if limit.RateLimitType == "REQUEST_WEIGHT" && limit.Interval == "MINUTE" && limit.IntervalNum == 1 {
+			c.setWeightLimit(limit.Limit)
+		}
+	}
+
+	symbols := make([]exchange.Symbol, 0, len(response.Symbols))
+	for _, symbol := range response.Symbols {
+		parsed, err := symbol.symbol()
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, parsed)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Symbol < symbols[j].Symbol })
+	return symbols, nil
+}
+
+// symbol reads the trading rules from the PRICE_FILTER, LOT_SIZE and
+// (MIN_)NOTIONAL filters
+func (s symbolResponse) symbol() (exchange.Symbol, error) {
+	symbol := exchange.Symbol{
+		Symbol: s.Symbol,
+		Base:   s.BaseAsset,
+		Quote:  s.QuoteAsset,
+		Active: s.Status == symbolStatusTrading && s.IsSpotTradingAllowed,
+	}
+
+	parse := func(filter, field, value string) (float64, error) {
+		if value == "" {
+			return 0, nil
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s.%s of binance symbol %s: %w", filter, field, s.Symbol, err)
+		}
+		return parsed, nil
+	}
+	var err error
+	for _, filter := range s.Filters {
+		switch filter.FilterType {
+		case "PRICE_FILTER":
+			if symbol.PriceTick, err = parse(filter.FilterType, "tickSize", filter.TickSize); err != nil {
+				return symbol, err
+			}
+			symbol.PricePrecision = decimals(filter.TickSize)
+		case "LOT_SIZE":
+			if symbol.QuantityStep, err = parse(filter.FilterType, "stepSize", filter.StepSize); err != nil {
+				return symbol, err
+			}
+			if symbol.MinQuantity, err = parse(filter.FilterType, "minQty", filter.MinQty); err != nil {
+				return symbol, err
+			}
+			if symbol.MaxQuantity, err = parse(filter.FilterType, "maxQty", filter.MaxQty); err != nil {
+				return symbol, err
+			}
+			symbol.QuantityPrecision = decimals(filter.StepSize)
+		case "MIN_NOTIONAL", "NOTIONAL":
+			if symbol.MinNotional, err = parse(filter.FilterType, "minNotional", filter.MinNotional); err != nil {
+				return symbol, err
+			}
+		}
+	}
+	return symbol, nil
+}
+
+// Ticker implements exchange.Connector
+func (c *Client) Ticker(ctx context.Context, symbol string) (*exchange.Ticker, error) {
+	var response tickerResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/ticker/24hr", url.Values{"symbol": {symbol}}, public, &response); err != nil {
+		return nil, err
+	}
+	ticker := response.ticker()
+	return &ticker, nil
+}
+
+// Tickers implements exchange.Connector
+func (c *Client) Tickers(ctx context.Context) ([]exchange.Ticker, error) {
+	var response []tickerResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/ticker/24hr", nil, public, &response); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]exchange.Ticker, 0, len(response))
+	for _, ticker := range response {
+		tickers = append(tickers, ticker.ticker())
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	return tickers, nil
+}
+
+func (t tickerResponse) ticker() exchange.Ticker {
+	return exchange.Ticker{
+		Symbol:      t.Symbol,
+		Bid:         float64(t.BidPrice),
+		Ask:         float64(t.AskPrice),
+		Last:        float64(t.LastPrice),
+		Open:        float64(t.OpenPrice),
+		High:        float64(t.HighPrice),
+		Low:         float64(t.LowPrice),
+		Volume:      float64(t.Volume),
+		QuoteVolume: float64(t.QuoteVolume),
+		Timestamp:   time.UnixMilli(t.CloseTime).UTC(),
+	}
+}
+
+// OrderBook implements exchange.Connector. Depth 0 uses the Binance default of 100.
+func (c *Client) OrderBook(ctx context.Context, symbol string, depth int) (*exchange.OrderBook, error) {
+	params := url.Values{"symbol": {symbol}}
+	if depth > 0 {
+		params.Set("limit", strconv.Itoa(depth))
+	}
+	var response depthResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v3/depth", params, public, &response); err != nil {
+		return nil, err
+	}
+
+	return &exchange.OrderBook{
+		Symbol:    symbol,
+		Bids:      priceLevels(response.Bids),
+		Asks:      priceLevels(response.Asks),
+		Timestamp: c.now().UTC(),
+	}, nil
+}
+
+func priceLevels(levels [][2]number) []exchange.PriceLevel {
+	result := make([]exchange.PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, exchange.PriceLevel{Price: float64(level[0]), Quantity: float64(level[1])})
+	}
+	return result
+}
+
+// Candles implements exchange.Connector. Candles are returned oldest first.
+func (c *Client) Candles(ctx context.Context, symbol string, query exchange.CandleQuery) ([]exchange.Candle, error) {
+	if !intervals[query.Interval] {
+		return nil, fmt.Errorf("unsupported binance candle interval: %s", query.Interval)
+	}
+
+	params := url.Values{"symbol": {symbol}, "interval": {string(query.Interval)}}
+	if !query.From.IsZero() {
+		params.Set("startTime", strconv.FormatInt(query.From.UnixMilli(), 10))
+	}
+	if !query.Till.IsZero() {
+		params.Set("endTime", strconv.FormatInt(query.Till.UnixMilli(), 10))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	// Each kline is [openTime, open, high, low, close, volume, closeTime, quoteVolume, ...]
+	var response [][]json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/v3/klines", params, public, &response); err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchange.Candle, 0, len(response))
+	for _, kline := range response {
+		if len(kline) < 8 {
+			return nil, fmt.Errorf("invalid binance kline of %s: %d fields", symbol, len(kline))
+		}
+		var openTime int64
+		var open, high, low, closing, volume, quoteVolume number
+		fields := []interface{}{&openTime, &open, &high, &low, &closing, &volume, nil, &quoteVolume}
+		for i, field := range fields {
+			if field == nil {
+				continue
+			}
+			if err := json.Unmarshal(kline[i], field); err != nil {
+				return nil, fmt.Errorf("invalid binance kline of %s: %w", symbol, err)
+			}
+		}
+		candles = append(candles, exchange.Candle{
+			OpenTime:    time.UnixMilli(openTime).UTC(),
+			Open:        float64(open),
+			High:        float64(high),
+			Low:         float64(low),
+			Close:       float64(closing),
+			Volume:      float64(volume),
+			QuoteVolume: float64(quoteVolume),
+		})
+	}
+	return candles, nil
+}
@@ -0,0 +1,231 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trader/internal/exchange"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// listenKeyKeepAlive is how often the listen key is extended; Binance expires
+// it after an hour without a keepalive
+const listenKeyKeepAlive = 30 * time.Minute
+
+// streamBuffer is the capacity of the event channel
+const streamBuffer = 256
+
+// userDataChannel names the user data stream in StreamStatus events
+const userDataChannel = "user_data"
+
+var errListenKeyExpired = errors.New("binance listen key expired")
+
+// executionReport is an order update of the user data stream. encoding/json
+// matches keys case insensitively, so every key whose other case is also sent
+// ("c" and "C", "x" and "X", ...) has a field, even if unused.
+type executionReport struct {
+	EventType               string          `json:"e"`
+	EventTime               int64           `json:"E"`
+	Symbol                  string          `json:"s"`
+	Side                    string          `json:"S"`
+	ClientOrderID           string          `json:"c"`
+	OrigClientOrderID       string          `json:"C"`
+	Type                    string          `json:"o"`
+	CreatedAt               int64           `json:"O"`
+	TimeInForce             string          `json:"f"`
+	IcebergQuantity         json.RawMessage `json:"F"`
+	Quantity                number          `json:"q"`
+	QuoteQuantity           json.RawMessage `json:"Q"`
+	Price                   number          `json:"p"`
+	StopPrice               json.RawMessage `json:"P"`
+	ExecutionType           string          `json:"x"`
+	Status                  string          `json:"X"`
+	OrderID                 int64           `json:"i"`
+	Ignore                  json.RawMessage `json:"I"`
+	LastQuantity            number          `json:"l"`
+	LastPrice               number          `json:"L"`
+	CumulativeQuantity      number          `json:"z"`
+	CumulativeQuoteQuantity number          `json:"Z"`
+	Commission              number          `json:"n"`
+	CommissionAsset         json.RawMessage `json:"N"`
+	TradeID                 int64           `json:"t"`
+	TransactionTime         int64           `json:"T"`
+	Maker                   bool            `json:"m"`
+	IgnoreM                 json.RawMessage `json:"M"`
+	Working                 json.RawMessage `json:"w"`
+	WorkingTime             json.RawMessage `json:"W"`
+	PreventedMatchID        json.RawMessage `json:"v"`
+	SelfTradePrevention     json.RawMessage `json:"V"`
+}
+
+// Stream implements exchange.Streamer for the account's order reports, which
+// Binance sends on a user data stream opened with a listen key. Market data
+// streams are not implemented yet. The listen key is kept alive while the
+// stream runs; when it expires or the connection drops, a new key is created
+// and the connection re-established with backoff.
+func (c *Client) Stream(ctx context.Context, sub exchange.Subscription) (<-chan exchange.StreamEvent, error) {
+	if len(sub.Tickers) > 0 || len(sub.Trades) > 0 || len(sub.OrderBooks) > 0 {
+		return nil, fmt.Errorf("binance streams only deliver order reports")
+	}
+	if sub.Orders && c.credentials == nil {
+		return nil, fmt.Errorf("%w: credentials are required for order reports", exchange.ErrAuthFailed)
+	}
+
+	events := make(chan exchange.StreamEvent, streamBuffer)
+	go func() {
+		defer close(events)
+		if !sub.Orders {
+			return
+		}
+		exchange.KeepConnected(ctx, userDataChannel, c.maxReconnectDelay, events, func(ctx context.Context) (bool, error) {
+			return c.connectUserData(ctx, events)
+		})
+	}()
+	return events, nil
+}
+
+// connectUserData runs one connection of the user data stream. It reports
+// whether the connection was established.
+func (c *Client) connectUserData(ctx context.Context, events chan<- exchange.StreamEvent) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var key struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v3/userDataStream", nil, apiKey, &key); err != nil {
+		return false, err
+	}
+	params := func() url.Values { return url.Values{"listenKey": {key.ListenKey}} }
+	defer func() {
+		// Close the key even when ctx is done; the stream is gone either way
+		closeCtx, cancelClose := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancelClose()
+		_ = c.do(closeCtx, http.MethodDelete, "/api/v3/userDataStream", params(), apiKey, nil)
+	}()
+
+	dialCtx, cancelDial := context.WithTimeout(ctx, c.timeout)
+	ws, _, err := websocket.Dial(dialCtx, c.streamURL+"/ws/"+url.PathEscape(key.ListenKey), nil)
+	cancelDial()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to binance user data stream: %w", err)
+	}
+	defer ws.CloseNow()
+
+	publish := func(event exchange.StreamEvent) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+	publish(exchange.StreamStatus{Channel: userDataChannel, State: exchange.StreamConnected})
+	heartbeat := exchange.Heartbeat(ctx, c.heartbeat, ws.Ping, cancel)
+
+	keepAliveFailed := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(listenKeyKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := c.do(ctx, http.MethodPut, "/api/v3/userDataStream", params(), apiKey, nil); err != nil && ctx.Err() == nil {
+				keepAliveFailed <- fmt.Errorf("failed to keep the binance listen key alive: %w", err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for {
+		var message json.RawMessage
+		if err := wsjson.Read(ctx, ws, &message); err != nil {
+			select {
+			case err = <-heartbeat:
+			case err = <-keepAliveFailed:
+			default:
+			}
+			return true, fmt.Errorf("binance user data stream closed: %w", err)
+		}
+
+		// "E" is declared so it does not land in "e", see executionReport
+		var event struct {
+			Type string `json:"e"`
+			Time int64  `json:"E"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			return true, fmt.Errorf("failed to decode binance user data event: %w", err)
+		}
+		switch event.Type {
+		case "executionReport":
+			var report executionReport
+			if err := json.Unmarshal(message, &report); err != nil {
+				return true, fmt.Errorf("failed to decode binance execution report: %w", err)
+			}
+			orderReport, err := report.orderReport()
+			if err != nil {
+				return true, err
+			}
+			publish(orderReport)
+		case "listenKeyExpired":
+			return true, errListenKeyExpired
+		}
+	}
+}
+
+func (r *executionReport) orderReport() (exchange.OrderReport, error) {
+	status, ok := orderStatuses[r.Status]
+	if !ok {
+		return exchange.OrderReport{}, fmt.Errorf("%w: binance status %q of order %s", exchange.ErrUnknownOrderStatus, r.Status, r.ClientOrderID)
+	}
+	orderType, ok := orderTypes[r.Type]
+	if !ok {
+		orderType = strings.ToLower(r.Type)
+	}
+	// Cancellations carry the id of the cancel request in "c" and the order's in "C"
+	clientOrderID := r.ClientOrderID
+	if r.OrigClientOrderID != "" {
+		clientOrderID = r.OrigClientOrderID
+	}
+
+	order := exchange.Order{
+		ID:             fmt.Sprint(r.OrderID),
+		ClientOrderID:  clientOrderID,
+		Symbol:         r.Symbol,
+		Side:           strings.ToLower(r.Side),
+		Type:           orderType,
+		Status:         status,
+		TimeInForce:    r.TimeInForce,
+		Price:          float64(r.Price),
+		Quantity:       float64(r.Quantity),
+		FilledQuantity: float64(r.CumulativeQuantity),
+		CreatedAt:      time.UnixMilli(r.CreatedAt).UTC(),
+		UpdatedAt:      time.UnixMilli(r.TransactionTime).UTC(),
+	}
+	if r.CumulativeQuantity > 0 {
+		order.AveragePrice = float64(r.CumulativeQuoteQuantity) / float64(r.CumulativeQuantity)
+	}
+
+	report := exchange.OrderReport{Order: order}
+	if r.ExecutionType == "TRADE" {
+		report.Fill = &exchange.Fill{
+			TradeID:   fmt.Sprint(r.TradeID),
+			Price:     float64(r.LastPrice),
+			Quantity:  float64(r.LastQuantity),
+			Fee:       float64(r.Commission),
+			Taker:     !r.Maker,
+			Timestamp: order.UpdatedAt,
+		}
+	}
+	return report, nil
+}
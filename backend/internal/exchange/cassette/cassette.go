@@ -0,0 +1,156 @@
+// Package cassette records sessions with an exchange, HTTP requests and
+// WebSocket streams, into sanitized cassette files and replays them, so
+// connector tests run offline against what the exchange really answered.
+//
+// A Recorder is a proxy in front of the exchange: point a connector's base
+// and stream URLs at it, run the test against the live exchange and save the
+// cassette. A Player serves the saved cassette in its place.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directions of stream frames
+const (
+	// Sent frames went from the connector to the exchange
+	Sent = "sent"
+	// Received frames came from the exchange
+	Received = "received"
+)
+
+// Cassette is a recorded session
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+	Streams      []Stream      `json:"streams,omitempty"`
+}
+
+// Interaction is one HTTP request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Headers are not recorded; they carry the
+// credentials and are not used for matching.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   Body   `json:"body,omitempty"`
+}
+
+// key identifies the requests a recorded interaction answers
+func (r Request) key() string {
+	return r.Method + " " + r.Path + "?" + r.Query
+}
+
+// Response is a recorded response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Stream is one recorded WebSocket connection
+type Stream struct {
+	Path   string  `json:"path"`
+	Query  string  `json:"query,omitempty"`
+	Frames []Frame `json:"frames"`
+}
+
+func (s Stream) key() string {
+	return s.Path + "?" + s.Query
+}
+
+// Frame is one message of a stream
+type Frame struct {
+	Direction string `json:"direction"`
+	Message   Body   `json:"message"`
+}
+
+// Body is a request, response or frame body. JSON bodies are stored as JSON,
+// which keeps cassettes readable and their diffs small, other bodies as a
+// string.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] != '"' && json.Valid(trimmed) {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, trimmed); err != nil {
+			return nil, err
+		}
+		return compact.Bytes(), nil
+	}
+	return json.Marshal(string(b))
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*b = Body(text)
+		return nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return err
+	}
+	*b = compact.Bytes()
+	return nil
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(content, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, creating its directory
+func (c *Cassette) Save(path string) error {
+	if c.Interactions == nil {
+		c.Interactions = []Interaction{}
+	}
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// canonicalQuery sorts the parameters of a query string
+func canonicalQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	return values.Encode()
+}
+
+// isWebSocket reports whether the request opens a WebSocket connection
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
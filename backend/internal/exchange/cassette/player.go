@@ -0,0 +1,150 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+// Player serves a cassette in place of the exchange. Requests are matched on
+// method, path and query after sanitizing; requests that match several
+// interactions get their responses in recorded order, the last one
+// repeating. WebSocket connections replay the streams recorded for their
+// path in the order they were opened: received frames are sent as soon as
+// every sent frame before them has been read from the client. It is safe for
+// concurrent use.
+type Player struct {
+	sanitizer    Sanitizer
+	secrets      []string
+	interactions map[string][]Interaction
+	streams      map[string][]Stream
+
+	mu        sync.Mutex
+	played    map[string]int
+	requests  []Request
+	unmatched []string
+}
+
+func NewPlayer(cassette *Cassette, sanitizer Sanitizer) *Player {
+	p := &Player{
+		sanitizer:    sanitizer,
+		secrets:      sortSecrets(sanitizer.Secrets),
+		interactions: make(map[string][]Interaction),
+		streams:      make(map[string][]Stream),
+		played:       make(map[string]int),
+	}
+	for _, interaction := range cassette.Interactions {
+		request := interaction.Request
+		request.Query = canonicalQuery(request.Query)
+		key := request.key()
+		p.interactions[key] = append(p.interactions[key], interaction)
+	}
+	for _, stream := range cassette.Streams {
+		stream.Query = canonicalQuery(stream.Query)
+		key := "ws " + stream.key()
+		p.streams[key] = append(p.streams[key], stream)
+	}
+	return p
+}
+
+// Requests returns the sanitized requests the player received, in order, so
+// tests can check what a connector sent
+func (p *Player) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+// Unmatched returns the requests and streams the cassette has no recording for
+func (p *Player) Unmatched() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.unmatched...)
+}
+
+func (p *Player) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebSocket(r) {
+		p.playStream(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	request := p.sanitizer.request(Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: body}, p.secrets)
+	key := request.key()
+
+	p.mu.Lock()
+	p.requests = append(p.requests, request)
+	recorded := p.interactions[key]
+	if len(recorded) == 0 {
+		p.unmatched = append(p.unmatched, key)
+		p.mu.Unlock()
+		http.Error(w, "cassette: no recorded interaction for "+key, http.StatusNotImplemented)
+		return
+	}
+	interaction := recorded[min(p.played[key], len(recorded)-1)]
+	p.played[key]++
+	p.mu.Unlock()
+
+	for name, values := range interaction.Response.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(interaction.Response.Status)
+	_, _ = w.Write(interaction.Response.Body)
+}
+
+func (p *Player) playStream(w http.ResponseWriter, r *http.Request) {
+	stream := Stream{
+		Path:  redactSecrets(r.URL.Path, p.secrets),
+		Query: canonicalQuery(redactSecrets(p.sanitizer.redactParams(r.URL.RawQuery), p.secrets)),
+	}
+	key := "ws " + stream.key()
+
+	p.mu.Lock()
+	recorded := p.streams[key]
+	if len(recorded) == 0 {
+		p.unmatched = append(p.unmatched, key)
+		p.mu.Unlock()
+		http.Error(w, "cassette: no recorded stream for "+key, http.StatusNotImplemented)
+		return
+	}
+	stream = recorded[min(p.played[key], len(recorded)-1)]
+	p.played[key]++
+	p.mu.Unlock()
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(-1)
+
+	// The connection outlives the request once hijacked; it ends when the
+	// client closes it
+	ctx := context.Background()
+	for _, frame := range stream.Frames {
+		switch frame.Direction {
+		case Sent:
+			if _, _, err := conn.Read(ctx); err != nil {
+				return
+			}
+		case Received:
+			if err := conn.Write(ctx, websocket.MessageText, frame.Message); err != nil {
+				return
+			}
+		}
+	}
+	// Keep reading, which answers pings, until the client disconnects
+	for {
+		if _, _, err := conn.Read(ctx); err != nil {
+			return
+		}
+	}
+}
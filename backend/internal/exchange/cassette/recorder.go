@@ -0,0 +1,178 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// hopHeaders are not passed through the proxy
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade", "Te", "Trailer",
+	"Content-Length", "Content-Encoding", "Accept-Encoding", "Host",
+	"Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Accept",
+}
+
+// Recorder is a proxy that records the requests it passes to an exchange.
+// HTTP requests go to the base URL, WebSocket connections to the stream URL,
+// both with the path and query they came with. It is safe for concurrent use.
+type Recorder struct {
+	baseURL   string
+	streamURL string
+	sanitizer Sanitizer
+	client    *http.Client
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder for the exchange at baseURL and streamURL.
+// The sanitizer is applied when the cassette is taken, once every secret the
+// session revealed is known.
+func NewRecorder(baseURL, streamURL string, sanitizer Sanitizer) *Recorder {
+	return &Recorder{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		streamURL: strings.TrimRight(streamURL, "/"),
+		sanitizer: sanitizer,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Cassette returns the sanitized recording so far
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	var cassette Cassette
+	content, err := json.Marshal(r.cassette)
+	r.mu.Unlock()
+	if err == nil {
+		err = json.Unmarshal(content, &cassette)
+	}
+	if err != nil {
+		// Bodies always encode; a failure here is a bug
+		panic(fmt.Sprintf("cassette: failed to copy recording: %v", err))
+	}
+	r.sanitizer.Sanitize(&cassette)
+	return &cassette
+}
+
+// Save writes the sanitized recording to path
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if isWebSocket(req) {
+		r.proxyStream(w, req)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	target := r.baseURL + req.URL.Path
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+	upstream, err := http.NewRequestWithContext(req.Context(), req.Method, target, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: failed to create request: %v", err), http.StatusBadRequest)
+		return
+	}
+	upstream.Header = cloneHeader(req.Header)
+
+	resp, err := r.client.Do(upstream)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: request failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: failed to read response: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	header := cloneHeader(resp.Header)
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery, Body: body},
+		Response: Response{Status: resp.StatusCode, Header: header, Body: content},
+	})
+	r.mu.Unlock()
+
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(content)
+}
+
+// proxyStream connects the client to the exchange's stream and records the
+// messages both ways until either side closes
+func (r *Recorder) proxyStream(w http.ResponseWriter, req *http.Request) {
+	target := r.streamURL + req.URL.Path
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+	// The connections outlive the request once hijacked
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, 30*time.Second)
+	upstream, _, err := websocket.Dial(dialCtx, target, nil)
+	dialCancel()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cassette: failed to connect to stream: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer upstream.CloseNow()
+	upstream.SetReadLimit(-1)
+
+	client, err := websocket.Accept(w, req, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	defer client.CloseNow()
+	client.SetReadLimit(-1)
+
+	r.mu.Lock()
+	index := len(r.cassette.Streams)
+	r.cassette.Streams = append(r.cassette.Streams, Stream{Path: req.URL.Path, Query: req.URL.RawQuery, Frames: []Frame{}})
+	r.mu.Unlock()
+
+	relay := func(from, to *websocket.Conn, direction string) {
+		defer cancel()
+		for {
+			messageType, message, err := from.Read(ctx)
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			r.cassette.Streams[index].Frames = append(r.cassette.Streams[index].Frames, Frame{Direction: direction, Message: message})
+			r.mu.Unlock()
+			if err := to.Write(ctx, messageType, message); err != nil {
+				return
+			}
+		}
+	}
+	go relay(client, upstream, Sent)
+	relay(upstream, client, Received)
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := header.Clone()
+	for _, name := range hopHeaders {
+		clone.Del(name)
+	}
+	return clone
+}
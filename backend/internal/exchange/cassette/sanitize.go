@@ -0,0 +1,231 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the sanitized values
+const Redacted = "REDACTED"
+
+// minSecretLength keeps short field values, which could appear anywhere, from
+// being redacted outside their field
+const minSecretLength = 8
+
+// Sanitizer strips credentials and other secrets from cassettes. Requests
+// are sanitized the same way when they are replayed, so redacted parameters
+// such as signatures and timestamps still match.
+type Sanitizer struct {
+	// Headers are left out of recorded responses
+	Headers []string
+	// Params are query and form parameters whose values are redacted
+	Params []string
+	// Fields are JSON fields whose values are redacted in bodies and frames.
+	// Their string values are also redacted wherever else they appear, e.g. a
+	// listen key in a stream path.
+	Fields []string
+	// Secrets, such as the API key and secret, are redacted wherever they appear
+	Secrets []string
+}
+
+// DefaultSanitizer covers the signed requests of the supported exchanges
+var DefaultSanitizer = Sanitizer{
+	Headers: []string{"Authorization", "Cookie", "Set-Cookie", "X-Mbx-Apikey"},
+	Params:  []string{"signature", "timestamp", "recvWindow", "api_key", "apiKey", "nonce"},
+	Fields:  []string{"api_key", "apiKey", "secret_key", "signature", "listenKey", "token"},
+}
+
+// Sanitize redacts the secrets of the cassette in place
+func (s Sanitizer) Sanitize(c *Cassette) {
+	secrets := append([]string{}, s.Secrets...)
+	fields := s.lookup(s.Fields)
+	for _, interaction := range c.Interactions {
+		secrets = append(secrets, collectFields(interaction.Request.Body, fields)...)
+		secrets = append(secrets, collectFields(interaction.Response.Body, fields)...)
+	}
+	for _, stream := range c.Streams {
+		for _, frame := range stream.Frames {
+			secrets = append(secrets, collectFields(frame.Message, fields)...)
+		}
+	}
+	secrets = sortSecrets(secrets)
+
+	for i := range c.Interactions {
+		interaction := &c.Interactions[i]
+		interaction.Request = s.request(interaction.Request, secrets)
+
+		header := http.Header{}
+		for name, values := range interaction.Response.Header {
+			if s.dropHeader(name) {
+				continue
+			}
+			for _, value := range values {
+				header.Add(name, redactSecrets(value, secrets))
+			}
+		}
+		interaction.Response.Header = header
+		interaction.Response.Body = Body(redactSecrets(string(redactFields(interaction.Response.Body, fields)), secrets))
+	}
+	for i := range c.Streams {
+		stream := &c.Streams[i]
+		stream.Path = redactSecrets(stream.Path, secrets)
+		stream.Query = redactSecrets(s.redactParams(stream.Query), secrets)
+		for j := range stream.Frames {
+			frame := &stream.Frames[j]
+			frame.Message = Body(redactSecrets(string(redactFields(frame.Message, fields)), secrets))
+		}
+	}
+}
+
+// request sanitizes a recorded or replayed request
+func (s Sanitizer) request(r Request, secrets []string) Request {
+	r.Path = redactSecrets(r.Path, secrets)
+	r.Query = redactSecrets(s.redactParams(r.Query), secrets)
+	body := redactFields(r.Body, s.lookup(s.Fields))
+	if !json.Valid(bytes.TrimSpace(body)) {
+		body = Body(s.redactParams(string(body)))
+	}
+	r.Body = Body(redactSecrets(string(body), secrets))
+	return r
+}
+
+func (s Sanitizer) dropHeader(name string) bool {
+	for _, header := range s.Headers {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactParams redacts the parameters of a query string or form body. The
+// result is canonical: parameters are sorted.
+func (s Sanitizer) redactParams(query string) string {
+	if query == "" || !strings.Contains(query, "=") {
+		return query
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	params := s.lookup(s.Params)
+	for name := range values {
+		if params[strings.ToLower(name)] {
+			values[name] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+func (s Sanitizer) lookup(names []string) map[string]bool {
+	lookup := make(map[string]bool, len(names))
+	for _, name := range names {
+		lookup[strings.ToLower(name)] = true
+	}
+	return lookup
+}
+
+// redactFields replaces the values of the fields in a JSON body. Other
+// bodies and bodies without the fields are returned unchanged.
+func redactFields(body Body, fields map[string]bool) Body {
+	value, ok := decodeJSON(body)
+	if !ok {
+		return body
+	}
+	changed := false
+	walkFields(value, fields, func(parent map[string]interface{}, name string) {
+		parent[name] = Redacted
+		changed = true
+	})
+	if !changed {
+		return body
+	}
+
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return body
+	}
+	return Body(bytes.TrimSpace(encoded.Bytes()))
+}
+
+// collectFields returns the string values of the fields in a JSON body that
+// are long enough to be redacted anywhere
+func collectFields(body Body, fields map[string]bool) []string {
+	value, ok := decodeJSON(body)
+	if !ok {
+		return nil
+	}
+	var secrets []string
+	walkFields(value, fields, func(parent map[string]interface{}, name string) {
+		if secret, ok := parent[name].(string); ok && len(secret) >= minSecretLength {
+			secrets = append(secrets, secret)
+		}
+	})
+	return secrets
+}
+
+func decodeJSON(body Body) (interface{}, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// walkFields calls visit for every field of value, at any depth, whose name
+// is one of fields
+func walkFields(value interface{}, fields map[string]bool, visit func(parent map[string]interface{}, name string)) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, child := range value {
+			if fields[strings.ToLower(name)] {
+				visit(value, name)
+				continue
+			}
+			walkFields(child, fields, visit)
+		}
+	case []interface{}:
+		for _, child := range value {
+			walkFields(child, fields, visit)
+		}
+	}
+}
+
+// sortSecrets removes duplicates and empty secrets and puts longer secrets
+// first, so a secret containing another is redacted whole
+func sortSecrets(secrets []string) []string {
+	seen := make(map[string]bool, len(secrets))
+	unique := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret == "" || secret == Redacted || seen[secret] {
+			continue
+		}
+		seen[secret] = true
+		unique = append(unique, secret)
+	}
+	sort.Slice(unique, func(i, j int) bool { return len(unique[i]) > len(unique[j]) })
+	return unique
+}
+
+// redactSecrets replaces the secrets, also in their URL-escaped form
+func redactSecrets(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			text = strings.ReplaceAll(text, escaped, Redacted)
+		}
+	}
+	return text
+}
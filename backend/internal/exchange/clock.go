@@ -0,0 +1,54 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ServerTimer is implemented by connectors that can read the exchange's
+// clock, which signed requests must agree with
+type ServerTimer interface {
+	ServerTime(ctx context.Context) (time.Time, error)
+}
+
+// Clock is the local clock corrected by its offset to an exchange's clock.
+// It is safe for concurrent use.
+type Clock struct {
+	now func() time.Time
+
+	mu     sync.RWMutex
+	offset time.Duration
+}
+
+// NewClock creates a clock over now, which defaults to time.Now
+func NewClock(now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+	return &Clock{now: now}
+}
+
+// Now returns the local time plus the offset
+func (c *Clock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now().Add(c.offset)
+}
+
+// Offset is how far the exchange's clock is ahead of the local one
+func (c *Clock) Offset() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset
+}
+
+// Observe sets the offset from a server time read by a request sent and
+// answered at the given local times, assuming the server read its clock
+// halfway through the round trip
+func (c *Clock) Observe(server, sent, received time.Time) {
+	local := sent.Add(received.Sub(sent) / 2)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = server.Sub(local)
+}
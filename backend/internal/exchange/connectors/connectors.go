@@ -0,0 +1,115 @@
+// Package connectors creates the exchange connector for a models.Exchange row.
+package connectors
+
+import (
+	"fmt"
+	"sync"
+
+	"trader/internal/config"
+	"trader/internal/exchange"
+	"trader/internal/exchange/binance"
+	"trader/internal/exchange/hitbtc"
+	"trader/internal/exchange/paper"
+	"trader/internal/exchange/resilience"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// registry holds the rate limits, breakers and clocks of the exchanges, which
+// all connectors of the process share. It is created on first use.
+var (
+	registryMu sync.Mutex
+	registry   *resilience.Registry
+)
+
+func sharedRegistry(cfg config.ExchangeConfig) *resilience.Registry {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if registry == nil {
+		registry = resilience.NewRegistry(resilience.Config{
+			RetryAttempts:     cfg.RetryAttempts,
+			RetryBaseDelay:    cfg.RetryBaseDelay,
+			BreakerThreshold:  cfg.BreakerThreshold,
+			BreakerCooldown:   cfg.BreakerCooldown,
+			MaxWait:           cfg.RateLimitMaxWait,
+			ClockSyncInterval: cfg.ClockSyncInterval,
+		})
+	}
+	return registry
+}
+
+// Health reports the state of the exchanges connectors were created for
+func Health() []resilience.Health {
+	registryMu.Lock()
+	current := registry
+	registryMu.Unlock()
+
+	if current == nil {
+		return []resilience.Health{}
+	}
+	return current.Health()
+}
+
+// New creates the connector of an exchange. Credentials may be nil for public
+// data. Connectors of real exchanges are wrapped in the resilience middleware.
+// Connectors that stream updates also implement exchange.Streamer. The
+// database holds the accounts of the paper exchange.
+func New(cfg config.ExchangeConfig, db *gorm.DB, ex *models.Exchange, credentials *exchange.Credentials) (exchange.Connector, error) {
+	connectorConfig := exchange.Config{
+		BaseURL:           ex.APIUrl,
+		Credentials:       credentials,
+		Timeout:           cfg.RequestTimeout,
+		Heartbeat:         cfg.StreamHeartbeat,
+		MaxReconnectDelay: cfg.StreamMaxReconnectDelay,
+	}
+
+	guard := sharedRegistry(cfg)
+	key := resilience.KeyLabel(credentials)
+
+	switch ex.Code {
+	case hitbtc.Code:
+		connectorConfig.StreamURL = cfg.HitBTCStreamURL
+		connectorConfig.Clock = guard.Clock(ex.Code, ex.APIUrl)
+		return guard.Wrap(hitbtc.New(connectorConfig), ex.Code, ex.APIUrl, key), nil
+	case binance.Code:
+		connectorConfig.StreamURL = cfg.BinanceStreamURL
+		connectorConfig.Clock = guard.Clock(ex.Code, ex.APIUrl)
+		return guard.Wrap(binance.New(connectorConfig), ex.Code, ex.APIUrl, key), nil
+	case paper.Code:
+		return newPaper(cfg, db, credentials)
+	default:
+		return nil, fmt.Errorf("%w: %s", exchange.ErrUnsupportedExchange, ex.Code)
+	}
+}
+
+// newPaper creates a paper exchange connector that fills against the live
+// tickers of the configured market exchange
+func newPaper(cfg config.ExchangeConfig, db *gorm.DB, credentials *exchange.Credentials) (exchange.Connector, error) {
+	if cfg.PaperMarketExchange == paper.Code {
+		return nil, fmt.Errorf("the paper exchange needs a real exchange as market")
+	}
+	var marketExchange models.Exchange
+	if err := db.Where("code = ?", cfg.PaperMarketExchange).First(&marketExchange).Error; err != nil {
+		return nil, fmt.Errorf("failed to find market exchange %s of the paper exchange: %w", cfg.PaperMarketExchange, err)
+	}
+	market, err := New(cfg, db, &marketExchange, nil)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := paper.ParseBalances(cfg.PaperInitialBalances)
+	if err != nil {
+		return nil, err
+	}
+
+	return paper.New(db, credentials, paper.Config{
+		Market:          market,
+		Feed:            paper.NewTickerFeed(market),
+		InitialBalances: balances,
+		MakerFee:        cfg.PaperMakerFee,
+		TakerFee:        cfg.PaperTakerFee,
+		Slippage:        paper.FixedSlippage(cfg.PaperSlippage),
+		Fill:            paper.RatioFill(cfg.PaperFillRatio),
+	}), nil
+}
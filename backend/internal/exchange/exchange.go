@@ -0,0 +1,163 @@
+// Package exchange defines how the backend talks to exchanges. Each exchange
+// has a connector in its own subpackage; callers only use the Connector
+// interface and the errors of this package.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Errors of the connectors. Connectors map the exchange's own error codes
+// onto them, so callers can tell the cases apart with errors.Is whatever the
+// exchange. The rejections of an order also match ErrInvalidOrder.
+var (
+	ErrAuthFailed          = errors.New("exchange rejected the credentials")
+	ErrUnsupportedExchange = errors.New("exchange is not supported")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrInvalidOrder        = errors.New("invalid order")
+	ErrRateLimited         = errors.New("exchange rate limit exceeded")
+	// ErrClockSkew is returned when the exchange rejects a signed request's timestamp
+	ErrClockSkew = errors.New("request timestamp is outside the exchange's window")
+	// ErrUnknownOrderStatus is returned for an order in a status the connector
+	// cannot map, so the order's state is not known
+	ErrUnknownOrderStatus = errors.New("unknown order status")
+
+	ErrInsufficientBalance error = &orderError{"insufficient balance"}
+	ErrInvalidSymbol       error = &orderError{"invalid symbol"}
+	// ErrWouldTriggerImmediately is returned for orders that would execute on
+	// arrival but must not, such as post-only orders that would take liquidity
+	ErrWouldTriggerImmediately error = &orderError{"order would trigger immediately"}
+)
+
+// orderError is a kind of order rejection
+type orderError struct {
+	message string
+}
+
+func (e *orderError) Error() string {
+	return e.message
+}
+
+// Unwrap makes the rejection match ErrInvalidOrder
+func (e *orderError) Unwrap() error {
+	return ErrInvalidOrder
+}
+
+// Permissions an API key can hold on an exchange
+const (
+	PermissionRead     = "read"
+	PermissionTrade    = "trade"
+	PermissionWithdraw = "withdraw"
+)
+
+// Credentials authenticate private requests
+type Credentials struct {
+	APIKey    string
+	APISecret string
+	// Passphrase is only used by exchanges that require one
+	Passphrase string
+	// KeyID and OrganizationID identify the stored API key the credentials
+	// belong to. The paper exchange keeps one account per stored key.
+	KeyID          uint
+	OrganizationID uint
+}
+
+// Capabilities are what a key's credentials are allowed to do
+type Capabilities struct {
+	Read     bool `json:"read"`
+	Trade    bool `json:"trade"`
+	Withdraw bool `json:"withdraw"`
+}
+
+// Permissions lists the granted permissions
+func (c Capabilities) Permissions() []string {
+	permissions := make([]string, 0, 3)
+	if c.Read {
+		permissions = append(permissions, PermissionRead)
+	}
+	if c.Trade {
+		permissions = append(permissions, PermissionTrade)
+	}
+	if c.Withdraw {
+		permissions = append(permissions, PermissionWithdraw)
+	}
+	return permissions
+}
+
+// Connector talks to one exchange, optionally on behalf of one API key
+type Connector interface {
+	// Capabilities checks that the credentials authenticate and detects what
+	// they are allowed to do without changing anything on the account. It
+	// returns ErrAuthFailed when the exchange rejects the credentials.
+	Capabilities(ctx context.Context) (*Capabilities, error)
+
+	// Public market data
+	Symbols(ctx context.Context) ([]Symbol, error)
+	Ticker(ctx context.Context, symbol string) (*Ticker, error)
+	Tickers(ctx context.Context) ([]Ticker, error)
+	OrderBook(ctx context.Context, symbol string, depth int) (*OrderBook, error)
+	Candles(ctx context.Context, symbol string, query CandleQuery) ([]Candle, error)
+
+	// Account, these require credentials
+	Balances(ctx context.Context) ([]Balance, error)
+	PlaceOrder(ctx context.Context, req *OrderRequest) (*Order, error)
+	// CancelOrder and GetOrder identify the order by its client order ID
+	CancelOrder(ctx context.Context, symbol, clientOrderID string) (*Order, error)
+	GetOrder(ctx context.Context, symbol, clientOrderID string) (*Order, error)
+}
+
+// Config configures a connector
+type Config struct {
+	BaseURL string
+	// Credentials are required for private endpoints only
+	Credentials *Credentials
+	Timeout     time.Duration
+	// HTTPClient overrides the client built from Timeout
+	HTTPClient *http.Client
+
+	// StreamURL is the base URL of the exchange's WebSocket API
+	StreamURL string
+	// Heartbeat is how often streams ping the exchange; a missed pong reconnects
+	Heartbeat time.Duration
+	// MaxReconnectDelay caps the backoff between reconnect attempts
+	MaxReconnectDelay time.Duration
+
+	// Clock timestamps signed requests; it defaults to the local clock
+	Clock *Clock
+}
+
+// Client returns the configured HTTP client
+func (c Config) Client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// Now returns the time signed requests are stamped with
+func (c Config) Now() func() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now
+	}
+	return time.Now
+}
+
+// RateLimitUsage is the request weight used in the exchange's current window
+type RateLimitUsage struct {
+	Used    int       `json:"used"`
+	Limit   int       `json:"limit"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// RateLimited is implemented by connectors that track the request weight the
+// exchange reports in its responses
+type RateLimited interface {
+	RateLimitUsage() RateLimitUsage
+}
@@ -0,0 +1,161 @@
+package hitbtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// orderStatuses maps HitBTC order statuses onto the normalized ones. A
+// suspended order is a stop order waiting for its trigger.
+var orderStatuses = map[string]string{
+	"new":             exchange.OrderStatusNew,
+	"suspended":       exchange.OrderStatusNew,
+	"partiallyFilled": exchange.OrderStatusPartiallyFilled,
+	"filled":          exchange.OrderStatusFilled,
+	"canceled":        exchange.OrderStatusCanceled,
+	"expired":         exchange.OrderStatusExpired,
+	"rejected":        exchange.OrderStatusRejected,
+}
+
+type balanceResponse struct {
+	Currency  string `json:"currency"`
+	Available number `json:"available"`
+	Reserved  number `json:"reserved"`
+}
+
+type orderResponse struct {
+	ID                 json.Number `json:"id"`
+	ClientOrderID      string      `json:"client_order_id"`
+	Symbol             string      `json:"symbol"`
+	Side               string      `json:"side"`
+	Status             string      `json:"status"`
+	Type               string      `json:"type"`
+	TimeInForce        string      `json:"time_in_force"`
+	Quantity           number      `json:"quantity"`
+	QuantityCumulative number      `json:"quantity_cumulative"`
+	Price              number      `json:"price"`
+	PriceAverage       number      `json:"price_average"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+// Balances implements exchange.Connector. It returns the spot trading balances.
+func (c *Client) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	var response []balanceResponse
+	if err := c.do(ctx, http.MethodGet, "spot/balance", nil, nil, true, &response); err != nil {
+		return nil, err
+	}
+
+	balances := make([]exchange.Balance, 0, len(response))
+	for _, balance := range response {
+		balances = append(balances, exchange.Balance{
+			Currency:  balance.Currency,
+			Available: float64(balance.Available),
+			Reserved:  float64(balance.Reserved),
+		})
+	}
+	return balances, nil
+}
+
+// PlaceOrder implements exchange.Connector
+func (c *Client) PlaceOrder(ctx context.Context, req *exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	clientOrderID := req.ClientOrderID
+	if clientOrderID == "" {
+		var err error
+		if clientOrderID, err = randomID(); err != nil {
+			return nil, err
+		}
+	}
+
+	body := url.Values{
+		"client_order_id": {clientOrderID},
+		"symbol":          {req.Symbol},
+		"side":            {req.Side},
+		"type":            {req.Type},
+		"quantity":        {formatNumber(req.Quantity)},
+	}
+	if req.Type == exchange.OrderTypeLimit {
+		body.Set("price", formatNumber(req.Price))
+	}
+	if req.TimeInForce != "" {
+		body.Set("time_in_force", req.TimeInForce)
+	}
+	if req.PostOnly {
+		body.Set("post_only", "true")
+	}
+
+	var response orderResponse
+	if err := c.do(ctx, http.MethodPost, "spot/order", nil, body, true, &response); err != nil {
+		return nil, err
+	}
+	return response.order()
+}
+
+// CancelOrder implements exchange.Connector. HitBTC identifies orders by their
+// client order ID alone, the symbol is not needed.
+func (c *Client) CancelOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	var response orderResponse
+	if err := c.do(ctx, http.MethodDelete, "spot/order/"+url.PathEscape(clientOrderID), nil, nil, true, &response); err != nil {
+		return nil, err
+	}
+	return response.order()
+}
+
+// GetOrder implements exchange.Connector. Active orders are looked up first;
+// filled, cancelled and expired orders are only in the order history.
+func (c *Client) GetOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	var active orderResponse
+	err := c.do(ctx, http.MethodGet, "spot/order/"+url.PathEscape(clientOrderID), nil, nil, true, &active)
+	if err == nil {
+		return active.order()
+	}
+	if !errors.Is(err, exchange.ErrOrderNotFound) {
+		return nil, err
+	}
+
+	query := url.Values{"client_order_id": {clientOrderID}}
+	if symbol != "" {
+		query.Set("symbol", symbol)
+	}
+	var history []orderResponse
+	if err := c.do(ctx, http.MethodGet, "spot/history/order", query, nil, true, &history); err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("%w: %s", exchange.ErrOrderNotFound, clientOrderID)
+	}
+	return history[0].order()
+}
+
+func (o orderResponse) order() (*exchange.Order, error) {
+	status, ok := orderStatuses[o.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: hitbtc status %q of order %s", exchange.ErrUnknownOrderStatus, o.Status, o.ClientOrderID)
+	}
+
+	return &exchange.Order{
+		ID:             o.ID.String(),
+		ClientOrderID:  o.ClientOrderID,
+		Symbol:         o.Symbol,
+		Side:           o.Side,
+		Type:           o.Type,
+		Status:         status,
+		TimeInForce:    o.TimeInForce,
+		Price:          float64(o.Price),
+		Quantity:       float64(o.Quantity),
+		FilledQuantity: float64(o.QuantityCumulative),
+		AveragePrice:   float64(o.PriceAverage),
+		CreatedAt:      o.CreatedAt,
+		UpdatedAt:      o.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package hitbtc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"trader/internal/exchange"
+)
+
+// Capabilities implements exchange.Connector. HitBTC does not report the access
+// rights of a key, so each right is probed with a request that cannot change
+// the account: reading the trading balance, cancelling an order and rolling
+// back a withdrawal that do not exist. A key without the right gets "action
+// is forbidden"; a key with it gets "not found".
+func (c *Client) Capabilities(ctx context.Context) (*exchange.Capabilities, error) {
+	var capabilities exchange.Capabilities
+	var err error
+
+	if capabilities.Read, err = c.probe(ctx, http.MethodGet, "spot/balance"); err != nil {
+		return nil, err
+	}
+
+	orderID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	if capabilities.Trade, err = c.probe(ctx, http.MethodDelete, "spot/order/"+orderID); err != nil {
+		return nil, err
+	}
+
+	withdrawalID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	if capabilities.Withdraw, err = c.probe(ctx, http.MethodDelete, "wallet/crypto/withdraw/"+withdrawalID); err != nil {
+		return nil, err
+	}
+	return &capabilities, nil
+}
+
+// probe reports whether the key may call the endpoint
+func (c *Client) probe(ctx context.Context, method, path string) (bool, error) {
+	err := c.do(ctx, method, path, nil, nil, true, nil)
+	if err == nil {
+		return true, nil
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || errors.Is(err, exchange.ErrAuthFailed) {
+		return false, err
+	}
+	switch {
+	case apiErr.Code == errorCodeActionForbidden || apiErr.Status == http.StatusForbidden:
+		return false, nil
+	case apiErr.Code == errorCodeOrderNotFound || apiErr.Code == errorCodeWithdrawNotFound,
+		apiErr.Status == http.StatusBadRequest || apiErr.Status == http.StatusNotFound:
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// randomID returns a random 32 character identifier, used for client order
+// IDs and for probes that must match no order or withdrawal
+func randomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,255 @@
+// Package hitbtc implements the exchange connector for HitBTC API v3.
+package hitbtc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// Code is the models.Exchange code of HitBTC
+const Code = "hitbtc"
+
+// DefaultBaseURL is used when the exchange row has no API URL
+const DefaultBaseURL = "https://api.hitbtc.com"
+
+// DefaultStreamURL is the base URL of the WebSocket API
+const DefaultStreamURL = "wss://api.hitbtc.com/api/3/ws"
+
+// HitBTC error codes, see https://api.hitbtc.com/#error-response
+const (
+	errorCodeTooManyRequests   = 429
+	errorCodeAuthRequired      = 1001
+	errorCodeAuthFailed        = 1002
+	errorCodeActionForbidden   = 1003
+	errorCodeUnsupportedAuth   = 1004
+	errorCodeSymbolNotFound    = 2001
+	errorCodeCurrencyNotFound  = 2002
+	errorCodeInvalidQuantity   = 2010
+	errorCodeQuantityTooLow    = 2011
+	errorCodeBadQuantity       = 2012
+	errorCodeInvalidPrice      = 2020
+	errorCodeBadPrice          = 2022
+	errorCodeValidation        = 10001
+	errorCodeInsufficientFunds = 20001
+	errorCodeOrderNotFound     = 20002
+	errorCodeWithdrawNotFound  = 20003
+	errorCodeDuplicateOrderID  = 20008
+)
+
+// errorCodes maps HitBTC error codes onto the errors of the exchange
+// package. HitBTC does not reject post-only orders that would take
+// liquidity; it expires them.
+var errorCodes = map[int]error{
+	errorCodeTooManyRequests:   exchange.ErrRateLimited,
+	errorCodeAuthRequired:      exchange.ErrAuthFailed,
+	errorCodeAuthFailed:        exchange.ErrAuthFailed,
+	errorCodeUnsupportedAuth:   exchange.ErrAuthFailed,
+	errorCodeSymbolNotFound:    exchange.ErrInvalidSymbol,
+	errorCodeCurrencyNotFound:  exchange.ErrInvalidSymbol,
+	errorCodeInvalidQuantity:   exchange.ErrInvalidOrder,
+	errorCodeQuantityTooLow:    exchange.ErrInvalidOrder,
+	errorCodeBadQuantity:       exchange.ErrInvalidOrder,
+	errorCodeInvalidPrice:      exchange.ErrInvalidOrder,
+	errorCodeBadPrice:          exchange.ErrInvalidOrder,
+	errorCodeValidation:        exchange.ErrInvalidOrder,
+	errorCodeInsufficientFunds: exchange.ErrInsufficientBalance,
+	errorCodeOrderNotFound:     exchange.ErrOrderNotFound,
+	errorCodeDuplicateOrderID:  exchange.ErrInvalidOrder,
+}
+
+// Client is a HitBTC REST client. Private requests are signed with HS256.
+type Client struct {
+	baseURL     string
+	credentials *exchange.Credentials
+	http        *http.Client
+	now         func() time.Time
+
+	streamURL         string
+	timeout           time.Duration
+	heartbeat         time.Duration
+	maxReconnectDelay time.Duration
+}
+
+func New(cfg exchange.Config) *Client {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	streamURL := strings.TrimRight(cfg.StreamURL, "/")
+	if streamURL == "" {
+		streamURL = DefaultStreamURL
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	heartbeat := cfg.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	maxReconnectDelay := cfg.MaxReconnectDelay
+	if maxReconnectDelay <= 0 {
+		maxReconnectDelay = time.Minute
+	}
+
+	return &Client{
+		baseURL:           baseURL,
+		credentials:       cfg.Credentials,
+		http:              cfg.Client(),
+		now:               cfg.Now(),
+		streamURL:         streamURL,
+		timeout:           timeout,
+		heartbeat:         heartbeat,
+		maxReconnectDelay: maxReconnectDelay,
+	}
+}
+
+// APIError is an error response of the HitBTC API
+type APIError struct {
+	Status      int    `json:"-"`
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("hitbtc: %d %s: %s", e.Code, e.Message, e.Description)
+	}
+	return fmt.Sprintf("hitbtc: %d %s", e.Code, e.Message)
+}
+
+// Unwrap maps HitBTC error codes onto the errors of the exchange package
+func (e *APIError) Unwrap() error {
+	return errorCodes[e.Code]
+}
+
+// HTTPStatus is the status code of the response
+func (e *APIError) HTTPStatus() int {
+	return e.Status
+}
+
+// do sends a request to /api/3/<path> and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body url.Values, signed bool, out interface{}) error {
+	requestPath := "/api/3/" + strings.TrimPrefix(path, "/")
+	target := c.baseURL + requestPath
+	encodedQuery := ""
+	if len(query) > 0 {
+		encodedQuery = query.Encode()
+		target += "?" + encodedQuery
+	}
+
+	var payload []byte
+	if len(body) > 0 {
+		payload = []byte(body.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create hitbtc request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if signed {
+		if c.credentials == nil {
+			return fmt.Errorf("%w: credentials are required", exchange.ErrAuthFailed)
+		}
+		req.Header.Set("Authorization", c.sign(method, requestPath, encodedQuery, payload))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("hitbtc request %s %s failed: %w", method, requestPath, err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read hitbtc response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var envelope struct {
+			Error *APIError `json:"error"`
+		}
+		if err := json.Unmarshal(content, &envelope); err != nil || envelope.Error == nil {
+			return &APIError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		}
+		envelope.Error.Status = resp.StatusCode
+		return envelope.Error
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("failed to decode hitbtc response: %w", err)
+	}
+	return nil
+}
+
+// sign builds the HS256 Authorization header:
+// base64(apiKey:hex(HMAC-SHA256(secret, method+path+?query+body+timestamp)):timestamp)
+func (c *Client) sign(method, path, query string, body []byte) string {
+	timestamp := strconv.FormatInt(c.now().UnixMilli(), 10)
+
+	message := method + path
+	if query != "" {
+		message += "?" + query
+	}
+	message += string(body) + timestamp
+
+	mac := hmac.New(sha256.New, []byte(c.credentials.APISecret))
+	mac.Write([]byte(message))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	token := c.credentials.APIKey + ":" + signature + ":" + timestamp
+	return "HS256 " + base64.StdEncoding.EncodeToString([]byte(token))
+}
+
+// number decodes the decimal strings HitBTC uses for prices and quantities
+type number float64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*n = 0
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid hitbtc number %s: %w", data, err)
+	}
+	*n = number(parsed)
+	return nil
+}
+
+// decimals returns the number of decimal places of a step such as "0.0001"
+func decimals(step string) int {
+	_, fraction, ok := strings.Cut(step, ".")
+	if !ok {
+		return 0
+	}
+	return len(strings.TrimRight(fraction, "0"))
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
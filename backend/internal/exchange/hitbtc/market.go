@@ -0,0 +1,233 @@
+package hitbtc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// symbolStatusWorking is the status of symbols open for trading
+const symbolStatusWorking = "working"
+
+// periods maps candle intervals onto HitBTC periods
+var periods = map[exchange.Interval]string{
+	exchange.Interval1m:  "M1",
+	exchange.Interval5m:  "M5",
+	exchange.Interval15m: "M15",
+	exchange.Interval30m: "M30",
+	exchange.Interval1h:  "H1",
+	exchange.Interval4h:  "H4",
+	exchange.Interval1d:  "D1",
+	exchange.Interval1w:  "D7",
+}
+
+type symbolResponse struct {
+	Type              string `json:"type"`
+	BaseCurrency      string `json:"base_currency"`
+	QuoteCurrency     string `json:"quote_currency"`
+	Status            string `json:"status"`
+	QuantityIncrement string `json:"quantity_increment"`
+	TickSize          string `json:"tick_size"`
+	TakeRate          number `json:"take_rate"`
+	MakeRate          number `json:"make_rate"`
+	FeeCurrency       string `json:"fee_currency"`
+}
+
+type tickerResponse struct {
+	Ask         number    `json:"ask"`
+	Bid         number    `json:"bid"`
+	Last        number    `json:"last"`
+	Low         number    `json:"low"`
+	High        number    `json:"high"`
+	Open        number    `json:"open"`
+	Volume      number    `json:"volume"`
+	VolumeQuote number    `json:"volume_quote"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type orderBookResponse struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Ask       [][2]number `json:"ask"`
+	Bid       [][2]number `json:"bid"`
+}
+
+type candleResponse struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Open        number    `json:"open"`
+	Close       number    `json:"close"`
+	Min         number    `json:"min"`
+	Max         number    `json:"max"`
+	Volume      number    `json:"volume"`
+	VolumeQuote number    `json:"volume_quote"`
+}
+
+// ServerTime implements exchange.ServerTimer. HitBTC has no time endpoint,
+// so the Date header of a small public response is used; its one-second
+// resolution is well within the ten seconds signed requests may be off.
+func (c *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/3/public/currency/BTC", nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to create hitbtc request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("hitbtc request GET /api/3/public/currency/BTC failed: %w", err)
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("hitbtc response has no valid Date header: %w", err)
+	}
+	// The header is truncated to the second; on average the time is half a second later
+	return serverTime.Add(500 * time.Millisecond), nil
+}
+
+// Symbols implements exchange.Connector. Only spot symbols are returned;
+// HitBTC has no minimum notional, the minimum quantity is one increment.
+func (c *Client) Symbols(ctx context.Context) ([]exchange.Symbol, error) {
+	var response map[string]symbolResponse
+	if err := c.do(ctx, http.MethodGet, "public/symbol", nil, nil, false, &response); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]exchange.Symbol, 0, len(response))
+	for name, symbol := range response {
+		if symbol.Type != "" && symbol.Type != "spot" {
+			continue
+		}
+		tick, err := strconv.ParseFloat(symbol.TickSize, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tick size of hitbtc symbol %s: %w", name, err)
+		}
+		step, err := strconv.ParseFloat(symbol.QuantityIncrement, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity increment of hitbtc symbol %s: %w", name, err)
+		}
+
+		symbols = append(symbols, exchange.Symbol{
+			Symbol:            name,
+			Base:              symbol.BaseCurrency,
+			Quote:             symbol.QuoteCurrency,
+			Active:            symbol.Status == symbolStatusWorking,
+			PriceTick:         tick,
+			QuantityStep:      step,
+			PricePrecision:    decimals(symbol.TickSize),
+			QuantityPrecision: decimals(symbol.QuantityIncrement),
+			MinQuantity:       step,
+			MakerFee:          float64(symbol.MakeRate),
+			TakerFee:          float64(symbol.TakeRate),
+			FeeCurrency:       symbol.FeeCurrency,
+		})
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Symbol < symbols[j].Symbol })
+	return symbols, nil
+}
+
+// Ticker implements exchange.Connector
+func (c *Client) Ticker(ctx context.Context, symbol string) (*exchange.Ticker, error) {
+	var response tickerResponse
+	if err := c.do(ctx, http.MethodGet, "public/ticker/"+url.PathEscape(symbol), nil, nil, false, &response); err != nil {
+		return nil, err
+	}
+	ticker := response.ticker(symbol)
+	return &ticker, nil
+}
+
+// Tickers implements exchange.Connector
+func (c *Client) Tickers(ctx context.Context) ([]exchange.Ticker, error) {
+	var response map[string]tickerResponse
+	if err := c.do(ctx, http.MethodGet, "public/ticker", nil, nil, false, &response); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]exchange.Ticker, 0, len(response))
+	for symbol, ticker := range response {
+		tickers = append(tickers, ticker.ticker(symbol))
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	return tickers, nil
+}
+
+func (t tickerResponse) ticker(symbol string) exchange.Ticker {
+	return exchange.Ticker{
+		Symbol:      symbol,
+		Bid:         float64(t.Bid),
+		Ask:         float64(t.Ask),
+		Last:        float64(t.Last),
+		Open:        float64(t.Open),
+		High:        float64(t.High),
+		Low:         float64(t.Low),
+		Volume:      float64(t.Volume),
+		QuoteVolume: float64(t.VolumeQuote),
+		Timestamp:   t.Timestamp,
+	}
+}
+
+// OrderBook implements exchange.Connector. Depth 0 returns the full book.
+func (c *Client) OrderBook(ctx context.Context, symbol string, depth int) (*exchange.OrderBook, error) {
+	query := url.Values{"depth": {strconv.Itoa(depth)}}
+	var response orderBookResponse
+	if err := c.do(ctx, http.MethodGet, "public/orderbook/"+url.PathEscape(symbol), query, nil, false, &response); err != nil {
+		return nil, err
+	}
+
+	return &exchange.OrderBook{
+		Symbol:    symbol,
+		Bids:      priceLevels(response.Bid),
+		Asks:      priceLevels(response.Ask),
+		Timestamp: response.Timestamp,
+	}, nil
+}
+
+func priceLevels(levels [][2]number) []exchange.PriceLevel {
+	result := make([]exchange.PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, exchange.PriceLevel{Price: float64(level[0]), Quantity: float64(level[1])})
+	}
+	return result
+}
+
+// Candles implements exchange.Connector. Candles are returned oldest first.
+func (c *Client) Candles(ctx context.Context, symbol string, query exchange.CandleQuery) ([]exchange.Candle, error) {
+	period, ok := periods[query.Interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hitbtc candle interval: %s", query.Interval)
+	}
+
+	params := url.Values{"period": {period}, "sort": {"ASC"}}
+	if !query.From.IsZero() {
+		params.Set("from", query.From.UTC().Format(time.RFC3339))
+	}
+	if !query.Till.IsZero() {
+		params.Set("till", query.Till.UTC().Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var response []candleResponse
+	if err := c.do(ctx, http.MethodGet, "public/candles/"+url.PathEscape(symbol), params, nil, false, &response); err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchange.Candle, 0, len(response))
+	for _, candle := range response {
+		candles = append(candles, exchange.Candle{
+			OpenTime:    candle.Timestamp,
+			Open:        float64(candle.Open),
+			High:        float64(candle.Max),
+			Low:         float64(candle.Min),
+			Close:       float64(candle.Close),
+			Volume:      float64(candle.Volume),
+			QuoteVolume: float64(candle.VolumeQuote),
+		})
+	}
+	return candles, nil
+}
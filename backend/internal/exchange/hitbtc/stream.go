@@ -0,0 +1,426 @@
+package hitbtc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// Stream channels of the WebSocket API
+const (
+	channelTicker    = "ticker/1s"
+	channelTrades    = "trades"
+	channelOrderBook = "orderbook/full"
+)
+
+// streamBuffer is the capacity of the event channel
+const streamBuffer = 256
+
+// Stream implements exchange.Streamer. Market data and order reports use
+// separate connections, each reconnecting with exponential backoff and
+// restoring its subscriptions. Order book updates carry a sequence number; on
+// a gap the book is resubscribed, which makes HitBTC send a new snapshot. A
+// connection whose credentials are rejected is not retried.
+func (c *Client) Stream(ctx context.Context, sub exchange.Subscription) (<-chan exchange.StreamEvent, error) {
+	if sub.Orders && c.credentials == nil {
+		return nil, fmt.Errorf("%w: credentials are required for order reports", exchange.ErrAuthFailed)
+	}
+
+	events := make(chan exchange.StreamEvent, streamBuffer)
+	var wg sync.WaitGroup
+	if len(sub.Tickers) > 0 || len(sub.Trades) > 0 || len(sub.OrderBooks) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runStream(ctx, &publicSession{sub: sub}, events)
+		}()
+	}
+	if sub.Orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runStream(ctx, &tradingSession{client: c}, events)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events, nil
+}
+
+// session is the protocol spoken on one stream connection
+type session interface {
+	// name is the path of the endpoint below the stream URL
+	name() string
+	// start logs in and subscribes on a new connection
+	start(ctx context.Context, conn *streamConn) error
+	// handle processes one message; an error drops the connection
+	handle(ctx context.Context, conn *streamConn, message *streamMessage) error
+}
+
+// streamConn is one WebSocket connection and the events it publishes
+type streamConn struct {
+	ws     *websocket.Conn
+	events chan<- exchange.StreamEvent
+	nextID int64
+	mu     sync.Mutex
+}
+
+func (s *streamConn) send(ctx context.Context, method string, params interface{}) error {
+	s.mu.Lock()
+	s.nextID++
+	request := map[string]interface{}{"method": method, "params": params, "id": s.nextID}
+	s.mu.Unlock()
+
+	if err := wsjson.Write(ctx, s.ws, request); err != nil {
+		return fmt.Errorf("failed to send hitbtc %s: %w", method, err)
+	}
+	return nil
+}
+
+func (s *streamConn) publish(ctx context.Context, event exchange.StreamEvent) {
+	select {
+	case s.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// streamMessage is any message of the WebSocket API: a response to a request,
+// a channel notification or a method notification
+type streamMessage struct {
+	ID       *int64          `json:"id"`
+	Result   json.RawMessage `json:"result"`
+	Error    *APIError       `json:"error"`
+	Channel  string          `json:"ch"`
+	Data     json.RawMessage `json:"data"`
+	Snapshot json.RawMessage `json:"snapshot"`
+	Update   json.RawMessage `json:"update"`
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// runStream keeps a session connected until ctx is cancelled
+func (c *Client) runStream(ctx context.Context, s session, events chan<- exchange.StreamEvent) {
+	exchange.KeepConnected(ctx, s.name(), c.maxReconnectDelay, events, func(ctx context.Context) (bool, error) {
+		return c.connect(ctx, s, events)
+	})
+}
+
+// connect runs one connection of the session. It reports whether the
+// session got as far as subscribing.
+func (c *Client) connect(ctx context.Context, s session, events chan<- exchange.StreamEvent) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dialCtx, cancelDial := context.WithTimeout(ctx, c.timeout)
+	ws, _, err := websocket.Dial(dialCtx, c.streamURL+"/"+s.name(), nil)
+	cancelDial()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to hitbtc %s stream: %w", s.name(), err)
+	}
+	defer ws.CloseNow()
+	// Full order book snapshots exceed the default 32 KiB
+	ws.SetReadLimit(16 << 20)
+
+	conn := &streamConn{ws: ws, events: events}
+	if err := s.start(ctx, conn); err != nil {
+		return false, err
+	}
+	conn.publish(ctx, exchange.StreamStatus{Channel: s.name(), State: exchange.StreamConnected})
+	heartbeat := exchange.Heartbeat(ctx, c.heartbeat, ws.Ping, cancel)
+
+	for {
+		var message streamMessage
+		if err := wsjson.Read(ctx, ws, &message); err != nil {
+			select {
+			case err = <-heartbeat:
+			default:
+			}
+			return true, fmt.Errorf("hitbtc %s stream closed: %w", s.name(), err)
+		}
+		if message.Error != nil {
+			return true, message.Error
+		}
+		if err := s.handle(ctx, conn, &message); err != nil {
+			return true, err
+		}
+	}
+}
+
+// publicSession streams tickers, trades and order books
+type publicSession struct {
+	sub exchange.Subscription
+	// sequences holds the last order book sequence per symbol; a symbol is
+	// missing until its snapshot arrives
+	sequences map[string]int64
+}
+
+// streamTicker has a field for every key: encoding/json matches keys case
+// insensitively, so "B" (bid size) would otherwise overwrite "b" (bid)
+type streamTicker struct {
+	Timestamp   int64       `json:"t"`
+	Ask         number      `json:"a"`
+	AskSize     number      `json:"A"`
+	Bid         number      `json:"b"`
+	BidSize     number      `json:"B"`
+	Last        number      `json:"c"`
+	Open        number      `json:"o"`
+	High        number      `json:"h"`
+	Low         number      `json:"l"`
+	Volume      number      `json:"v"`
+	Quote       number      `json:"q"`
+	Change      number      `json:"p"`
+	ChangeRate  number      `json:"P"`
+	LastTradeID json.Number `json:"L"`
+}
+
+type streamTrade struct {
+	Timestamp int64       `json:"t"`
+	ID        json.Number `json:"i"`
+	Price     number      `json:"p"`
+	Quantity  number      `json:"q"`
+	Side      string      `json:"s"`
+}
+
+type streamBook struct {
+	Timestamp int64       `json:"t"`
+	Sequence  int64       `json:"s"`
+	Ask       [][2]number `json:"a"`
+	Bid       [][2]number `json:"b"`
+}
+
+func (p *publicSession) name() string { return "public" }
+
+func (p *publicSession) start(ctx context.Context, conn *streamConn) error {
+	p.sequences = make(map[string]int64)
+	subscriptions := []struct {
+		channel string
+		symbols []string
+	}{
+		{channelTicker, p.sub.Tickers},
+		{channelTrades, p.sub.Trades},
+		{channelOrderBook, p.sub.OrderBooks},
+	}
+	for _, subscription := range subscriptions {
+		if len(subscription.symbols) == 0 {
+			continue
+		}
+		if err := p.subscribe(ctx, conn, subscription.channel, subscription.symbols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *publicSession) subscribe(ctx context.Context, conn *streamConn, channel string, symbols []string) error {
+	return conn.send(ctx, "subscribe", map[string]interface{}{"ch": channel, "symbols": symbols})
+}
+
+func (p *publicSession) handle(ctx context.Context, conn *streamConn, message *streamMessage) error {
+	switch message.Channel {
+	case channelTicker:
+		var tickers map[string]streamTicker
+		if err := json.Unmarshal(message.Data, &tickers); err != nil {
+			return fmt.Errorf("failed to decode hitbtc ticker: %w", err)
+		}
+		for _, symbol := range sortedKeys(tickers) {
+			ticker := tickers[symbol]
+			conn.publish(ctx, exchange.TickerUpdate{Ticker: exchange.Ticker{
+				Symbol:      symbol,
+				Bid:         float64(ticker.Bid),
+				Ask:         float64(ticker.Ask),
+				Last:        float64(ticker.Last),
+				Open:        float64(ticker.Open),
+				High:        float64(ticker.High),
+				Low:         float64(ticker.Low),
+				Volume:      float64(ticker.Volume),
+				QuoteVolume: float64(ticker.Quote),
+				Timestamp:   time.UnixMilli(ticker.Timestamp).UTC(),
+			}})
+		}
+	case channelTrades:
+		payload := message.Update
+		if payload == nil {
+			payload = message.Snapshot
+		}
+		var trades map[string][]streamTrade
+		if err := json.Unmarshal(payload, &trades); err != nil {
+			return fmt.Errorf("failed to decode hitbtc trades: %w", err)
+		}
+		for _, symbol := range sortedKeys(trades) {
+			update := exchange.TradeUpdate{Symbol: symbol, Trades: make([]exchange.Trade, 0, len(trades[symbol]))}
+			for _, trade := range trades[symbol] {
+				update.Trades = append(update.Trades, exchange.Trade{
+					ID:        trade.ID.String(),
+					Price:     float64(trade.Price),
+					Quantity:  float64(trade.Quantity),
+					Side:      trade.Side,
+					Timestamp: time.UnixMilli(trade.Timestamp).UTC(),
+				})
+			}
+			if len(update.Trades) > 0 {
+				conn.publish(ctx, update)
+			}
+		}
+	case channelOrderBook:
+		return p.handleOrderBook(ctx, conn, message)
+	}
+	return nil
+}
+
+func (p *publicSession) handleOrderBook(ctx context.Context, conn *streamConn, message *streamMessage) error {
+	snapshot := message.Snapshot != nil
+	payload := message.Update
+	if snapshot {
+		payload = message.Snapshot
+	}
+	var books map[string]streamBook
+	if err := json.Unmarshal(payload, &books); err != nil {
+		return fmt.Errorf("failed to decode hitbtc order book: %w", err)
+	}
+
+	for _, symbol := range sortedKeys(books) {
+		book := books[symbol]
+		last, synced := p.sequences[symbol]
+		if !snapshot {
+			if !synced {
+				// Updates between a gap and the new snapshot are dropped
+				continue
+			}
+			if book.Sequence <= last {
+				continue
+			}
+			if book.Sequence != last+1 {
+				delete(p.sequences, symbol)
+				conn.publish(ctx, exchange.StreamStatus{
+					Channel: channelOrderBook + "/" + symbol,
+					State:   exchange.StreamResyncing,
+					Err:     fmt.Errorf("hitbtc order book %s skipped from sequence %d to %d", symbol, last, book.Sequence),
+				})
+				if err := p.resync(ctx, conn, symbol); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		p.sequences[symbol] = book.Sequence
+		conn.publish(ctx, exchange.OrderBookUpdate{
+			Symbol:    symbol,
+			Snapshot:  snapshot,
+			Sequence:  book.Sequence,
+			Bids:      priceLevels(book.Bid),
+			Asks:      priceLevels(book.Ask),
+			Timestamp: time.UnixMilli(book.Timestamp).UTC(),
+		})
+	}
+	return nil
+}
+
+// resync resubscribes to a book, which makes HitBTC send a new snapshot
+func (p *publicSession) resync(ctx context.Context, conn *streamConn, symbol string) error {
+	if err := conn.send(ctx, "unsubscribe", map[string]interface{}{"ch": channelOrderBook, "symbols": []string{symbol}}); err != nil {
+		return err
+	}
+	return p.subscribe(ctx, conn, channelOrderBook, []string{symbol})
+}
+
+// tradingSession streams the account's order reports
+type tradingSession struct {
+	client *Client
+}
+
+type orderReportResponse struct {
+	orderResponse
+	ReportType    string      `json:"report_type"`
+	TradeID       json.Number `json:"trade_id"`
+	TradeQuantity number      `json:"trade_quantity"`
+	TradePrice    number      `json:"trade_price"`
+	TradeFee      number      `json:"trade_fee"`
+	TradeTaker    bool        `json:"trade_taker"`
+}
+
+func (t *tradingSession) name() string { return "trading" }
+
+// start logs in with an HS256 signature of the timestamp and subscribes to reports
+func (t *tradingSession) start(ctx context.Context, conn *streamConn) error {
+	timestamp := t.client.now().UnixMilli()
+	mac := hmac.New(sha256.New, []byte(t.client.credentials.APISecret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+
+	err := conn.send(ctx, "login", map[string]interface{}{
+		"type":      "HS256",
+		"api_key":   t.client.credentials.APIKey,
+		"timestamp": timestamp,
+		"signature": hex.EncodeToString(mac.Sum(nil)),
+	})
+	if err != nil {
+		return err
+	}
+	return conn.send(ctx, "spot_subscribe", map[string]interface{}{})
+}
+
+func (t *tradingSession) handle(ctx context.Context, conn *streamConn, message *streamMessage) error {
+	switch message.Method {
+	case "spot_orders":
+		// The active orders, sent after subscribing
+		var reports []orderReportResponse
+		if err := json.Unmarshal(message.Params, &reports); err != nil {
+			return fmt.Errorf("failed to decode hitbtc orders: %w", err)
+		}
+		for _, report := range reports {
+			if err := t.publish(ctx, conn, &report); err != nil {
+				return err
+			}
+		}
+	case "spot_order":
+		var report orderReportResponse
+		if err := json.Unmarshal(message.Params, &report); err != nil {
+			return fmt.Errorf("failed to decode hitbtc order report: %w", err)
+		}
+		return t.publish(ctx, conn, &report)
+	}
+	return nil
+}
+
+func (t *tradingSession) publish(ctx context.Context, conn *streamConn, report *orderReportResponse) error {
+	order, err := report.order()
+	if err != nil {
+		return err
+	}
+	event := exchange.OrderReport{Order: *order}
+	if report.ReportType == "trade" {
+		event.Fill = &exchange.Fill{
+			TradeID:   report.TradeID.String(),
+			Price:     float64(report.TradePrice),
+			Quantity:  float64(report.TradeQuantity),
+			Fee:       float64(report.TradeFee),
+			Taker:     report.TradeTaker,
+			Timestamp: order.UpdatedAt,
+		}
+	}
+	conn.publish(ctx, event)
+	return nil
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
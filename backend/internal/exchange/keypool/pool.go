@@ -0,0 +1,265 @@
+// Package keypool balances requests over several API keys for the same
+// exchange. It tracks, per key, the request-weight budget the exchange
+// reports, the recent error rate and a circuit breaker that opens when the
+// exchange rejects the key's credentials.
+package keypool
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+var ErrNoKeyAvailable = errors.New("no api key available")
+
+// Key states reported by Health
+const (
+	StateHealthy  = "healthy"
+	StateDegraded = "degraded"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// outcomeHistory is the number of recent outcomes kept per key
+const outcomeHistory = 50
+
+type Config struct {
+	// ErrorWindow is how far back errors count towards the error rate
+	ErrorWindow time.Duration
+	// BreakerCooldown is how long a key stays unused after an auth error.
+	// Every failed trial afterwards doubles it, up to MaxBreakerCooldown.
+	BreakerCooldown    time.Duration
+	MaxBreakerCooldown time.Duration
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Budget is a key's request-weight allowance in the exchange's current window
+type Budget struct {
+	Used    int       `json:"used"`
+	Limit   int       `json:"limit"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// Health is a snapshot of a key's state in the pool
+type Health struct {
+	State     string     `json:"state"`
+	ErrorRate float64    `json:"error_rate"`
+	Budget    *Budget    `json:"budget,omitempty"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// Pool is safe for concurrent use
+type Pool struct {
+	cfg  Config
+	now  func() time.Time
+	mu   sync.Mutex
+	keys map[uint]*keyState
+}
+
+type keyState struct {
+	outcomes     []outcome
+	budget       *Budget
+	lastSelected time.Time
+	openUntil    time.Time
+	cooldown     time.Duration
+}
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+func New(cfg Config) *Pool {
+	if cfg.ErrorWindow <= 0 {
+		cfg.ErrorWindow = 5 * time.Minute
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = 5 * time.Minute
+	}
+	if cfg.MaxBreakerCooldown < cfg.BreakerCooldown {
+		cfg.MaxBreakerCooldown = 12 * cfg.BreakerCooldown
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Pool{
+		cfg:  cfg,
+		now:  cfg.Now,
+		keys: make(map[uint]*keyState),
+	}
+}
+
+// Select picks the key with the best score among the candidates: the share of
+// the weight budget left times the share of recent requests that succeeded.
+// Keys with an open breaker or without budget for the weight are skipped; a key
+// whose cooldown has passed gets a trial request only if no healthy key is left.
+// Ties go to the key used least recently, so equal keys take turns.
+func (p *Pool) Select(candidates []uint, weight int) (uint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	type scored struct {
+		id    uint
+		score float64
+		trial bool
+		last  time.Time
+	}
+	var options []scored
+	for _, id := range candidates {
+		state := p.state(id)
+		if now.Before(state.openUntil) {
+			continue
+		}
+		remaining := 1.0
+		if budget := state.currentBudget(now); budget != nil && budget.Limit > 0 {
+			left := budget.Limit - budget.Used
+			if left < weight {
+				continue
+			}
+			remaining = float64(left) / float64(budget.Limit)
+		}
+		options = append(options, scored{
+			id:    id,
+			score: remaining * (1 - state.errorRate(now, p.cfg.ErrorWindow)),
+			trial: !state.openUntil.IsZero(),
+			last:  state.lastSelected,
+		})
+	}
+	if len(options) == 0 {
+		return 0, ErrNoKeyAvailable
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].trial != options[j].trial {
+			return !options[i].trial
+		}
+		if options[i].score != options[j].score {
+			return options[i].score > options[j].score
+		}
+		return options[i].last.Before(options[j].last)
+	})
+
+	chosen := options[0]
+	state := p.keys[chosen.id]
+	state.lastSelected = now
+	if chosen.trial {
+		// Only one trial at a time: the key stays open until the trial reports back
+		state.openUntil = now.Add(state.cooldown)
+	}
+	return chosen.id, nil
+}
+
+// Report records the outcome of a request made with the key. An auth error
+// opens the key's breaker; a success closes it.
+func (p *Pool) Report(id uint, weight int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	state := p.state(id)
+	state.outcomes = append(state.outcomes, outcome{at: now, failed: err != nil})
+	if len(state.outcomes) > outcomeHistory {
+		state.outcomes = state.outcomes[len(state.outcomes)-outcomeHistory:]
+	}
+	if budget := state.currentBudget(now); budget != nil {
+		budget.Used += weight
+	}
+
+	switch {
+	case errors.Is(err, exchange.ErrAuthFailed):
+		if state.cooldown == 0 {
+			state.cooldown = p.cfg.BreakerCooldown
+		} else {
+			state.cooldown = min(2*state.cooldown, p.cfg.MaxBreakerCooldown)
+		}
+		state.openUntil = now.Add(state.cooldown)
+	case err == nil:
+		state.openUntil = time.Time{}
+		state.cooldown = 0
+	}
+}
+
+// UpdateBudget records the request weight the exchange reports as used for the key
+func (p *Pool) UpdateBudget(id uint, budget Budget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state(id).budget = &budget
+}
+
+// Health returns the key's state; unknown keys are healthy
+func (p *Pool) Health(id uint) Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	state, ok := p.keys[id]
+	if !ok {
+		return Health{State: StateHealthy}
+	}
+
+	health := Health{State: StateHealthy, ErrorRate: state.errorRate(now, p.cfg.ErrorWindow)}
+	if budget := state.currentBudget(now); budget != nil {
+		copied := *budget
+		health.Budget = &copied
+	}
+	switch {
+	case now.Before(state.openUntil):
+		openUntil := state.openUntil
+		health.State = StateOpen
+		health.OpenUntil = &openUntil
+	case !state.openUntil.IsZero():
+		health.State = StateHalfOpen
+	case health.ErrorRate >= 0.5:
+		health.State = StateDegraded
+	}
+	return health
+}
+
+// Forget drops the state of a key, e.g. after its credentials were replaced
+func (p *Pool) Forget(id uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.keys, id)
+}
+
+func (p *Pool) state(id uint) *keyState {
+	state, ok := p.keys[id]
+	if !ok {
+		state = &keyState{}
+		p.keys[id] = state
+	}
+	return state
+}
+
+// currentBudget returns the budget unless its window has been reset
+func (s *keyState) currentBudget(now time.Time) *Budget {
+	if s.budget == nil || (!s.budget.ResetAt.IsZero() && !now.Before(s.budget.ResetAt)) {
+		return nil
+	}
+	return s.budget
+}
+
+func (s *keyState) errorRate(now time.Time, window time.Duration) float64 {
+	var total, failed int
+	for _, outcome := range s.outcomes {
+		if now.Sub(outcome.at) > window {
+			continue
+		}
+		total++
+		if outcome.failed {
+			failed++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) / float64(total)
+}
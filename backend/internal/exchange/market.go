@@ -0,0 +1,113 @@
+package exchange
+
+import (
+	"context"
+	"time"
+)
+
+// Symbol is a trading pair as listed by an exchange
+type Symbol struct {
+	// Symbol is the exchange's own name of the pair, e.g. BTCUSDT
+	Symbol string `json:"symbol"`
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	// Active is false when trading is halted or the pair is delisted
+	Active bool `json:"active"`
+	// PriceTick is the smallest price increment
+	PriceTick float64 `json:"price_tick"`
+	// QuantityStep is the smallest quantity increment
+	QuantityStep      float64 `json:"quantity_step"`
+	PricePrecision    int     `json:"price_precision"`
+	QuantityPrecision int     `json:"quantity_precision"`
+	MinQuantity       float64 `json:"min_quantity"`
+	// MaxQuantity is 0 when the exchange has no limit
+	MaxQuantity float64 `json:"max_quantity"`
+	// MinNotional is the smallest order value in the quote currency, 0 when unlimited
+	MinNotional float64 `json:"min_notional"`
+	MakerFee    float64 `json:"maker_fee"`
+	TakerFee    float64 `json:"taker_fee"`
+	// FeeCurrency is the currency fees are charged in, empty when it depends
+	// on the side of the trade
+	FeeCurrency string `json:"fee_currency,omitempty"`
+}
+
+// AccountFees are the fees an account trades at, which depend on its fee
+// tier on the exchange. Maker and Taker are fractions of the traded value.
+type AccountFees struct {
+	// Tier is the exchange's name of the account's tier, empty if it has none
+	Tier  string  `json:"tier,omitempty"`
+	Maker float64 `json:"maker"`
+	Taker float64 `json:"taker"`
+	// FeeCurrency is set when the account pays its fees in one currency, such
+	// as the exchange's own token, instead of the traded ones
+	FeeCurrency string `json:"fee_currency,omitempty"`
+}
+
+// FeeReporter is implemented by connectors that can read the fees of the
+// account their credentials belong to. Fees returns nil when the exchange
+// does not report them.
+type FeeReporter interface {
+	Fees(ctx context.Context) (*AccountFees, error)
+}
+
+// Ticker is the 24h summary of a symbol
+type Ticker struct {
+	Symbol      string    `json:"symbol"`
+	Bid         float64   `json:"bid"`
+	Ask         float64   `json:"ask"`
+	Last        float64   `json:"last"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Volume      float64   `json:"volume"`
+	QuoteVolume float64   `json:"quote_volume"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// PriceLevel is the total quantity offered at one price
+type PriceLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook holds the best bids (descending) and asks (ascending)
+type OrderBook struct {
+	Symbol    string       `json:"symbol"`
+	Bids      []PriceLevel `json:"bids"`
+	Asks      []PriceLevel `json:"asks"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// Candle intervals
+type Interval string
+
+const (
+	Interval1m  Interval = "1m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval30m Interval = "30m"
+	Interval1h  Interval = "1h"
+	Interval4h  Interval = "4h"
+	Interval1d  Interval = "1d"
+	Interval1w  Interval = "1w"
+)
+
+// CandleQuery selects candles of a symbol. Zero From/Till leave the range
+// open; Limit 0 uses the exchange's default.
+type CandleQuery struct {
+	Interval Interval
+	From     time.Time
+	Till     time.Time
+	Limit    int
+}
+
+// Candle is one OHLCV bar; OpenTime is the start of the interval
+type Candle struct {
+	OpenTime    time.Time `json:"open_time"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      float64   `json:"volume"`
+	QuoteVolume float64   `json:"quote_volume"`
+}
@@ -0,0 +1,100 @@
+package exchange
+
+import (
+	"fmt"
+	"time"
+)
+
+// Balance of one currency on the account
+type Balance struct {
+	Currency  string  `json:"currency"`
+	Available float64 `json:"available"`
+	// Reserved is locked by open orders
+	Reserved float64 `json:"reserved"`
+}
+
+// Order sides
+const (
+	OrderSideBuy  = "buy"
+	OrderSideSell = "sell"
+)
+
+// Order types
+const (
+	OrderTypeLimit  = "limit"
+	OrderTypeMarket = "market"
+)
+
+// Time in force of limit orders
+const (
+	TimeInForceGTC = "GTC"
+	TimeInForceIOC = "IOC"
+	TimeInForceFOK = "FOK"
+)
+
+// Order statuses, normalized across exchanges
+const (
+	OrderStatusNew             = "new"
+	OrderStatusPartiallyFilled = "partially_filled"
+	OrderStatusFilled          = "filled"
+	OrderStatusCanceled        = "canceled"
+	OrderStatusExpired         = "expired"
+	OrderStatusRejected        = "rejected"
+)
+
+// OrderRequest places an order. ClientOrderID identifies the order in later
+// calls; connectors generate one when it is empty.
+type OrderRequest struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Type     string  `json:"type"`
+	Quantity float64 `json:"quantity"`
+	// Price is required for limit orders
+	Price       float64 `json:"price,omitempty"`
+	TimeInForce string  `json:"time_in_force,omitempty"`
+	// PostOnly rejects limit orders that would fill immediately
+	PostOnly      bool   `json:"post_only,omitempty"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+}
+
+// Order as reported by the exchange
+type Order struct {
+	ID             string  `json:"id"`
+	ClientOrderID  string  `json:"client_order_id"`
+	Symbol         string  `json:"symbol"`
+	Side           string  `json:"side"`
+	Type           string  `json:"type"`
+	Status         string  `json:"status"`
+	TimeInForce    string  `json:"time_in_force,omitempty"`
+	Price          float64 `json:"price"`
+	Quantity       float64 `json:"quantity"`
+	FilledQuantity float64 `json:"filled_quantity"`
+	// AveragePrice is the average fill price, 0 before the first fill
+	AveragePrice float64   `json:"average_price"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Open reports whether the order can still fill
+func (o *Order) Open() bool {
+	return o.Status == OrderStatusNew || o.Status == OrderStatusPartiallyFilled
+}
+
+// Validate checks the request before it is sent, wrapping ErrInvalidOrder
+func (r *OrderRequest) Validate() error {
+	switch {
+	case r.Symbol == "":
+		return fmt.Errorf("%w: symbol is required", ErrInvalidOrder)
+	case r.Side != OrderSideBuy && r.Side != OrderSideSell:
+		return fmt.Errorf("%w: unknown side %q", ErrInvalidOrder, r.Side)
+	case r.Type != OrderTypeLimit && r.Type != OrderTypeMarket:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOrder, r.Type)
+	case r.Quantity <= 0:
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidOrder)
+	case r.Type == OrderTypeLimit && r.Price <= 0:
+		return fmt.Errorf("%w: limit orders need a positive price", ErrInvalidOrder)
+	case r.Type == OrderTypeMarket && r.PostOnly:
+		return fmt.Errorf("%w: market orders cannot be post-only", ErrInvalidOrder)
+	}
+	return nil
+}
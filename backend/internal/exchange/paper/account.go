@@ -0,0 +1,434 @@
+package paper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"trader/internal/exchange"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// epsilon absorbs float rounding when comparing amounts
+const epsilon = 1e-12
+
+// Balances implements exchange.Connector. Resting orders are matched against
+// the current quotes first.
+func (c *Client) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	db := c.db.WithContext(ctx)
+	account, err := c.account(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.match(ctx, db, account); err != nil {
+		return nil, err
+	}
+
+	var rows []models.PaperBalance
+	if err := db.Where("account_id = ?", account.ID).Order("currency").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load paper balances: %w", err)
+	}
+	balances := make([]exchange.Balance, 0, len(rows))
+	for _, row := range rows {
+		if row.Available <= epsilon && row.Reserved <= epsilon {
+			continue
+		}
+		balances = append(balances, exchange.Balance{Currency: row.Currency, Available: row.Available, Reserved: row.Reserved})
+	}
+	return balances, nil
+}
+
+// PlaceOrder implements exchange.Connector. The order's funds are reserved,
+// then it is matched against the current quote: market orders fill
+// completely, limit orders that cross fill as taker and the rest of IOC and
+// FOK orders expires. Post-only orders that would cross are rejected.
+func (c *Client) PlaceOrder(ctx context.Context, req *exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	db := c.db.WithContext(ctx)
+	account, err := c.account(db)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := c.symbol(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !symbol.Active {
+		return nil, fmt.Errorf("%w: %s is not trading", exchange.ErrInvalidOrder, req.Symbol)
+	}
+	quote, err := c.cfg.Feed.Quote(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOrderID := req.ClientOrderID
+	if clientOrderID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, fmt.Errorf("failed to generate client order id: %w", err)
+		}
+		clientOrderID = hex.EncodeToString(id)
+	}
+	order := &models.PaperOrder{
+		AccountID:     account.ID,
+		ClientOrderID: clientOrderID,
+		Symbol:        symbol.Symbol,
+		Base:          symbol.Base,
+		Quote:         symbol.Quote,
+		Side:          req.Side,
+		Type:          req.Type,
+		Status:        exchange.OrderStatusNew,
+		TimeInForce:   req.TimeInForce,
+		PostOnly:      req.PostOnly,
+		Price:         req.Price,
+		Quantity:      req.Quantity,
+	}
+	if order.Type == exchange.OrderTypeLimit && order.TimeInForce == "" {
+		order.TimeInForce = exchange.TimeInForceGTC
+	}
+	if order.PostOnly && crosses(order, quote, true) {
+		return nil, fmt.Errorf("%w: post-only order would take liquidity", exchange.ErrWouldTriggerImmediately)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.PaperOrder{}).Where("account_id = ? AND client_order_id = ?", account.ID, clientOrderID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to check client order id: %w", err)
+		}
+		if existing > 0 {
+			return fmt.Errorf("%w: duplicate client order id %s", exchange.ErrInvalidOrder, clientOrderID)
+		}
+
+		if err := c.reserve(tx, order, quote); err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create paper order: %w", err)
+		}
+
+		if crosses(order, quote, true) {
+			if err := c.fill(tx, order, quote, true); err != nil {
+				return err
+			}
+		}
+		if open(order) && (order.Type == exchange.OrderTypeMarket || order.TimeInForce == exchange.TimeInForceIOC || order.TimeInForce == exchange.TimeInForceFOK) {
+			if err := c.close(tx, order, exchange.OrderStatusExpired); err != nil {
+				return err
+			}
+		}
+		// The order has seen this quote; it fills as maker from the next one
+		order.LastQuoteAt = &quote.Time
+		if err := tx.Save(order).Error; err != nil {
+			return fmt.Errorf("failed to save paper order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(order), nil
+}
+
+// CancelOrder implements exchange.Connector. Fills up to the current quote
+// are applied before the order is cancelled; closed orders are not found,
+// like on the real exchanges.
+func (c *Client) CancelOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	db := c.db.WithContext(ctx)
+	account, err := c.account(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.match(ctx, db, account); err != nil {
+		return nil, err
+	}
+
+	var order models.PaperOrder
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("account_id = ? AND symbol = ? AND client_order_id = ?", account.ID, symbol, clientOrderID).
+			First(&order).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", exchange.ErrOrderNotFound, clientOrderID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find paper order: %w", err)
+		}
+		if !open(&order) {
+			return fmt.Errorf("%w: %s is %s", exchange.ErrOrderNotFound, clientOrderID, order.Status)
+		}
+		if err := c.close(tx, &order, exchange.OrderStatusCanceled); err != nil {
+			return err
+		}
+		if err := tx.Save(&order).Error; err != nil {
+			return fmt.Errorf("failed to save paper order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(&order), nil
+}
+
+// GetOrder implements exchange.Connector
+func (c *Client) GetOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	db := c.db.WithContext(ctx)
+	account, err := c.account(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.match(ctx, db, account); err != nil {
+		return nil, err
+	}
+
+	var order models.PaperOrder
+	err = db.Where("account_id = ? AND symbol = ? AND client_order_id = ?", account.ID, symbol, clientOrderID).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", exchange.ErrOrderNotFound, clientOrderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find paper order: %w", err)
+	}
+	return toOrder(&order), nil
+}
+
+// match fills the account's resting orders against the feed's current
+// quotes. Each order is matched once per quote.
+func (c *Client) match(ctx context.Context, db *gorm.DB, account *models.PaperAccount) error {
+	var orders []models.PaperOrder
+	err := db.Where("account_id = ? AND status IN ?", account.ID, []string{exchange.OrderStatusNew, exchange.OrderStatusPartiallyFilled}).
+		Order("id").Find(&orders).Error
+	if err != nil {
+		return fmt.Errorf("failed to load open paper orders: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	// Quotes are fetched before the transaction, which must not wait on the feed
+	quotes := make(map[string]Quote)
+	for _, order := range orders {
+		if _, ok := quotes[order.Symbol]; ok {
+			continue
+		}
+		quote, err := c.cfg.Feed.Quote(ctx, order.Symbol)
+		if err != nil {
+			return err
+		}
+		quotes[order.Symbol] = quote
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, listed := range orders {
+			// Reload under lock, the order may have changed since it was listed
+			var order models.PaperOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, listed.ID).Error; err != nil {
+				return fmt.Errorf("failed to lock paper order: %w", err)
+			}
+			quote := quotes[order.Symbol]
+			if !open(&order) || (order.LastQuoteAt != nil && !quote.Time.After(*order.LastQuoteAt)) {
+				continue
+			}
+			if crosses(&order, quote, false) {
+				if err := c.fill(tx, &order, quote, false); err != nil {
+					return err
+				}
+			}
+			order.LastQuoteAt = &quote.Time
+			if err := tx.Save(&order).Error; err != nil {
+				return fmt.Errorf("failed to save paper order: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// crosses reports whether the quote reaches the order. Takers need the
+// opposite side of the book at their price, resting orders the traded range.
+func crosses(order *models.PaperOrder, quote Quote, taker bool) bool {
+	switch {
+	case order.Type == exchange.OrderTypeMarket:
+		return true
+	case order.Side == exchange.OrderSideBuy && taker:
+		return quote.Ask <= order.Price
+	case order.Side == exchange.OrderSideBuy:
+		return quote.Low <= order.Price
+	case taker:
+		return quote.Bid >= order.Price
+	default:
+		return quote.High >= order.Price
+	}
+}
+
+// reserve locks the funds an order can spend: the base quantity of a sell,
+// the quote value plus fees of a buy. Market buys are valued at the ask
+// after slippage.
+func (c *Client) reserve(tx *gorm.DB, order *models.PaperOrder, quote Quote) error {
+	currency, amount := order.Base, order.Quantity
+	if order.Side == exchange.OrderSideBuy {
+		price := order.Price
+		if order.Type == exchange.OrderTypeMarket {
+			price = c.cfg.Slippage.Price(order.Side, quote.Ask, order.Quantity, quote)
+		}
+		currency, amount = order.Quote, order.Quantity*price*(1+max(c.cfg.MakerFee, c.cfg.TakerFee))
+	}
+
+	balance, err := c.balance(tx, order.AccountID, currency)
+	if err != nil {
+		return err
+	}
+	if balance.Available+epsilon < amount {
+		return fmt.Errorf("%w: %s balance, %s available, %s needed", exchange.ErrInsufficientBalance,
+			currency, strconv.FormatFloat(balance.Available, 'f', -1, 64), strconv.FormatFloat(amount, 'f', -1, 64))
+	}
+	amount = min(amount, balance.Available)
+	balance.Available -= amount
+	balance.Reserved += amount
+	order.Reserved = amount
+	if err := tx.Save(balance).Error; err != nil {
+		return fmt.Errorf("failed to reserve paper balance: %w", err)
+	}
+	return nil
+}
+
+// fill executes part of an order against the quote and settles the balances.
+// Takers pay the taker fee at the quote's price after slippage, capped at the
+// limit price; resting orders pay the maker fee at their price.
+func (c *Client) fill(tx *gorm.DB, order *models.PaperOrder, quote Quote, taker bool) error {
+	remaining := order.Quantity - order.FilledQuantity
+	price, rate := order.Price, c.cfg.MakerFee
+	quantity := c.cfg.Fill.Fill(order.Quantity, remaining, quote)
+	if taker {
+		rate = c.cfg.TakerFee
+		best := quote.Bid
+		if order.Side == exchange.OrderSideBuy {
+			best = quote.Ask
+		}
+		price = c.cfg.Slippage.Price(order.Side, best, remaining, quote)
+		switch {
+		case order.Type == exchange.OrderTypeMarket:
+			quantity = remaining
+		case order.Side == exchange.OrderSideBuy:
+			price = min(price, order.Price)
+		default:
+			price = max(price, order.Price)
+		}
+	}
+	if order.TimeInForce == exchange.TimeInForceFOK && quantity < remaining-epsilon {
+		return nil
+	}
+	quantity = min(quantity, remaining)
+	if quantity <= epsilon {
+		return nil
+	}
+
+	// The fill frees its share of the reservation; the last fill frees the rest
+	release := order.Reserved * quantity / remaining
+	if remaining-quantity <= epsilon {
+		quantity, release = remaining, order.Reserved
+	}
+	value := price * quantity
+	fee := value * rate
+
+	base, err := c.balance(tx, order.AccountID, order.Base)
+	if err != nil {
+		return err
+	}
+	quoteBalance, err := c.balance(tx, order.AccountID, order.Quote)
+	if err != nil {
+		return err
+	}
+	if order.Side == exchange.OrderSideBuy {
+		quoteBalance.Reserved -= release
+		quoteBalance.Available += release - value - fee
+		base.Available += quantity
+	} else {
+		base.Reserved -= release
+		quoteBalance.Available += value - fee
+	}
+	if err := tx.Save(base).Error; err != nil {
+		return fmt.Errorf("failed to settle paper balance: %w", err)
+	}
+	if err := tx.Save(quoteBalance).Error; err != nil {
+		return fmt.Errorf("failed to settle paper balance: %w", err)
+	}
+
+	order.Reserved -= release
+	order.FilledQuantity += quantity
+	order.FilledQuote += value
+	order.Fee += fee
+	order.Status = exchange.OrderStatusPartiallyFilled
+	if order.Quantity-order.FilledQuantity <= epsilon {
+		order.Status = exchange.OrderStatusFilled
+	}
+	return nil
+}
+
+// close ends an open order and returns its reservation to the balance
+func (c *Client) close(tx *gorm.DB, order *models.PaperOrder, status string) error {
+	currency := order.Base
+	if order.Side == exchange.OrderSideBuy {
+		currency = order.Quote
+	}
+	balance, err := c.balance(tx, order.AccountID, currency)
+	if err != nil {
+		return err
+	}
+	balance.Reserved -= order.Reserved
+	balance.Available += order.Reserved
+	if err := tx.Save(balance).Error; err != nil {
+		return fmt.Errorf("failed to release paper balance: %w", err)
+	}
+	order.Reserved = 0
+	order.Status = status
+	return nil
+}
+
+// balance locks the account's balance of a currency, creating it when missing
+func (c *Client) balance(tx *gorm.DB, accountID uint, currency string) (*models.PaperBalance, error) {
+	balance := &models.PaperBalance{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("account_id = ? AND currency = ?", accountID, currency).
+		First(balance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		balance = &models.PaperBalance{AccountID: accountID, Currency: currency}
+		err = tx.Create(balance).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load paper balance: %w", err)
+	}
+	return balance, nil
+}
+
+func open(order *models.PaperOrder) bool {
+	return order.Status == exchange.OrderStatusNew || order.Status == exchange.OrderStatusPartiallyFilled
+}
+
+func toOrder(o *models.PaperOrder) *exchange.Order {
+	order := &exchange.Order{
+		ID:             strconv.FormatUint(uint64(o.ID), 10),
+		ClientOrderID:  o.ClientOrderID,
+		Symbol:         o.Symbol,
+		Side:           o.Side,
+		Type:           o.Type,
+		Status:         o.Status,
+		TimeInForce:    o.TimeInForce,
+		Price:          o.Price,
+		Quantity:       o.Quantity,
+		FilledQuantity: o.FilledQuantity,
+		CreatedAt:      o.CreatedAt.UTC(),
+		UpdatedAt:      o.UpdatedAt.UTC(),
+	}
+	if o.FilledQuantity > 0 {
+		order.AveragePrice = o.FilledQuote / o.FilledQuantity
+	}
+	return order
+}
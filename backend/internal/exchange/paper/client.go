@@ -0,0 +1,227 @@
+// Package paper implements a simulated exchange for paper trading. Balances
+// and orders are virtual and kept in the database; orders fill against a
+// price feed, either the live tickers of a real exchange or replayed candles.
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"trader/internal/exchange"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Code is the models.Exchange code of the paper exchange
+const Code = "paper"
+
+// errNoMarket is returned for market data the paper exchange has no source for
+var errNoMarket = errors.New("paper exchange has no market data source")
+
+// Config configures the paper exchange
+type Config struct {
+	// Market provides symbols and market data; it may be nil when Symbols is set
+	Market exchange.Connector
+	// Symbols replaces the symbols of Market, e.g. for candle replays
+	Symbols []exchange.Symbol
+	// Feed provides the quotes orders fill against
+	Feed PriceFeed
+	// InitialBalances are credited to new accounts, by currency
+	InitialBalances map[string]float64
+	// MakerFee and TakerFee are fractions of the traded value, charged in the
+	// quote currency
+	MakerFee float64
+	TakerFee float64
+	// Slippage defaults to none, Fill to FullFill
+	Slippage SlippageModel
+	Fill     FillModel
+}
+
+// Client is a connector to the paper exchange. It acts on the account of the
+// stored API key its credentials belong to.
+type Client struct {
+	db          *gorm.DB
+	credentials *exchange.Credentials
+	cfg         Config
+
+	mu      sync.Mutex
+	symbols map[string]exchange.Symbol
+}
+
+func New(db *gorm.DB, credentials *exchange.Credentials, cfg Config) *Client {
+	if cfg.Slippage == nil {
+		cfg.Slippage = FixedSlippage(0)
+	}
+	if cfg.Fill == nil {
+		cfg.Fill = FullFill{}
+	}
+	return &Client{db: db, credentials: credentials, cfg: cfg}
+}
+
+// ParseBalances parses starting balances such as "USDT:10000,BTC:0.5"
+func ParseBalances(value string) (map[string]float64, error) {
+	balances := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, amount, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid paper balance %q, expected CURRENCY:AMOUNT", entry)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid paper balance %q, expected CURRENCY:AMOUNT", entry)
+		}
+		balances[strings.ToUpper(strings.TrimSpace(currency))] = parsed
+	}
+	return balances, nil
+}
+
+// Capabilities implements exchange.Connector. Paper accounts can read and
+// trade but never withdraw.
+func (c *Client) Capabilities(ctx context.Context) (*exchange.Capabilities, error) {
+	if _, err := c.account(c.db.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+	return &exchange.Capabilities{Read: true, Trade: true}, nil
+}
+
+// account finds the account of the stored key and opens it on first use.
+// Accounts are not shared between keys, even when they were given the same
+// credentials.
+func (c *Client) account(db *gorm.DB) (*models.PaperAccount, error) {
+	if c.credentials == nil || c.credentials.KeyID == 0 || c.credentials.OrganizationID == 0 {
+		return nil, fmt.Errorf("%w: a stored api key is required", exchange.ErrAuthFailed)
+	}
+	organizationID, keyID := c.credentials.OrganizationID, c.credentials.KeyID
+
+	var account models.PaperAccount
+	err := db.Where("organization_id = ? AND api_key_id = ?", organizationID, keyID).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		account = models.PaperAccount{OrganizationID: organizationID, APIKeyID: keyID}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&account).Error; err != nil {
+				return err
+			}
+			for currency, amount := range c.cfg.InitialBalances {
+				balance := models.PaperBalance{AccountID: account.ID, Currency: currency, Available: amount}
+				if err := tx.Create(&balance).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			// A concurrent first request may have opened the account
+			if lookupErr := db.Where("organization_id = ? AND api_key_id = ?", organizationID, keyID).First(&account).Error; lookupErr != nil {
+				return nil, fmt.Errorf("failed to open paper account: %w", err)
+			}
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find paper account: %w", err)
+	}
+	return &account, nil
+}
+
+// Symbols implements exchange.Connector with the configured symbols or those
+// of the market, carrying the paper fees
+func (c *Client) Symbols(ctx context.Context) ([]exchange.Symbol, error) {
+	symbols, err := c.loadSymbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]exchange.Symbol, 0, len(symbols))
+	for _, symbol := range symbols {
+		result = append(result, symbol)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	return result, nil
+}
+
+func (c *Client) loadSymbols(ctx context.Context) (map[string]exchange.Symbol, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.symbols != nil {
+		return c.symbols, nil
+	}
+
+	symbols := c.cfg.Symbols
+	if symbols == nil {
+		if c.cfg.Market == nil {
+			return nil, errNoMarket
+		}
+		var err error
+		if symbols, err = c.cfg.Market.Symbols(ctx); err != nil {
+			return nil, err
+		}
+	}
+	c.symbols = make(map[string]exchange.Symbol, len(symbols))
+	for _, symbol := range symbols {
+		symbol.MakerFee = c.cfg.MakerFee
+		symbol.TakerFee = c.cfg.TakerFee
+		symbol.FeeCurrency = symbol.Quote
+		c.symbols[symbol.Symbol] = symbol
+	}
+	return c.symbols, nil
+}
+
+func (c *Client) symbol(ctx context.Context, name string) (exchange.Symbol, error) {
+	symbols, err := c.loadSymbols(ctx)
+	if err != nil {
+		return exchange.Symbol{}, err
+	}
+	symbol, ok := symbols[name]
+	if !ok {
+		return exchange.Symbol{}, fmt.Errorf("%w: unknown symbol %s", exchange.ErrInvalidSymbol, name)
+	}
+	return symbol, nil
+}
+
+// Ticker implements exchange.Connector with the feed's current quote, which
+// is what orders fill against
+func (c *Client) Ticker(ctx context.Context, symbol string) (*exchange.Ticker, error) {
+	quote, err := c.cfg.Feed.Quote(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &exchange.Ticker{
+		Symbol:    symbol,
+		Bid:       quote.Bid,
+		Ask:       quote.Ask,
+		Last:      quote.Last,
+		Timestamp: quote.Time,
+	}, nil
+}
+
+// Tickers implements exchange.Connector from the market
+func (c *Client) Tickers(ctx context.Context) ([]exchange.Ticker, error) {
+	if c.cfg.Market == nil {
+		return nil, errNoMarket
+	}
+	return c.cfg.Market.Tickers(ctx)
+}
+
+// OrderBook implements exchange.Connector from the market. Paper orders are
+// not part of it.
+func (c *Client) OrderBook(ctx context.Context, symbol string, depth int) (*exchange.OrderBook, error) {
+	if c.cfg.Market == nil {
+		return nil, errNoMarket
+	}
+	return c.cfg.Market.OrderBook(ctx, symbol, depth)
+}
+
+// Candles implements exchange.Connector from the market
+func (c *Client) Candles(ctx context.Context, symbol string, query exchange.CandleQuery) ([]exchange.Candle, error) {
+	if c.cfg.Market == nil {
+		return nil, errNoMarket
+	}
+	return c.cfg.Market.Candles(ctx, symbol, query)
+}
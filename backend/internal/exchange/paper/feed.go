@@ -0,0 +1,121 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// Quote is the market state orders are matched against. Market orders and
+// orders that cross on placement take Ask or Bid; resting limit orders fill
+// once the traded range reaches them: buys when Low drops to their price,
+// sells when High rises to it.
+type Quote struct {
+	Symbol string
+	Bid    float64
+	Ask    float64
+	Last   float64
+	Low    float64
+	High   float64
+	// Volume traded in base currency since the previous quote, if known
+	Volume float64
+	Time   time.Time
+}
+
+// PriceFeed provides the quotes paper orders fill against. A resting order is
+// matched once per quote, so a feed should move Time forward with each new
+// quote.
+type PriceFeed interface {
+	Quote(ctx context.Context, symbol string) (Quote, error)
+}
+
+// TickerFeed quotes the live tickers of a real exchange. A ticker has no
+// traded range, so a resting buy fills when the ask reaches it and a sell when
+// the bid does.
+type TickerFeed struct {
+	market exchange.Connector
+	now    func() time.Time
+}
+
+// NewTickerFeed quotes the tickers of market
+func NewTickerFeed(market exchange.Connector) *TickerFeed {
+	return &TickerFeed{market: market, now: time.Now}
+}
+
+// Quote implements PriceFeed
+func (f *TickerFeed) Quote(ctx context.Context, symbol string) (Quote, error) {
+	ticker, err := f.market.Ticker(ctx, symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	if ticker.Bid <= 0 || ticker.Ask <= 0 {
+		return Quote{}, fmt.Errorf("%w: %s has no bid or ask", exchange.ErrInvalidOrder, symbol)
+	}
+	timestamp := ticker.Timestamp
+	if timestamp.IsZero() {
+		timestamp = f.now()
+	}
+	return Quote{
+		Symbol: symbol,
+		Bid:    ticker.Bid,
+		Ask:    ticker.Ask,
+		Last:   ticker.Last,
+		Low:    ticker.Ask,
+		High:   ticker.Bid,
+		Time:   timestamp,
+	}, nil
+}
+
+// CandleFeed replays recorded candles, e.g. for backtests. All symbols step
+// through their candles together, so the candles of each symbol must cover
+// the same periods. Orders placed during a candle take its close; resting
+// orders fill within its low and high.
+type CandleFeed struct {
+	mu      sync.Mutex
+	candles map[string][]exchange.Candle
+	index   int
+}
+
+// NewCandleFeed replays candles by symbol, starting at the first candle
+func NewCandleFeed(candles map[string][]exchange.Candle) *CandleFeed {
+	return &CandleFeed{candles: candles}
+}
+
+// Step moves to the next candle. It returns false once the candles are used up.
+func (f *CandleFeed) Step() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, candles := range f.candles {
+		if f.index+1 >= len(candles) {
+			return false
+		}
+	}
+	f.index++
+	return true
+}
+
+// Quote implements PriceFeed
+func (f *CandleFeed) Quote(_ context.Context, symbol string) (Quote, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	candles := f.candles[symbol]
+	if f.index >= len(candles) {
+		return Quote{}, fmt.Errorf("%w: no candles for %s", exchange.ErrInvalidOrder, symbol)
+	}
+	candle := candles[f.index]
+	return Quote{
+		Symbol: symbol,
+		Bid:    candle.Close,
+		Ask:    candle.Close,
+		Last:   candle.Close,
+		Low:    candle.Low,
+		High:   candle.High,
+		Volume: candle.Volume,
+		Time:   candle.OpenTime,
+	}, nil
+}
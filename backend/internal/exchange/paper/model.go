@@ -0,0 +1,58 @@
+package paper
+
+import "trader/internal/exchange"
+
+// FillModel decides how much of a resting limit order fills against one
+// quote. Market orders always fill completely.
+type FillModel interface {
+	// Fill returns the quantity to fill, given the order's quantity and what
+	// is left of it
+	Fill(quantity, remaining float64, quote Quote) float64
+}
+
+// FullFill fills orders completely as soon as the price reaches them
+type FullFill struct{}
+
+// Fill implements FillModel
+func (FullFill) Fill(_, remaining float64, _ Quote) float64 {
+	return remaining
+}
+
+// RatioFill fills at most this share of an order's quantity per quote, so an
+// order of 1 BTC with RatioFill(0.25) needs four quotes to fill
+type RatioFill float64
+
+// Fill implements FillModel
+func (r RatioFill) Fill(quantity, remaining float64, _ Quote) float64 {
+	if r <= 0 || r >= 1 {
+		return remaining
+	}
+	return min(remaining, float64(r)*quantity)
+}
+
+// VolumeFill fills at most this share of the volume traded since the previous
+// quote. It needs a feed that reports volume, such as CandleFeed.
+type VolumeFill float64
+
+// Fill implements FillModel
+func (v VolumeFill) Fill(_, remaining float64, quote Quote) float64 {
+	return min(remaining, float64(v)*quote.Volume)
+}
+
+// SlippageModel sets the price an order gets when it takes liquidity. Resting
+// limit orders fill at their price.
+type SlippageModel interface {
+	Price(side string, price, quantity float64, quote Quote) float64
+}
+
+// FixedSlippage moves the price against the taker by a fixed fraction:
+// FixedSlippage(0.001) buys 0.1% above the ask and sells 0.1% below the bid
+type FixedSlippage float64
+
+// Price implements SlippageModel
+func (s FixedSlippage) Price(side string, price, _ float64, _ Quote) float64 {
+	if side == exchange.OrderSideBuy {
+		return price * (1 + float64(s))
+	}
+	return price * (1 - float64(s))
+}
@@ -0,0 +1,92 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states reported by Health
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// breaker opens after a run of consecutive failures and rejects calls for a
+// cooldown. Afterwards it lets one trial call through: a success closes it,
+// a failure opens it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration, now func() time.Time) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: now}
+}
+
+// allow reports whether a call may go ahead. After the cooldown only one
+// trial call is allowed until it reports back.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// report records the outcome of an allowed call; failed is true only for
+// failures that say something about the exchange's health. It returns true
+// when the call opened the breaker.
+func (b *breaker) report(failed bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return false
+	}
+	b.failures++
+	if !b.openUntil.IsZero() || b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		return true
+	}
+	return false
+}
+
+// release gives up a trial whose call was not made, e.g. because it was cancelled
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) health(key string) BreakerHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := BreakerHealth{Key: key, State: StateClosed, Failures: b.failures}
+	switch {
+	case b.openUntil.IsZero():
+	case b.now().Before(b.openUntil):
+		openUntil := b.openUntil
+		health.State = StateOpen
+		health.OpenUntil = &openUntil
+	default:
+		health.State = StateHalfOpen
+	}
+	return health
+}
@@ -0,0 +1,102 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+)
+
+// Limit is an exchange's request-weight allowance
+type Limit struct {
+	Weight int
+	Per    time.Duration
+}
+
+// tokenBucket holds up to a limit's weight in tokens and refills them evenly
+// over its period. Callers that find too few tokens reserve them anyway and
+// wait until the debt is repaid, so waiting callers are served in order.
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // tokens per second
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit Limit, now func() time.Time) *tokenBucket {
+	capacity := float64(limit.Weight)
+	return &tokenBucket{
+		capacity: capacity,
+		rate:     capacity / limit.Per.Seconds(),
+		now:      now,
+		tokens:   capacity,
+		last:     now(),
+	}
+}
+
+// wait takes weight tokens, waiting at most maxWait for them. It fails with
+// exchange.ErrRateLimited rather than wait longer or past ctx's deadline.
+func (b *tokenBucket) wait(ctx context.Context, weight int, maxWait time.Duration) error {
+	n := min(float64(weight), b.capacity)
+
+	b.mu.Lock()
+	now := b.now()
+	b.refill(now)
+	var delay time.Duration
+	if b.tokens < n {
+		delay = time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+		if delay > maxWait {
+			b.mu.Unlock()
+			return fmt.Errorf("%w: weight %d needs a %s wait", exchange.ErrRateLimited, weight, delay.Round(time.Millisecond))
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+			b.mu.Unlock()
+			return fmt.Errorf("%w: weight %d is not available before the deadline", exchange.ErrRateLimited, weight)
+		}
+	}
+	b.tokens -= n
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		b.mu.Lock()
+		b.tokens += n
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// available returns the tokens left, negative while callers wait
+func (b *tokenBucket) available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.now())
+	return b.tokens
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,647 @@
+// Package resilience wraps exchange connectors in a middleware chain that
+// keeps them within the exchanges' limits and stops calling an exchange that
+// is down. Every call passes, in order, a circuit breaker per exchange and
+// key, retries with jitter for idempotent calls, clock-skew correction for
+// signed calls and a token bucket per exchange charged with the endpoint's
+// weight.
+package resilience
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/binance"
+	"trader/internal/exchange/hitbtc"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrCircuitOpen = errors.New("exchange circuit breaker is open")
+
+// Operations, named after the connector methods
+const (
+	OpCapabilities = "capabilities"
+	OpSymbols      = "symbols"
+	OpTicker       = "ticker"
+	OpTickers      = "tickers"
+	OpOrderBook    = "order_book"
+	OpCandles      = "candles"
+	OpBalances     = "balances"
+	OpFees         = "fees"
+	OpPlaceOrder   = "place_order"
+	OpCancelOrder  = "cancel_order"
+	OpGetOrder     = "get_order"
+)
+
+// Exchange states reported by Health
+const (
+	StateHealthy     = "healthy"
+	StateDegraded    = "degraded"
+	StateUnavailable = "unavailable"
+)
+
+// PublicKey is the breaker key of calls without credentials
+const PublicKey = "public"
+
+// DefaultLimits are the exchanges' documented request-weight limits, by code.
+// Exchanges without one are not limited.
+var DefaultLimits = map[string]Limit{
+	binance.Code: {Weight: 6000, Per: time.Minute},
+	hitbtc.Code:  {Weight: 20, Per: time.Second},
+}
+
+// DefaultWeights are the request weights of the operations, by exchange code.
+// Operations without one weigh 1.
+var DefaultWeights = map[string]map[string]int{
+	binance.Code: {
+		OpSymbols:   20,
+		OpTicker:    2,
+		OpTickers:   80,
+		OpOrderBook: 5,
+		OpCandles:   2,
+		OpBalances:  20,
+		OpGetOrder:  4,
+		// Fees reads the account, its VIP level and its BNB setting
+		OpFees: 22,
+	},
+	hitbtc.Code: {
+		// Capabilities probes the trading and withdrawal endpoints too
+		OpCapabilities: 3,
+	},
+}
+
+// signedOperations carry a timestamp the exchange checks against its clock
+var signedOperations = map[string]bool{
+	OpCapabilities: true,
+	OpBalances:     true,
+	OpFees:         true,
+	OpPlaceOrder:   true,
+	OpCancelOrder:  true,
+	OpGetOrder:     true,
+}
+
+type Config struct {
+	// Limits and Weights default to DefaultLimits and DefaultWeights
+	Limits  map[string]Limit
+	Weights map[string]map[string]int
+	// RetryAttempts is how often an idempotent call is tried in all. Retries
+	// wait a random delay up to RetryBaseDelay, doubling per attempt up to
+	// RetryMaxDelay.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// BreakerThreshold consecutive failures open a breaker for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MaxWait is the longest a call waits for rate limit budget
+	MaxWait time.Duration
+	// ClockSyncInterval is how often signed calls re-read the exchange's clock
+	ClockSyncInterval time.Duration
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Health is the state of one exchange's calls
+type Health struct {
+	Exchange string `json:"exchange"`
+	BaseURL  string `json:"base_url"`
+	// State is unavailable when every breaker is open, degraded when some are
+	State         string           `json:"state"`
+	Breakers      []BreakerHealth  `json:"breakers"`
+	RateLimit     *RateLimitHealth `json:"rate_limit,omitempty"`
+	ClockOffsetMs int64            `json:"clock_offset_ms"`
+	ClockSyncedAt *time.Time       `json:"clock_synced_at,omitempty"`
+}
+
+// BreakerHealth is the state of the breaker of one key
+type BreakerHealth struct {
+	Key       string     `json:"key"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// RateLimitHealth is the weight left in an exchange's token bucket
+type RateLimitHealth struct {
+	Available float64 `json:"available"`
+	Capacity  int     `json:"capacity"`
+}
+
+// Registry holds the limiters, breakers and clocks of the exchanges, shared
+// by all connectors it wraps. Exchanges are told apart by code and base URL.
+// It is safe for concurrent use.
+type Registry struct {
+	cfg Config
+
+	mu        sync.Mutex
+	exchanges map[string]*exchangeState
+}
+
+type exchangeState struct {
+	code    string
+	baseURL string
+	limiter *tokenBucket
+	clock   *exchange.Clock
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	// syncedAt is the last clock sync attempt, clockSynced the last success
+	syncedAt    time.Time
+	clockSynced time.Time
+}
+
+func NewRegistry(cfg Config) *Registry {
+	if cfg.Limits == nil {
+		cfg.Limits = DefaultLimits
+	}
+	if cfg.Weights == nil {
+		cfg.Weights = DefaultWeights
+	}
+	if cfg.RetryAttempts <= 0 {
+		cfg.RetryAttempts = 3
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = 250 * time.Millisecond
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		cfg.RetryMaxDelay = 20 * cfg.RetryBaseDelay
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = 5
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = 30 * time.Second
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = 10 * time.Second
+	}
+	if cfg.ClockSyncInterval <= 0 {
+		cfg.ClockSyncInterval = 10 * time.Minute
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Registry{cfg: cfg, exchanges: make(map[string]*exchangeState)}
+}
+
+// KeyLabel identifies credentials in breaker keys and health without
+// revealing them
+func KeyLabel(credentials *exchange.Credentials) string {
+	if credentials == nil || credentials.APIKey == "" {
+		return PublicKey
+	}
+	sum := sha256.Sum256([]byte(credentials.APIKey))
+	return "key:" + hex.EncodeToString(sum[:4])
+}
+
+// Clock returns the clock connectors of the exchange stamp signed requests with
+func (r *Registry) Clock(code, baseURL string) *exchange.Clock {
+	return r.exchange(code, baseURL).clock
+}
+
+func (r *Registry) exchange(code, baseURL string) *exchangeState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scope := code + " " + baseURL
+	state, ok := r.exchanges[scope]
+	if !ok {
+		state = &exchangeState{
+			code:     code,
+			baseURL:  baseURL,
+			clock:    exchange.NewClock(r.cfg.Now),
+			breakers: make(map[string]*breaker),
+		}
+		if limit, ok := r.cfg.Limits[code]; ok && limit.Weight > 0 && limit.Per > 0 {
+			state.limiter = newTokenBucket(limit, r.cfg.Now)
+		}
+		r.exchanges[scope] = state
+	}
+	return state
+}
+
+func (s *exchangeState) breaker(key string, cfg Config) *breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[key]
+	if !ok {
+		b = newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, cfg.Now)
+		s.breakers[key] = b
+	}
+	return b
+}
+
+// Wrap puts the connector of the exchange at baseURL behind the middleware
+// chain, with the breaker of key. Streams of a connector that implements
+// exchange.Streamer bypass the chain.
+func (r *Registry) Wrap(connector exchange.Connector, code, baseURL, key string) exchange.Connector {
+	state := r.exchange(code, baseURL)
+	wrapped := &Connector{
+		next:     connector,
+		code:     code,
+		registry: r,
+	}
+	wrapped.middlewares = []middleware{
+		breakerMiddleware(state.breaker(key, r.cfg), code, key),
+		retryMiddleware(r.cfg),
+		clockMiddleware(state, connector, r.cfg),
+		limiterMiddleware(state, connector, r.cfg),
+	}
+
+	if streamer, ok := connector.(exchange.Streamer); ok {
+		return &streamingConnector{Connector: wrapped, streamer: streamer}
+	}
+	return wrapped
+}
+
+// Health returns the state of every exchange the registry has wrapped a
+// connector for
+func (r *Registry) Health() []Health {
+	r.mu.Lock()
+	states := make([]*exchangeState, 0, len(r.exchanges))
+	for _, state := range r.exchanges {
+		states = append(states, state)
+	}
+	r.mu.Unlock()
+
+	health := make([]Health, 0, len(states))
+	for _, state := range states {
+		health = append(health, state.health())
+	}
+	sort.Slice(health, func(i, j int) bool {
+		if health[i].Exchange != health[j].Exchange {
+			return health[i].Exchange < health[j].Exchange
+		}
+		return health[i].BaseURL < health[j].BaseURL
+	})
+	return health
+}
+
+func (s *exchangeState) health() Health {
+	s.mu.Lock()
+	health := Health{
+		Exchange:      s.code,
+		BaseURL:       s.baseURL,
+		State:         StateHealthy,
+		Breakers:      make([]BreakerHealth, 0, len(s.breakers)),
+		ClockOffsetMs: s.clock.Offset().Milliseconds(),
+	}
+	if !s.clockSynced.IsZero() {
+		synced := s.clockSynced
+		health.ClockSyncedAt = &synced
+	}
+	for key, b := range s.breakers {
+		health.Breakers = append(health.Breakers, b.health(key))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(health.Breakers, func(i, j int) bool { return health.Breakers[i].Key < health.Breakers[j].Key })
+	open := 0
+	for _, b := range health.Breakers {
+		if b.State != StateClosed {
+			open++
+		}
+	}
+	switch {
+	case open > 0 && open == len(health.Breakers):
+		health.State = StateUnavailable
+	case open > 0:
+		health.State = StateDegraded
+	}
+	if s.limiter != nil {
+		health.RateLimit = &RateLimitHealth{Available: s.limiter.available(), Capacity: int(s.limiter.capacity)}
+	}
+	return health
+}
+
+// call describes one connector call to the middlewares
+type call struct {
+	operation string
+	weight    int
+	// idempotent calls may be retried after a failure
+	idempotent bool
+	// signed calls carry a timestamp the exchange checks
+	signed bool
+}
+
+type handler func(ctx context.Context, c *call) error
+
+type middleware func(next handler) handler
+
+// breakerMiddleware fails fast while the breaker is open. Only failures that
+// say the exchange is unwell count; a rejected order is a healthy answer.
+func breakerMiddleware(b *breaker, code, key string) middleware {
+	return func(next handler) handler {
+		return func(ctx context.Context, c *call) error {
+			if !b.allow() {
+				return fmt.Errorf("%w: %s (%s)", ErrCircuitOpen, code, key)
+			}
+			err := next(ctx, c)
+			if err != nil && ctx.Err() != nil {
+				b.release()
+				return err
+			}
+			if b.report(failure(err)) {
+				log.Warn().Err(err).Str("exchange", code).Str("key", key).Msg("Exchange circuit breaker opened")
+			}
+			return err
+		}
+	}
+}
+
+// retryMiddleware retries idempotent calls after transient errors, waiting a
+// random delay up to an exponentially growing cap ("full jitter")
+func retryMiddleware(cfg Config) middleware {
+	return func(next handler) handler {
+		return func(ctx context.Context, c *call) error {
+			for attempt := 1; ; attempt++ {
+				err := next(ctx, c)
+				if err == nil || !c.idempotent || attempt >= cfg.RetryAttempts || !retryable(err) || ctx.Err() != nil {
+					return err
+				}
+
+				ceiling := min(cfg.RetryBaseDelay<<(attempt-1), cfg.RetryMaxDelay)
+				delay := rand.N(ceiling) + 1
+				log.Debug().Err(err).Str("operation", c.operation).Int("attempt", attempt).Dur("delay", delay).Msg("Retrying exchange call")
+				if sleep(ctx, delay) != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// clockMiddleware keeps the exchange's clock offset current for signed
+// calls. A call the exchange rejects for its timestamp was not executed, so
+// it is repeated once after a fresh sync, whether idempotent or not.
+func clockMiddleware(state *exchangeState, connector exchange.Connector, cfg Config) middleware {
+	timer, ok := connector.(exchange.ServerTimer)
+	return func(next handler) handler {
+		if !ok {
+			return next
+		}
+		return func(ctx context.Context, c *call) error {
+			if !c.signed {
+				return next(ctx, c)
+			}
+			state.syncClock(ctx, timer, cfg, false)
+			err := next(ctx, c)
+			if errors.Is(err, exchange.ErrClockSkew) {
+				state.syncClock(ctx, timer, cfg, true)
+				err = next(ctx, c)
+			}
+			return err
+		}
+	}
+}
+
+// syncClock reads the exchange's clock when the last attempt is older than
+// the sync interval, or when forced. A failed sync keeps the old offset.
+func (s *exchangeState) syncClock(ctx context.Context, timer exchange.ServerTimer, cfg Config, force bool) {
+	s.mu.Lock()
+	now := cfg.Now()
+	if !force && !s.syncedAt.IsZero() && now.Sub(s.syncedAt) < cfg.ClockSyncInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.syncedAt = now
+	s.mu.Unlock()
+
+	if s.limiter != nil {
+		if err := s.limiter.wait(ctx, 1, cfg.MaxWait); err != nil {
+			return
+		}
+	}
+	sent := cfg.Now()
+	serverTime, err := timer.ServerTime(ctx)
+	received := cfg.Now()
+	if err != nil {
+		log.Warn().Err(err).Str("exchange", s.code).Msg("Failed to read exchange clock")
+		return
+	}
+	s.clock.Observe(serverTime, sent, received)
+
+	s.mu.Lock()
+	s.clockSynced = received
+	s.mu.Unlock()
+	log.Debug().Str("exchange", s.code).Dur("offset", s.clock.Offset()).Msg("Exchange clock synced")
+}
+
+// limiterMiddleware takes the call's weight from the exchange's token bucket.
+// Connectors that track the weight the exchange reports also wait for the
+// exchange's window to reset when it is used up.
+func limiterMiddleware(state *exchangeState, connector exchange.Connector, cfg Config) middleware {
+	limited, _ := connector.(exchange.RateLimited)
+	return func(next handler) handler {
+		return func(ctx context.Context, c *call) error {
+			if state.limiter != nil {
+				if err := state.limiter.wait(ctx, c.weight, cfg.MaxWait); err != nil {
+					return err
+				}
+			}
+			if limited != nil {
+				usage := limited.RateLimitUsage()
+				if usage.Limit > 0 && usage.Used+c.weight > usage.Limit {
+					delay := usage.ResetAt.Sub(cfg.Now())
+					if delay > cfg.MaxWait {
+						return fmt.Errorf("%w: the exchange's window resets at %s", exchange.ErrRateLimited, usage.ResetAt.Format(time.RFC3339))
+					}
+					if delay > 0 {
+						if err := sleep(ctx, delay); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return next(ctx, c)
+		}
+	}
+}
+
+// statusError is implemented by the API errors of the connectors
+type statusError interface {
+	HTTPStatus() int
+}
+
+// retryable reports whether an error may go away on its own: network errors,
+// throttling and server errors. Answers such as a rejected order or unknown
+// credentials will not.
+func retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var status statusError
+	if errors.As(err, &status) && status.HTTPStatus() != 0 {
+		code := status.HTTPStatus()
+		return code == 429 || code == 418 || code >= 500
+	}
+	for _, permanent := range []error{
+		ErrCircuitOpen,
+		exchange.ErrRateLimited,
+		exchange.ErrAuthFailed,
+		exchange.ErrInvalidOrder,
+		exchange.ErrOrderNotFound,
+		exchange.ErrUnsupportedExchange,
+		exchange.ErrClockSkew,
+		exchange.ErrUnknownOrderStatus,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// failure reports whether an error counts against the breaker: transient
+// errors other than throttling, which the limiters handle
+func failure(err error) bool {
+	var status statusError
+	if errors.As(err, &status) && (status.HTTPStatus() == 429 || status.HTTPStatus() == 418) {
+		return false
+	}
+	return retryable(err)
+}
+
+// Connector is a connector behind the middleware chain
+type Connector struct {
+	next        exchange.Connector
+	code        string
+	registry    *Registry
+	middlewares []middleware
+}
+
+// streamingConnector also passes streams through
+type streamingConnector struct {
+	*Connector
+	streamer exchange.Streamer
+}
+
+// Stream implements exchange.Streamer
+func (c *streamingConnector) Stream(ctx context.Context, sub exchange.Subscription) (<-chan exchange.StreamEvent, error) {
+	return c.streamer.Stream(ctx, sub)
+}
+
+// Unwrap returns the wrapped connector
+func (c *Connector) Unwrap() exchange.Connector {
+	return c.next
+}
+
+// RateLimitUsage implements exchange.RateLimited; connectors that do not
+// track usage report none
+func (c *Connector) RateLimitUsage() exchange.RateLimitUsage {
+	if limited, ok := c.next.(exchange.RateLimited); ok {
+		return limited.RateLimitUsage()
+	}
+	return exchange.RateLimitUsage{}
+}
+
+func (c *Connector) newCall(operation string) *call {
+	weight, ok := c.registry.cfg.Weights[c.code][operation]
+	if !ok {
+		weight = 1
+	}
+	return &call{
+		operation:  operation,
+		weight:     weight,
+		idempotent: operation != OpPlaceOrder,
+		signed:     signedOperations[operation],
+	}
+}
+
+// run sends fn through the middleware chain
+func run[T any](ctx context.Context, c *Connector, operation string, fn func(context.Context) (T, error)) (T, error) {
+	var result T
+	h := handler(func(ctx context.Context, _ *call) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	err := h(ctx, c.newCall(operation))
+	return result, err
+}
+
+// Capabilities implements exchange.Connector
+func (c *Connector) Capabilities(ctx context.Context) (*exchange.Capabilities, error) {
+	return run(ctx, c, OpCapabilities, c.next.Capabilities)
+}
+
+// Symbols implements exchange.Connector
+func (c *Connector) Symbols(ctx context.Context) ([]exchange.Symbol, error) {
+	return run(ctx, c, OpSymbols, c.next.Symbols)
+}
+
+// Ticker implements exchange.Connector
+func (c *Connector) Ticker(ctx context.Context, symbol string) (*exchange.Ticker, error) {
+	return run(ctx, c, OpTicker, func(ctx context.Context) (*exchange.Ticker, error) {
+		return c.next.Ticker(ctx, symbol)
+	})
+}
+
+// Tickers implements exchange.Connector
+func (c *Connector) Tickers(ctx context.Context) ([]exchange.Ticker, error) {
+	return run(ctx, c, OpTickers, c.next.Tickers)
+}
+
+// OrderBook implements exchange.Connector
+func (c *Connector) OrderBook(ctx context.Context, symbol string, depth int) (*exchange.OrderBook, error) {
+	return run(ctx, c, OpOrderBook, func(ctx context.Context) (*exchange.OrderBook, error) {
+		return c.next.OrderBook(ctx, symbol, depth)
+	})
+}
+
+// Candles implements exchange.Connector
+func (c *Connector) Candles(ctx context.Context, symbol string, query exchange.CandleQuery) ([]exchange.Candle, error) {
+	return run(ctx, c, OpCandles, func(ctx context.Context) ([]exchange.Candle, error) {
+		return c.next.Candles(ctx, symbol, query)
+	})
+}
+
+// Balances implements exchange.Connector
+func (c *Connector) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	return run(ctx, c, OpBalances, c.next.Balances)
+}
+
+// Fees implements exchange.FeeReporter; connectors that do not read the
+// account's fees report none
+func (c *Connector) Fees(ctx context.Context) (*exchange.AccountFees, error) {
+	reporter, ok := c.next.(exchange.FeeReporter)
+	if !ok {
+		return nil, nil
+	}
+	return run(ctx, c, OpFees, reporter.Fees)
+}
+
+// PlaceOrder implements exchange.Connector. It is never retried: a request
+// that timed out may still have placed the order.
+func (c *Connector) PlaceOrder(ctx context.Context, req *exchange.OrderRequest) (*exchange.Order, error) {
+	return run(ctx, c, OpPlaceOrder, func(ctx context.Context) (*exchange.Order, error) {
+		return c.next.PlaceOrder(ctx, req)
+	})
+}
+
+// CancelOrder implements exchange.Connector
+func (c *Connector) CancelOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	return run(ctx, c, OpCancelOrder, func(ctx context.Context) (*exchange.Order, error) {
+		return c.next.CancelOrder(ctx, symbol, clientOrderID)
+	})
+}
+
+// GetOrder implements exchange.Connector
+func (c *Connector) GetOrder(ctx context.Context, symbol, clientOrderID string) (*exchange.Order, error) {
+	return run(ctx, c, OpGetOrder, func(ctx context.Context) (*exchange.Order, error) {
+		return c.next.GetOrder(ctx, symbol, clientOrderID)
+	})
+}
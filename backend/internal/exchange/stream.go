@@ -0,0 +1,210 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// minReconnectDelay is the first backoff after a connection is lost
+const minReconnectDelay = 500 * time.Millisecond
+
+// Streamer is implemented by connectors that push updates over a persistent
+// connection. Callers type-assert a Connector to find out.
+type Streamer interface {
+	// Stream delivers the subscribed updates until ctx is cancelled, then
+	// closes the channel. Connections are re-established and the subscriptions
+	// restored on their own; a StreamStatus event reports each change.
+	Stream(ctx context.Context, sub Subscription) (<-chan StreamEvent, error)
+}
+
+// Subscription selects what a stream delivers, by exchange symbol
+type Subscription struct {
+	Tickers    []string
+	Trades     []string
+	OrderBooks []string
+	// Orders subscribes to the account's order and fill reports, which needs credentials
+	Orders bool
+}
+
+// StreamEvent is one of TickerUpdate, TradeUpdate, OrderBookUpdate,
+// OrderReport and StreamStatus
+type StreamEvent interface {
+	streamEvent()
+}
+
+// TickerUpdate is a new ticker of a symbol
+type TickerUpdate struct {
+	Ticker
+}
+
+// Trade is one public trade. Side is the taker's side.
+type Trade struct {
+	ID        string    `json:"id"`
+	Price     float64   `json:"price"`
+	Quantity  float64   `json:"quantity"`
+	Side      string    `json:"side"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TradeUpdate holds new public trades of a symbol
+type TradeUpdate struct {
+	Symbol string  `json:"symbol"`
+	Trades []Trade `json:"trades"`
+}
+
+// OrderBookUpdate changes an order book. A snapshot replaces the book;
+// otherwise each level sets the quantity at its price, and a zero quantity
+// removes the price. Updates arrive in sequence: after a gap the stream
+// fetches and sends a new snapshot.
+type OrderBookUpdate struct {
+	Symbol    string       `json:"symbol"`
+	Snapshot  bool         `json:"snapshot"`
+	Sequence  int64        `json:"sequence"`
+	Bids      []PriceLevel `json:"bids"`
+	Asks      []PriceLevel `json:"asks"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// Fill is one execution of an order
+type Fill struct {
+	TradeID  string  `json:"trade_id"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Fee      float64 `json:"fee"`
+	// Taker is false when the order provided liquidity
+	Taker     bool      `json:"taker"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// OrderReport is a change of one of the account's orders. Fill is set when
+// the change is an execution.
+type OrderReport struct {
+	Order Order `json:"order"`
+	Fill  *Fill `json:"fill,omitempty"`
+}
+
+// Stream states
+const (
+	StreamConnected    = "connected"
+	StreamDisconnected = "disconnected"
+	StreamResyncing    = "resyncing"
+)
+
+// StreamStatus reports a change of one of the stream's connections. Err is
+// set when the connection was lost or a resync was needed.
+type StreamStatus struct {
+	// Channel names the connection or subscription, e.g. "public" or "orderbook/ETHBTC"
+	Channel string
+	State   string
+	Err     error
+}
+
+func (TickerUpdate) streamEvent()    {}
+func (TradeUpdate) streamEvent()     {}
+func (OrderBookUpdate) streamEvent() {}
+func (OrderReport) streamEvent()     {}
+func (StreamStatus) streamEvent()    {}
+
+// Apply applies an update to the book, keeping bids descending and asks ascending
+func (b *OrderBook) Apply(update *OrderBookUpdate) {
+	b.Symbol = update.Symbol
+	b.Timestamp = update.Timestamp
+	if update.Snapshot {
+		b.Bids = applyLevels(nil, update.Bids, true)
+		b.Asks = applyLevels(nil, update.Asks, false)
+		return
+	}
+	b.Bids = applyLevels(b.Bids, update.Bids, true)
+	b.Asks = applyLevels(b.Asks, update.Asks, false)
+}
+
+func applyLevels(levels, changes []PriceLevel, descending bool) []PriceLevel {
+	quantities := make(map[float64]float64, len(levels)+len(changes))
+	for _, level := range levels {
+		quantities[level.Price] = level.Quantity
+	}
+	for _, change := range changes {
+		if change.Quantity == 0 {
+			delete(quantities, change.Price)
+		} else {
+			quantities[change.Price] = change.Quantity
+		}
+	}
+
+	result := make([]PriceLevel, 0, len(quantities))
+	for price, quantity := range quantities {
+		result = append(result, PriceLevel{Price: price, Quantity: quantity})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].Price > result[j].Price
+		}
+		return result[i].Price < result[j].Price
+	})
+	return result
+}
+
+// KeepConnected runs connect until ctx is cancelled. Each time connect
+// returns, a StreamDisconnected status is published and it is called again
+// after a jittered backoff that doubles up to maxDelay. The backoff starts
+// over once connect reports that it got connected. Rejected credentials
+// (ErrAuthFailed) end the loop instead.
+func KeepConnected(ctx context.Context, channel string, maxDelay time.Duration, events chan<- StreamEvent, connect func(ctx context.Context) (bool, error)) {
+	delay := min(minReconnectDelay, maxDelay)
+	for {
+		connected, err := connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case events <- StreamStatus{Channel: channel, State: StreamDisconnected, Err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if errors.Is(err, ErrAuthFailed) {
+			return
+		}
+
+		if connected {
+			delay = min(minReconnectDelay, maxDelay)
+		}
+		// Jitter keeps many streams from reconnecting in lockstep
+		wait := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(2*delay, maxDelay)
+	}
+}
+
+// Heartbeat pings every interval until ctx ends. When a ping fails it calls
+// cancel to drop the connection and sends the error on the returned channel.
+func Heartbeat(ctx context.Context, interval time.Duration, ping func(ctx context.Context) error, cancel context.CancelFunc) <-chan error {
+	failed := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			pingCtx, cancelPing := context.WithTimeout(ctx, interval)
+			err := ping(pingCtx)
+			cancelPing()
+			if err != nil && ctx.Err() == nil {
+				failed <- fmt.Errorf("missed a heartbeat: %w", err)
+				cancel()
+				return
+			}
+		}
+	}()
+	return failed
+}
@@ -0,0 +1,104 @@
+// Package fees computes what exchanges charge for trades: the rate of an
+// order under a fee schedule, the currency the fee is charged in and its
+// value in the quote currency.
+package fees
+
+import (
+	"trader/internal/exchange"
+	"trader/internal/models"
+)
+
+// DefaultTier is the fee tier of accounts the exchange has not reported one for
+const DefaultTier = "default"
+
+// Currencies a schedule charges fees in
+const (
+	InBase  = "base"
+	InQuote = "quote"
+	// InReceived charges the currency the trade pays out: the base currency
+	// of buys and the quote currency of sells
+	InReceived = "received"
+	// InNative charges the exchange's own token
+	InNative = "native"
+)
+
+// Sources of a schedule
+const (
+	// SourceManual schedules are maintained by hand and never synced
+	SourceManual = "manual"
+	// SourceSynced schedules are refreshed from the exchange
+	SourceSynced = "synced"
+)
+
+// Schedule is the fees an account pays on a symbol. Maker and Taker are
+// fractions of the traded value.
+type Schedule struct {
+	Maker    float64
+	Taker    float64
+	Currency string
+	// NativeDiscount is taken off the rates when fees are paid in the native token
+	NativeCurrency string
+	NativeDiscount float64
+}
+
+// FromModel reads a stored fee schedule
+func FromModel(schedule *models.FeeSchedule) Schedule {
+	return Schedule{
+		Maker:          schedule.MakerRate,
+		Taker:          schedule.TakerRate,
+		Currency:       schedule.FeeCurrency,
+		NativeCurrency: schedule.NativeCurrency,
+		NativeDiscount: schedule.NativeDiscount,
+	}
+}
+
+// Rate returns the fraction of the traded value an order pays, after the
+// native discount
+func (s Schedule) Rate(taker bool) float64 {
+	rate := s.Maker
+	if taker {
+		rate = s.Taker
+	}
+	if s.Currency == InNative {
+		rate *= 1 - s.NativeDiscount
+	}
+	return rate
+}
+
+// ChargedIn returns the currency a trade on the side pays its fee in: InBase,
+// InQuote or InNative. Unknown currencies are charged in the quote currency.
+func (s Schedule) ChargedIn(side string) string {
+	switch s.Currency {
+	case InBase, InNative:
+		return s.Currency
+	case InReceived:
+		if side == exchange.OrderSideBuy {
+			return InBase
+		}
+	}
+	return InQuote
+}
+
+// Fee is the fee of one trade
+type Fee struct {
+	// Currency is InBase, InQuote or InNative
+	Currency string `json:"currency"`
+	// Amount is the fee in the base or quote currency. Native fees are only
+	// known by value, converting them needs the token's price.
+	Amount float64 `json:"amount"`
+	// Value is the fee in the quote currency at the trade's price
+	Value float64 `json:"value"`
+}
+
+// Charge returns the fee of trading quantity at price
+func (s Schedule) Charge(side string, taker bool, quantity, price float64) Fee {
+	rate := s.Rate(taker)
+	fee := Fee{Currency: s.ChargedIn(side), Value: quantity * price * rate}
+	switch fee.Currency {
+	case InBase:
+		fee.Amount = quantity * rate
+	case InQuote:
+		fee.Amount = fee.Value
+	}
+	return fee
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/services"
+	"trader/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// GetAPIKeys lists the active workspace's exchange keys with masked previews
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	keys, err := h.apiKeyService.List(c.Context(), workspace)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to fetch API keys")
+	}
+
+	return Success(c, keys)
+}
+
+// GetAPIKey returns one exchange key with a masked preview
+func (h *APIKeyHandler) GetAPIKey(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid API key ID")
+	}
+
+	key, err := h.apiKeyService.Get(c.Context(), workspace, uint(id))
+	if err != nil {
+		return apiKeyError(c, err, "Failed to fetch API key")
+	}
+
+	return Success(c, key)
+}
+
+// CreateAPIKey stores new exchange credentials encrypted. The secret is not returned.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	key, err := h.apiKeyService.Create(c.Context(), workspace, &req)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to create API key")
+	}
+
+	return Created(c, key)
+}
+
+// UpdateAPIKey renames, (de)activates, sets the fee tier or replaces the
+// credentials of a key
+func (h *APIKeyHandler) UpdateAPIKey(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid API key ID")
+	}
+
+	var req services.UpdateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body")
+	}
+
+	key, err := h.apiKeyService.Update(c.Context(), workspace, uint(id), &req)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to update API key")
+	}
+
+	return Success(c, key)
+}
+
+// DeleteAPIKey deletes a key and discards its credentials
+func (h *APIKeyHandler) DeleteAPIKey(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid API key ID")
+	}
+
+	if err := h.apiKeyService.Delete(c.Context(), workspace, uint(id)); err != nil {
+		return apiKeyError(c, err, "Failed to delete API key")
+	}
+
+	return NoContent(c)
+}
+
+// TestAPIKey checks the key authenticates on its exchange and detects its permissions
+func (h *APIKeyHandler) TestAPIKey(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid API key ID")
+	}
+
+	result, err := h.apiKeyService.Test(c.Context(), workspace, uint(id))
+	if err != nil {
+		return apiKeyError(c, err, "Failed to test API key")
+	}
+
+	return Success(c, result)
+}
+
+// GetAPIKeyBalances returns the current balances of the key's account, their
+// history and unexpected drops, newest first. since (RFC 3339) and limit
+// narrow the history.
+func (h *APIKeyHandler) GetAPIKeyBalances(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid API key ID")
+	}
+
+	filter := &services.BalanceHistoryFilter{Limit: services.DefaultBalanceHistoryLimit}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= services.MaxBalanceHistoryLimit {
+			filter.Limit = parsed
+		}
+	}
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return BadRequest(c, "Invalid since, expected an RFC 3339 time")
+		}
+		filter.Since = &parsed
+	}
+
+	balances, err := h.apiKeyService.Balances(c.Context(), workspace, uint(id), filter)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to fetch API key balances")
+	}
+
+	return Success(c, balances)
+}
+
+// apiKeyError maps API key service errors onto responses. Request bodies are
+// never echoed because they contain credentials.
+func apiKeyError(c *fiber.Ctx, err error, message string) error {
+	var validationErrors utils.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrors):
+		return BadRequest(c, "Invalid API key", validationErrors.Error())
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return NotFound(c, "API key not found")
+	case errors.Is(err, services.ErrExchangeNotFound):
+		return BadRequest(c, "Exchange not found or inactive")
+	case errors.Is(err, services.ErrAPIKeyInactive):
+		return Conflict(c, "API key is inactive")
+	case errors.Is(err, services.ErrAPIKeyWithdrawEnabled):
+		return Conflict(c, "API key has withdrawal rights; remove the permission on the exchange and test the key again")
+	case errors.Is(err, exchange.ErrUnsupportedExchange):
+		return BadRequest(c, "Exchange is not supported yet")
+	case errors.Is(err, services.ErrOrganizationForbidden):
+		return Forbidden(c, "Your role in this workspace does not allow this action")
+	case IsExchangeError(err):
+		return ExchangeError(c, err)
+	default:
+		return InternalServerError(c, message, err.Error())
+	}
+}
@@ -140,13 +140,9 @@ func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
 	})
 }
 
-// ResetPassword completes password reset process
+// ResetPassword sets a new password with a reset or invite token
 func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
-	var req struct {
-		Token    string `json:"token"`
-		Password string `json:"password"`
-	}
-
+	var req services.ResetPasswordRequest
 	if err := c.BodyParser(&req); err != nil {
 		return BadRequest(c, "Invalid request body", err.Error())
 	}
@@ -155,12 +151,17 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 		return BadRequest(c, "Token and password are required")
 	}
 
-	// TODO: Implement password reset completion logic
-	// This would involve:
-	// 1. Validate reset token
-	// 2. Check token expiration
-	// 3. Update user password
-	// 4. Mark token as used
+	err := h.authService.ResetPassword(c.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidResetToken):
+			return BadRequest(c, "Invalid or expired reset token")
+		case errors.Is(err, services.ErrWeakPassword):
+			return BadRequest(c, "Password does not meet security requirements")
+		default:
+			return InternalServerError(c, "Failed to reset password", err.Error())
+		}
+	}
 
 	return Success(c, fiber.Map{
 		"message": "Password has been reset successfully",
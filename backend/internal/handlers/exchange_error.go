@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/resilience"
+	"trader/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// exchangeErrors maps the errors of the exchange package onto responses. The
+// rejections of an order come before ErrInvalidOrder, which they also match.
+var exchangeErrors = []struct {
+	err     error
+	status  int
+	code    string
+	message string
+}{
+	{exchange.ErrInsufficientBalance, fiber.StatusUnprocessableEntity, "INSUFFICIENT_BALANCE", "Insufficient balance on the exchange"},
+	{exchange.ErrInvalidSymbol, fiber.StatusBadRequest, "INVALID_SYMBOL", "The exchange does not know the symbol"},
+	{exchange.ErrWouldTriggerImmediately, fiber.StatusUnprocessableEntity, "ORDER_WOULD_TRIGGER_IMMEDIATELY", "The order would execute immediately"},
+	{exchange.ErrInvalidOrder, fiber.StatusUnprocessableEntity, "INVALID_ORDER", "The exchange rejected the order"},
+	{exchange.ErrOrderNotFound, fiber.StatusNotFound, "ORDER_NOT_FOUND", "Order not found on the exchange"},
+	{exchange.ErrUnknownOrderStatus, fiber.StatusBadGateway, "UNKNOWN_ORDER_STATUS", "The exchange reported an unknown order status"},
+	// Not 401: the user's session is fine, the exchange rejected the API key
+	{exchange.ErrAuthFailed, fiber.StatusUnprocessableEntity, "EXCHANGE_AUTH_FAILED", "The exchange rejected the credentials"},
+	{exchange.ErrRateLimited, fiber.StatusTooManyRequests, "EXCHANGE_RATE_LIMITED", "The exchange's rate limit is exceeded, try again later"},
+	{exchange.ErrClockSkew, fiber.StatusBadGateway, "EXCHANGE_CLOCK_SKEW", "The exchange rejected the request time"},
+	{resilience.ErrCircuitOpen, fiber.StatusServiceUnavailable, "EXCHANGE_UNAVAILABLE", "The exchange is unavailable, try again later"},
+	{services.ErrNoAPIKeyAvailable, fiber.StatusServiceUnavailable, "NO_API_KEY_AVAILABLE", "No API key is available for the exchange"},
+	{exchange.ErrUnsupportedExchange, fiber.StatusBadRequest, "UNSUPPORTED_EXCHANGE", "Exchange is not supported yet"},
+}
+
+// IsExchangeError reports whether ExchangeError has a response for err
+func IsExchangeError(err error) bool {
+	for _, mapping := range exchangeErrors {
+		if errors.Is(err, mapping.err) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExchangeError responds to an error of an exchange connector with its code.
+// Other errors are answered as a failed exchange request.
+func ExchangeError(c *fiber.Ctx, err error) error {
+	for _, mapping := range exchangeErrors {
+		if errors.Is(err, mapping.err) {
+			return c.Status(mapping.status).JSON(ErrorResponse{
+				Code:    mapping.code,
+				Message: mapping.message,
+				Details: err.Error(),
+			})
+		}
+	}
+	return c.Status(fiber.StatusBadGateway).JSON(ErrorResponse{
+		Code:    "EXCHANGE_ERROR",
+		Message: "The exchange request failed",
+		Details: err.Error(),
+	})
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"trader/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	authService         *services.AuthService
+}
+
+type SwitchOrganizationRequest struct {
+	// RefreshToken of the current session, revoked once the switch succeeds
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService, authService *services.AuthService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		authService:         authService,
+	}
+}
+
+// GetOrganizations lists the organizations the current user belongs to
+func (h *OrganizationHandler) GetOrganizations(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	organizations, err := h.organizationService.ListForUser(c.Context(), userID)
+	if err != nil {
+		return InternalServerError(c, "Failed to fetch organizations", err.Error())
+	}
+
+	return Success(c, organizations)
+}
+
+// CreateOrganization creates a shared organization owned by the current user
+func (h *OrganizationHandler) CreateOrganization(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	var req services.CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	organization, err := h.organizationService.Create(c.Context(), userID, &req)
+	if err != nil {
+		return organizationError(c, err, "Failed to create organization")
+	}
+
+	return Created(c, organization)
+}
+
+// SwitchOrganization issues tokens for another workspace of the current user
+func (h *OrganizationHandler) SwitchOrganization(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	organizationID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid organization ID")
+	}
+
+	var req SwitchOrganizationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return BadRequest(c, "Invalid request body", err.Error())
+		}
+	}
+
+	response, err := h.authService.SwitchOrganization(c.Context(), userID, uint(organizationID), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotOrganizationMember):
+			return NotFound(c, "Organization not found")
+		case errors.Is(err, services.ErrUserInactive):
+			return Unauthorized(c, "Account is inactive")
+		case errors.Is(err, services.ErrRefreshTokenOwner):
+			return BadRequest(c, "Invalid refresh token")
+		default:
+			return organizationError(c, err, "Failed to switch organization")
+		}
+	}
+
+	// The access token of the previous workspace is revoked as well
+	if token, ok := c.Locals("token").(string); ok {
+		h.authService.Logout(c.Context(), token)
+	}
+
+	return Success(c, response)
+}
+
+// GetCurrentOrganization returns the active workspace
+func (h *OrganizationHandler) GetCurrentOrganization(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	organization, err := h.organizationService.Get(c.Context(), workspace)
+	if err != nil {
+		return organizationError(c, err, "Failed to fetch organization")
+	}
+
+	return Success(c, organization)
+}
+
+// UpdateCurrentOrganization renames the active workspace
+func (h *OrganizationHandler) UpdateCurrentOrganization(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	var req services.CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	organization, err := h.organizationService.Rename(c.Context(), workspace, req.Name)
+	if err != nil {
+		return organizationError(c, err, "Failed to update organization")
+	}
+
+	return Success(c, organization)
+}
+
+// DeleteCurrentOrganization deletes the active shared workspace
+func (h *OrganizationHandler) DeleteCurrentOrganization(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	if err := h.organizationService.Delete(c.Context(), workspace); err != nil {
+		return organizationError(c, err, "Failed to delete organization")
+	}
+
+	return NoContent(c)
+}
+
+// GetMembers lists the members of the active workspace
+func (h *OrganizationHandler) GetMembers(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	members, err := h.organizationService.Members(c.Context(), workspace)
+	if err != nil {
+		return organizationError(c, err, "Failed to fetch members")
+	}
+
+	return Success(c, members)
+}
+
+// AddMember adds an existing user to the active workspace
+func (h *OrganizationHandler) AddMember(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	var req services.AddOrganizationMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	member, err := h.organizationService.AddMember(c.Context(), workspace, &req)
+	if err != nil {
+		return organizationError(c, err, "Failed to add member")
+	}
+
+	return Created(c, member)
+}
+
+// UpdateMember changes a member's role in the active workspace
+func (h *OrganizationHandler) UpdateMember(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid user ID")
+	}
+
+	var req services.UpdateOrganizationMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	if err := h.organizationService.UpdateMemberRole(c.Context(), workspace, uint(userID), req.Role); err != nil {
+		return organizationError(c, err, "Failed to update member")
+	}
+
+	return NoContent(c)
+}
+
+// RemoveMember removes a member from the active workspace, or lets the caller leave it
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	workspace, err := GetWorkspace(c)
+	if err != nil {
+		return Forbidden(c, "Workspace not selected")
+	}
+
+	userID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid user ID")
+	}
+
+	if err := h.organizationService.RemoveMember(c.Context(), workspace, uint(userID)); err != nil {
+		return organizationError(c, err, "Failed to remove member")
+	}
+
+	return NoContent(c)
+}
+
+// organizationError maps organization service errors onto responses
+func organizationError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		return NotFound(c, "Organization not found")
+	case errors.Is(err, services.ErrNotOrganizationMember):
+		return NotFound(c, "Member not found")
+	case errors.Is(err, services.ErrUserNotFound):
+		return NotFound(c, "User not found")
+	case errors.Is(err, services.ErrOrganizationForbidden):
+		return Forbidden(c, "Your role in this workspace does not allow this action")
+	case errors.Is(err, services.ErrPersonalOrganization):
+		return Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrInvalidOrganizationName), errors.Is(err, services.ErrInvalidOrganizationRole):
+		return BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrAlreadyOrganizationMember), errors.Is(err, services.ErrLastOrganizationOwner):
+		return Conflict(c, err.Error())
+	default:
+		return InternalServerError(c, message, err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+
+	"trader/internal/services"
+	"trader/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PreferencesHandler struct {
+	preferencesService *services.PreferencesService
+}
+
+func NewPreferencesHandler(preferencesService *services.PreferencesService) *PreferencesHandler {
+	return &PreferencesHandler{
+		preferencesService: preferencesService,
+	}
+}
+
+// GetPreferences returns the current user's preferences
+func (h *PreferencesHandler) GetPreferences(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	prefs, err := h.preferencesService.Get(c.Context(), userID)
+	if err != nil {
+		return InternalServerError(c, "Failed to fetch preferences", err.Error())
+	}
+
+	return Success(c, prefs)
+}
+
+// UpdatePreferences changes the current user's preferences
+func (h *PreferencesHandler) UpdatePreferences(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	var req services.UpdatePreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	prefs, err := h.preferencesService.Update(c.Context(), userID, &req)
+	if err != nil {
+		var validationErrors utils.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrors):
+			return BadRequest(c, "Invalid preferences", validationErrors.Error())
+		case errors.Is(err, services.ErrPreferencesConflict):
+			return Conflict(c, "Preferences were changed by another request, reload and try again")
+		default:
+			return InternalServerError(c, "Failed to update preferences", err.Error())
+		}
+	}
+
+	return Success(c, prefs)
+}
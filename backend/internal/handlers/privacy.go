@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"trader/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PrivacyHandler struct {
+	privacyService *services.PrivacyService
+	authService    *services.AuthService
+}
+
+type EraseAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+func NewPrivacyHandler(privacyService *services.PrivacyService, authService *services.AuthService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+		authService:    authService,
+	}
+}
+
+// ExportData returns the current user's personal data as a zip archive
+func (h *PrivacyHandler) ExportData(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	archive, err := h.privacyService.ExportUserData(c.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return NotFound(c, "User not found")
+		default:
+			return InternalServerError(c, "Failed to export personal data", err.Error())
+		}
+	}
+
+	filename := fmt.Sprintf("personal-data-%d-%s.zip", userID, time.Now().UTC().Format("20060102"))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.Send(archive)
+}
+
+// EraseAccount anonymizes the current user's account after password confirmation
+func (h *PrivacyHandler) EraseAccount(c *fiber.Ctx) error {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return Unauthorized(c, "User not authenticated")
+	}
+
+	var req EraseAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequest(c, "Invalid request body", err.Error())
+	}
+
+	if req.Password == "" {
+		return BadRequest(c, "Password is required to erase the account")
+	}
+
+	result, err := h.privacyService.EraseAccount(c.Context(), userID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return NotFound(c, "User not found")
+		case errors.Is(err, services.ErrInvalidPassword):
+			return BadRequest(c, "Password is incorrect")
+		case errors.Is(err, services.ErrUserErased):
+			return Conflict(c, "Account has already been erased")
+		default:
+			return InternalServerError(c, "Failed to erase account", err.Error())
+		}
+	}
+
+	// Revoke the token used for this request
+	if token, ok := c.Locals("token").(string); ok {
+		h.authService.Logout(c.Context(), token)
+	}
+
+	return Success(c, result)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"trader/internal/services"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,9 +18,10 @@ type ErrorResponse struct {
 }
 
 type Meta struct {
-	Total  int `json:"total,omitempty"`
-	Limit  int `json:"limit,omitempty"`
-	Offset int `json:"offset,omitempty"`
+	Total      int    `json:"total,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // Success response helpers
@@ -126,6 +129,15 @@ func GetUserID(c *fiber.Ctx) (uint, error) {
 	return userID, nil
 }
 
+// GetWorkspace returns the workspace resolved by middleware.RequireWorkspace
+func GetWorkspace(c *fiber.Ctx) (*services.Workspace, error) {
+	workspace, ok := c.Locals("workspace").(*services.Workspace)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Workspace not found in context")
+	}
+	return workspace, nil
+}
+
 func GetUserEmail(c *fiber.Ctx) (string, error) {
 	email, ok := c.Locals("email").(string)
 	if !ok {
@@ -4,17 +4,22 @@ import (
 	"time"
 
 	"trader/internal/database"
+	"trader/internal/exchange/resilience"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type SystemHandler struct {
-	db *database.Database
+	db             *database.Database
+	exchangeHealth func() []resilience.Health
 }
 
-func NewSystemHandler(db *database.Database) *SystemHandler {
+// NewSystemHandler creates the handler; exchangeHealth reports the state of
+// the exchange connectors and may be nil
+func NewSystemHandler(db *database.Database, exchangeHealth func() []resilience.Health) *SystemHandler {
 	return &SystemHandler{
-		db: db,
+		db:             db,
+		exchangeHealth: exchangeHealth,
 	}
 }
 
@@ -75,10 +80,26 @@ func (h *SystemHandler) ReadinessCheck(c *fiber.Ctx) error {
 		})
 	}
 
-	return Success(c, fiber.Map{
+	response := fiber.Map{
 		"status":  "ready",
 		"message": "Service is ready to accept requests",
-	})
+	}
+
+	// Unavailable exchanges degrade the service but do not make it unready;
+	// restarting would not bring them back
+	if h.exchangeHealth != nil {
+		exchanges := h.exchangeHealth()
+		for _, exchange := range exchanges {
+			if exchange.State != resilience.StateHealthy {
+				response["status"] = "degraded"
+				response["message"] = "Service is ready, some exchanges are failing"
+				break
+			}
+		}
+		response["exchanges"] = exchanges
+	}
+
+	return Success(c, response)
 }
 
 // LivenessCheck returns liveness status (basic service check)
@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	"trader/internal/services"
 
@@ -50,7 +51,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	return Created(c, user)
 }
 
-// GetUsers returns paginated list of users
+// GetUsers returns a page of users. Pass meta.next_cursor as cursor to fetch the next page.
 func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	// Parse query parameters
 	limit := 10
@@ -60,31 +61,48 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 		}
 	}
 
-	offset := 0
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	filter := &services.ListUsersFilter{
+		Search:    c.Query("search"),
+		Role:      c.Query("role"),
+		SortBy:    c.Query("sort"),
+		SortOrder: c.Query("order"),
+		Limit:     limit,
+		Cursor:    c.Query("cursor"),
 	}
 
-	role := c.Query("role")
-
-	var active *bool
-	if a := c.Query("active"); a != "" {
-		if parsed, err := strconv.ParseBool(a); err == nil {
-			active = &parsed
-		}
+	var err error
+	if filter.Active, err = parseBoolQuery(c, "active"); err != nil {
+		return BadRequest(c, "Invalid active filter")
+	}
+	if filter.Locked, err = parseBoolQuery(c, "locked"); err != nil {
+		return BadRequest(c, "Invalid locked filter")
+	}
+	if filter.EmailVerified, err = parseBoolQuery(c, "verified"); err != nil {
+		return BadRequest(c, "Invalid verified filter")
+	}
+	if filter.LastLoginFrom, err = parseTimeQuery(c, "last_login_from", false); err != nil {
+		return BadRequest(c, "Invalid last_login_from, expected RFC3339 or YYYY-MM-DD")
+	}
+	if filter.LastLoginTo, err = parseTimeQuery(c, "last_login_to", true); err != nil {
+		return BadRequest(c, "Invalid last_login_to, expected RFC3339 or YYYY-MM-DD")
 	}
 
-	response, err := h.userService.ListUsers(c.Context(), limit, offset, role, active)
+	response, err := h.userService.ListUsers(c.Context(), filter)
 	if err != nil {
-		return InternalServerError(c, "Failed to fetch users", err.Error())
+		switch {
+		case errors.Is(err, services.ErrInvalidSort):
+			return BadRequest(c, "Invalid sort field or order")
+		case errors.Is(err, services.ErrInvalidCursor):
+			return BadRequest(c, "Invalid pagination cursor")
+		default:
+			return InternalServerError(c, "Failed to fetch users", err.Error())
+		}
 	}
 
 	return SuccessWithMeta(c, response.Users, &Meta{
-		Total:  response.Meta.Total,
-		Limit:  response.Meta.Limit,
-		Offset: response.Meta.Offset,
+		Total:      response.Meta.Total,
+		Limit:      response.Meta.Limit,
+		NextCursor: response.Meta.NextCursor,
 	})
 }
 
@@ -158,6 +176,114 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	return NoContent(c)
 }
 
+// GetDeletedUsers returns soft-deleted users, most recently deleted first
+func (h *UserHandler) GetDeletedUsers(c *fiber.Ctx) error {
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	filter := &services.ListDeletedUsersFilter{
+		Search: c.Query("search"),
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	}
+
+	purgeable, err := parseBoolQuery(c, "purgeable")
+	if err != nil {
+		return BadRequest(c, "Invalid purgeable filter")
+	}
+	filter.Purgeable = purgeable != nil && *purgeable
+
+	response, err := h.userService.ListDeletedUsers(c.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCursor):
+			return BadRequest(c, "Invalid pagination cursor")
+		default:
+			return InternalServerError(c, "Failed to fetch deleted users", err.Error())
+		}
+	}
+
+	return SuccessWithMeta(c, response.Users, &Meta{
+		Total:      response.Meta.Total,
+		Limit:      response.Meta.Limit,
+		NextCursor: response.Meta.NextCursor,
+	})
+}
+
+// RestoreUser restores a soft-deleted user with its roles
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	userID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid user ID")
+	}
+
+	var restoredBy *uint
+	if currentUserID, err := GetUserID(c); err == nil {
+		restoredBy = &currentUserID
+	}
+
+	user, err := h.userService.RestoreUser(c.Context(), uint(userID), restoredBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return NotFound(c, "User not found")
+		case errors.Is(err, services.ErrUserNotDeleted):
+			return Conflict(c, "User is not deleted")
+		case errors.Is(err, services.ErrUserErased):
+			return Conflict(c, "User data has been erased and cannot be restored")
+		case errors.Is(err, services.ErrEmailExists):
+			return Conflict(c, "Email has been registered by another user")
+		default:
+			return InternalServerError(c, "Failed to restore user", err.Error())
+		}
+	}
+
+	return Success(c, user)
+}
+
+// PurgeUser permanently removes a soft-deleted user once the grace period has passed.
+// force=true skips the grace period.
+func (h *UserHandler) PurgeUser(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	userID, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		return BadRequest(c, "Invalid user ID")
+	}
+
+	force, err := parseBoolQuery(c, "force")
+	if err != nil {
+		return BadRequest(c, "Invalid force flag")
+	}
+
+	var purgedBy *uint
+	if currentUserID, err := GetUserID(c); err == nil {
+		purgedBy = &currentUserID
+	}
+
+	err = h.userService.PurgeUser(c.Context(), uint(userID), force != nil && *force, purgedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return NotFound(c, "User not found")
+		case errors.Is(err, services.ErrUserNotDeleted):
+			return Conflict(c, "User must be deleted before it can be purged")
+		case errors.Is(err, services.ErrPurgeGracePeriod):
+			return Conflict(c, "User is still within the restore grace period")
+		case errors.Is(err, services.ErrUserErased):
+			return Conflict(c, "Erased users are kept as pseudonyms and cannot be purged")
+		default:
+			return InternalServerError(c, "Failed to purge user", err.Error())
+		}
+	}
+
+	return NoContent(c)
+}
+
 // GetProfile returns current user's profile
 func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 	userID, err := GetUserID(c)
@@ -262,3 +388,39 @@ func (h *UserHandler) GetUserByEmail(c *fiber.Ctx) error {
 
 	return Success(c, user)
 }
+
+// parseBoolQuery returns nil when the parameter is absent
+func parseBoolQuery(c *fiber.Ctx, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// parseTimeQuery accepts RFC3339 or a plain date. A plain date used as an
+// upper bound covers the whole day.
+func parseTimeQuery(c *fiber.Ctx, key string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		parsed = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// lockKeyPrefix is the Redis key prefix used to run a job on one instance only
+const lockKeyPrefix = "job_lock:"
+
+// releaseLockScript deletes the lock only if it is still held by this run
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Job is a periodic background task
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// RunOnStart runs the job once immediately instead of waiting for the first tick
+	RunOnStart bool
+	Run        func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs on their intervals until stopped.
+// When a Redis client is provided, each run takes a lock so that only one
+// API instance executes a given job at a time.
+type Scheduler struct {
+	redis  *redis.Client
+	jobs   []Job
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+func NewScheduler(redis *redis.Client) *Scheduler {
+	return &Scheduler{
+		redis: redis,
+	}
+}
+
+// Register adds a job; it must be called before Start
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start launches one goroutine per registered job
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		if job.Interval <= 0 {
+			log.Warn().Str("job", job.Name).Msg("Job has no interval, skipping")
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+
+		log.Info().
+			Str("job", job.Name).
+			Dur("interval", job.Interval).
+			Msg("Job scheduled")
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	if job.RunOnStart {
+		s.runOnce(ctx, job)
+	}
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	if s.redis != nil {
+		token, acquired := s.acquireLock(ctx, job)
+		if !acquired {
+			log.Debug().Str("job", job.Name).Msg("Job is running elsewhere, skipping")
+			return
+		}
+		defer s.releaseLock(job, token)
+	}
+
+	started := time.Now()
+	if err := job.Run(ctx); err != nil {
+		log.Error().
+			Err(err).
+			Str("job", job.Name).
+			Dur("duration", time.Since(started)).
+			Msg("Job failed")
+		return
+	}
+
+	log.Info().
+		Str("job", job.Name).
+		Dur("duration", time.Since(started)).
+		Msg("Job completed")
+}
+
+func (s *Scheduler) acquireLock(ctx context.Context, job Job) (string, bool) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		log.Error().Err(err).Str("job", job.Name).Msg("Failed to generate job lock token")
+		return "", false
+	}
+	value := hex.EncodeToString(token)
+
+	acquired, err := s.redis.SetNX(ctx, lockKeyPrefix+job.Name, value, job.Interval).Result()
+	if err != nil {
+		log.Error().Err(err).Str("job", job.Name).Msg("Failed to acquire job lock")
+		return "", false
+	}
+	return value, acquired
+}
+
+func (s *Scheduler) releaseLock(job Job, token string) {
+	// Use a fresh context so the lock is released even after shutdown started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := releaseLockScript.Run(ctx, s.redis, []string{lockKeyPrefix + job.Name}, token).Err(); err != nil {
+		log.Error().Err(err).Str("job", job.Name).Msg("Failed to release job lock")
+	}
+}
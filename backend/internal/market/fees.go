@@ -0,0 +1,303 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/fees"
+	"trader/internal/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var ErrNoFeeSchedule = errors.New("no fee schedule")
+
+const rateScale = 1e8
+
+// FeeSyncResult is the outcome of syncing the fees of one exchange
+type FeeSyncResult struct {
+	Exchange string `json:"exchange"`
+	Created  int    `json:"created"`
+	Updated  int    `json:"updated"`
+	Removed  int    `json:"removed"`
+	// Manual counts the symbols whose schedule is maintained by hand
+	Manual    int    `json:"manual"`
+	Unchanged int    `json:"unchanged"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SyncFees stores the fees that every active exchange, or only the one with
+// the code, lists per symbol as schedules of the default tier. Symbols the
+// exchange no longer lists with fees lose their synced schedule. Exchanges that report fees
+// per account only, such as Binance, keep their exchange-wide schedule; the
+// fees of the accounts are stored by StoreAccountFees. Schedules edited by
+// hand are left alone. An exchange that fails does not stop the others.
+func (s *Service) SyncFees(ctx context.Context, code string) ([]FeeSyncResult, error) {
+	query := s.db.WithContext(ctx).Where("is_active = ?", true)
+	if code != "" {
+		query = query.Where("code = ?", code)
+	}
+	var exchanges []models.Exchange
+	if err := query.Order("code").Find(&exchanges).Error; err != nil {
+		return nil, fmt.Errorf("failed to load exchanges: %w", err)
+	}
+	if code != "" && len(exchanges) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExchange, code)
+	}
+
+	results := make([]FeeSyncResult, 0, len(exchanges))
+	var errs []error
+	for i := range exchanges {
+		result, err := s.syncExchangeFees(ctx, &exchanges[i])
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", exchanges[i].Code, err))
+			log.Error().Err(err).Str("exchange", exchanges[i].Code).Msg("Fee sync failed")
+		} else {
+			log.Info().
+				Str("exchange", result.Exchange).
+				Int("created", result.Created).
+				Int("updated", result.Updated).
+				Int("removed", result.Removed).
+				Msg("Fees synced")
+		}
+		results = append(results, *result)
+	}
+	return results, errors.Join(errs...)
+}
+
+func (s *Service) syncExchangeFees(ctx context.Context, ex *models.Exchange) (*FeeSyncResult, error) {
+	result := &FeeSyncResult{Exchange: ex.Code}
+
+	connector, err := connectors.New(s.cfg.Exchange, s.db, ex, nil)
+	if err != nil {
+		return result, err
+	}
+	symbols, err := connector.Symbols(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch symbols: %w", err)
+	}
+
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var schedules []models.FeeSchedule
+		err := tx.Where("exchange_id = ? AND tier = ? AND symbol <> ?", ex.ID, fees.DefaultTier, "").Find(&schedules).Error
+		if err != nil {
+			return fmt.Errorf("failed to load fee schedules: %w", err)
+		}
+		existing := make(map[string]*models.FeeSchedule, len(schedules))
+		for i := range schedules {
+			existing[schedules[i].Symbol] = &schedules[i]
+		}
+
+		listed := make(map[string]bool, len(symbols))
+		for _, symbol := range symbols {
+			if symbol.MakerFee == 0 && symbol.TakerFee == 0 {
+				continue
+			}
+			listed[symbol.Symbol] = true
+			wanted := symbolSchedule(symbol)
+			schedule, ok := existing[symbol.Symbol]
+			switch {
+			case !ok:
+				wanted.ExchangeID = ex.ID
+				wanted.Tier = fees.DefaultTier
+				if err := createSchedule(tx, &wanted, now); err != nil {
+					return err
+				}
+				result.Created++
+			case schedule.Source != fees.SourceSynced:
+				result.Manual++
+			default:
+				// The discount is not reported; it is kept as configured
+				wanted.NativeDiscount = schedule.NativeDiscount
+				changed, err := updateSchedule(tx, schedule, wanted, now)
+				if err != nil {
+					return err
+				}
+				if changed {
+					result.Updated++
+				} else {
+					result.Unchanged++
+				}
+			}
+		}
+
+		for _, schedule := range schedules {
+			if listed[schedule.Symbol] || schedule.Source != fees.SourceSynced {
+				continue
+			}
+			if err := tx.Delete(&models.FeeSchedule{}, schedule.ID).Error; err != nil {
+				return fmt.Errorf("failed to remove fee schedule %s: %w", schedule.Symbol, err)
+			}
+			result.Removed++
+		}
+		return nil
+	})
+	return result, err
+}
+
+// StoreAccountFees stores the fees an exchange reported for an account as the
+// exchange-wide schedule of the account's tier and returns the tier. Fees
+// without a tier are not stored, the account trades at the default tier.
+// Schedules edited by hand are not overwritten.
+func (s *Service) StoreAccountFees(ctx context.Context, exchangeID uint, reported *exchange.AccountFees) (string, error) {
+	if reported == nil || reported.Tier == "" {
+		return fees.DefaultTier, nil
+	}
+
+	now := time.Now()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var schedules []models.FeeSchedule
+		err := tx.Where("exchange_id = ? AND tier IN ? AND symbol = ?", exchangeID, []string{reported.Tier, fees.DefaultTier}, "").Find(&schedules).Error
+		if err != nil {
+			return fmt.Errorf("failed to load fee schedules: %w", err)
+		}
+		var current, standard *models.FeeSchedule
+		for i := range schedules {
+			if schedules[i].Tier == reported.Tier {
+				current = &schedules[i]
+			} else {
+				standard = &schedules[i]
+			}
+		}
+
+		// The currency and discount are those of the exchange's standard
+		// schedule unless the account pays in a currency of its own
+		wanted := models.FeeSchedule{
+			ExchangeID:  exchangeID,
+			Tier:        reported.Tier,
+			MakerRate:   roundRate(reported.Maker),
+			TakerRate:   roundRate(reported.Taker),
+			FeeCurrency: fees.InQuote,
+		}
+		if standard != nil {
+			wanted.NativeCurrency, wanted.NativeDiscount = standard.NativeCurrency, standard.NativeDiscount
+			if standard.FeeCurrency != fees.InNative {
+				wanted.FeeCurrency = standard.FeeCurrency
+			}
+		}
+		if reported.FeeCurrency != "" {
+			wanted.FeeCurrency, wanted.NativeCurrency = fees.InNative, reported.FeeCurrency
+		}
+
+		switch {
+		case current == nil:
+			return createSchedule(tx, &wanted, now)
+		case current.Source != fees.SourceSynced:
+			return nil
+		default:
+			_, err := updateSchedule(tx, current, wanted, now)
+			return err
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return reported.Tier, nil
+}
+
+// FeeSchedule returns the schedule an account of the tier pays on a symbol:
+// the tier's schedule of the symbol, else the tier's exchange-wide schedule,
+// else those of the default tier. Without any it returns ErrNoFeeSchedule;
+// fees are never assumed to be zero.
+func (s *Service) FeeSchedule(ctx context.Context, exchangeID uint, tier, symbol string) (*models.FeeSchedule, error) {
+	if tier == "" {
+		tier = fees.DefaultTier
+	}
+	var schedules []models.FeeSchedule
+	err := s.db.WithContext(ctx).
+		Where("exchange_id = ? AND tier IN ? AND symbol IN ?", exchangeID, []string{tier, fees.DefaultTier}, []string{symbol, ""}).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fee schedules: %w", err)
+	}
+
+	rank := func(schedule *models.FeeSchedule) int {
+		r := 0
+		if schedule.Tier != tier {
+			r += 2
+		}
+		if schedule.Symbol != symbol {
+			r++
+		}
+		return r
+	}
+	sort.Slice(schedules, func(i, j int) bool { return rank(&schedules[i]) < rank(&schedules[j]) })
+	if len(schedules) == 0 {
+		return nil, fmt.Errorf("%w for %s on exchange %d", ErrNoFeeSchedule, symbol, exchangeID)
+	}
+	return &schedules[0], nil
+}
+
+// symbolSchedule maps the fees of an exchange symbol onto a schedule
+func symbolSchedule(symbol exchange.Symbol) models.FeeSchedule {
+	schedule := models.FeeSchedule{
+		Symbol:    symbol.Symbol,
+		MakerRate: roundRate(symbol.MakerFee),
+		TakerRate: roundRate(symbol.TakerFee),
+	}
+	switch symbol.FeeCurrency {
+	case "":
+		schedule.FeeCurrency = fees.InReceived
+	case symbol.Base:
+		schedule.FeeCurrency = fees.InBase
+	case symbol.Quote:
+		schedule.FeeCurrency = fees.InQuote
+	default:
+		schedule.FeeCurrency, schedule.NativeCurrency = fees.InNative, symbol.FeeCurrency
+	}
+	return schedule
+}
+
+func createSchedule(tx *gorm.DB, schedule *models.FeeSchedule, now time.Time) error {
+	schedule.Source = fees.SourceSynced
+	schedule.SyncedAt = &now
+	if err := tx.Create(schedule).Error; err != nil {
+		return fmt.Errorf("failed to create fee schedule: %w", err)
+	}
+	return nil
+}
+
+// updateSchedule stores the synced columns of a schedule and reports whether
+// any of them changed. The sync time is refreshed either way.
+func updateSchedule(tx *gorm.DB, schedule *models.FeeSchedule, wanted models.FeeSchedule, now time.Time) (bool, error) {
+	changed := schedule.MakerRate != wanted.MakerRate ||
+		schedule.TakerRate != wanted.TakerRate ||
+		schedule.FeeCurrency != wanted.FeeCurrency ||
+		schedule.NativeCurrency != wanted.NativeCurrency ||
+		schedule.NativeDiscount != wanted.NativeDiscount
+	columns := map[string]interface{}{"synced_at": now}
+	if changed {
+		columns = scheduleColumns(wanted)
+		columns["synced_at"] = now
+	}
+	if err := tx.Model(&models.FeeSchedule{}).Where("id = ?", schedule.ID).Updates(columns).Error; err != nil {
+		return false, fmt.Errorf("failed to update fee schedule: %w", err)
+	}
+	return changed, nil
+}
+
+// roundRate matches the DECIMAL(12,8) columns of the rates, so values the
+// column cannot hold do not show up as a change on every sync
+func roundRate(rate float64) float64 {
+	return math.Round(rate*rateScale) / rateScale
+}
+
+// scheduleColumns returns the synced columns of a schedule, zero values included
+func scheduleColumns(schedule models.FeeSchedule) map[string]interface{} {
+	return map[string]interface{}{
+		"maker_rate":      schedule.MakerRate,
+		"taker_rate":      schedule.TakerRate,
+		"fee_currency":    schedule.FeeCurrency,
+		"native_currency": schedule.NativeCurrency,
+		"native_discount": schedule.NativeDiscount,
+	}
+}
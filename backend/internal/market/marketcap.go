@@ -0,0 +1,186 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+)
+
+// Market cap providers
+const (
+	ProviderCoinGecko = "coingecko"
+	ProviderFile      = "file"
+)
+
+// coinGeckoPageSize is the most coins CoinGecko returns per page
+const coinGeckoPageSize = 250
+
+// MarketCap is the market capitalization of a coin in USD
+type MarketCap struct {
+	Symbol    string
+	Name      string
+	MarketCap int64
+	// Rank is the coin's position by market cap, starting at 1
+	Rank int
+}
+
+// MarketCapProvider ranks coins by market cap
+type MarketCapProvider interface {
+	// MarketCaps returns at most limit coins, largest market cap first
+	MarketCaps(ctx context.Context, limit int) ([]MarketCap, error)
+}
+
+// NewMarketCapProvider creates the configured provider
+func NewMarketCapProvider(cfg config.MarketConfig) (MarketCapProvider, error) {
+	switch cfg.MarketCapProvider {
+	case ProviderCoinGecko:
+		return NewCoinGeckoProvider(cfg.MarketCapURL, cfg.MarketCapAPIKey), nil
+	case ProviderFile:
+		if cfg.MarketCapFile == "" {
+			return nil, fmt.Errorf("the file market cap provider needs MARKET_CAP_FILE")
+		}
+		return NewFileProvider(cfg.MarketCapFile), nil
+	default:
+		return nil, fmt.Errorf("unknown market cap provider: %s", cfg.MarketCapProvider)
+	}
+}
+
+// coinGeckoMarket is an entry of the CoinGecko /coins/markets response.
+// Market caps and ranks are null for coins CoinGecko cannot value.
+type coinGeckoMarket struct {
+	ID            string   `json:"id"`
+	Symbol        string   `json:"symbol"`
+	Name          string   `json:"name"`
+	MarketCap     *float64 `json:"market_cap"`
+	MarketCapRank *int     `json:"market_cap_rank"`
+}
+
+// marketCaps converts a markets response, which is ordered by market cap,
+// skipping coins without one
+func marketCaps(markets []coinGeckoMarket, limit int) []MarketCap {
+	caps := make([]MarketCap, 0, len(markets))
+	for _, market := range markets {
+		if len(caps) == limit {
+			break
+		}
+		if market.MarketCap == nil || *market.MarketCap <= 0 {
+			continue
+		}
+		rank := len(caps) + 1
+		if market.MarketCapRank != nil && *market.MarketCapRank > 0 {
+			rank = *market.MarketCapRank
+		}
+		caps = append(caps, MarketCap{
+			Symbol:    strings.ToUpper(market.Symbol),
+			Name:      market.Name,
+			MarketCap: int64(*market.MarketCap),
+			Rank:      rank,
+		})
+	}
+	return caps
+}
+
+// CoinGeckoProvider reads market caps from the CoinGecko API or a service
+// with the same /coins/markets endpoint
+type CoinGeckoProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewCoinGeckoProvider creates a provider for the API at baseURL. The key is
+// optional; keys for the pro API (pro-api.coingecko.com) are sent in the pro
+// header, others as demo keys.
+func NewCoinGeckoProvider(baseURL, apiKey string) *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// MarketCaps implements MarketCapProvider, fetching as many pages as needed
+func (p *CoinGeckoProvider) MarketCaps(ctx context.Context, limit int) ([]MarketCap, error) {
+	var markets []coinGeckoMarket
+	for page := 1; len(markets) < limit; page++ {
+		batch, err := p.page(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		markets = append(markets, batch...)
+		if len(batch) < coinGeckoPageSize {
+			break
+		}
+	}
+	return marketCaps(markets, limit), nil
+}
+
+func (p *CoinGeckoProvider) page(ctx context.Context, page int) ([]coinGeckoMarket, error) {
+	query := url.Values{}
+	query.Set("vs_currency", "usd")
+	query.Set("order", "market_cap_desc")
+	query.Set("per_page", strconv.Itoa(coinGeckoPageSize))
+	query.Set("page", strconv.Itoa(page))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/coins/markets?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create market cap request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		if strings.Contains(p.baseURL, "pro-api.") {
+			req.Header.Set("x-cg-pro-api-key", p.apiKey)
+		} else {
+			req.Header.Set("x-cg-demo-api-key", p.apiKey)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch market caps: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("market cap provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var markets []coinGeckoMarket
+	if err := json.NewDecoder(resp.Body).Decode(&markets); err != nil {
+		return nil, fmt.Errorf("failed to decode market caps: %w", err)
+	}
+	return markets, nil
+}
+
+// FileProvider reads market caps from a saved CoinGecko /coins/markets
+// response, for offline use. The file is read on every call, so it can be
+// replaced while the server runs.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// MarketCaps implements MarketCapProvider
+func (p *FileProvider) MarketCaps(_ context.Context, limit int) ([]MarketCap, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market cap file: %w", err)
+	}
+	var markets []coinGeckoMarket
+	if err := json.Unmarshal(data, &markets); err != nil {
+		return nil, fmt.Errorf("failed to decode market cap file %s: %w", p.path, err)
+	}
+	return marketCaps(markets, limit), nil
+}
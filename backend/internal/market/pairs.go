@@ -0,0 +1,371 @@
+// Package market keeps the exchange and coin reference data current: the
+// trading pairs each exchange lists and the coins they trade.
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"trader/internal/config"
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownExchange = errors.New("unknown exchange")
+
+// Pair change actions
+const (
+	PairCreated     = "created"
+	PairUpdated     = "updated"
+	PairDeactivated = "deactivated"
+)
+
+// Reasons a pair is deactivated
+const (
+	ReasonDelisted = "delisted"
+	ReasonHalted   = "halted"
+)
+
+// sizeScale matches the DECIMAL(20,8) columns of the order sizes, so values
+// the column cannot hold do not show up as a change on every sync
+const sizeScale = 1e8
+
+// FieldChange is one changed column of a trading pair
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to"`
+}
+
+// PairChange is the diff of one trading pair
+type PairChange struct {
+	Symbol string        `json:"symbol"`
+	Action string        `json:"action"`
+	Reason string        `json:"reason,omitempty"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// String formats the change as a diff line: "+" for new pairs, "~" for
+// updates and "-" for deactivations
+func (c PairChange) String() string {
+	var b strings.Builder
+	switch c.Action {
+	case PairCreated:
+		b.WriteString("+ ")
+	case PairDeactivated:
+		b.WriteString("- ")
+	default:
+		b.WriteString("~ ")
+	}
+	b.WriteString(c.Symbol)
+	if c.Reason != "" {
+		fmt.Fprintf(&b, " (%s)", c.Reason)
+	}
+	for i, field := range c.Fields {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(", ")
+		}
+		if c.Action == PairCreated {
+			fmt.Fprintf(&b, "%s=%s", field.Field, field.To)
+		} else {
+			fmt.Fprintf(&b, "%s: %s → %s", field.Field, field.From, field.To)
+		}
+	}
+	return b.String()
+}
+
+// PairSyncResult is the outcome of syncing the pairs of one exchange
+type PairSyncResult struct {
+	Exchange     string       `json:"exchange"`
+	Changes      []PairChange `json:"changes"`
+	CoinsCreated []string     `json:"coins_created"`
+	Unchanged    int          `json:"unchanged"`
+	DryRun       bool         `json:"dry_run"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// Count returns how many changes have the action
+func (r *PairSyncResult) Count(action string) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Service syncs reference data from the exchanges and the market cap provider
+type Service struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewService(db *gorm.DB, cfg *config.Config) *Service {
+	return &Service{db: db, cfg: cfg}
+}
+
+// SyncPairs pulls the symbols of every active exchange, or only of the one
+// with the code, and upserts its trading pairs. Missing coins are created.
+// Pairs the exchange no longer lists or has halted are deactivated; pairs
+// deleted by hand are left alone. An exchange that fails does not stop the
+// others; its error is in its result and in the returned error.
+func (s *Service) SyncPairs(ctx context.Context, code string, dryRun bool) ([]PairSyncResult, error) {
+	query := s.db.WithContext(ctx).Where("is_active = ?", true)
+	if code != "" {
+		query = query.Where("code = ?", code)
+	}
+	var exchanges []models.Exchange
+	if err := query.Order("code").Find(&exchanges).Error; err != nil {
+		return nil, fmt.Errorf("failed to load exchanges: %w", err)
+	}
+	if code != "" && len(exchanges) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExchange, code)
+	}
+
+	results := make([]PairSyncResult, 0, len(exchanges))
+	var errs []error
+	for i := range exchanges {
+		result, err := s.syncExchange(ctx, &exchanges[i], dryRun)
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", exchanges[i].Code, err))
+			log.Error().Err(err).Str("exchange", exchanges[i].Code).Msg("Trading pair sync failed")
+		}
+		results = append(results, *result)
+
+		for _, change := range result.Changes {
+			log.Info().
+				Str("exchange", result.Exchange).
+				Str("symbol", change.Symbol).
+				Str("action", change.Action).
+				Bool("dry_run", dryRun).
+				Msg(change.String())
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+func (s *Service) syncExchange(ctx context.Context, ex *models.Exchange, dryRun bool) (*PairSyncResult, error) {
+	result := &PairSyncResult{Exchange: ex.Code, Changes: []PairChange{}, CoinsCreated: []string{}, DryRun: dryRun}
+
+	connector, err := connectors.New(s.cfg.Exchange, s.db, ex, nil)
+	if err != nil {
+		return result, err
+	}
+	symbols, err := connector.Symbols(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch symbols: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pairs []models.TradingPair
+		if err := tx.Unscoped().Where("exchange_id = ?", ex.ID).Find(&pairs).Error; err != nil {
+			return fmt.Errorf("failed to load trading pairs: %w", err)
+		}
+		existing := make(map[string]*models.TradingPair, len(pairs))
+		for i := range pairs {
+			existing[pairs[i].Symbol] = &pairs[i]
+		}
+		coins := &coinResolver{tx: tx, dryRun: dryRun, ids: make(map[string]uint), result: result}
+
+		listed := make(map[string]bool, len(symbols))
+		for _, symbol := range symbols {
+			listed[symbol.Symbol] = true
+			pair, ok := existing[symbol.Symbol]
+			switch {
+			case !ok:
+				if err := s.createPair(tx, ex, symbol, coins, dryRun, result); err != nil {
+					return err
+				}
+			case pair.DeletedAt.Valid:
+				continue
+			default:
+				wanted := pairValues(symbol)
+				reason := ""
+				if pair.IsActive && !wanted.IsActive {
+					reason = ReasonHalted
+				}
+				if err := updatePair(tx, pair, wanted, reason, dryRun, result); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range pairs {
+			pair := &pairs[i]
+			if listed[pair.Symbol] || pair.DeletedAt.Valid || !pair.IsActive {
+				continue
+			}
+			wanted := *pair
+			wanted.IsActive = false
+			if err := updatePair(tx, pair, wanted, ReasonDelisted, dryRun, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool { return result.Changes[i].Symbol < result.Changes[j].Symbol })
+	sort.Strings(result.CoinsCreated)
+	return result, nil
+}
+
+func (s *Service) createPair(tx *gorm.DB, ex *models.Exchange, symbol exchange.Symbol, coins *coinResolver, dryRun bool, result *PairSyncResult) error {
+	pair := pairValues(symbol)
+	pair.ExchangeID = ex.ID
+	var err error
+	if pair.BaseCoinID, err = coins.id(symbol.Base); err != nil {
+		return err
+	}
+	if pair.QuoteCoinID, err = coins.id(symbol.Quote); err != nil {
+		return err
+	}
+
+	result.Changes = append(result.Changes, PairChange{
+		Symbol: symbol.Symbol,
+		Action: PairCreated,
+		Fields: diffPair(&models.TradingPair{}, &pair, true),
+	})
+	if dryRun {
+		return nil
+	}
+	// Create skips zero values in favor of the column defaults, which would
+	// turn a halted pair active and a precision of 0 into 8
+	columns := pairColumns(pair)
+	if err := tx.Create(&pair).Error; err != nil {
+		return fmt.Errorf("failed to create trading pair %s: %w", symbol.Symbol, err)
+	}
+	if err := tx.Model(&pair).Updates(columns).Error; err != nil {
+		return fmt.Errorf("failed to create trading pair %s: %w", symbol.Symbol, err)
+	}
+	return nil
+}
+
+// updatePair stores the changed columns of a pair. A pair that becomes
+// inactive is reported as deactivated for the reason.
+func updatePair(tx *gorm.DB, pair *models.TradingPair, wanted models.TradingPair, reason string, dryRun bool, result *PairSyncResult) error {
+	fields := diffPair(pair, &wanted, false)
+	if len(fields) == 0 {
+		result.Unchanged++
+		return nil
+	}
+
+	change := PairChange{Symbol: pair.Symbol, Action: PairUpdated, Fields: fields}
+	if pair.IsActive && !wanted.IsActive {
+		change.Action = PairDeactivated
+		change.Reason = reason
+	}
+	result.Changes = append(result.Changes, change)
+	if dryRun {
+		return nil
+	}
+
+	if err := tx.Model(&models.TradingPair{}).Where("id = ?", pair.ID).Updates(pairColumns(wanted)).Error; err != nil {
+		return fmt.Errorf("failed to update trading pair %s: %w", pair.Symbol, err)
+	}
+	return nil
+}
+
+// pairValues maps an exchange symbol onto the synced columns of a pair
+func pairValues(symbol exchange.Symbol) models.TradingPair {
+	return models.TradingPair{
+		Symbol:            symbol.Symbol,
+		IsActive:          symbol.Active,
+		MinOrderSize:      roundSize(symbol.MinQuantity),
+		MaxOrderSize:      roundSize(symbol.MaxQuantity),
+		PricePrecision:    symbol.PricePrecision,
+		QuantityPrecision: symbol.QuantityPrecision,
+	}
+}
+
+// pairColumns returns the synced columns of a pair, zero values included
+func pairColumns(pair models.TradingPair) map[string]interface{} {
+	return map[string]interface{}{
+		"is_active":          pair.IsActive,
+		"min_order_size":     pair.MinOrderSize,
+		"max_order_size":     pair.MaxOrderSize,
+		"price_precision":    pair.PricePrecision,
+		"quantity_precision": pair.QuantityPrecision,
+	}
+}
+
+// diffPair lists the synced columns that differ; all of them when created
+func diffPair(old, wanted *models.TradingPair, created bool) []FieldChange {
+	fields := make([]FieldChange, 0, 5)
+	add := func(field, from, to string) {
+		if created || from != to {
+			fields = append(fields, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+	if !created {
+		add("is_active", strconv.FormatBool(old.IsActive), strconv.FormatBool(wanted.IsActive))
+	} else if !wanted.IsActive {
+		fields = append(fields, FieldChange{Field: "is_active", To: "false"})
+	}
+	add("min_order_size", formatSize(roundSize(old.MinOrderSize)), formatSize(wanted.MinOrderSize))
+	add("max_order_size", formatSize(roundSize(old.MaxOrderSize)), formatSize(wanted.MaxOrderSize))
+	add("price_precision", strconv.Itoa(old.PricePrecision), strconv.Itoa(wanted.PricePrecision))
+	add("quantity_precision", strconv.Itoa(old.QuantityPrecision), strconv.Itoa(wanted.QuantityPrecision))
+	if created {
+		for i := range fields {
+			fields[i].From = ""
+		}
+	}
+	return fields
+}
+
+func roundSize(value float64) float64 {
+	return math.Round(value*sizeScale) / sizeScale
+}
+
+func formatSize(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// coinResolver finds coins by symbol and creates the missing ones
+type coinResolver struct {
+	tx     *gorm.DB
+	dryRun bool
+	ids    map[string]uint
+	result *PairSyncResult
+}
+
+func (r *coinResolver) id(symbol string) (uint, error) {
+	symbol = strings.ToUpper(symbol)
+	if id, ok := r.ids[symbol]; ok {
+		return id, nil
+	}
+
+	var coin models.Coin
+	err := r.tx.Unscoped().Where("symbol = ?", symbol).First(&coin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		r.result.CoinsCreated = append(r.result.CoinsCreated, symbol)
+		if !r.dryRun {
+			// The name is unknown until the coin's metadata is filled in
+			coin = models.Coin{Symbol: symbol, Name: symbol, IsActive: true}
+			err = r.tx.Create(&coin).Error
+		} else {
+			err = nil
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve coin %s: %w", symbol, err)
+	}
+	r.ids[symbol] = coin.ID
+	return coin.ID, nil
+}
@@ -0,0 +1,115 @@
+package market
+
+import (
+	"context"
+	"fmt"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/models"
+)
+
+// bridgeCurrencies value currencies that are not traded against the
+// valuation currency, in this order
+var bridgeCurrencies = []string{"BTC", "ETH"}
+
+// Prices values currencies in one currency at the last prices of an
+// exchange's active pairs
+type Prices struct {
+	currency string
+	// rates maps base and quote currency to the price of the pair
+	rates map[string]map[string]float64
+}
+
+// NewPrices values currencies in currency with the tickers of the symbols
+func NewPrices(currency string, symbols []exchange.Symbol, tickers []exchange.Ticker) *Prices {
+	last := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		if price := tickerPrice(ticker); price > 0 {
+			last[ticker.Symbol] = price
+		}
+	}
+
+	prices := &Prices{currency: currency, rates: make(map[string]map[string]float64)}
+	for _, symbol := range symbols {
+		price, ok := last[symbol.Symbol]
+		if !symbol.Active || !ok {
+			continue
+		}
+		if prices.rates[symbol.Base] == nil {
+			prices.rates[symbol.Base] = make(map[string]float64)
+		}
+		prices.rates[symbol.Base][symbol.Quote] = price
+	}
+	return prices
+}
+
+// Prices loads the prices of an exchange in currency
+func (s *Service) Prices(ctx context.Context, ex *models.Exchange, currency string) (*Prices, error) {
+	connector, err := connectors.New(s.cfg.Exchange, s.db, ex, nil)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := connector.Symbols(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch symbols: %w", err)
+	}
+	tickers, err := connector.Tickers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tickers: %w", err)
+	}
+	return NewPrices(currency, symbols, tickers), nil
+}
+
+// Currency is the currency prices are in
+func (p *Prices) Currency() string {
+	return p.currency
+}
+
+// Price returns the value of one unit of the currency, false when the
+// exchange has no pair to price it. Currencies without a pair to the
+// valuation currency are valued through BTC or ETH.
+func (p *Prices) Price(currency string) (float64, bool) {
+	if currency == p.currency {
+		return 1, true
+	}
+	if price, ok := p.rate(currency, p.currency); ok {
+		return price, true
+	}
+	for _, bridge := range bridgeCurrencies {
+		if bridge == currency || bridge == p.currency {
+			continue
+		}
+		toBridge, ok := p.rate(currency, bridge)
+		if !ok {
+			continue
+		}
+		if bridgePrice, ok := p.rate(bridge, p.currency); ok {
+			return toBridge * bridgePrice, true
+		}
+	}
+	return 0, false
+}
+
+// rate is the price of from in to on a pair in either direction
+func (p *Prices) rate(from, to string) (float64, bool) {
+	if price, ok := p.rates[from][to]; ok {
+		return price, true
+	}
+	if price, ok := p.rates[to][from]; ok {
+		return 1 / price, true
+	}
+	return 0, false
+}
+
+// tickerPrice is the last trade price, or the middle of the spread before
+// the first trade
+func tickerPrice(ticker exchange.Ticker) float64 {
+	if ticker.Last > 0 {
+		return ticker.Last
+	}
+	if ticker.Bid > 0 && ticker.Ask > 0 {
+		return (ticker.Bid + ticker.Ask) / 2
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"trader/internal/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var ErrNoMarketCaps = errors.New("market cap provider returned no coins")
+
+// MarketCapSyncResult is the outcome of a market cap refresh
+type MarketCapSyncResult struct {
+	// Ranked counts the coins the provider ranked; Unranked lists the others
+	Ranked   int      `json:"ranked"`
+	Unranked []string `json:"unranked"`
+	// Entered and DroppedOut list the coins that joined and left the top
+	Entered    []string `json:"entered"`
+	DroppedOut []string `json:"dropped_out"`
+}
+
+// SyncMarketCaps refreshes the market cap, rank and sort order of every coin
+// from the provider. Coins ranked within the configured top are flagged
+// TopRanked; a coin that leaves the top gets DroppedOutAt and is reported.
+// Coins the provider does not rank sort after the ranked ones.
+func (s *Service) SyncMarketCaps(ctx context.Context, provider MarketCapProvider) (*MarketCapSyncResult, error) {
+	top := s.cfg.Market.TopCoins
+	// Fetching past the top shows how far a coin that dropped out fell
+	caps, err := provider.MarketCaps(ctx, max(top, coinGeckoPageSize))
+	if err != nil {
+		return nil, err
+	}
+	// An empty response would drop every coin out of the top
+	if len(caps) == 0 {
+		return nil, ErrNoMarketCaps
+	}
+
+	// Symbols are not unique across all tokens; the largest one is meant
+	bySymbol := make(map[string]MarketCap, len(caps))
+	lastRank := 0
+	for _, marketCap := range caps {
+		if existing, ok := bySymbol[marketCap.Symbol]; !ok || marketCap.Rank < existing.Rank {
+			bySymbol[marketCap.Symbol] = marketCap
+		}
+		lastRank = max(lastRank, marketCap.Rank)
+	}
+
+	result := &MarketCapSyncResult{Unranked: []string{}, Entered: []string{}, DroppedOut: []string{}}
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var coins []models.Coin
+		if err := tx.Order("symbol").Find(&coins).Error; err != nil {
+			return fmt.Errorf("failed to load coins: %w", err)
+		}
+
+		for _, coin := range coins {
+			updates := map[string]interface{}{
+				"market_cap":            int64(0),
+				"market_cap_rank":       0,
+				"sort_order":            lastRank + 1,
+				"market_cap_updated_at": now,
+			}
+			marketCap, ranked := bySymbol[coin.Symbol]
+			if ranked {
+				result.Ranked++
+				updates["market_cap"] = marketCap.MarketCap
+				updates["market_cap_rank"] = marketCap.Rank
+				updates["sort_order"] = marketCap.Rank
+				// Coins created by the pair sync are named after their symbol
+				if coin.Name == coin.Symbol && marketCap.Name != "" {
+					updates["name"] = marketCap.Name
+				}
+			} else {
+				result.Unranked = append(result.Unranked, coin.Symbol)
+			}
+
+			topRanked := ranked && marketCap.Rank <= top
+			updates["top_ranked"] = topRanked
+			switch {
+			case coin.TopRanked && !topRanked:
+				updates["dropped_out_at"] = now
+				result.DroppedOut = append(result.DroppedOut, coin.Symbol)
+			case !coin.TopRanked && topRanked:
+				updates["dropped_out_at"] = nil
+				result.Entered = append(result.Entered, coin.Symbol)
+			}
+
+			if err := tx.Model(&models.Coin{}).Where("id = ?", coin.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update coin %s: %w", coin.Symbol, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(result.Entered)
+	sort.Strings(result.DroppedOut)
+	for _, symbol := range result.DroppedOut {
+		rank := "unranked"
+		if marketCap, ok := bySymbol[symbol]; ok {
+			rank = fmt.Sprintf("#%d", marketCap.Rank)
+		}
+		log.Warn().Str("coin", symbol).Str("rank", rank).Int("top", top).Msg("Coin dropped out of the top coins by market cap")
+	}
+	for _, symbol := range result.Entered {
+		log.Info().Str("coin", symbol).Int("rank", bySymbol[symbol].Rank).Int("top", top).Msg("Coin entered the top coins by market cap")
+	}
+	return result, nil
+}
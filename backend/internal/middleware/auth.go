@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strconv"
 	"strings"
 
 	"trader/internal/services"
@@ -48,6 +49,7 @@ func AuthMiddleware(authService *services.AuthService) fiber.Handler {
 		c.Locals("user_id", claims.UserID)
 		c.Locals("email", claims.Email)
 		c.Locals("permissions", claims.Permissions)
+		c.Locals("organization_id", claims.OrganizationID)
 		c.Locals("token", token)
 
 		return c.Next()
@@ -78,6 +80,31 @@ func RequirePermission(permission string) fiber.Handler {
 	}
 }
 
+// RequireAnyPermission checks if user has at least one of the permissions
+func RequireAnyPermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userPermissions, ok := c.Locals("permissions").([]string)
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Code:    "FORBIDDEN",
+				Message: "Unable to verify permissions",
+			})
+		}
+
+		for _, permission := range permissions {
+			if hasPermission(userPermissions, permission) {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+			Code:    "INSUFFICIENT_PERMISSIONS",
+			Message: "You do not have permission to access this resource",
+			Details: "Required one of: " + strings.Join(permissions, ", "),
+		})
+	}
+}
+
 // RequireResourceAccess checks if user can access specific resource
 // This middleware should be used for endpoints that access user-specific resources
 func RequireResourceAccess(resourceParam string) fiber.Handler {
@@ -195,6 +222,7 @@ func OptionalAuth(authService *services.AuthService) fiber.Handler {
 		c.Locals("user_id", claims.UserID)
 		c.Locals("email", claims.Email)
 		c.Locals("permissions", claims.Permissions)
+		c.Locals("organization_id", claims.OrganizationID)
 		c.Locals("authenticated", true)
 
 		return c.Next()
@@ -237,22 +265,42 @@ func canAccessResource(userID uint, resourceID string, c *fiber.Ctx, permissions
 		if resourceID == "me" {
 			return true
 		}
-		// Convert resourceID to uint and compare with userID
-		// In a real implementation, you'd parse the resourceID properly
-		return resourceID == string(rune(userID+'0')) // Simplified check
+		id, err := strconv.ParseUint(resourceID, 10, 64)
+		return err == nil && uint(id) == userID
 
 	case strings.Contains(path, "/profile"):
 		// Profile endpoints are always for current user
 		return true
 
 	case strings.Contains(path, "/api-keys/"), strings.Contains(path, "/positions/"):
-		// For API keys and positions, you'd query the database to check ownership
-		// This is a placeholder - implement actual ownership check
-		return hasPermission(permissions, "read_own") ||
-			hasPermission(permissions, "manage_own")
+		// API keys and positions belong to an organization. The row itself is
+		// looked up within the workspace scope, so the _own permission and the
+		// workspace role are what is checked here.
+		workspace, ok := c.Locals("workspace").(*services.Workspace)
+		if !ok {
+			return false
+		}
+		resource := "api_keys"
+		if strings.Contains(path, "/positions/") {
+			resource = "positions"
+		}
+		action, workspaceAction := ownResourceAction(c.Method())
+		return hasPermission(permissions, resource+":"+action) && workspace.Can(workspaceAction)
 
 	default:
 		// Default to deny access for unknown resources
 		return false
 	}
 }
+
+// ownResourceAction maps a request method onto the _own permission action and the workspace action it needs
+func ownResourceAction(method string) (string, string) {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead:
+		return "read_own", services.OrgActionRead
+	case fiber.MethodDelete:
+		return "delete_own", services.OrgActionWrite
+	default:
+		return "update_own", services.OrgActionWrite
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+
+	"trader/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireWorkspace resolves the organization carried by the access token and
+// verifies the user is still a member. Must run after AuthMiddleware.
+func RequireWorkspace(organizationService *services.OrganizationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Code:    "FORBIDDEN",
+				Message: "Unable to verify user identity",
+			})
+		}
+
+		organizationID, _ := c.Locals("organization_id").(uint)
+		if organizationID == 0 {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Code:    "NO_WORKSPACE",
+				Message: "Token is not bound to a workspace, log in again",
+			})
+		}
+
+		// Membership is checked on every request so removed members lose access
+		// before their access token expires
+		workspace, err := organizationService.Workspace(c.Context(), organizationID, userID)
+		if err != nil {
+			if errors.Is(err, services.ErrNotOrganizationMember) {
+				return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+					Code:    "WORKSPACE_ACCESS_DENIED",
+					Message: "You are not a member of this workspace",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "Unable to verify workspace membership",
+			})
+		}
+
+		c.Locals("workspace", workspace)
+		return c.Next()
+	}
+}
+
+// RequireWorkspaceAction checks the caller's organization role allows an action.
+// Must run after RequireWorkspace.
+func RequireWorkspaceAction(action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		workspace, ok := c.Locals("workspace").(*services.Workspace)
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Code:    "FORBIDDEN",
+				Message: "Unable to verify workspace membership",
+			})
+		}
+
+		if !workspace.Can(action) {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Code:    "INSUFFICIENT_WORKSPACE_ROLE",
+				Message: "Your role in this workspace does not allow this action",
+				Details: "Required action: " + action,
+			})
+		}
+
+		return c.Next()
+	}
+}
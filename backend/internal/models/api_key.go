@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey holds an organization's credentials for an exchange. Credential fields
+// are ciphertexts sealed with the record's data key, which is stored wrapped by
+// the master key of MasterKeyVersion; they are cleared when the key is deleted.
+// FeeTier selects the fee schedules of the exchange that apply to the account.
+// The Can* and Test* fields hold the result of the last connection test and are
+// reset when the credentials are replaced.
+type APIKey struct {
+	gorm.Model
+	OrganizationID      uint       `gorm:"not null;index" json:"organization_id"`
+	ExchangeID          uint       `gorm:"not null;index" json:"exchange_id"`
+	FeeTier             string     `gorm:"not null;size:50;default:'default'" json:"fee_tier"` // The account's fee tier on the exchange
+	CreatedBy           *uint      `gorm:"index" json:"created_by,omitempty"`
+	Name                string     `gorm:"not null;size:100" json:"name"`
+	KeyPreview          string     `gorm:"not null;size:32" json:"key_preview"` // Masked API key, e.g. "AbCd…wXyZ"
+	EncryptedKey        []byte     `json:"-"`
+	EncryptedSecret     []byte     `json:"-"`
+	EncryptedPassphrase []byte     `json:"-"`
+	WrappedDataKey      []byte     `json:"-"`
+	MasterKeyVersion    int        `gorm:"not null;default:1;index" json:"-"` // Master key version that wraps WrappedDataKey
+	IsActive            bool       `gorm:"not null;default:true" json:"is_active"`
+	CanRead             bool       `gorm:"not null;default:false" json:"can_read"`
+	CanTrade            bool       `gorm:"not null;default:false" json:"can_trade"`
+	CanWithdraw         bool       `gorm:"not null;default:false" json:"can_withdraw"`
+	TestStatus          string     `gorm:"not null;size:20;default:''" json:"test_status"` // ok, auth_failed, withdraw_enabled, error; empty until tested
+	TestError           string     `gorm:"not null;size:255;default:''" json:"test_error,omitempty"`
+	TestLatencyMS       *int       `json:"test_latency_ms,omitempty"`
+	LastTestedAt        *time.Time `json:"last_tested_at,omitempty"`
+	LastUsedAt          *time.Time `json:"last_used_at,omitempty"`
+
+	// Relations
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Exchange     Exchange     `gorm:"foreignKey:ExchangeID" json:"exchange,omitempty"`
+}
+
+// TableName overrides the table name used by APIKey to `api_keys`
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditChainHead holds the tip of the audit log hash chain.
+// A single row (ID = 1) is locked while appending so that concurrent writers
+// always link to the latest entry. ArchivedLogID/ArchivedHash anchor the start
+// of the chain once old entries have been moved out by the retention job, and
+// ArchivedSignature signs the anchor with the checkpoint secret.
+type AuditChainHead struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	LastLogID         uint      `gorm:"not null;default:0" json:"last_log_id"`
+	LastHash          string    `gorm:"not null;size:64;default:''" json:"last_hash"`
+	ArchivedLogID     uint      `gorm:"not null;default:0" json:"archived_log_id"`
+	ArchivedHash      string    `gorm:"not null;size:64;default:''" json:"archived_hash"`
+	ArchivedSignature string    `gorm:"not null;size:64;default:''" json:"archived_signature"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName overrides the table name used by AuditChainHead to `audit_chain_heads`
+func (AuditChainHead) TableName() string {
+	return "audit_chain_heads"
+}
+
+// AuditCheckpoint is a signed snapshot of the audit log chain at a given entry
+type AuditCheckpoint struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	LastLogID uint      `gorm:"not null;index" json:"last_log_id"`
+	Hash      string    `gorm:"not null;size:64" json:"hash"`
+	Signature string    `gorm:"not null;size:64" json:"signature"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName overrides the table name used by AuditCheckpoint to `audit_checkpoints`
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
@@ -16,10 +16,12 @@ type AuditLog struct {
 	NewValues  json.RawMessage `gorm:"type:json" json:"new_values,omitempty"`
 	IPAddress  *string         `gorm:"size:45" json:"ip_address,omitempty"`
 	UserAgent  *string         `gorm:"type:text" json:"user_agent,omitempty"`
+	PrevHash   string          `gorm:"not null;size:64;default:''" json:"prev_hash"`
+	Hash       string          `gorm:"not null;size:64;default:'';index" json:"hash"`
 	CreatedAt  time.Time       `gorm:"autoCreateTime;index" json:"created_at"`
 
-	// Relations
-	User *User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user,omitempty"`
+	// Relations. No database constraint: entries outlive purged users and are never rewritten.
+	User *User `gorm:"foreignKey:UserID;constraint:-" json:"user,omitempty"`
 }
 
 // TableName overrides the table name used by AuditLog to `audit_logs`
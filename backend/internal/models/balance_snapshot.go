@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BalanceSnapshot holds the balances of the account behind an API key.
+// Balances is a JSON list of the non-zero currencies. When the balances have
+// not changed since the last snapshot, LastSeenAt and the values of that
+// snapshot are updated instead of adding one. Value is nil when no currency
+// could be priced.
+type BalanceSnapshot struct {
+	ID                uint            `gorm:"primarykey" json:"id"`
+	APIKeyID          uint            `gorm:"not null;index:idx_balance_snapshots_key_taken" json:"api_key_id"`
+	Balances          json.RawMessage `gorm:"type:json;not null" json:"balances"`
+	Value             *float64        `gorm:"type:decimal(32,8)" json:"value"`
+	ValuationCurrency string          `gorm:"not null;size:20" json:"valuation_currency"`
+	TakenAt           time.Time       `gorm:"not null;index:idx_balance_snapshots_key_taken" json:"taken_at"`
+	LastSeenAt        time.Time       `gorm:"not null" json:"last_seen_at"`
+
+	// Relations
+	APIKey APIKey `gorm:"foreignKey:APIKeyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name used by BalanceSnapshot to `balance_snapshots`
+func (BalanceSnapshot) TableName() string {
+	return "balance_snapshots"
+}
+
+// BalanceEvent reports a change between two snapshots of an account, such as
+// a loss in value that trades and price moves do not explain. Values are in
+// ValuationCurrency; Changes lists the currencies that decreased.
+type BalanceEvent struct {
+	ID                uint            `gorm:"primarykey" json:"id"`
+	APIKeyID          uint            `gorm:"not null;index:idx_balance_events_key_created" json:"api_key_id"`
+	SnapshotID        uint            `gorm:"not null" json:"snapshot_id"`
+	Type              string          `gorm:"not null;size:30" json:"type"`
+	ValuationCurrency string          `gorm:"not null;size:20" json:"valuation_currency"`
+	ValueBefore       float64         `gorm:"type:decimal(32,8);not null" json:"value_before"`
+	ValueAfter        float64         `gorm:"type:decimal(32,8);not null" json:"value_after"`
+	DropPercent       float64         `gorm:"type:decimal(8,4);not null" json:"drop_percent"`
+	Changes           json.RawMessage `gorm:"type:json;not null" json:"changes"`
+	CreatedAt         time.Time       `gorm:"index:idx_balance_events_key_created" json:"created_at"`
+
+	// Relations
+	APIKey   APIKey          `gorm:"foreignKey:APIKeyID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Snapshot BalanceSnapshot `gorm:"foreignKey:SnapshotID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name used by BalanceEvent to `balance_events`
+func (BalanceEvent) TableName() string {
+	return "balance_events"
+}
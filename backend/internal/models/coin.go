@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Coin represents a cryptocurrency
 type Coin struct {
@@ -10,6 +14,13 @@ type Coin struct {
 	IsActive  bool   `gorm:"default:true" json:"is_active"`
 	MarketCap int64  `gorm:"default:0" json:"market_cap"`
 	SortOrder int    `gorm:"default:0;index" json:"sort_order"`
+
+	// Market cap ranking, refreshed from the market cap provider. MarketCapRank
+	// is 0 for coins the provider does not rank.
+	MarketCapRank      int        `gorm:"not null;default:0" json:"market_cap_rank"`
+	TopRanked          bool       `gorm:"not null;default:false;index" json:"top_ranked"`
+	DroppedOutAt       *time.Time `json:"dropped_out_at,omitempty"`
+	MarketCapUpdatedAt *time.Time `json:"market_cap_updated_at,omitempty"`
 }
 
 func (Coin) TableName() string {
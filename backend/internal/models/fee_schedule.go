@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// FeeSchedule holds the trading fees of an exchange for the accounts of one
+// fee tier, for one symbol or, with an empty Symbol, for every symbol without
+// its own schedule. Rates are fractions of the traded value; a negative maker
+// rate is a rebate.
+type FeeSchedule struct {
+	ID          uint    `gorm:"primarykey" json:"id"`
+	ExchangeID  uint    `gorm:"not null;uniqueIndex:idx_fee_schedules_tier_symbol" json:"exchange_id"`
+	Tier        string  `gorm:"not null;size:50;default:'default';uniqueIndex:idx_fee_schedules_tier_symbol" json:"tier"`
+	Symbol      string  `gorm:"not null;size:50;default:'';uniqueIndex:idx_fee_schedules_tier_symbol" json:"symbol"`
+	MakerRate   float64 `gorm:"type:decimal(12,8);not null;default:0" json:"maker_rate"`
+	TakerRate   float64 `gorm:"type:decimal(12,8);not null;default:0" json:"taker_rate"`
+	FeeCurrency string  `gorm:"not null;size:10;default:'quote'" json:"fee_currency"` // base, quote, received or native
+	// NativeCurrency is the exchange's token that native fees are paid in, at
+	// NativeDiscount (a fraction) off the rates
+	NativeCurrency string     `gorm:"not null;size:20;default:''" json:"native_currency,omitempty"`
+	NativeDiscount float64    `gorm:"type:decimal(6,4);not null;default:0" json:"native_discount"`
+	Source         string     `gorm:"not null;size:10;default:'manual'" json:"source"` // manual or synced
+	SyncedAt       *time.Time `json:"synced_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relations
+	Exchange Exchange `gorm:"foreignKey:ExchangeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name used by FeeSchedule to `fee_schedules`
+func (FeeSchedule) TableName() string {
+	return "fee_schedules"
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization is a workspace shared by its members. Exchange keys, strategies
+// and positions belong to an organization rather than to a single user.
+type Organization struct {
+	gorm.Model
+	Name       string `gorm:"not null;size:100" json:"name"`
+	IsPersonal bool   `gorm:"not null;default:false" json:"is_personal"` // Created for a single user, cannot be shared
+	CreatedBy  *uint  `gorm:"index" json:"created_by,omitempty"`
+
+	// Relations
+	Members []OrganizationMember `gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"members,omitempty"`
+}
+
+// TableName overrides the table name used by Organization to `organizations`
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember links a user to an organization with a per-organization role
+type OrganizationMember struct {
+	OrganizationID uint      `gorm:"primaryKey;autoIncrement:false" json:"organization_id"`
+	UserID         uint      `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+	Role           string    `gorm:"not null;size:20" json:"role"`
+	InvitedBy      *uint     `json:"invited_by,omitempty"`
+	JoinedAt       time.Time `gorm:"autoCreateTime" json:"joined_at"`
+
+	// Relations
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"organization,omitempty"`
+	User         User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
+}
+
+// TableName overrides the table name used by OrganizationMember to `organization_members`
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
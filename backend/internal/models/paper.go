@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PaperAccount is an account of the simulated paper exchange. Every stored API
+// key of the paper exchange has its own account; the first request with a key
+// opens the account with the configured starting balances.
+type PaperAccount struct {
+	gorm.Model
+	OrganizationID uint `gorm:"not null;uniqueIndex:idx_paper_accounts_api_key" json:"organization_id"`
+	APIKeyID       uint `gorm:"not null;uniqueIndex:idx_paper_accounts_api_key" json:"api_key_id"`
+}
+
+// TableName overrides the table name used by PaperAccount to `paper_accounts`
+func (PaperAccount) TableName() string {
+	return "paper_accounts"
+}
+
+// PaperBalance is a virtual balance of a paper account. Reserved holds the
+// funds locked by open orders.
+type PaperBalance struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	AccountID uint      `gorm:"not null;uniqueIndex:idx_paper_balances_currency" json:"-"`
+	Currency  string    `gorm:"not null;size:20;uniqueIndex:idx_paper_balances_currency" json:"currency"`
+	Available float64   `gorm:"type:decimal(32,12);not null;default:0" json:"available"`
+	Reserved  float64   `gorm:"type:decimal(32,12);not null;default:0" json:"reserved"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name used by PaperBalance to `paper_balances`
+func (PaperBalance) TableName() string {
+	return "paper_balances"
+}
+
+// PaperOrder is an order on the paper exchange. Reserved is what the order
+// still locks of its balance: the quote currency for buys, the base currency
+// for sells. Fees are charged in the quote currency.
+type PaperOrder struct {
+	gorm.Model
+	AccountID      uint       `gorm:"not null;uniqueIndex:idx_paper_orders_client_order" json:"-"`
+	ClientOrderID  string     `gorm:"not null;size:64;uniqueIndex:idx_paper_orders_client_order" json:"client_order_id"`
+	Symbol         string     `gorm:"not null;size:50" json:"symbol"`
+	Base           string     `gorm:"not null;size:20" json:"base"`
+	Quote          string     `gorm:"not null;size:20" json:"quote"`
+	Side           string     `gorm:"not null;size:10" json:"side"`
+	Type           string     `gorm:"not null;size:10" json:"type"`
+	Status         string     `gorm:"not null;size:20;index" json:"status"`
+	TimeInForce    string     `gorm:"not null;size:10;default:''" json:"time_in_force"`
+	PostOnly       bool       `gorm:"not null;default:false" json:"post_only"`
+	Price          float64    `gorm:"type:decimal(32,12);not null;default:0" json:"price"`
+	Quantity       float64    `gorm:"type:decimal(32,12);not null" json:"quantity"`
+	FilledQuantity float64    `gorm:"type:decimal(32,12);not null;default:0" json:"filled_quantity"`
+	FilledQuote    float64    `gorm:"type:decimal(32,12);not null;default:0" json:"filled_quote"` // Sum of price × quantity of the fills
+	Fee            float64    `gorm:"type:decimal(32,12);not null;default:0" json:"fee"`
+	Reserved       float64    `gorm:"type:decimal(32,12);not null;default:0" json:"-"`
+	LastQuoteAt    *time.Time `json:"-"` // Time of the last quote the order was matched against
+}
+
+// TableName overrides the table name used by PaperOrder to `paper_orders`
+func (PaperOrder) TableName() string {
+	return "paper_orders"
+}
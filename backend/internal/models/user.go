@@ -8,7 +8,10 @@ import (
 // User represents a system user
 type User struct {
 	gorm.Model
-	Email         string     `gorm:"uniqueIndex;not null;size:255" json:"email"`
+	Email string `gorm:"index;not null;size:255" json:"email"`
+	// ActiveEmail is generated by the database and NULL once the user is deleted;
+	// its unique index frees the email of soft-deleted accounts
+	ActiveEmail   *string    `gorm:"->;type:varchar(255) GENERATED ALWAYS AS (CASE WHEN deleted_at IS NULL THEN email END) VIRTUAL;uniqueIndex:idx_users_active_email" json:"-"`
 	FirstName     string     `gorm:"size:100" json:"first_name"`
 	LastName      string     `gorm:"size:100" json:"last_name"`
 	PasswordHash  string     `gorm:"not null;size:255" json:"-"` // Never include in JSON
@@ -17,6 +20,7 @@ type User struct {
 	LoginAttempts int        `gorm:"default:0" json:"-"` // Never include in JSON
 	LockedUntil   *time.Time `json:"-"`                  // Never include in JSON
 	IsActive      *bool      `gorm:"default:true" json:"is_active"`
+	ErasedAt      *time.Time `gorm:"index" json:"erased_at,omitempty"` // Set when personal data was erased
 
 	// Relations
 	Roles       []Role           `gorm:"many2many:user_roles;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"roles,omitempty"`
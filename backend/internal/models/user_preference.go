@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserPreference stores a user's preference document
+type UserPreference struct {
+	UserID        uint            `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	SchemaVersion int             `gorm:"not null;default:1" json:"schema_version"`
+	Revision      int             `gorm:"not null;default:1" json:"revision"`
+	Data          json.RawMessage `gorm:"type:json;not null" json:"data"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name used by UserPreference to `user_preferences`
+func (UserPreference) TableName() string {
+	return "user_preferences"
+}
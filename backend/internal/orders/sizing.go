@@ -0,0 +1,211 @@
+// Package orders turns what a strategy or a user wants to trade into orders
+// the exchange accepts: prices on the tick, quantities on the step and sizes
+// within the pair's limits.
+package orders
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"trader/internal/exchange"
+	"trader/internal/models"
+)
+
+// Rejection reasons
+const (
+	RejectInvalidSide      = "invalid_side"
+	RejectInvalidType      = "invalid_type"
+	RejectInvalidPrice     = "invalid_price"
+	RejectInvalidSize      = "invalid_size"
+	RejectBelowStep        = "below_quantity_step"
+	RejectBelowMinQuantity = "below_min_quantity"
+	RejectAboveMaxQuantity = "above_max_quantity"
+	RejectBelowMinNotional = "below_min_notional"
+)
+
+// epsilon absorbs float noise such as 0.3/0.1 = 2.9999999999999996 before
+// rounding to a step
+const epsilon = 1e-9
+
+// Filters are the exchange's limits for the orders of one pair. Zero values
+// are not enforced.
+type Filters struct {
+	PriceTick         float64
+	QuantityStep      float64
+	PricePrecision    int
+	QuantityPrecision int
+	MinQuantity       float64
+	MaxQuantity       float64
+	// MinNotional is the smallest order value in the quote currency
+	MinNotional float64
+}
+
+// FiltersFromPair reads the filters of a stored trading pair. Its ticks and
+// steps follow from the precisions.
+func FiltersFromPair(pair *models.TradingPair) Filters {
+	return Filters{
+		PriceTick:         math.Pow10(-pair.PricePrecision),
+		QuantityStep:      math.Pow10(-pair.QuantityPrecision),
+		PricePrecision:    pair.PricePrecision,
+		QuantityPrecision: pair.QuantityPrecision,
+		MinQuantity:       pair.MinOrderSize,
+		MaxQuantity:       pair.MaxOrderSize,
+	}
+}
+
+// FiltersFromSymbol reads the filters an exchange reports for a symbol
+func FiltersFromSymbol(symbol exchange.Symbol) Filters {
+	filters := Filters{
+		PriceTick:         symbol.PriceTick,
+		QuantityStep:      symbol.QuantityStep,
+		PricePrecision:    symbol.PricePrecision,
+		QuantityPrecision: symbol.QuantityPrecision,
+		MinQuantity:       symbol.MinQuantity,
+		MaxQuantity:       symbol.MaxQuantity,
+		MinNotional:       symbol.MinNotional,
+	}
+	if filters.PriceTick <= 0 {
+		filters.PriceTick = math.Pow10(-symbol.PricePrecision)
+	}
+	if filters.QuantityStep <= 0 {
+		filters.QuantityStep = math.Pow10(-symbol.QuantityPrecision)
+	}
+	return filters
+}
+
+// Intent is an order as the caller wants it, before it is fitted to the
+// filters. It is sized either by Notional or by Quantity.
+type Intent struct {
+	Symbol string
+	Side   string
+	Type   string
+	// Notional is the order value in the quote currency, e.g. a DCA amount
+	Notional float64
+	// Quantity is the order size in the base currency, e.g. a position to sell
+	Quantity float64
+	// Price is the limit price. Market orders need it too, as the expected
+	// price the notional is converted and checked at; it is not sent.
+	Price         float64
+	TimeInForce   string
+	PostOnly      bool
+	ClientOrderID string
+}
+
+// Rejection explains why an intent cannot become a valid order. It matches
+// exchange.ErrInvalidOrder with errors.Is.
+type Rejection struct {
+	Reason  string
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return "order rejected: " + r.Message
+}
+
+func (r *Rejection) Unwrap() error {
+	return exchange.ErrInvalidOrder
+}
+
+func reject(reason, format string, args ...interface{}) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Size fits an intent to the filters. Rounding never makes the order worse
+// for the caller: buy prices round down and sell prices up to the tick, and
+// quantities round down to the step, so a buy never spends more than its
+// notional and a sell never sells more than it holds. An order that does not
+// fit after rounding is rejected with a *Rejection.
+func Size(filters Filters, intent Intent) (*exchange.OrderRequest, error) {
+	if intent.Side != exchange.OrderSideBuy && intent.Side != exchange.OrderSideSell {
+		return nil, reject(RejectInvalidSide, "side must be %s or %s, got %q", exchange.OrderSideBuy, exchange.OrderSideSell, intent.Side)
+	}
+	if intent.Type != exchange.OrderTypeLimit && intent.Type != exchange.OrderTypeMarket {
+		return nil, reject(RejectInvalidType, "type must be %s or %s, got %q", exchange.OrderTypeLimit, exchange.OrderTypeMarket, intent.Type)
+	}
+	if intent.Price <= 0 || math.IsNaN(intent.Price) || math.IsInf(intent.Price, 0) {
+		return nil, reject(RejectInvalidPrice, "price must be positive, got %s", format(intent.Price))
+	}
+	if (intent.Notional > 0) == (intent.Quantity > 0) || intent.Notional < 0 || intent.Quantity < 0 {
+		return nil, reject(RejectInvalidSize, "exactly one of notional and quantity must be positive")
+	}
+
+	price := intent.Price
+	if intent.Type == exchange.OrderTypeLimit {
+		price = roundPrice(filters, intent.Side, price)
+		if price <= 0 {
+			return nil, reject(RejectInvalidPrice, "price %s is below the tick %s", format(intent.Price), format(filters.PriceTick))
+		}
+	}
+
+	wanted := intent.Quantity
+	if intent.Notional > 0 {
+		wanted = intent.Notional / price
+	}
+	quantity := roundQuantity(filters, wanted)
+	if quantity <= 0 {
+		return nil, reject(RejectBelowStep, "quantity %s is below the quantity step %s", format(wanted), format(filters.QuantityStep))
+	}
+	if filters.MinQuantity > 0 && quantity < filters.MinQuantity-epsilon*filters.MinQuantity {
+		return nil, reject(RejectBelowMinQuantity, "quantity %s is below the minimum quantity %s", format(quantity), format(filters.MinQuantity))
+	}
+	if filters.MaxQuantity > 0 && quantity > filters.MaxQuantity+epsilon*filters.MaxQuantity {
+		return nil, reject(RejectAboveMaxQuantity, "quantity %s is above the maximum quantity %s", format(quantity), format(filters.MaxQuantity))
+	}
+	if notional := quantity * price; filters.MinNotional > 0 && notional < filters.MinNotional-epsilon*filters.MinNotional {
+		return nil, reject(RejectBelowMinNotional, "order value %s is below the minimum order value %s", format(snap(notional, 8)), format(filters.MinNotional))
+	}
+
+	request := &exchange.OrderRequest{
+		Symbol:        intent.Symbol,
+		Side:          intent.Side,
+		Type:          intent.Type,
+		Quantity:      quantity,
+		TimeInForce:   intent.TimeInForce,
+		PostOnly:      intent.PostOnly,
+		ClientOrderID: intent.ClientOrderID,
+	}
+	if intent.Type == exchange.OrderTypeLimit {
+		request.Price = price
+	}
+	return request, nil
+}
+
+// roundPrice rounds a limit price to the tick in the caller's favor
+func roundPrice(filters Filters, side string, price float64) float64 {
+	if filters.PriceTick <= 0 {
+		return price
+	}
+	ticks := price / filters.PriceTick
+	if side == exchange.OrderSideBuy {
+		ticks = math.Floor(ticks + epsilon)
+	} else {
+		ticks = math.Ceil(ticks - epsilon)
+	}
+	return snap(ticks*filters.PriceTick, decimals(filters.PriceTick, filters.PricePrecision))
+}
+
+// roundQuantity rounds a quantity down to the step
+func roundQuantity(filters Filters, quantity float64) float64 {
+	if filters.QuantityStep <= 0 {
+		return quantity
+	}
+	steps := math.Floor(quantity/filters.QuantityStep + epsilon)
+	return snap(steps*filters.QuantityStep, decimals(filters.QuantityStep, filters.QuantityPrecision))
+}
+
+// decimals returns the decimals needed to write multiples of the step, at
+// least the precision
+func decimals(step float64, precision int) int {
+	return max(precision, int(math.Ceil(-math.Log10(step)-epsilon)), 0)
+}
+
+// snap removes the float noise of a multiplication, so 3 * 0.1 becomes 0.3
+func snap(value float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Round(value*scale) / scale
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
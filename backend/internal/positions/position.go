@@ -0,0 +1,141 @@
+// Package positions keeps the books of a position that buys build and sells
+// reduce, net of the fees of every trade: what the held quantity cost, the
+// profit made and the prices it can be sold at without a loss.
+package positions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"trader/internal/exchange"
+	"trader/internal/fees"
+)
+
+var ErrInsufficientQuantity = errors.New("position holds less than the sell needs")
+
+// epsilon absorbs float noise when a sell empties the position
+const epsilon = 1e-9
+
+// Position is a holding of one symbol's base currency. Every fee adds to its
+// cost, so its prices and profits are net of fees: fees charged in the base
+// currency reduce the quantity held, the others are paid on top.
+type Position struct {
+	Fees fees.Schedule
+	// Quantity is the base currency held, after fees charged in it
+	Quantity float64
+	// Cost is what the held quantity cost in the quote currency, fees included
+	Cost float64
+	// Realized is the profit of the sells so far, net of fees
+	Realized float64
+	// FeesPaid is the value of every fee in the quote currency
+	FeesPaid float64
+}
+
+// New opens an empty position that trades under the fee schedule
+func New(schedule fees.Schedule) *Position {
+	return &Position{Fees: schedule}
+}
+
+// Buy adds a fill of quantity at price
+func (p *Position) Buy(quantity, price float64, taker bool) fees.Fee {
+	fee := p.Fees.Charge(exchange.OrderSideBuy, taker, quantity, price)
+	p.Quantity += quantity
+	p.Cost += quantity * price
+	if fee.Currency == fees.InBase {
+		p.Quantity -= fee.Amount
+	} else {
+		p.Cost += fee.Value
+	}
+	p.FeesPaid += fee.Value
+	return fee
+}
+
+// Sell removes a fill of quantity at price and realizes its profit against
+// the cost of what it took from the position. A fee charged in the base
+// currency comes out of the position as well; a sell that needs more than the
+// position holds is refused with ErrInsufficientQuantity.
+func (p *Position) Sell(quantity, price float64, taker bool) (fees.Fee, error) {
+	fee := p.Fees.Charge(exchange.OrderSideSell, taker, quantity, price)
+	used := quantity
+	proceeds := quantity * price
+	if fee.Currency == fees.InBase {
+		used += fee.Amount
+	} else {
+		proceeds -= fee.Value
+	}
+	if used > p.Quantity*(1+epsilon) {
+		return fees.Fee{}, fmt.Errorf("%w: selling %s uses %s of %s", ErrInsufficientQuantity,
+			format(quantity), format(used), format(p.Quantity))
+	}
+
+	cost := p.Cost
+	if used < p.Quantity {
+		cost = p.Cost * used / p.Quantity
+	}
+	p.Realized += proceeds - cost
+	p.FeesPaid += fee.Value
+	p.Quantity -= used
+	p.Cost -= cost
+	if p.Quantity <= epsilon {
+		p.Quantity, p.Cost = 0, 0
+	}
+	return fee, nil
+}
+
+// AverageCost is the cost of one unit held, fees included
+func (p *Position) AverageCost() float64 {
+	if p.Quantity <= 0 {
+		return 0
+	}
+	return p.Cost / p.Quantity
+}
+
+// exitFactor is the share of a unit's price that selling it nets: the sell
+// fee comes off the proceeds, or a fee in the base currency needs part of
+// the unit
+func (p *Position) exitFactor(taker bool) float64 {
+	rate := p.Fees.Rate(taker)
+	if p.Fees.ChargedIn(exchange.OrderSideSell) == fees.InBase {
+		return 1 / (1 + rate)
+	}
+	return 1 - rate
+}
+
+// BreakEven is the lowest price the position can be sold at without a loss
+// after the fees of buying and selling, 0 for an empty position. Rounding a
+// sell price up to the tick, as orders.Size does, keeps it above.
+func (p *Position) BreakEven(taker bool) float64 {
+	if p.Quantity <= 0 {
+		return 0
+	}
+	return p.Cost / (p.Quantity * p.exitFactor(taker))
+}
+
+// TakeProfitPrice is the price at which selling makes percent profit on the
+// cost, net of fees. Selling part of the position at it makes the same
+// profit on that part.
+func (p *Position) TakeProfitPrice(percent float64, taker bool) float64 {
+	return p.BreakEven(taker) * (1 + percent/100)
+}
+
+// Profitable reports whether selling at price realizes no loss net of fees.
+// Pass taker=true when the sell may take liquidity, the taker rate being the
+// higher one on most exchanges.
+func (p *Position) Profitable(price float64, taker bool) bool {
+	return p.Quantity > 0 && price >= p.BreakEven(taker)*(1-epsilon)
+}
+
+// UnrealizedPnL is the profit selling the whole position at price would make
+func (p *Position) UnrealizedPnL(price float64, taker bool) float64 {
+	return p.Quantity*price*p.exitFactor(taker) - p.Cost
+}
+
+// PnL is the realized profit plus the unrealized profit at price
+func (p *Position) PnL(price float64, taker bool) float64 {
+	return p.Realized + p.UnrealizedPnL(price, taker)
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,382 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/models"
+	"trader/internal/services"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Policy names
+const (
+	PolicyAuditLogs           = "audit_logs"
+	PolicyPasswordResetTokens = "password_reset_tokens"
+	PolicyTokenBlacklist      = "token_blacklist"
+)
+
+// blacklistScanCount is the SCAN page size used for the token blacklist
+const blacklistScanCount = 500
+
+// Policy describes how long data is kept hot and whether it is archived before deletion
+type Policy struct {
+	Name    string        `json:"name"`
+	MaxAge  time.Duration `json:"max_age"`
+	Archive bool          `json:"archive"`
+}
+
+// PolicyResult summarizes what a single policy run did
+type PolicyResult struct {
+	Policy   string    `json:"policy"`
+	Cutoff   time.Time `json:"cutoff"`
+	Archived int       `json:"archived"`
+	Deleted  int       `json:"deleted"`
+	Files    []string  `json:"files,omitempty"`
+	DryRun   bool      `json:"dry_run"`
+}
+
+// Service applies retention policies: old rows are archived to compressed
+// NDJSON files and then deleted in batches
+type Service struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	store     ArchiveStore
+	audit     *services.AuditService
+	policies  []Policy
+	batchSize int
+	now       func() time.Time
+}
+
+// DefaultPolicies builds the retention policies from configuration
+func DefaultPolicies(cfg config.RetentionConfig) []Policy {
+	return []Policy{
+		{Name: PolicyAuditLogs, MaxAge: cfg.AuditLogsMaxAge, Archive: true},
+		{Name: PolicyPasswordResetTokens, MaxAge: cfg.PasswordResetMaxAge, Archive: true},
+		// Blacklisted tokens are secrets and are only useful until they expire
+		{Name: PolicyTokenBlacklist, Archive: false},
+	}
+}
+
+func NewService(db *gorm.DB, redis *redis.Client, store ArchiveStore, audit *services.AuditService, cfg *config.Config) *Service {
+	return &Service{
+		db:        db,
+		redis:     redis,
+		store:     store,
+		audit:     audit,
+		policies:  DefaultPolicies(cfg.Retention),
+		batchSize: cfg.Retention.BatchSize,
+		now:       time.Now,
+	}
+}
+
+// Policies returns the configured policies
+func (s *Service) Policies() []Policy {
+	return s.policies
+}
+
+// Run applies every policy, or only the named one when policyName is not empty
+func (s *Service) Run(ctx context.Context, policyName string, dryRun bool) ([]PolicyResult, error) {
+	results := make([]PolicyResult, 0, len(s.policies))
+	found := false
+
+	for _, policy := range s.policies {
+		if policyName != "" && policy.Name != policyName {
+			continue
+		}
+		found = true
+
+		result, err := s.apply(ctx, policy, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("retention policy %s failed: %w", policy.Name, err)
+		}
+		results = append(results, *result)
+
+		log.Info().
+			Str("policy", result.Policy).
+			Int("archived", result.Archived).
+			Int("deleted", result.Deleted).
+			Bool("dry_run", dryRun).
+			Msg("Retention policy applied")
+	}
+
+	if !found {
+		return nil, fmt.Errorf("unknown retention policy: %s", policyName)
+	}
+	return results, nil
+}
+
+func (s *Service) apply(ctx context.Context, policy Policy, dryRun bool) (*PolicyResult, error) {
+	result := &PolicyResult{Policy: policy.Name, DryRun: dryRun}
+	if policy.MaxAge > 0 {
+		result.Cutoff = s.now().Add(-policy.MaxAge).UTC()
+	}
+
+	switch policy.Name {
+	case PolicyAuditLogs:
+		return result, s.expireAuditLogs(ctx, policy, result)
+	case PolicyPasswordResetTokens:
+		return result, s.expirePasswordResetTokens(ctx, policy, result)
+	case PolicyTokenBlacklist:
+		return result, s.expireTokenBlacklist(ctx, result)
+	default:
+		return nil, fmt.Errorf("unsupported retention policy: %s", policy.Name)
+	}
+}
+
+func (s *Service) expireAuditLogs(ctx context.Context, policy Policy, result *PolicyResult) error {
+	if policy.MaxAge <= 0 {
+		return nil
+	}
+
+	db := s.db.WithContext(ctx)
+	if result.DryRun {
+		var count int64
+		if err := db.Model(&models.AuditLog{}).Where("created_at < ?", result.Cutoff).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count audit logs: %w", err)
+		}
+		result.Archived = int(count)
+		return nil
+	}
+
+	// Archive entries written by this run must not be picked up by later batches
+	var maxID uint
+	err := db.Model(&models.AuditLog{}).Where("created_at < ?", result.Cutoff).
+		Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error
+	if err != nil {
+		return fmt.Errorf("failed to find last expired audit log: %w", err)
+	}
+
+	var lastID uint
+	for {
+		// Entries are removed strictly in id order so the chain start stays contiguous
+		var logs []models.AuditLog
+		err := db.Where("id > ? AND id <= ? AND created_at < ?", lastID, maxID, result.Cutoff).
+			Order("id ASC").Limit(s.batchSize).Find(&logs).Error
+		if err != nil {
+			return fmt.Errorf("failed to load audit logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		records := make([]interface{}, len(logs))
+		ids := make([]uint, len(logs))
+		for i := range logs {
+			records[i] = logs[i]
+			ids[i] = logs[i].ID
+		}
+		first, last := logs[0], logs[len(logs)-1]
+
+		file, err := s.archive(ctx, policy, first.ID, last.ID, records)
+		if err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id IN ?", ids).Delete(&models.AuditLog{}).Error; err != nil {
+				return fmt.Errorf("failed to delete audit logs: %w", err)
+			}
+			if err := s.audit.MarkArchived(tx, last.ID, last.Hash); err != nil {
+				return err
+			}
+			return s.recordArchive(tx, policy, first.ID, last.ID, len(ids), file, last.Hash)
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Archived += len(records)
+		result.Deleted += len(ids)
+		result.Files = append(result.Files, file)
+		lastID = last.ID
+	}
+}
+
+// passwordResetTokenRecord is the archived form of a reset token. The token
+// value itself is a credential and is never written to the archive.
+type passwordResetTokenRecord struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (s *Service) expirePasswordResetTokens(ctx context.Context, policy Policy, result *PolicyResult) error {
+	if policy.MaxAge <= 0 {
+		return nil
+	}
+
+	// Tokens are hard-deleted, so soft-delete scoping is bypassed throughout
+	db := s.db.WithContext(ctx)
+	if result.DryRun {
+		var count int64
+		if err := db.Unscoped().Model(&models.PasswordResetToken{}).Where("expires_at < ?", result.Cutoff).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count password reset tokens: %w", err)
+		}
+		result.Archived = int(count)
+		return nil
+	}
+
+	var lastID uint
+	for {
+		var tokens []models.PasswordResetToken
+		err := db.Unscoped().Where("id > ? AND expires_at < ?", lastID, result.Cutoff).
+			Order("id ASC").Limit(s.batchSize).Find(&tokens).Error
+		if err != nil {
+			return fmt.Errorf("failed to load password reset tokens: %w", err)
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+
+		records := make([]interface{}, len(tokens))
+		ids := make([]uint, len(tokens))
+		for i, token := range tokens {
+			records[i] = passwordResetTokenRecord{
+				ID:        token.ID,
+				UserID:    token.UserID,
+				ExpiresAt: token.ExpiresAt,
+				UsedAt:    token.UsedAt,
+				CreatedAt: token.CreatedAt,
+			}
+			ids[i] = token.ID
+		}
+		firstID, lastBatchID := ids[0], ids[len(ids)-1]
+
+		file, err := s.archive(ctx, policy, firstID, lastBatchID, records)
+		if err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.PasswordResetToken{}).Error; err != nil {
+				return fmt.Errorf("failed to delete password reset tokens: %w", err)
+			}
+			return s.recordArchive(tx, policy, firstID, lastBatchID, len(ids), file, "")
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Archived += len(records)
+		result.Deleted += len(ids)
+		result.Files = append(result.Files, file)
+		lastID = lastBatchID
+	}
+}
+
+// expireTokenBlacklist removes blacklist keys that outlived their token.
+// Keys are normally written with a TTL; this catches keys that lost it.
+func (s *Service) expireTokenBlacklist(ctx context.Context, result *PolicyResult) error {
+	if s.redis == nil {
+		return nil
+	}
+
+	now := s.now()
+	parser := jwt.NewParser()
+	iter := s.redis.Scan(ctx, 0, services.TokenBlacklistPrefix+"*", blacklistScanCount).Iterator()
+
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		ttl, err := s.redis.TTL(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read blacklist TTL: %w", err)
+		}
+		// Negative TTL other than -1 means the key is already gone
+		if ttl != -1 {
+			continue
+		}
+
+		// Without a TTL the token's own expiry decides how long the key is needed
+		var expiresAt time.Time
+		claims := &jwt.RegisteredClaims{}
+		token := key[len(services.TokenBlacklistPrefix):]
+		if _, _, err := parser.ParseUnverified(token, claims); err == nil && claims.ExpiresAt != nil {
+			expiresAt = claims.ExpiresAt.Time
+		}
+
+		if expiresAt.After(now) {
+			if !result.DryRun {
+				if err := s.redis.ExpireAt(ctx, key, expiresAt).Err(); err != nil {
+					return fmt.Errorf("failed to set blacklist expiry: %w", err)
+				}
+			}
+			continue
+		}
+
+		if !result.DryRun {
+			if err := s.redis.Del(ctx, key).Err(); err != nil {
+				return fmt.Errorf("failed to delete blacklist key: %w", err)
+			}
+		}
+		result.Deleted++
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan token blacklist: %w", err)
+	}
+	return nil
+}
+
+// archive writes records as gzip-compressed NDJSON and returns the file location
+func (s *Service) archive(ctx context.Context, policy Policy, firstID, lastID uint, records []interface{}) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return "", fmt.Errorf("failed to encode archive record: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	now := s.now().UTC()
+	key := fmt.Sprintf("%s/%s/%s-%d-%d-%s.ndjson.gz",
+		policy.Name,
+		now.Format("2006/01"),
+		policy.Name,
+		firstID,
+		lastID,
+		now.Format("20060102T150405Z"),
+	)
+
+	if err := s.store.Put(ctx, key, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to store archive: %w", err)
+	}
+	return s.store.Location(key), nil
+}
+
+// recordArchive leaves a tamper-evident trace of every purge in the audit
+// trail. lastHash is the hash of the last archived audit log, which the chain
+// verification matches against the archive anchor; empty for other policies.
+func (s *Service) recordArchive(tx *gorm.DB, policy Policy, firstID, lastID uint, count int, file, lastHash string) error {
+	values := map[string]interface{}{
+		"first_id": firstID,
+		"last_id":  lastID,
+		"count":    count,
+		"file":     file,
+	}
+	if lastHash != "" {
+		values["last_hash"] = lastHash
+	}
+	_, err := s.audit.Record(tx, &services.AuditEntry{
+		Action:    services.AuditActionArchive,
+		Resource:  policy.Name,
+		NewValues: values,
+	})
+	return err
+}
@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"trader/internal/config"
+)
+
+// ArchiveStore persists archive files produced by the retention job
+type ArchiveStore interface {
+	// Put writes data under the given slash-separated key
+	Put(ctx context.Context, key string, data []byte) error
+	// Location returns a human readable location of the key
+	Location(key string) string
+}
+
+// NewArchiveStore creates the archive store selected in configuration
+func NewArchiveStore(cfg config.RetentionConfig) (ArchiveStore, error) {
+	switch cfg.ArchiveDriver {
+	case "local":
+		return NewLocalStore(cfg.ArchiveDir), nil
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.ArchiveS3Endpoint,
+			Region:    cfg.ArchiveS3Region,
+			Bucket:    cfg.ArchiveS3Bucket,
+			Prefix:    cfg.ArchiveS3Prefix,
+			AccessKey: cfg.ArchiveS3AccessKey,
+			SecretKey: cfg.ArchiveS3SecretKey,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown archive driver: %s", cfg.ArchiveDriver)
+	}
+}
+
+// LocalStore writes archive files into a directory on local disk
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{
+		dir: dir,
+	}
+}
+
+// Put writes the file atomically so a crash never leaves a partial archive
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte) error {
+	path := s.Location(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync archive file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close archive file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move archive file into place: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Location(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(strings.TrimPrefix(key, "/")))
+}
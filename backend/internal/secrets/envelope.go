@@ -0,0 +1,160 @@
+// Package secrets encrypts credentials at rest with envelope encryption.
+//
+// Every record gets its own random data key. The record's fields are sealed
+// with AES-256-GCM under that data key, and the data key itself is stored
+// wrapped (AES-256-GCM encrypted) by the master key. The master key never
+// touches the database and only ever encrypts other keys.
+//
+// Master keys are managed by a KeyProvider selected in configuration: a key
+// ring from the environment, a file such as a Docker secret, a passphrase
+// protected keystore, or a Vault transit key that never leaves Vault.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the size of master and data keys in bytes (AES-256)
+const KeySize = 32
+
+var (
+	ErrInvalidMasterKey = errors.New("master key must be 32 bytes, raw or base64 encoded")
+	ErrDecryption       = errors.New("failed to decrypt: wrong key or tampered data")
+)
+
+// dataKeyAAD binds wrapped data keys so they cannot be swapped with sealed fields
+var dataKeyAAD = []byte("secrets:data-key")
+
+// Envelope wraps and unwraps per-record data keys with the master key
+type Envelope struct {
+	kek cipher.AEAD
+}
+
+// DataKey seals and opens the fields of a single record
+type DataKey struct {
+	aead cipher.AEAD
+}
+
+// ParseMasterKey accepts a 32 character key or the base64 encoding of 32 random bytes
+func ParseMasterKey(value string) ([]byte, error) {
+	if len(value) == KeySize {
+		return []byte(value), nil
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(value); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidMasterKey
+}
+
+func NewEnvelope(masterKey []byte) (*Envelope, error) {
+	if len(masterKey) != KeySize {
+		return nil, ErrInvalidMasterKey
+	}
+	kek, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{kek: kek}, nil
+}
+
+// NewDataKey generates a random data key and returns it with its wrapped form for storage
+func (e *Envelope) NewDataKey() (*DataKey, []byte, error) {
+	raw := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := e.wrap(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := newDataKey(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, wrapped, nil
+}
+
+// UnwrapDataKey decrypts a stored data key
+func (e *Envelope) UnwrapDataKey(wrapped []byte) (*DataKey, error) {
+	raw, err := e.unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return newDataKey(raw)
+}
+
+func (e *Envelope) wrap(raw []byte) ([]byte, error) {
+	return seal(e.kek, raw, dataKeyAAD)
+}
+
+func (e *Envelope) unwrap(wrapped []byte) ([]byte, error) {
+	raw, err := open(e.kek, wrapped, dataKeyAAD)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != KeySize {
+		return nil, ErrDecryption
+	}
+	return raw, nil
+}
+
+func newDataKey(raw []byte) (*DataKey, error) {
+	aead, err := newGCM(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{aead: aead}, nil
+}
+
+// Seal encrypts a field. The aad names the field so ciphertexts cannot be moved between fields.
+func (k *DataKey) Seal(plaintext []byte, aad string) ([]byte, error) {
+	return seal(k.aead, plaintext, []byte(aad))
+}
+
+// Open decrypts a field sealed with the same aad
+func (k *DataKey) Open(ciphertext []byte, aad string) ([]byte, error) {
+	return open(k.aead, ciphertext, []byte(aad))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// seal returns nonce || ciphertext || tag
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecryption
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plaintext, nil
+}
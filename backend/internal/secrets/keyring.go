@@ -0,0 +1,161 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownKeyVersion = errors.New("unknown master key version")
+	ErrInvalidKeyRing    = errors.New("invalid master key ring")
+)
+
+// KeyRing holds every master key version that may still wrap stored data keys.
+// New data keys are wrapped with the active version; older versions are only
+// used to unwrap until every record has been re-wrapped.
+type KeyRing struct {
+	envelopes map[int]*Envelope
+	active    int
+}
+
+// NewKeyRing builds a key ring. An active version of 0 selects the highest version.
+func NewKeyRing(keys map[int][]byte, active int) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidKeyRing)
+	}
+
+	ring := &KeyRing{envelopes: make(map[int]*Envelope, len(keys))}
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("%w: version %d must be positive", ErrInvalidKeyRing, version)
+		}
+		envelope, err := NewEnvelope(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key version %d: %w", version, err)
+		}
+		ring.envelopes[version] = envelope
+		if active == 0 && version > ring.active {
+			ring.active = version
+		}
+	}
+
+	if active != 0 {
+		if _, ok := ring.envelopes[active]; !ok {
+			return nil, fmt.Errorf("%w: active version %d", ErrUnknownKeyVersion, active)
+		}
+		ring.active = active
+	}
+	return ring, nil
+}
+
+// ParseKeyRing reads comma separated "version:key" pairs, e.g. "1:<base64>,2:<base64>"
+func ParseKeyRing(spec string, active int) (*KeyRing, error) {
+	keys, err := ParseMasterKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(keys, active)
+}
+
+// ParseMasterKeys reads the master keys of a key ring specification by version
+func ParseMasterKeys(spec string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionPart, keyPart, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: entries must be version:key", ErrInvalidKeyRing)
+		}
+		version, err := strconv.Atoi(strings.TrimSpace(versionPart))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid version %q", ErrInvalidKeyRing, versionPart)
+		}
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrInvalidKeyRing, version)
+		}
+
+		key, err := ParseMasterKey(strings.TrimSpace(keyPart))
+		if err != nil {
+			return nil, fmt.Errorf("master key version %d: %w", version, err)
+		}
+		keys[version] = key
+	}
+	return keys, nil
+}
+
+// LoadKeyRingFile reads a key ring from a file such as a Docker secret
+// (/run/secrets/...). The file holds either a single master key, which becomes
+// version 1, or "version:key" pairs separated by commas or newlines. Lines
+// starting with # are ignored.
+func LoadKeyRingFile(path string, active int) (*KeyRing, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key ring file: %w", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	if len(entries) == 1 {
+		if key, err := ParseMasterKey(entries[0]); err == nil {
+			return NewKeyRing(map[int][]byte{1: key}, active)
+		}
+	}
+	return ParseKeyRing(strings.Join(entries, ","), active)
+}
+
+// ActiveVersion implements KeyProvider
+func (r *KeyRing) ActiveVersion(ctx context.Context) (int, error) {
+	return r.active, nil
+}
+
+// Versions implements KeyProvider
+func (r *KeyRing) Versions(ctx context.Context) ([]int, error) {
+	versions := make([]int, 0, len(r.envelopes))
+	for version := range r.envelopes {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// WrapKey implements KeyProvider with the active master key
+func (r *KeyRing) WrapKey(ctx context.Context, raw []byte) ([]byte, int, error) {
+	wrapped, err := r.envelopes[r.active].wrap(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	return wrapped, r.active, nil
+}
+
+// UnwrapKey implements KeyProvider with the master key version the data key was wrapped with
+func (r *KeyRing) UnwrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, error) {
+	envelope, ok := r.envelopes[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+	return envelope.unwrap(wrapped)
+}
+
+// RewrapKey implements KeyProvider. The data key itself, and therefore every
+// field sealed with it, stays the same.
+func (r *KeyRing) RewrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, int, error) {
+	raw, err := r.UnwrapKey(ctx, wrapped, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.WrapKey(ctx, raw)
+}
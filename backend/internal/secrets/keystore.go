@@ -0,0 +1,171 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidKeystore = errors.New("invalid keystore file")
+
+const keystoreFormat = 1
+
+// Argon2id parameters for new keystores (RFC 9106, second recommended option)
+const (
+	keystoreTime    = 3
+	keystoreMemory  = 64 * 1024
+	keystoreThreads = 4
+	keystoreSaltLen = 16
+)
+
+// keystoreFile is the on-disk format. Every master key is sealed with a key
+// derived from the passphrase; the version is bound as additional data.
+type keystoreFile struct {
+	Format int               `json:"format"`
+	KDF    keystoreKDF       `json:"kdf"`
+	Keys   map[string]string `json:"keys"`
+}
+
+type keystoreKDF struct {
+	Name    string `json:"name"`
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// GenerateMasterKey returns a new random master key
+func GenerateMasterKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	return key, nil
+}
+
+// OpenKeystore loads a passphrase protected keystore as a key ring.
+// An active version of 0 selects the highest version.
+func OpenKeystore(path string, passphrase []byte, active int) (*KeyRing, error) {
+	keys, err := ReadKeystore(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(keys, active)
+}
+
+// ReadKeystore decrypts the master keys of a keystore by version
+func ReadKeystore(path string, passphrase []byte) (map[int][]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeystore, err)
+	}
+	if file.Format != keystoreFormat || file.KDF.Name != "argon2id" {
+		return nil, fmt.Errorf("%w: unsupported format %d/%s", ErrInvalidKeystore, file.Format, file.KDF.Name)
+	}
+	salt, err := base64.StdEncoding.DecodeString(file.KDF.Salt)
+	if err != nil || len(salt) < keystoreSaltLen || file.KDF.Time == 0 || file.KDF.Memory == 0 || file.KDF.Threads == 0 {
+		return nil, fmt.Errorf("%w: invalid kdf parameters", ErrInvalidKeystore)
+	}
+
+	kek, err := newGCM(argon2.IDKey(passphrase, salt, file.KDF.Time, file.KDF.Memory, file.KDF.Threads, KeySize))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[int][]byte, len(file.Keys))
+	for versionPart, sealed := range file.Keys {
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid version %q", ErrInvalidKeystore, versionPart)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: version %d is not base64", ErrInvalidKeystore, version)
+		}
+		key, err := open(kek, ciphertext, keystoreAAD(version))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open keystore key %d, wrong passphrase?: %w", version, err)
+		}
+		keys[version] = key
+	}
+	return keys, nil
+}
+
+// WriteKeystore seals the master keys with the passphrase and atomically
+// replaces the keystore. Every write uses a new salt.
+func WriteKeystore(path string, passphrase []byte, keys map[int][]byte) error {
+	if len(passphrase) == 0 {
+		return fmt.Errorf("%w: passphrase is required", ErrInvalidKeystore)
+	}
+
+	salt := make([]byte, keystoreSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	kek, err := newGCM(argon2.IDKey(passphrase, salt, keystoreTime, keystoreMemory, keystoreThreads, KeySize))
+	if err != nil {
+		return err
+	}
+
+	file := keystoreFile{
+		Format: keystoreFormat,
+		KDF: keystoreKDF{
+			Name:    "argon2id",
+			Salt:    base64.StdEncoding.EncodeToString(salt),
+			Time:    keystoreTime,
+			Memory:  keystoreMemory,
+			Threads: keystoreThreads,
+		},
+		Keys: make(map[string]string, len(keys)),
+	}
+	for version, key := range keys {
+		if version <= 0 || len(key) != KeySize {
+			return fmt.Errorf("%w: version %d", ErrInvalidKeyRing, version)
+		}
+		sealed, err := seal(kek, key, keystoreAAD(version))
+		if err != nil {
+			return err
+		}
+		file.Keys[strconv.Itoa(version)] = base64.StdEncoding.EncodeToString(sealed)
+	}
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keystore: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keystore-*")
+	if err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace keystore: %w", err)
+	}
+	return nil
+}
+
+func keystoreAAD(version int) []byte {
+	return []byte("secrets:keystore:" + strconv.Itoa(version))
+}
@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"trader/internal/config"
+)
+
+// KeyProvider wraps and unwraps data keys with master keys it manages. Local
+// providers load the master keys into memory; remote providers such as Vault
+// transit never release them, so every call is a request.
+type KeyProvider interface {
+	// ActiveVersion is the master key version new data keys are wrapped with
+	ActiveVersion(ctx context.Context) (int, error)
+	// Versions lists the master key versions data keys can be unwrapped with
+	Versions(ctx context.Context) ([]int, error)
+	// WrapKey wraps a data key with the active version and returns that version
+	WrapKey(ctx context.Context, raw []byte) ([]byte, int, error)
+	// UnwrapKey unwraps a data key with the version it was wrapped with
+	UnwrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, error)
+	// RewrapKey wraps a data key with the active version without changing it
+	RewrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, int, error)
+}
+
+// NewKeyProvider creates the key provider selected in configuration
+func NewKeyProvider(cfg config.EncryptionConfig) (KeyProvider, error) {
+	switch cfg.Provider {
+	case "env":
+		return keyRingProvider(ParseKeyRing(cfg.KeyRing(), cfg.ActiveKeyVersion))
+	case "file":
+		return keyRingProvider(LoadKeyRingFile(cfg.KeysFile, cfg.ActiveKeyVersion))
+	case "keystore":
+		passphrase, err := ReadSecret(cfg.KeystorePassphrase, cfg.KeystorePassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keystore passphrase: %w", err)
+		}
+		return keyRingProvider(OpenKeystore(cfg.KeystorePath, []byte(passphrase), cfg.ActiveKeyVersion))
+	case "vault":
+		token, err := ReadSecret(cfg.VaultToken, cfg.VaultTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault token: %w", err)
+		}
+		vault, err := NewVaultTransit(VaultConfig{
+			Address:   cfg.VaultAddress,
+			Token:     token,
+			Namespace: cfg.VaultNamespace,
+			Mount:     cfg.VaultMount,
+			KeyName:   cfg.VaultKeyName,
+			Timeout:   cfg.VaultTimeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return vault, nil
+	default:
+		return nil, fmt.Errorf("unknown key provider: %s", cfg.Provider)
+	}
+}
+
+// keyRingProvider keeps a failed load from returning a non-nil provider holding a nil ring
+func keyRingProvider(ring *KeyRing, err error) (KeyProvider, error) {
+	if err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// NewDataKey generates a random data key and returns it with its wrapped form
+// and the master key version that wrapped it
+func NewDataKey(ctx context.Context, provider KeyProvider) (*DataKey, []byte, int, error) {
+	raw := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, version, err := provider.WrapKey(ctx, raw)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	dataKey, err := newDataKey(raw)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return dataKey, wrapped, version, nil
+}
+
+// OpenDataKey unwraps a stored data key
+func OpenDataKey(ctx context.Context, provider KeyProvider, wrapped []byte, version int) (*DataKey, error) {
+	raw, err := provider.UnwrapKey(ctx, wrapped, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != KeySize {
+		return nil, ErrDecryption
+	}
+	return newDataKey(raw)
+}
+
+// ReadSecret returns the content of the file, e.g. a Docker secret, if a path
+// is set and the inline value otherwise
+func ReadSecret(value, path string) (string, error) {
+	if path == "" {
+		return value, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
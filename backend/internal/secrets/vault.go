@@ -0,0 +1,227 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrVaultRequest = errors.New("vault transit request failed")
+
+// VaultConfig configures a HashiCorp Vault transit secrets engine key
+type VaultConfig struct {
+	Address   string
+	Token     string
+	Namespace string
+	// Mount is the path the transit engine is mounted at, "transit" by default
+	Mount   string
+	KeyName string
+	Timeout time.Duration
+}
+
+// VaultTransit wraps data keys with a Vault transit key. The master key never
+// leaves Vault; the token needs update on encrypt, decrypt and rewrap and read
+// on the key itself. Key versions are Vault's, rotated with
+// `vault write -f transit/keys/<name>/rotate`.
+type VaultTransit struct {
+	cfg    VaultConfig
+	client *http.Client
+}
+
+func NewVaultTransit(cfg VaultConfig) (*VaultTransit, error) {
+	if cfg.Address == "" || cfg.KeyName == "" {
+		return nil, fmt.Errorf("vault address and transit key name are required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &VaultTransit{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// ActiveVersion implements KeyProvider with the latest version of the transit key
+func (v *VaultTransit) ActiveVersion(ctx context.Context) (int, error) {
+	var key vaultKey
+	if err := v.do(ctx, http.MethodGet, "keys", nil, &key); err != nil {
+		return 0, err
+	}
+	return key.LatestVersion, nil
+}
+
+// Versions implements KeyProvider with the versions Vault still decrypts with
+func (v *VaultTransit) Versions(ctx context.Context) ([]int, error) {
+	var key vaultKey
+	if err := v.do(ctx, http.MethodGet, "keys", nil, &key); err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(key.Keys))
+	for versionPart := range key.Keys {
+		version, err := strconv.Atoi(versionPart)
+		if err == nil && version >= key.MinDecryptionVersion {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// WrapKey implements KeyProvider. The wrapped key is Vault's "vault:v<N>:..." ciphertext.
+func (v *VaultTransit) WrapKey(ctx context.Context, raw []byte) ([]byte, int, error) {
+	var result struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.do(ctx, http.MethodPost, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(raw)}, &result)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	version, err := vaultCiphertextVersion(result.Ciphertext)
+	if err != nil {
+		return nil, 0, err
+	}
+	return []byte(result.Ciphertext), version, nil
+}
+
+// UnwrapKey implements KeyProvider
+func (v *VaultTransit) UnwrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, error) {
+	if err := checkVaultCiphertext(wrapped, version); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := v.do(ctx, http.MethodPost, "decrypt", map[string]string{"ciphertext": string(wrapped)}, &result); err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(result.Plaintext)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return raw, nil
+}
+
+// RewrapKey implements KeyProvider. Vault re-encrypts without returning the plaintext.
+func (v *VaultTransit) RewrapKey(ctx context.Context, wrapped []byte, version int) ([]byte, int, error) {
+	if err := checkVaultCiphertext(wrapped, version); err != nil {
+		return nil, 0, err
+	}
+
+	var result struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := v.do(ctx, http.MethodPost, "rewrap", map[string]string{"ciphertext": string(wrapped)}, &result); err != nil {
+		return nil, 0, err
+	}
+
+	newVersion, err := vaultCiphertextVersion(result.Ciphertext)
+	if err != nil {
+		return nil, 0, err
+	}
+	return []byte(result.Ciphertext), newVersion, nil
+}
+
+type vaultKey struct {
+	LatestVersion        int                    `json:"latest_version"`
+	MinDecryptionVersion int                    `json:"min_decryption_version"`
+	Keys                 map[string]interface{} `json:"keys"`
+}
+
+// do calls /v1/<mount>/<operation>/<key> and decodes the response's data field
+func (v *VaultTransit) do(ctx context.Context, method, operation string, body interface{}, data interface{}) error {
+	endpoint := strings.TrimRight(v.cfg.Address, "/") + "/v1/" + strings.Trim(v.cfg.Mount, "/") + "/" + operation + "/" + url.PathEscape(v.cfg.KeyName)
+
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode vault request: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.cfg.Token)
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrVaultRequest, operation, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("%w: %s: invalid response: %v", ErrVaultRequest, operation, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		message := strings.Join(envelope.Errors, "; ")
+		// Vault answers 400 for ciphertexts it cannot decrypt
+		if resp.StatusCode == http.StatusBadRequest && operation != "encrypt" {
+			return fmt.Errorf("%w: %s", ErrDecryption, message)
+		}
+		return fmt.Errorf("%w: %s: status %d: %s", ErrVaultRequest, operation, resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		return fmt.Errorf("%w: %s: invalid response data: %v", ErrVaultRequest, operation, err)
+	}
+	return nil
+}
+
+// vaultCiphertextVersion reads N from "vault:v<N>:<base64>"
+func vaultCiphertextVersion(ciphertext string) (int, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, ErrDecryption
+	}
+	version, err := strconv.Atoi(parts[1][1:])
+	if err != nil || version <= 0 {
+		return 0, ErrDecryption
+	}
+	return version, nil
+}
+
+// checkVaultCiphertext rejects ciphertexts whose embedded version disagrees with the stored one
+func checkVaultCiphertext(wrapped []byte, version int) error {
+	embedded, err := vaultCiphertextVersion(string(wrapped))
+	if err != nil {
+		return err
+	}
+	if embedded != version {
+		return fmt.Errorf("%w: stored version %d, ciphertext version %d", ErrUnknownKeyVersion, version, embedded)
+	}
+	return nil
+}
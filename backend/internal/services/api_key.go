@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/exchange"
+	"trader/internal/exchange/keypool"
+	"trader/internal/fees"
+	"trader/internal/models"
+	"trader/internal/secrets"
+	"trader/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrAPIKeyInactive   = errors.New("api key is inactive")
+	ErrExchangeNotFound = errors.New("exchange not found or inactive")
+	// ErrAPIKeyWithdrawEnabled refuses keys whose connection test found withdrawal rights
+	ErrAPIKeyWithdrawEnabled = errors.New("api key has withdrawal rights")
+)
+
+// Field names bound into the ciphertexts so they cannot be swapped
+const (
+	apiKeyFieldKey        = "api_keys.key"
+	apiKeyFieldSecret     = "api_keys.secret"
+	apiKeyFieldPassphrase = "api_keys.passphrase"
+)
+
+const (
+	maxAPIKeyLength     = 512
+	maxAPISecretLength  = 4096
+	maxPassphraseLength = 256
+)
+
+type CreateAPIKeyRequest struct {
+	ExchangeID uint   `json:"exchange_id" validate:"required"`
+	Name       string `json:"name" validate:"required"`
+	APIKey     string `json:"api_key" validate:"required"`
+	APISecret  string `json:"api_secret" validate:"required"`
+	// Passphrase is only used by exchanges that require one
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// UpdateAPIKeyRequest changes the fields that are set. Credentials can only be
+// replaced as a whole: APIKey and APISecret must be given together.
+type UpdateAPIKeyRequest struct {
+	Name       *string `json:"name,omitempty"`
+	IsActive   *bool   `json:"is_active,omitempty"`
+	APIKey     *string `json:"api_key,omitempty"`
+	APISecret  *string `json:"api_secret,omitempty"`
+	Passphrase *string `json:"passphrase,omitempty"`
+	// FeeTier sets the account's fee tier on exchanges that do not report it
+	FeeTier *string `json:"fee_tier,omitempty"`
+}
+
+// APIKeyInfo is the public view of a key. It never contains credentials.
+type APIKeyInfo struct {
+	ID             uint       `json:"id"`
+	OrganizationID uint       `json:"organization_id"`
+	ExchangeID     uint       `json:"exchange_id"`
+	ExchangeCode   string     `json:"exchange_code"`
+	ExchangeName   string     `json:"exchange_name"`
+	Name           string     `json:"name"`
+	FeeTier        string     `json:"fee_tier"`
+	KeyPreview     string     `json:"key_preview"`
+	HasPassphrase  bool       `json:"has_passphrase"`
+	IsActive       bool       `json:"is_active"`
+	Permissions    []string   `json:"permissions"`
+	TestStatus     string     `json:"test_status,omitempty"`
+	TestError      string     `json:"test_error,omitempty"`
+	TestLatencyMS  *int       `json:"test_latency_ms,omitempty"`
+	LastTestedAt   *time.Time `json:"last_tested_at,omitempty"`
+	// Health is the key's state in the key pool of this process
+	Health     *keypool.Health `json:"health,omitempty"`
+	CreatedBy  *uint           `json:"created_by,omitempty"`
+	LastUsedAt *time.Time      `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// APICredentials are decrypted credentials, for exchange connectors only
+type APICredentials struct {
+	APIKey     string
+	APISecret  string
+	Passphrase string
+}
+
+type APIKeyService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	audit *AuditService
+	keys  secrets.KeyProvider
+	// pool balances calls over the keys of an exchange; state is per process
+	pool *keypool.Pool
+}
+
+func NewAPIKeyService(db *gorm.DB, cfg *config.Config) (*APIKeyService, error) {
+	keys, err := secrets.NewKeyProvider(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyService{
+		db:    db,
+		cfg:   cfg,
+		audit: NewAuditService(db, cfg),
+		keys:  keys,
+		pool: keypool.New(keypool.Config{
+			ErrorWindow:     cfg.Exchange.KeyErrorWindow,
+			BreakerCooldown: cfg.Exchange.KeyBreakerCooldown,
+		}),
+	}, nil
+}
+
+// List returns the workspace's keys
+func (s *APIKeyService) List(ctx context.Context, ws *Workspace) ([]APIKeyInfo, error) {
+	if !ws.Can(OrgActionRead) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	var keys []models.APIKey
+	err := s.db.WithContext(ctx).Scopes(ws.Scope).Preload("Exchange").
+		Order("name ASC, id ASC").Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for i := range keys {
+		info := apiKeyInfo(&keys[i])
+		health := s.pool.Health(keys[i].ID)
+		info.Health = &health
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Get returns one of the workspace's keys
+func (s *APIKeyService) Get(ctx context.Context, ws *Workspace, id uint) (*APIKeyInfo, error) {
+	if !ws.Can(OrgActionRead) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	key, err := s.find(s.db.WithContext(ctx), ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	info := apiKeyInfo(key)
+	health := s.pool.Health(key.ID)
+	info.Health = &health
+	return &info, nil
+}
+
+// Create encrypts and stores new credentials in the workspace
+func (s *APIKeyService) Create(ctx context.Context, ws *Workspace, req *CreateAPIKeyRequest) (*APIKeyInfo, error) {
+	if !ws.Can(OrgActionWrite) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	name := strings.TrimSpace(req.Name)
+	credentials := APICredentials{
+		APIKey:     strings.TrimSpace(req.APIKey),
+		APISecret:  strings.TrimSpace(req.APISecret),
+		Passphrase: req.Passphrase,
+	}
+	errs := validateAPIKeyName(name)
+	errs = append(errs, validateAPICredentials(&credentials)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var key models.APIKey
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var exchange models.Exchange
+		if err := tx.Where("id = ? AND is_active = ?", req.ExchangeID, true).First(&exchange).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrExchangeNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+
+		key = models.APIKey{
+			OrganizationID: ws.OrganizationID,
+			ExchangeID:     exchange.ID,
+			FeeTier:        fees.DefaultTier,
+			CreatedBy:      &ws.UserID,
+			Name:           name,
+			IsActive:       true,
+		}
+		if err := s.seal(ctx, &key, &credentials); err != nil {
+			return err
+		}
+		if err := tx.Omit("Organization", "Exchange").Create(&key).Error; err != nil {
+			return fmt.Errorf("failed to create api key: %w", err)
+		}
+		key.Exchange = exchange
+
+		_, err := s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "create",
+			Resource:   "api_keys",
+			ResourceID: strconv.FormatUint(uint64(key.ID), 10),
+			NewValues: map[string]interface{}{
+				"organization_id": ws.OrganizationID,
+				"exchange":        exchange.Code,
+				"name":            key.Name,
+				"key_preview":     key.KeyPreview,
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := apiKeyInfo(&key)
+	return &info, nil
+}
+
+// Update renames, (de)activates, sets the fee tier or replaces the credentials
+// of a key
+func (s *APIKeyService) Update(ctx context.Context, ws *Workspace, id uint, req *UpdateAPIKeyRequest) (*APIKeyInfo, error) {
+	if !ws.Can(OrgActionWrite) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	var errs utils.ValidationErrors
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		req.Name = &name
+		errs = append(errs, validateAPIKeyName(name)...)
+	}
+	if req.FeeTier != nil {
+		tier := strings.TrimSpace(*req.FeeTier)
+		req.FeeTier = &tier
+		if tier == "" || len(tier) > 50 {
+			errs = append(errs, utils.ValidationError{Field: "fee_tier", Message: "must be 1 to 50 characters"})
+		}
+	}
+
+	var credentials *APICredentials
+	if req.APIKey != nil || req.APISecret != nil || req.Passphrase != nil {
+		if req.APIKey == nil || req.APISecret == nil {
+			errs = append(errs, utils.ValidationError{Field: "api_secret", Message: "api_key and api_secret must be replaced together"})
+		} else {
+			credentials = &APICredentials{
+				APIKey:    strings.TrimSpace(*req.APIKey),
+				APISecret: strings.TrimSpace(*req.APISecret),
+			}
+			if req.Passphrase != nil {
+				credentials.Passphrase = *req.Passphrase
+			}
+			errs = append(errs, validateAPICredentials(credentials)...)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var key *models.APIKey
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = s.find(tx, ws, id)
+		if err != nil {
+			return err
+		}
+
+		oldValues := map[string]interface{}{}
+		newValues := map[string]interface{}{}
+		if req.Name != nil && *req.Name != key.Name {
+			oldValues["name"], newValues["name"] = key.Name, *req.Name
+			key.Name = *req.Name
+		}
+		if req.FeeTier != nil && *req.FeeTier != key.FeeTier {
+			oldValues["fee_tier"], newValues["fee_tier"] = key.FeeTier, *req.FeeTier
+			key.FeeTier = *req.FeeTier
+		}
+		if req.IsActive != nil && *req.IsActive != key.IsActive {
+			oldValues["is_active"], newValues["is_active"] = key.IsActive, *req.IsActive
+			key.IsActive = *req.IsActive
+		}
+		if credentials != nil {
+			// New credentials get a new data key as well and have not been tested
+			oldValues["key_preview"] = key.KeyPreview
+			if err := s.seal(ctx, key, credentials); err != nil {
+				return err
+			}
+			newValues["key_preview"] = key.KeyPreview
+			key.CanRead, key.CanTrade, key.CanWithdraw = false, false, false
+			key.TestStatus, key.TestError, key.TestLatencyMS, key.LastTestedAt = "", "", nil, nil
+		} else if req.IsActive != nil && *req.IsActive && key.CanWithdraw {
+			return ErrAPIKeyWithdrawEnabled
+		}
+		if len(newValues) == 0 {
+			return nil
+		}
+
+		err = tx.Model(key).Select("Name", "FeeTier", "IsActive", "KeyPreview", "EncryptedKey", "EncryptedSecret", "EncryptedPassphrase", "WrappedDataKey", "MasterKeyVersion",
+			"CanRead", "CanTrade", "CanWithdraw", "TestStatus", "TestError", "TestLatencyMS", "LastTestedAt").
+			Updates(key).Error
+		if err != nil {
+			return fmt.Errorf("failed to update api key: %w", err)
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "update",
+			Resource:   "api_keys",
+			ResourceID: strconv.FormatUint(uint64(key.ID), 10),
+			OldValues:  oldValues,
+			NewValues:  newValues,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credentials != nil {
+		s.pool.Forget(key.ID)
+	}
+
+	info := apiKeyInfo(key)
+	return &info, nil
+}
+
+// Delete soft-deletes a key and discards its credentials. Without the wrapped
+// data key the ciphertexts can no longer be decrypted, even from backups.
+func (s *APIKeyService) Delete(ctx context.Context, ws *Workspace, id uint) error {
+	if !ws.Can(OrgActionWrite) {
+		return ErrOrganizationForbidden
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		key, err := s.find(tx, ws, id)
+		if err != nil {
+			return err
+		}
+		if err := shredAPIKeys(tx.Where("id = ?", key.ID)); err != nil {
+			return err
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "delete",
+			Resource:   "api_keys",
+			ResourceID: strconv.FormatUint(uint64(key.ID), 10),
+			OldValues:  map[string]interface{}{"name": key.Name, "key_preview": key.KeyPreview},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.pool.Forget(id)
+	return nil
+}
+
+// Credentials decrypts a workspace key for use by an exchange connector
+func (s *APIKeyService) Credentials(ctx context.Context, ws *Workspace, id uint) (*APICredentials, error) {
+	if !ws.Can(OrgActionRead) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	key, err := s.find(s.db.WithContext(ctx), ws, id)
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsActive {
+		return nil, ErrAPIKeyInactive
+	}
+	if key.CanWithdraw {
+		return nil, ErrAPIKeyWithdrawEnabled
+	}
+	return s.Decrypt(ctx, key)
+}
+
+// Decrypt opens the credentials of a loaded key. Callers are responsible for
+// checking the key belongs to the organization they act for.
+func (s *APIKeyService) Decrypt(ctx context.Context, key *models.APIKey) (*APICredentials, error) {
+	dataKey, err := secrets.OpenDataKey(ctx, s.keys, key.WrappedDataKey, key.MasterKeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of api key %d: %w", key.ID, err)
+	}
+
+	apiKey, err := dataKey.Open(key.EncryptedKey, apiKeyFieldKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt api key %d: %w", key.ID, err)
+	}
+	apiSecret, err := dataKey.Open(key.EncryptedSecret, apiKeyFieldSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt api secret %d: %w", key.ID, err)
+	}
+
+	credentials := &APICredentials{APIKey: string(apiKey), APISecret: string(apiSecret)}
+	if len(key.EncryptedPassphrase) > 0 {
+		passphrase, err := dataKey.Open(key.EncryptedPassphrase, apiKeyFieldPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt passphrase %d: %w", key.ID, err)
+		}
+		credentials.Passphrase = string(passphrase)
+	}
+	return credentials, nil
+}
+
+// Name implements PersonalDataProvider
+func (s *APIKeyService) Name() string {
+	return "api_keys"
+}
+
+// Export implements PersonalDataProvider. Only masked previews are exported.
+func (s *APIKeyService) Export(ctx context.Context, db *gorm.DB, userID uint) (interface{}, error) {
+	var keys []models.APIKey
+	if err := db.Preload("Exchange").Where("created_by = ?", userID).Order("id ASC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+
+	infos := make([]APIKeyInfo, 0, len(keys))
+	for i := range keys {
+		infos = append(infos, apiKeyInfo(&keys[i]))
+	}
+	return infos, nil
+}
+
+// Erase implements PersonalDataProvider. Keys of the personal workspace are
+// deleted; keys the user added to shared organizations stay with the organization.
+func (s *APIKeyService) Erase(ctx context.Context, tx *gorm.DB, userID uint) error {
+	personal := tx.Unscoped().Model(&models.Organization{}).Select("id").
+		Where("created_by = ? AND is_personal = ?", userID, true)
+	if err := shredAPIKeys(tx.Where("organization_id IN (?)", personal)); err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Model(&models.APIKey{}).Where("created_by = ?", userID).Update("created_by", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach api keys: %w", err)
+	}
+	return nil
+}
+
+func (s *APIKeyService) find(db *gorm.DB, ws *Workspace, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := db.Scopes(ws.Scope).Preload("Exchange").Where("id = ?", id).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &key, nil
+}
+
+// seal encrypts the credentials into the key under a fresh data key
+func (s *APIKeyService) seal(ctx context.Context, key *models.APIKey, credentials *APICredentials) error {
+	dataKey, wrapped, version, err := secrets.NewDataKey(ctx, s.keys)
+	if err != nil {
+		return err
+	}
+
+	if key.EncryptedKey, err = dataKey.Seal([]byte(credentials.APIKey), apiKeyFieldKey); err != nil {
+		return err
+	}
+	if key.EncryptedSecret, err = dataKey.Seal([]byte(credentials.APISecret), apiKeyFieldSecret); err != nil {
+		return err
+	}
+	key.EncryptedPassphrase = nil
+	if credentials.Passphrase != "" {
+		if key.EncryptedPassphrase, err = dataKey.Seal([]byte(credentials.Passphrase), apiKeyFieldPassphrase); err != nil {
+			return err
+		}
+	}
+	key.WrappedDataKey = wrapped
+	key.MasterKeyVersion = version
+	key.KeyPreview = maskAPIKey(credentials.APIKey)
+	return nil
+}
+
+// shredAPIKeys clears the credentials of the matched keys and soft-deletes them
+func shredAPIKeys(query *gorm.DB) error {
+	// Paper accounts are only reachable through their key and go with it
+	db := query.Session(&gorm.Session{NewDB: true})
+	keys := query.Session(&gorm.Session{}).Model(&models.APIKey{}).Select("id")
+	accounts := db.Unscoped().Model(&models.PaperAccount{}).Select("id").Where("api_key_id IN (?)", keys)
+	if err := db.Unscoped().Where("account_id IN (?)", accounts).Delete(&models.PaperOrder{}).Error; err != nil {
+		return fmt.Errorf("failed to delete paper orders: %w", err)
+	}
+	if err := db.Unscoped().Where("account_id IN (?)", accounts).Delete(&models.PaperBalance{}).Error; err != nil {
+		return fmt.Errorf("failed to delete paper balances: %w", err)
+	}
+	if err := db.Unscoped().Where("api_key_id IN (?)", keys).Delete(&models.PaperAccount{}).Error; err != nil {
+		return fmt.Errorf("failed to delete paper accounts: %w", err)
+	}
+
+	err := query.Session(&gorm.Session{}).Model(&models.APIKey{}).Updates(map[string]interface{}{
+		"encrypted_key":        nil,
+		"encrypted_secret":     nil,
+		"encrypted_passphrase": nil,
+		"wrapped_data_key":     nil,
+		"is_active":            false,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear api key credentials: %w", err)
+	}
+	if err := query.Session(&gorm.Session{}).Delete(&models.APIKey{}).Error; err != nil {
+		return fmt.Errorf("failed to delete api keys: %w", err)
+	}
+	return nil
+}
+
+// maskAPIKey keeps only enough characters for users to recognize the key
+func maskAPIKey(apiKey string) string {
+	if len(apiKey) >= 16 {
+		return apiKey[:4] + "****" + apiKey[len(apiKey)-4:]
+	}
+	if len(apiKey) > 8 {
+		return "****" + apiKey[len(apiKey)-4:]
+	}
+	return "****"
+}
+
+func validateAPIKeyName(name string) utils.ValidationErrors {
+	if name == "" || len(name) > 100 {
+		return utils.ValidationErrors{{Field: "name", Message: "must be 1 to 100 characters"}}
+	}
+	return nil
+}
+
+// validateAPICredentials never echoes the submitted values back
+func validateAPICredentials(credentials *APICredentials) utils.ValidationErrors {
+	var errs utils.ValidationErrors
+	switch {
+	case credentials.APIKey == "":
+		errs = append(errs, utils.ValidationError{Field: "api_key", Message: "This field is required"})
+	case len(credentials.APIKey) > maxAPIKeyLength || strings.ContainsAny(credentials.APIKey, " \t\r\n"):
+		errs = append(errs, utils.ValidationError{Field: "api_key", Message: "must be a single token of at most 512 characters"})
+	}
+	switch {
+	case credentials.APISecret == "":
+		errs = append(errs, utils.ValidationError{Field: "api_secret", Message: "This field is required"})
+	case len(credentials.APISecret) > maxAPISecretLength:
+		errs = append(errs, utils.ValidationError{Field: "api_secret", Message: "must be at most 4096 characters"})
+	}
+	if len(credentials.Passphrase) > maxPassphraseLength {
+		errs = append(errs, utils.ValidationError{Field: "passphrase", Message: "must be at most 256 characters"})
+	}
+	return errs
+}
+
+func apiKeyInfo(key *models.APIKey) APIKeyInfo {
+	return APIKeyInfo{
+		ID:             key.ID,
+		OrganizationID: key.OrganizationID,
+		ExchangeID:     key.ExchangeID,
+		ExchangeCode:   key.Exchange.Code,
+		ExchangeName:   key.Exchange.Name,
+		Name:           key.Name,
+		FeeTier:        key.FeeTier,
+		KeyPreview:     key.KeyPreview,
+		HasPassphrase:  len(key.EncryptedPassphrase) > 0,
+		IsActive:       key.IsActive,
+		Permissions:    exchange.Capabilities{Read: key.CanRead, Trade: key.CanTrade, Withdraw: key.CanWithdraw}.Permissions(),
+		TestStatus:     key.TestStatus,
+		TestError:      key.TestError,
+		TestLatencyMS:  key.TestLatencyMS,
+		LastTestedAt:   key.LastTestedAt,
+		CreatedBy:      key.CreatedBy,
+		LastUsedAt:     key.LastUsedAt,
+		CreatedAt:      key.CreatedAt,
+		UpdatedAt:      key.UpdatedAt,
+	}
+}
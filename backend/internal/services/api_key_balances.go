@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/exchange/keypool"
+	"trader/internal/market"
+	"trader/internal/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Balance event types
+const (
+	// BalanceEventUnexpectedDrop is a loss in value that trades and price
+	// moves do not explain, such as a withdrawal
+	BalanceEventUnexpectedDrop = "unexpected_drop"
+)
+
+// Balance history page sizes
+const (
+	DefaultBalanceHistoryLimit = 100
+	MaxBalanceHistoryLimit     = 1000
+)
+
+// BalanceAmount is the balance of one currency in a snapshot
+type BalanceAmount struct {
+	Currency  string  `json:"currency"`
+	Available float64 `json:"available"`
+	Reserved  float64 `json:"reserved"`
+	Total     float64 `json:"total"`
+	// Value is the total in the valuation currency, nil when the currency has no price
+	Value *float64 `json:"value,omitempty"`
+}
+
+// BalanceChange is the decrease of one currency in a balance event
+type BalanceChange struct {
+	Currency string  `json:"currency"`
+	Before   float64 `json:"before"`
+	After    float64 `json:"after"`
+}
+
+// BalanceSnapshotInfo is a snapshot with its balances decoded
+type BalanceSnapshotInfo struct {
+	ID                uint            `json:"id"`
+	TakenAt           time.Time       `json:"taken_at"`
+	LastSeenAt        time.Time       `json:"last_seen_at"`
+	Value             *float64        `json:"value"`
+	ValuationCurrency string          `json:"valuation_currency"`
+	Balances          []BalanceAmount `json:"balances"`
+}
+
+// APIKeyBalances are the current balances of the account behind a key, the
+// snapshots before them, newest first, and the events found between them
+type APIKeyBalances struct {
+	Current *BalanceSnapshotInfo  `json:"current"`
+	History []BalanceSnapshotInfo `json:"history"`
+	Events  []models.BalanceEvent `json:"events"`
+}
+
+// BalanceHistoryFilter limits the history and events returned with the balances
+type BalanceHistoryFilter struct {
+	// Since skips snapshots last seen and events created before it
+	Since *time.Time
+	Limit int
+}
+
+// BalanceSnapshotResult is the outcome of a round of balance snapshots
+type BalanceSnapshotResult struct {
+	Taken int `json:"taken"`
+	// Unchanged counts the accounts whose last snapshot was extended
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+	Drops     int `json:"drops"`
+	// Skipped counts the keys whose breaker in the pool is open
+	Skipped int `json:"skipped"`
+}
+
+// Balances returns the current balances of the account behind a key and
+// their history
+func (s *APIKeyService) Balances(ctx context.Context, ws *Workspace, id uint, filter *BalanceHistoryFilter) (*APIKeyBalances, error) {
+	if !ws.Can(OrgActionRead) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	db := s.db.WithContext(ctx)
+	key, err := s.find(db, ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultBalanceHistoryLimit
+	}
+	if limit > MaxBalanceHistoryLimit {
+		limit = MaxBalanceHistoryLimit
+	}
+
+	snapshotQuery := db.Where("api_key_id = ?", key.ID)
+	eventQuery := db.Where("api_key_id = ?", key.ID)
+	if filter.Since != nil {
+		snapshotQuery = snapshotQuery.Where("last_seen_at >= ?", *filter.Since)
+		eventQuery = eventQuery.Where("created_at >= ?", *filter.Since)
+	}
+
+	var snapshots []models.BalanceSnapshot
+	if err := snapshotQuery.Order("taken_at DESC, id DESC").Limit(limit).Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to load balance snapshots: %w", err)
+	}
+	result := &APIKeyBalances{History: make([]BalanceSnapshotInfo, 0, len(snapshots))}
+	for i := range snapshots {
+		info, err := balanceSnapshotInfo(&snapshots[i])
+		if err != nil {
+			return nil, err
+		}
+		result.History = append(result.History, *info)
+	}
+
+	// The latest snapshot is current even when it is older than Since
+	latest, err := latestBalanceSnapshot(db, key.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		if result.Current, err = balanceSnapshotInfo(latest); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Events = []models.BalanceEvent{}
+	if err := eventQuery.Order("created_at DESC, id DESC").Limit(limit).Find(&result.Events).Error; err != nil {
+		return nil, fmt.Errorf("failed to load balance events: %w", err)
+	}
+	return result, nil
+}
+
+// SnapshotBalances fetches the balances of the account behind every active
+// key whose credentials were not rejected by their last test and stores them
+// valued in the configured currency. Keys whose breaker in the pool is open
+// are skipped until it half-opens. A snapshot worth less than the last one
+// by more than the drop threshold, both valued at the current prices, is
+// reported as an unexpected drop. A key that fails does not stop the others.
+func (s *APIKeyService) SnapshotBalances(ctx context.Context) (*BalanceSnapshotResult, error) {
+	var keys []models.APIKey
+	err := s.db.WithContext(ctx).Preload("Exchange").
+		Where("is_active = ? AND can_withdraw = ? AND test_status <> ?", true, false, APIKeyTestAuthFailed).
+		Order("id").Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+
+	marketService := market.NewService(s.db, s.cfg)
+	prices := make(map[uint]*market.Prices)
+	result := &BalanceSnapshotResult{}
+	var errs []error
+	for i := range keys {
+		key := &keys[i]
+		if s.pool.Health(key.ID).State == keypool.StateOpen {
+			result.Skipped++
+			continue
+		}
+		exchangePrices, ok := prices[key.ExchangeID]
+		if !ok {
+			// Without prices the balances are stored unvalued
+			exchangePrices, err = marketService.Prices(ctx, &key.Exchange, s.cfg.Balances.ValuationCurrency)
+			if err != nil {
+				log.Warn().Err(err).Str("exchange", key.Exchange.Code).Msg("Failed to load prices for balance snapshots")
+			}
+			prices[key.ExchangeID] = exchangePrices
+		}
+
+		changed, event, err := s.snapshotBalances(ctx, key, exchangePrices)
+		switch {
+		case err != nil:
+			result.Failed++
+			errs = append(errs, fmt.Errorf("api key %d: %w", key.ID, err))
+			log.Warn().Err(err).Uint("api_key_id", key.ID).Str("exchange", key.Exchange.Code).Msg("Balance snapshot failed")
+		case changed:
+			result.Taken++
+		default:
+			result.Unchanged++
+		}
+		if event != nil {
+			result.Drops++
+			log.Warn().
+				Uint("api_key_id", key.ID).
+				Uint("organization_id", key.OrganizationID).
+				Float64("value_before", event.ValueBefore).
+				Float64("value_after", event.ValueAfter).
+				Float64("drop_percent", event.DropPercent).
+				Msg("Unexpected balance drop")
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// snapshotBalances stores the key's balances. It reports whether they changed
+// since the last snapshot and the event of an unexpected drop.
+func (s *APIKeyService) snapshotBalances(ctx context.Context, key *models.APIKey, prices *market.Prices) (bool, *models.BalanceEvent, error) {
+	credentials, err := s.Decrypt(ctx, key)
+	if err != nil {
+		return false, nil, err
+	}
+	connector, err := connectors.New(s.cfg.Exchange, s.db, &key.Exchange, credentials.exchangeCredentials(key))
+	if err != nil {
+		return false, nil, err
+	}
+	balances, err := connector.Balances(ctx)
+	// Rejected credentials open the key's breaker in the pool
+	s.pool.Report(key.ID, 0, err)
+	if err != nil {
+		return false, nil, err
+	}
+
+	amounts, value := valueBalances(balances, prices)
+	data, err := json.Marshal(amounts)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to encode balances: %w", err)
+	}
+	now := time.Now().UTC()
+
+	changed := true
+	var event *models.BalanceEvent
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		previous, err := latestBalanceSnapshot(tx, key.ID)
+		if err != nil {
+			return err
+		}
+		var before []BalanceAmount
+		if previous != nil {
+			if err := json.Unmarshal(previous.Balances, &before); err != nil {
+				return fmt.Errorf("failed to decode balance snapshot %d: %w", previous.ID, err)
+			}
+		}
+
+		if previous != nil && sameBalances(before, amounts) {
+			err := tx.Model(previous).Updates(map[string]interface{}{
+				"balances":     data,
+				"value":        value,
+				"last_seen_at": now,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update balance snapshot: %w", err)
+			}
+			changed = false
+			return nil
+		}
+
+		snapshot := &models.BalanceSnapshot{
+			APIKeyID:          key.ID,
+			Balances:          data,
+			Value:             value,
+			ValuationCurrency: s.cfg.Balances.ValuationCurrency,
+			TakenAt:           now,
+			LastSeenAt:        now,
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return fmt.Errorf("failed to store balance snapshot: %w", err)
+		}
+
+		event, err = detectBalanceDrop(before, amounts, prices, s.cfg.Balances.DropThreshold)
+		if err != nil || event == nil {
+			return err
+		}
+		event.APIKeyID = key.ID
+		event.SnapshotID = snapshot.ID
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed to store balance event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return changed, event, nil
+}
+
+// latestBalanceSnapshot returns the key's last snapshot, nil if it has none
+func latestBalanceSnapshot(db *gorm.DB, apiKeyID uint) (*models.BalanceSnapshot, error) {
+	var snapshot models.BalanceSnapshot
+	err := db.Where("api_key_id = ?", apiKeyID).Order("taken_at DESC, id DESC").First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balance snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+func balanceSnapshotInfo(snapshot *models.BalanceSnapshot) (*BalanceSnapshotInfo, error) {
+	info := &BalanceSnapshotInfo{
+		ID:                snapshot.ID,
+		TakenAt:           snapshot.TakenAt,
+		LastSeenAt:        snapshot.LastSeenAt,
+		Value:             snapshot.Value,
+		ValuationCurrency: snapshot.ValuationCurrency,
+	}
+	if err := json.Unmarshal(snapshot.Balances, &info.Balances); err != nil {
+		return nil, fmt.Errorf("failed to decode balance snapshot %d: %w", snapshot.ID, err)
+	}
+	return info, nil
+}
+
+// valueBalances keeps the non-zero balances, sorted by currency, with their
+// values. The total value is nil when no balance could be priced.
+func valueBalances(balances []exchange.Balance, prices *market.Prices) ([]BalanceAmount, *float64) {
+	amounts := make([]BalanceAmount, 0, len(balances))
+	var total *float64
+	for _, balance := range balances {
+		amount := BalanceAmount{
+			Currency:  balance.Currency,
+			Available: balance.Available,
+			Reserved:  balance.Reserved,
+			Total:     balance.Available + balance.Reserved,
+		}
+		if amount.Total == 0 {
+			continue
+		}
+		if value, ok := balanceValue(prices, amount.Currency, amount.Total); ok {
+			amount.Value = &value
+			if total == nil {
+				total = new(float64)
+			}
+			*total += value
+		}
+		amounts = append(amounts, amount)
+	}
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i].Currency < amounts[j].Currency })
+	if total != nil {
+		*total = roundValue(*total)
+	}
+	return amounts, total
+}
+
+func balanceValue(prices *market.Prices, currency string, quantity float64) (float64, bool) {
+	if prices == nil {
+		return 0, false
+	}
+	price, ok := prices.Price(currency)
+	if !ok {
+		return 0, false
+	}
+	return roundValue(quantity * price), true
+}
+
+// roundValue matches the DECIMAL(32,8) value columns
+func roundValue(value float64) float64 {
+	return math.Round(value*1e8) / 1e8
+}
+
+// sameBalances reports whether two snapshots hold the same amounts,
+// regardless of their values
+func sameBalances(a, b []BalanceAmount) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Currency != b[i].Currency || a[i].Available != b[i].Available || a[i].Reserved != b[i].Reserved {
+			return false
+		}
+	}
+	return true
+}
+
+// detectBalanceDrop values both snapshots at the current prices, so price
+// moves cancel out and trades change the value by their fees only. A loss
+// above the threshold returns an event; currencies without a price are left
+// out of both values.
+func detectBalanceDrop(before, after []BalanceAmount, prices *market.Prices, threshold float64) (*models.BalanceEvent, error) {
+	if prices == nil || len(before) == 0 {
+		return nil, nil
+	}
+
+	totals := make(map[string]float64, len(after))
+	for _, amount := range after {
+		totals[amount.Currency] = amount.Total
+	}
+	var valueBefore, valueAfter float64
+	for _, amount := range after {
+		if value, ok := balanceValue(prices, amount.Currency, amount.Total); ok {
+			valueAfter += value
+		}
+	}
+	var changes []BalanceChange
+	for _, amount := range before {
+		if value, ok := balanceValue(prices, amount.Currency, amount.Total); ok {
+			valueBefore += value
+		}
+		if totals[amount.Currency] < amount.Total {
+			changes = append(changes, BalanceChange{Currency: amount.Currency, Before: amount.Total, After: totals[amount.Currency]})
+		}
+	}
+	if valueBefore <= 0 {
+		return nil, nil
+	}
+	drop := (valueBefore - valueAfter) / valueBefore
+	if drop < threshold {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode balance changes: %w", err)
+	}
+	return &models.BalanceEvent{
+		Type:              BalanceEventUnexpectedDrop,
+		ValuationCurrency: prices.Currency(),
+		ValueBefore:       roundValue(valueBefore),
+		ValueAfter:        roundValue(valueAfter),
+		DropPercent:       math.Round(drop*1e6) / 1e4,
+		Changes:           data,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Connection test statuses stored in APIKey.TestStatus
+const (
+	APIKeyTestOK              = "ok"
+	APIKeyTestAuthFailed      = "auth_failed"
+	APIKeyTestWithdrawEnabled = "withdraw_enabled"
+	APIKeyTestError           = "error"
+)
+
+// APIKeyTestResult is the outcome of a connection test
+type APIKeyTestResult struct {
+	Success     bool       `json:"success"`
+	Status      string     `json:"status"`
+	Message     string     `json:"message"`
+	Error       string     `json:"error,omitempty"`
+	Permissions []string   `json:"permissions"`
+	LatencyMS   int        `json:"latency_ms"`
+	TestedAt    time.Time  `json:"tested_at"`
+	Key         APIKeyInfo `json:"key"`
+}
+
+// Test authenticates the key against its exchange and records the detected
+// permissions and the latency on the key. Keys with withdrawal rights are
+// refused: they are deactivated and cannot be used until the right is removed
+// on the exchange and the key is tested again.
+func (s *APIKeyService) Test(ctx context.Context, ws *Workspace, id uint) (*APIKeyTestResult, error) {
+	if !ws.Can(OrgActionWrite) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	key, err := s.find(s.db.WithContext(ctx), ws, id)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := s.Decrypt(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := connectors.New(s.cfg.Exchange, s.db, &key.Exchange, credentials.exchangeCredentials(key))
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	capabilities, testErr := connector.Capabilities(ctx)
+	latency := int(time.Since(started).Milliseconds())
+	// A passing test closes the key's breaker in the pool, rejected credentials open it
+	s.pool.Report(key.ID, 0, testErr)
+
+	result := &APIKeyTestResult{Permissions: []string{}, LatencyMS: latency, TestedAt: started}
+	switch {
+	case errors.Is(testErr, exchange.ErrAuthFailed):
+		result.Status = APIKeyTestAuthFailed
+		result.Message = "The exchange rejected the credentials"
+		result.Error = testErr.Error()
+	case testErr != nil:
+		result.Status = APIKeyTestError
+		result.Message = "Could not connect to the exchange"
+		result.Error = testErr.Error()
+	case capabilities.Withdraw:
+		result.Status = APIKeyTestWithdrawEnabled
+		result.Message = "The key has withdrawal rights and was deactivated. Remove the withdrawal permission on the exchange and test again."
+		result.Permissions = capabilities.Permissions()
+	default:
+		result.Success = true
+		result.Status = APIKeyTestOK
+		result.Message = "Connection successful"
+		result.Permissions = capabilities.Permissions()
+	}
+	// Permissions are only known when the exchange answered: rejected
+	// credentials have none, while a timeout or an outage says nothing and
+	// must not take a working key out of the pool
+	answered := testErr == nil || result.Status == APIKeyTestAuthFailed
+	if capabilities == nil {
+		capabilities = &exchange.Capabilities{}
+	}
+
+	testError := result.Error
+	if len(testError) > 255 {
+		testError = testError[:255]
+	}
+	updates := map[string]interface{}{
+		"test_status":     result.Status,
+		"test_error":      testError,
+		"test_latency_ms": latency,
+		"last_tested_at":  started,
+	}
+	if answered {
+		updates["can_read"] = capabilities.Read
+		updates["can_trade"] = capabilities.Trade
+		updates["can_withdraw"] = capabilities.Withdraw
+	}
+	if capabilities.Withdraw {
+		updates["is_active"] = false
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Skip the update if the credentials were replaced during the test
+		update := tx.Model(&models.APIKey{}).Where("id = ? AND wrapped_data_key = ?", key.ID, key.WrappedDataKey).Updates(updates)
+		if update.Error != nil {
+			return fmt.Errorf("failed to store connection test: %w", update.Error)
+		}
+		if update.RowsAffected == 0 {
+			return ErrAPIKeyNotFound
+		}
+
+		newValues := map[string]interface{}{
+			"status":     result.Status,
+			"latency_ms": latency,
+		}
+		if answered {
+			newValues["permissions"] = capabilities.Permissions()
+		}
+		if capabilities.Withdraw && key.IsActive {
+			newValues["is_active"] = false
+		}
+		_, err := s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "test",
+			Resource:   "api_keys",
+			ResourceID: strconv.FormatUint(uint64(key.ID), 10),
+			NewValues:  newValues,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = s.find(s.db.WithContext(ctx), ws, id)
+	if err != nil {
+		return nil, err
+	}
+	result.Key = apiKeyInfo(key)
+	health := s.pool.Health(key.ID)
+	result.Key.Health = &health
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/market"
+	"trader/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FeeTierSyncResult is the outcome of syncing the fee tiers of the keys
+type FeeTierSyncResult struct {
+	Synced int `json:"synced"`
+	// Unreported counts the keys whose exchange does not report account fees
+	Unreported int `json:"unreported"`
+	Failed     int `json:"failed"`
+}
+
+// SyncFeeTiers reads the fees of the account behind every active key whose
+// credentials were not rejected by their last test from exchanges that report
+// them, stores them as the schedule of the account's tier and records the tier
+// on the key. A key that fails does not stop the others.
+func (s *APIKeyService) SyncFeeTiers(ctx context.Context) (*FeeTierSyncResult, error) {
+	var keys []models.APIKey
+	err := s.db.WithContext(ctx).Preload("Exchange").
+		Where("is_active = ? AND can_withdraw = ? AND test_status <> ?", true, false, APIKeyTestAuthFailed).
+		Order("id").Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+
+	marketService := market.NewService(s.db, s.cfg)
+	result := &FeeTierSyncResult{}
+	var errs []error
+	for i := range keys {
+		key := &keys[i]
+		tier, err := s.syncFeeTier(ctx, marketService, key)
+		switch {
+		case err != nil:
+			result.Failed++
+			errs = append(errs, fmt.Errorf("api key %d: %w", key.ID, err))
+			log.Warn().Err(err).Uint("api_key_id", key.ID).Str("exchange", key.Exchange.Code).Msg("Fee tier sync failed")
+		case tier == "":
+			result.Unreported++
+		default:
+			result.Synced++
+			if tier != key.FeeTier {
+				log.Info().Uint("api_key_id", key.ID).Str("from", key.FeeTier).Str("to", tier).Msg("Fee tier changed")
+			}
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// syncFeeTier returns the key's tier, empty if its exchange does not report fees
+func (s *APIKeyService) syncFeeTier(ctx context.Context, marketService *market.Service, key *models.APIKey) (string, error) {
+	credentials, err := s.Decrypt(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	connector, err := connectors.New(s.cfg.Exchange, s.db, &key.Exchange, credentials.exchangeCredentials(key))
+	if err != nil {
+		return "", err
+	}
+	reporter, ok := connector.(exchange.FeeReporter)
+	if !ok {
+		return "", nil
+	}
+	reported, err := reporter.Fees(ctx)
+	// Rejected credentials open the key's breaker in the pool
+	s.pool.Report(key.ID, 0, err)
+	if err != nil || reported == nil {
+		return "", err
+	}
+
+	tier, err := marketService.StoreAccountFees(ctx, key.ExchangeID, reported)
+	if err != nil {
+		return "", err
+	}
+	// Skip the update if the credentials were replaced meanwhile
+	err = s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND wrapped_data_key = ?", key.ID, key.WrappedDataKey).
+		Update("fee_tier", tier).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to store fee tier: %w", err)
+	}
+	return tier, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/keypool"
+	"trader/internal/models"
+)
+
+var ErrNoAPIKeyAvailable = errors.New("no usable api key for this exchange")
+
+// AcquireAPIKeyRequest selects a key for calls to one exchange
+type AcquireAPIKeyRequest struct {
+	ExchangeID uint
+	// Permission is exchange.PermissionRead or exchange.PermissionTrade
+	Permission string
+	// Weight is the request weight the calls will consume
+	Weight int
+	// PinnedKeyID restricts the choice to one key. Orders belong to the account
+	// of the key that placed them, so a position whose keys are on different
+	// accounts pins the key it trades with.
+	PinnedKeyID *uint
+}
+
+// APIKeyLease is a key chosen by the pool. Callers report the outcome of
+// their calls, which feeds the key's error rate and circuit breaker.
+type APIKeyLease struct {
+	KeyID       uint
+	Exchange    models.Exchange
+	Credentials *exchange.Credentials
+
+	pool   *keypool.Pool
+	weight int
+}
+
+// Report records the outcome of the calls made with the key
+func (l *APIKeyLease) Report(err error) {
+	l.pool.Report(l.KeyID, l.weight, err)
+}
+
+// UpdateBudget records the request weight the exchange reports as used
+func (l *APIKeyLease) UpdateBudget(budget keypool.Budget) {
+	l.pool.UpdateBudget(l.KeyID, budget)
+}
+
+// Acquire chooses one of the workspace's active keys for the exchange. Keys
+// are eligible when their last connection test granted the permission (reading
+// is also allowed for untested keys); among those the pool prefers keys with
+// budget left and few recent errors, and skips keys whose breaker is open.
+func (s *APIKeyService) Acquire(ctx context.Context, ws *Workspace, req *AcquireAPIKeyRequest) (*APIKeyLease, error) {
+	action := OrgActionRead
+	if req.Permission == exchange.PermissionTrade {
+		action = OrgActionWrite
+	}
+	if !ws.Can(action) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	query := s.db.WithContext(ctx).Scopes(ws.Scope).Preload("Exchange").
+		Where("exchange_id = ? AND is_active = ? AND can_withdraw = ? AND test_status <> ?", req.ExchangeID, true, false, APIKeyTestAuthFailed)
+	switch req.Permission {
+	case exchange.PermissionTrade:
+		query = query.Where("can_trade = ?", true)
+	case exchange.PermissionRead:
+		query = query.Where("(can_read = ? OR test_status = ?)", true, "")
+	default:
+		return nil, fmt.Errorf("unsupported api key permission: %s", req.Permission)
+	}
+	if req.PinnedKeyID != nil {
+		query = query.Where("id = ?", *req.PinnedKeyID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("id ASC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoAPIKeyAvailable
+	}
+
+	candidates := make([]uint, 0, len(keys))
+	byID := make(map[uint]*models.APIKey, len(keys))
+	for i := range keys {
+		candidates = append(candidates, keys[i].ID)
+		byID[keys[i].ID] = &keys[i]
+	}
+	id, err := s.pool.Select(candidates, req.Weight)
+	if err != nil {
+		return nil, fmt.Errorf("%w: every key is rate limited or failing", ErrNoAPIKeyAvailable)
+	}
+
+	key := byID[id]
+	credentials, err := s.Decrypt(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(key).UpdateColumn("last_used_at", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to update api key: %w", err)
+	}
+
+	return &APIKeyLease{
+		KeyID:       key.ID,
+		Exchange:    key.Exchange,
+		Credentials: credentials.exchangeCredentials(key),
+		pool:        s.pool,
+		weight:      req.Weight,
+	}, nil
+}
+
+// exchangeCredentials are the credentials of key for its exchange connector
+func (c *APICredentials) exchangeCredentials(key *models.APIKey) *exchange.Credentials {
+	return &exchange.Credentials{
+		APIKey:         c.APIKey,
+		APISecret:      c.APISecret,
+		Passphrase:     c.Passphrase,
+		KeyID:          key.ID,
+		OrganizationID: key.OrganizationID,
+	}
+}
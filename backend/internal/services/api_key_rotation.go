@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultKeyRotationBatchSize is the number of API keys re-wrapped per transaction
+const DefaultKeyRotationBatchSize = 100
+
+// keyRotationResource is the audit resource of rotation runs; the resource ID is the target version
+const keyRotationResource = "master_keys"
+
+// KeyRotationStatus shows how many stored data keys each master key version still wraps
+type KeyRotationStatus struct {
+	ActiveVersion  int           `json:"active_version"`
+	LoadedVersions []int         `json:"loaded_versions"`
+	KeysByVersion  map[int]int64 `json:"keys_by_version"`
+	// Pending is the number of keys not yet wrapped with the active version
+	Pending int64 `json:"pending"`
+}
+
+type KeyRotationFailure struct {
+	APIKeyID uint   `json:"api_key_id"`
+	Version  int    `json:"version"`
+	Error    string `json:"error"`
+}
+
+type KeyRotationResult struct {
+	TargetVersion int `json:"target_version"`
+	// Resumed is set when an earlier run for the same version did not complete
+	Resumed   bool                 `json:"resumed"`
+	Rewrapped int                  `json:"rewrapped"`
+	Batches   int                  `json:"batches"`
+	Failed    []KeyRotationFailure `json:"failed,omitempty"`
+}
+
+// RotationStatus counts stored data keys per master key version
+func (s *APIKeyService) RotationStatus(ctx context.Context) (*KeyRotationStatus, error) {
+	var rows []struct {
+		MasterKeyVersion int
+		Count            int64
+	}
+	err := s.db.WithContext(ctx).Unscoped().Model(&models.APIKey{}).
+		Select("master_key_version, COUNT(*) AS count").
+		Where("wrapped_data_key IS NOT NULL").
+		Group("master_key_version").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count api keys: %w", err)
+	}
+
+	active, err := s.keys.ActiveVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch active master key version: %w", err)
+	}
+	versions, err := s.keys.Versions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch master key versions: %w", err)
+	}
+
+	status := &KeyRotationStatus{
+		ActiveVersion:  active,
+		LoadedVersions: versions,
+		KeysByVersion:  make(map[int]int64, len(rows)),
+	}
+	for _, row := range rows {
+		status.KeysByVersion[row.MasterKeyVersion] = row.Count
+		if row.MasterKeyVersion != status.ActiveVersion {
+			status.Pending += row.Count
+		}
+	}
+	return status, nil
+}
+
+// RotateMasterKey re-wraps every data key that is not wrapped with the active
+// master key. Each batch commits on its own together with an audit entry, so an
+// interrupted run is resumed by running it again. Keys that cannot be unwrapped
+// are reported and left untouched. progress, if set, is called after every batch.
+func (s *APIKeyService) RotateMasterKey(ctx context.Context, batchSize int, progress func(*KeyRotationResult)) (*KeyRotationResult, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultKeyRotationBatchSize
+	}
+
+	target, err := s.keys.ActiveVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch active master key version: %w", err)
+	}
+	result := &KeyRotationResult{TargetVersion: target}
+	resourceID := strconv.Itoa(target)
+
+	var last models.AuditLog
+	err = s.db.WithContext(ctx).
+		Where("resource = ? AND resource_id = ? AND action IN ?", keyRotationResource, resourceID, []string{"rotate_start", "rotate_complete"}).
+		Order("id DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch previous rotation: %w", err)
+	}
+	result.Resumed = err == nil && last.Action == "rotate_start"
+
+	status, err := s.RotationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.audit.Log(ctx, &AuditEntry{
+		Action:     "rotate_start",
+		Resource:   keyRotationResource,
+		ResourceID: resourceID,
+		NewValues:  map[string]interface{}{"target_version": target, "pending": status.Pending, "resumed": result.Resumed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var keys []models.APIKey
+		err := s.db.WithContext(ctx).Unscoped().
+			Select("id", "wrapped_data_key", "master_key_version").
+			Where("id > ? AND master_key_version <> ? AND wrapped_data_key IS NOT NULL", lastID, target).
+			Order("id ASC").Limit(batchSize).Find(&keys).Error
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch api keys: %w", err)
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		rewrapped, failed, err := s.rewrapBatch(ctx, keys, target)
+		if err != nil {
+			return result, err
+		}
+		lastID = keys[len(keys)-1].ID
+		result.Batches++
+		result.Rewrapped += rewrapped
+		result.Failed = append(result.Failed, failed...)
+
+		if progress != nil {
+			progress(result)
+		}
+	}
+
+	failedIDs := make([]uint, 0, len(result.Failed))
+	for _, failure := range result.Failed {
+		failedIDs = append(failedIDs, failure.APIKeyID)
+	}
+	_, err = s.audit.Log(ctx, &AuditEntry{
+		Action:     "rotate_complete",
+		Resource:   keyRotationResource,
+		ResourceID: resourceID,
+		NewValues: map[string]interface{}{
+			"target_version": target,
+			"rewrapped":      result.Rewrapped,
+			"batches":        result.Batches,
+			"failed_ids":     failedIDs,
+		},
+	})
+	return result, err
+}
+
+// rewrapBatch re-wraps one batch and stores it in a transaction together with
+// an audit entry. The key provider is called before the transaction opens
+// because remote providers make a request per key.
+func (s *APIKeyService) rewrapBatch(ctx context.Context, keys []models.APIKey, target int) (int, []KeyRotationFailure, error) {
+	type rewrappedKey struct {
+		key     *models.APIKey
+		wrapped []byte
+		version int
+	}
+
+	var pending []rewrappedKey
+	var failed []KeyRotationFailure
+	for i := range keys {
+		key := &keys[i]
+		wrapped, version, err := s.keys.RewrapKey(ctx, key.WrappedDataKey, key.MasterKeyVersion)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return 0, nil, ctxErr
+			}
+			failed = append(failed, KeyRotationFailure{APIKeyID: key.ID, Version: key.MasterKeyVersion, Error: err.Error()})
+			continue
+		}
+		pending = append(pending, rewrappedKey{key: key, wrapped: wrapped, version: version})
+	}
+
+	failedIDs := make([]uint, 0, len(failed))
+	for _, failure := range failed {
+		failedIDs = append(failedIDs, failure.APIKeyID)
+	}
+
+	var rewrapped int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rewrapped = 0
+		for _, entry := range pending {
+			// The version condition skips keys whose credentials were replaced meanwhile
+			update := tx.Unscoped().Model(&models.APIKey{}).
+				Where("id = ? AND master_key_version = ?", entry.key.ID, entry.key.MasterKeyVersion).
+				UpdateColumns(map[string]interface{}{"wrapped_data_key": entry.wrapped, "master_key_version": entry.version})
+			if update.Error != nil {
+				return fmt.Errorf("failed to re-wrap api key %d: %w", entry.key.ID, update.Error)
+			}
+			rewrapped += int(update.RowsAffected)
+		}
+
+		_, err := s.audit.Record(tx, &AuditEntry{
+			Action:     "rotate_batch",
+			Resource:   keyRotationResource,
+			ResourceID: strconv.Itoa(target),
+			NewValues: map[string]interface{}{
+				"first_id":   keys[0].ID,
+				"last_id":    keys[len(keys)-1].ID,
+				"rewrapped":  rewrapped,
+				"failed_ids": failedIDs,
+			},
+		})
+		return err
+	})
+	return rewrapped, failed, err
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAuditChainBroken = errors.New("audit log chain is broken")
+)
+
+const (
+	// auditChainHeadID is the primary key of the single chain head row
+	auditChainHeadID = 1
+	// auditGenesisHash is the previous hash of the first chained entry
+	auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	// defaultAuditVerifyBatchSize is used when VerifyChain is called without a batch size
+	defaultAuditVerifyBatchSize = 1000
+)
+
+// AuditActionArchive is the action of the entry the retention job records
+// for every archived batch
+const AuditActionArchive = "archive"
+
+type AuditService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// AuditEntry describes an action to be recorded in the audit trail
+type AuditEntry struct {
+	UserID     *uint
+	Action     string
+	Resource   string
+	ResourceID string
+	OldValues  interface{}
+	NewValues  interface{}
+	IPAddress  string
+	UserAgent  string
+}
+
+// ChainVerification is the result of walking the audit log hash chain
+type ChainVerification struct {
+	Valid       bool   `json:"valid"`
+	Checked     int    `json:"checked"`
+	Legacy      int    `json:"legacy"`
+	Checkpoints int    `json:"checkpoints"`
+	LastLogID   uint   `json:"last_log_id"`
+	BrokenAt    *uint  `json:"broken_at,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// auditHashPayload is the canonical content covered by an entry hash
+type auditHashPayload struct {
+	PrevHash   string          `json:"prev_hash"`
+	UserID     *uint           `json:"user_id"`
+	Action     string          `json:"action"`
+	Resource   string          `json:"resource"`
+	ResourceID *string         `json:"resource_id"`
+	OldValues  json.RawMessage `json:"old_values"`
+	NewValues  json.RawMessage `json:"new_values"`
+	IPAddress  *string         `json:"ip_address"`
+	UserAgent  *string         `json:"user_agent"`
+	CreatedAt  int64           `json:"created_at"`
+}
+
+func NewAuditService(db *gorm.DB, cfg *config.Config) *AuditService {
+	return &AuditService{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// Log appends a new entry to the audit trail in its own transaction
+func (s *AuditService) Log(ctx context.Context, entry *AuditEntry) (*models.AuditLog, error) {
+	var auditLog *models.AuditLog
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		auditLog, err = s.Record(tx, entry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auditLog, nil
+}
+
+// Record appends a new entry to the audit trail using the given transaction.
+// The chain head is locked for the rest of the transaction, so callers should
+// keep the surrounding transaction short.
+func (s *AuditService) Record(tx *gorm.DB, entry *AuditEntry) (*models.AuditLog, error) {
+	oldValues, err := marshalAuditValues(entry.OldValues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid old values: %w", err)
+	}
+	newValues, err := marshalAuditValues(entry.NewValues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid new values: %w", err)
+	}
+
+	// Lock the chain head so concurrent writers link to the latest entry
+	var head models.AuditChainHead
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		FirstOrCreate(&head, models.AuditChainHead{ID: auditChainHeadID}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock audit chain head: %w", err)
+	}
+
+	prevHash := head.LastHash
+	if prevHash == "" {
+		prevHash = auditGenesisHash
+	}
+
+	auditLog := models.AuditLog{
+		UserID:     entry.UserID,
+		Action:     entry.Action,
+		Resource:   entry.Resource,
+		ResourceID: optionalString(entry.ResourceID),
+		OldValues:  oldValues,
+		NewValues:  newValues,
+		IPAddress:  optionalString(entry.IPAddress),
+		UserAgent:  optionalString(entry.UserAgent),
+		PrevHash:   prevHash,
+		// Stored with second precision, so hash exactly what will be read back
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	auditLog.Hash, err = computeAuditHash(&auditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&auditLog).Error; err != nil {
+		return nil, fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	head.LastLogID = auditLog.ID
+	head.LastHash = auditLog.Hash
+	if err := tx.Save(&head).Error; err != nil {
+		return nil, fmt.Errorf("failed to update audit chain head: %w", err)
+	}
+
+	// Periodically sign the chain so a full rewrite can be detected
+	if interval := s.cfg.Audit.CheckpointInterval; interval > 0 && auditLog.ID%uint(interval) == 0 {
+		if _, err := s.createCheckpoint(tx, auditLog.ID, auditLog.Hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return &auditLog, nil
+}
+
+// CreateCheckpoint signs the current chain head
+func (s *AuditService) CreateCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	var head models.AuditChainHead
+	err := s.db.WithContext(ctx).Where("id = ?", auditChainHeadID).First(&head).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get audit chain head: %w", err)
+	}
+	if head.LastLogID == 0 {
+		return nil, fmt.Errorf("audit chain is empty")
+	}
+
+	return s.createCheckpoint(s.db.WithContext(ctx), head.LastLogID, head.LastHash)
+}
+
+// VerifyChain walks the whole audit trail and reports the first broken link
+func (s *AuditService) VerifyChain(ctx context.Context, batchSize int) (*ChainVerification, error) {
+	if batchSize <= 0 {
+		batchSize = defaultAuditVerifyBatchSize
+	}
+
+	db := s.db.WithContext(ctx)
+	result := &ChainVerification{}
+
+	var head models.AuditChainHead
+	err := db.Where("id = ?", auditChainHeadID).First(&head).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get audit chain head: %w", err)
+	}
+
+	// The anchor decides where the walk starts, so moving it requires the secret
+	if head.ArchivedLogID != 0 && !hmac.Equal([]byte(head.ArchivedSignature), []byte(s.signArchiveAnchor(head.ArchivedLogID, head.ArchivedHash))) {
+		return result.broken(head.ArchivedLogID, "archive anchor has an invalid signature"), nil
+	}
+
+	// Checkpoints are verified up front, then matched against entries during the walk
+	var checkpoints []models.AuditCheckpoint
+	if err := db.Order("last_log_id ASC").Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to load audit checkpoints: %w", err)
+	}
+	checkpointsByLog := make(map[uint][]models.AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		// Entries covered by archived checkpoints are no longer in the table
+		if checkpoint.LastLogID <= head.ArchivedLogID {
+			continue
+		}
+		if !hmac.Equal([]byte(checkpoint.Signature), []byte(s.signCheckpoint(checkpoint.LastLogID, checkpoint.Hash))) {
+			return result.broken(checkpoint.LastLogID, fmt.Sprintf("checkpoint %d has an invalid signature", checkpoint.ID)), nil
+		}
+		checkpointsByLog[checkpoint.LastLogID] = append(checkpointsByLog[checkpoint.LastLogID], checkpoint)
+		result.Checkpoints++
+	}
+
+	// After archival the chain continues from the last archived entry
+	prevHash := auditGenesisHash
+	if head.ArchivedHash != "" {
+		prevHash = head.ArchivedHash
+	}
+	chained := false
+	// The anchor must also be recorded by a chained archive entry
+	anchored := head.ArchivedLogID == 0
+	lastID := head.ArchivedLogID
+
+	for {
+		var logs []models.AuditLog
+		err := db.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&logs).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit logs: %w", err)
+		}
+		if len(logs) == 0 {
+			break
+		}
+
+		for i := range logs {
+			auditLog := &logs[i]
+			lastID = auditLog.ID
+
+			// Entries written before the chain was introduced have no hash
+			if auditLog.Hash == "" {
+				if chained {
+					return result.broken(auditLog.ID, "entry is missing its hash"), nil
+				}
+				result.Legacy++
+				continue
+			}
+			chained = true
+
+			if auditLog.PrevHash != prevHash {
+				return result.broken(auditLog.ID, "previous hash does not match the preceding entry"), nil
+			}
+
+			hash, err := computeAuditHash(auditLog)
+			if err != nil {
+				return nil, err
+			}
+			if hash != auditLog.Hash {
+				return result.broken(auditLog.ID, "entry content does not match its hash"), nil
+			}
+
+			for _, checkpoint := range checkpointsByLog[auditLog.ID] {
+				if checkpoint.Hash != auditLog.Hash {
+					return result.broken(auditLog.ID, fmt.Sprintf("entry does not match signed checkpoint %d", checkpoint.ID)), nil
+				}
+			}
+			delete(checkpointsByLog, auditLog.ID)
+
+			if !anchored && recordsArchiveAnchor(auditLog, &head) {
+				anchored = true
+			}
+
+			prevHash = auditLog.Hash
+			result.Checked++
+			result.LastLogID = auditLog.ID
+		}
+	}
+
+	// Any checkpoint left over points at an entry that no longer exists
+	for _, checkpoint := range checkpoints {
+		if _, ok := checkpointsByLog[checkpoint.LastLogID]; ok {
+			return result.broken(checkpoint.LastLogID, fmt.Sprintf("signed checkpoint %d references a missing entry", checkpoint.ID)), nil
+		}
+	}
+
+	if !anchored {
+		return result.broken(head.ArchivedLogID, "archive anchor is not recorded in the chain"), nil
+	}
+
+	// The head must point at the last entry, otherwise the tail was truncated
+	expectedLastID := result.LastLogID
+	if expectedLastID == 0 {
+		expectedLastID = head.ArchivedLogID
+	}
+	if head.LastLogID != 0 && (head.LastLogID != expectedLastID || head.LastHash != prevHash) {
+		return result.broken(head.LastLogID, "chain head does not match the last entry"), nil
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+// MarkArchived moves the start of the chain past entries removed by retention.
+// It must be called in the same transaction that deletes the entries, which
+// must also record an archive entry with the same last_id and last_hash.
+func (s *AuditService) MarkArchived(tx *gorm.DB, lastLogID uint, lastHash string) error {
+	var head models.AuditChainHead
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		FirstOrCreate(&head, models.AuditChainHead{ID: auditChainHeadID}).Error
+	if err != nil {
+		return fmt.Errorf("failed to lock audit chain head: %w", err)
+	}
+	if lastLogID <= head.ArchivedLogID {
+		return nil
+	}
+
+	head.ArchivedLogID = lastLogID
+	head.ArchivedHash = lastHash
+	head.ArchivedSignature = s.signArchiveAnchor(lastLogID, lastHash)
+	if err := tx.Save(&head).Error; err != nil {
+		return fmt.Errorf("failed to update audit chain head: %w", err)
+	}
+	return nil
+}
+
+func (s *AuditService) createCheckpoint(tx *gorm.DB, logID uint, hash string) (*models.AuditCheckpoint, error) {
+	checkpoint := models.AuditCheckpoint{
+		LastLogID: logID,
+		Hash:      hash,
+		Signature: s.signCheckpoint(logID, hash),
+	}
+	if err := tx.Create(&checkpoint).Error; err != nil {
+		return nil, fmt.Errorf("failed to create audit checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+func (s *AuditService) signCheckpoint(logID uint, hash string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Audit.CheckpointSecret))
+	fmt.Fprintf(mac, "%d:%s", logID, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signArchiveAnchor signs the start of the chain. The prefix keeps a checkpoint
+// signature from being reused as an anchor signature.
+func (s *AuditService) signArchiveAnchor(logID uint, hash string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Audit.CheckpointSecret))
+	fmt.Fprintf(mac, "archive:%d:%s", logID, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordsArchiveAnchor reports whether the entry is the archive entry written
+// together with the anchor of head
+func recordsArchiveAnchor(auditLog *models.AuditLog, head *models.AuditChainHead) bool {
+	if auditLog.Action != AuditActionArchive || len(auditLog.NewValues) == 0 {
+		return false
+	}
+	var values struct {
+		LastID   uint   `json:"last_id"`
+		LastHash string `json:"last_hash"`
+	}
+	if err := json.Unmarshal(auditLog.NewValues, &values); err != nil {
+		return false
+	}
+	return values.LastID == head.ArchivedLogID && values.LastHash == head.ArchivedHash
+}
+
+func (v *ChainVerification) broken(logID uint, reason string) *ChainVerification {
+	v.Valid = false
+	v.BrokenAt = &logID
+	v.Reason = reason
+	return v
+}
+
+// computeAuditHash hashes the entry content together with the previous hash
+func computeAuditHash(auditLog *models.AuditLog) (string, error) {
+	oldValues, err := canonicalJSON(auditLog.OldValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize old values of audit log %d: %w", auditLog.ID, err)
+	}
+	newValues, err := canonicalJSON(auditLog.NewValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize new values of audit log %d: %w", auditLog.ID, err)
+	}
+
+	payload, err := json.Marshal(auditHashPayload{
+		PrevHash:   auditLog.PrevHash,
+		UserID:     auditLog.UserID,
+		Action:     auditLog.Action,
+		Resource:   auditLog.Resource,
+		ResourceID: auditLog.ResourceID,
+		OldValues:  oldValues,
+		NewValues:  newValues,
+		IPAddress:  auditLog.IPAddress,
+		UserAgent:  auditLog.UserAgent,
+		CreatedAt:  auditLog.CreatedAt.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit log: %w", err)
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON re-encodes a JSON document with sorted keys and no whitespace,
+// so the hash does not depend on how the database normalizes JSON columns
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(value)
+}
+
+func marshalAuditValues(values interface{}) (json.RawMessage, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(raw)
+}
+
+func optionalString(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -9,6 +9,7 @@ import (
 	"trader/internal/auth"
 	"trader/internal/config"
 	"trader/internal/models"
+	"trader/internal/utils"
 
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
@@ -21,8 +22,13 @@ var (
 	ErrUserInactive       = errors.New("user account is inactive")
 	ErrAccountLocked      = errors.New("user account is locked")
 	ErrTokenNotFound      = errors.New("token not found")
+	ErrInvalidResetToken  = errors.New("password reset token is invalid or expired")
+	ErrRefreshTokenOwner  = errors.New("refresh token is invalid or issued to another user")
 )
 
+// TokenBlacklistPrefix is the Redis key prefix for revoked refresh tokens
+const TokenBlacklistPrefix = "blacklisted_token:"
+
 type AuthService struct {
 	db         *gorm.DB
 	redis      *redis.Client
@@ -35,11 +41,19 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,min=8"`
 }
 
+// ResetPasswordRequest redeems a password reset or invite token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
 type LoginResponse struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresIn    int64     `json:"expires_in"`
 	User         *UserInfo `json:"user"`
+	// OrganizationID is the workspace the tokens act in
+	OrganizationID uint `json:"organization_id,omitempty"`
 }
 
 type RefreshTokenRequest struct {
@@ -106,8 +120,14 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 	// Get user permissions
 	permissions := s.getUserPermissions(&user)
 
+	// Start the session in the user's default workspace
+	organizationID, err := defaultOrganizationID(s.db.WithContext(ctx), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate token pair
-	tokenPair, err := s.jwtManager.GenerateTokenPair(user.ID, user.Email, permissions)
+	tokenPair, err := s.jwtManager.GenerateWorkspaceTokenPair(user.ID, user.Email, permissions, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -126,10 +146,11 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 	}
 
 	return &LoginResponse{
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-		ExpiresIn:    tokenPair.ExpiresIn,
-		User:         userInfo,
+		AccessToken:    tokenPair.AccessToken,
+		RefreshToken:   tokenPair.RefreshToken,
+		ExpiresIn:      tokenPair.ExpiresIn,
+		User:           userInfo,
+		OrganizationID: organizationID,
 	}, nil
 }
 
@@ -165,8 +186,20 @@ func (s *AuthService) RefreshToken(ctx context.Context, req *RefreshTokenRequest
 	// Get current permissions (may have changed)
 	permissions := s.getUserPermissions(&user)
 
+	// Keep the active workspace unless the user has left it since
+	organizationID := claims.OrganizationID
+	if _, err := findWorkspace(s.db.WithContext(ctx), organizationID, user.ID); err != nil {
+		if !errors.Is(err, ErrNotOrganizationMember) {
+			return nil, err
+		}
+		organizationID, err = defaultOrganizationID(s.db.WithContext(ctx), user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate new token pair
-	tokenPair, err := s.jwtManager.GenerateTokenPair(user.ID, user.Email, permissions)
+	tokenPair, err := s.jwtManager.GenerateWorkspaceTokenPair(user.ID, user.Email, permissions, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -184,11 +217,66 @@ func (s *AuthService) RefreshToken(ctx context.Context, req *RefreshTokenRequest
 		Permissions:   permissions,
 	}
 
+	return &LoginResponse{
+		AccessToken:    tokenPair.AccessToken,
+		RefreshToken:   tokenPair.RefreshToken,
+		ExpiresIn:      tokenPair.ExpiresIn,
+		User:           userInfo,
+		OrganizationID: organizationID,
+	}, nil
+}
+
+// SwitchOrganization issues a token pair for another workspace the user belongs to.
+// The refresh token of the previous session is revoked; it must be the user's own.
+func (s *AuthService) SwitchOrganization(ctx context.Context, userID, organizationID uint, refreshToken string) (*LoginResponse, error) {
+	if refreshToken != "" {
+		claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
+		if err != nil || claims.UserID != userID {
+			return nil, ErrRefreshTokenOwner
+		}
+	}
+
+	if _, err := findWorkspace(s.db.WithContext(ctx), organizationID, userID); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err := s.db.Preload("Roles.Permissions").Preload("Permissions.Permission").
+		Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsActive == nil || !*user.IsActive || user.IsLocked() {
+		return nil, ErrUserInactive
+	}
+
+	permissions := s.getUserPermissions(&user)
+	tokenPair, err := s.jwtManager.GenerateWorkspaceTokenPair(user.ID, user.Email, permissions, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	if refreshToken != "" {
+		s.blacklistToken(ctx, refreshToken)
+	}
+
 	return &LoginResponse{
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
 		ExpiresIn:    tokenPair.ExpiresIn,
-		User:         userInfo,
+		User: &UserInfo{
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			IsActive:      user.IsActive,
+			EmailVerified: user.EmailVerified,
+			LastLoginAt:   user.LastLoginAt,
+			Roles:         s.getUserRoles(&user),
+			Permissions:   permissions,
+		},
+		OrganizationID: organizationID,
 	}, nil
 }
 
@@ -228,6 +316,53 @@ func (s *AuthService) GetCurrentUser(ctx context.Context, userID uint) (*UserInf
 	}, nil
 }
 
+// ResetPassword sets the password of the user a reset or invite token was
+// issued to and unlocks the account. The token is deleted so it cannot be
+// redeemed twice.
+func (s *AuthService) ResetPassword(ctx context.Context, req *ResetPasswordRequest) error {
+	if err := utils.ValidateStruct(req); err != nil {
+		return fmt.Errorf("%w: %v", ErrWeakPassword, err)
+	}
+	// Hash outside the transaction; bcrypt is deliberately slow
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), s.cfg.Security.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		err := tx.Where("token = ? AND expires_at > ? AND used_at IS NULL", req.Token, time.Now()).First(&resetToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find reset token: %w", err)
+		}
+
+		// A concurrent redemption of the same token deletes nothing and fails
+		deleted := tx.Unscoped().Delete(&resetToken)
+		if deleted.Error != nil {
+			return fmt.Errorf("failed to delete reset token: %w", deleted.Error)
+		}
+		if deleted.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		updated := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Updates(map[string]interface{}{
+			"password_hash":  string(hash),
+			"login_attempts": 0,
+			"locked_until":   nil,
+		})
+		if updated.Error != nil {
+			return fmt.Errorf("failed to update password: %w", updated.Error)
+		}
+		if updated.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+		return nil
+	})
+}
+
 // IsTokenBlacklisted checks if token is blacklisted
 func (s *AuthService) IsTokenBlacklisted(ctx context.Context, token string) bool {
 	return s.isTokenBlacklisted(ctx, token)
@@ -298,12 +433,12 @@ func (s *AuthService) getUserPermissions(user *models.User) []string {
 
 func (s *AuthService) blacklistToken(ctx context.Context, token string) {
 	// Store token in Redis with expiration matching refresh token duration
-	key := "blacklisted_token:" + token
+	key := TokenBlacklistPrefix + token
 	s.redis.Set(ctx, key, "1", s.cfg.JWT.RefreshDuration)
 }
 
 func (s *AuthService) isTokenBlacklisted(ctx context.Context, token string) bool {
-	key := "blacklisted_token:" + token
+	key := TokenBlacklistPrefix + token
 	result := s.redis.Exists(ctx, key)
 	return result.Val() > 0
 }
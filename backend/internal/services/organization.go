@@ -0,0 +1,609 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrNotOrganizationMember     = errors.New("user is not a member of the organization")
+	ErrOrganizationForbidden     = errors.New("organization role does not allow this action")
+	ErrInvalidOrganizationRole   = errors.New("invalid organization role")
+	ErrInvalidOrganizationName   = errors.New("organization name must be 1 to 100 characters")
+	ErrAlreadyOrganizationMember = errors.New("user is already a member of the organization")
+	ErrLastOrganizationOwner     = errors.New("organization must keep at least one owner")
+	ErrPersonalOrganization      = errors.New("personal workspaces cannot be shared or deleted")
+)
+
+// Organization roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleTrader = "trader"
+	OrgRoleViewer = "viewer"
+)
+
+// Actions checked against the organization role
+const (
+	// OrgActionRead covers reading keys, strategies, positions and analytics
+	OrgActionRead = "read"
+	// OrgActionWrite covers creating, changing and deleting those resources
+	OrgActionWrite = "write"
+	// OrgActionManageMembers covers adding, removing and changing members
+	OrgActionManageMembers = "manage_members"
+	// OrgActionManageOrganization covers renaming and deleting the organization
+	OrgActionManageOrganization = "manage_organization"
+)
+
+var OrganizationRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleTrader, OrgRoleViewer}
+
+var organizationRoleActions = map[string][]string{
+	OrgRoleOwner:  {OrgActionRead, OrgActionWrite, OrgActionManageMembers, OrgActionManageOrganization},
+	OrgRoleAdmin:  {OrgActionRead, OrgActionWrite, OrgActionManageMembers},
+	OrgRoleTrader: {OrgActionRead, OrgActionWrite},
+	OrgRoleViewer: {OrgActionRead},
+}
+
+const personalOrganizationName = "Personal workspace"
+
+// Workspace is the organization a request acts in, together with the caller's role there
+type Workspace struct {
+	OrganizationID uint
+	UserID         uint
+	Role           string
+}
+
+// Can reports whether the caller's organization role allows the action
+func (w *Workspace) Can(action string) bool {
+	return w != nil && containsString(organizationRoleActions[w.Role], action)
+}
+
+// Scope restricts a query to rows owned by the workspace's organization.
+// Use with db.Scopes on tables that have an organization_id column.
+func (w *Workspace) Scope(db *gorm.DB) *gorm.DB {
+	return db.Where("organization_id = ?", w.OrganizationID)
+}
+
+type OrganizationInfo struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	IsPersonal bool      `json:"is_personal"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type OrganizationMemberInfo struct {
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type AddOrganizationMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+type UpdateOrganizationMemberRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type OrganizationService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	audit *AuditService
+}
+
+func NewOrganizationService(db *gorm.DB, cfg *config.Config) *OrganizationService {
+	return &OrganizationService{
+		db:    db,
+		cfg:   cfg,
+		audit: NewAuditService(db, cfg),
+	}
+}
+
+// Workspace loads the user's membership in an organization
+func (s *OrganizationService) Workspace(ctx context.Context, organizationID, userID uint) (*Workspace, error) {
+	return findWorkspace(s.db.WithContext(ctx), organizationID, userID)
+}
+
+// ListForUser returns the organizations the user belongs to, personal workspace first
+func (s *OrganizationService) ListForUser(ctx context.Context, userID uint) ([]OrganizationInfo, error) {
+	var rows []struct {
+		models.Organization
+		Role string
+	}
+	err := s.db.WithContext(ctx).Model(&models.Organization{}).
+		Select("organizations.*, organization_members.role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.is_personal DESC, organizations.name ASC, organizations.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch organizations: %w", err)
+	}
+
+	organizations := make([]OrganizationInfo, 0, len(rows))
+	for _, row := range rows {
+		organizations = append(organizations, organizationInfo(&row.Organization, row.Role))
+	}
+	return organizations, nil
+}
+
+// Get returns the workspace's organization
+func (s *OrganizationService) Get(ctx context.Context, ws *Workspace) (*OrganizationInfo, error) {
+	var organization models.Organization
+	if err := s.db.WithContext(ctx).First(&organization, ws.OrganizationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	info := organizationInfo(&organization, ws.Role)
+	return &info, nil
+}
+
+// Create creates a shared organization owned by the user
+func (s *OrganizationService) Create(ctx context.Context, userID uint, req *CreateOrganizationRequest) (*OrganizationInfo, error) {
+	name, err := normalizeOrganizationName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	organization := models.Organization{Name: name, CreatedBy: &userID}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+		member := models.OrganizationMember{OrganizationID: organization.ID, UserID: userID, Role: OrgRoleOwner}
+		if err := tx.Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add owner: %w", err)
+		}
+
+		_, err := s.audit.Record(tx, &AuditEntry{
+			UserID:     &userID,
+			Action:     "create",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(organization.ID), 10),
+			NewValues:  map[string]interface{}{"name": name},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := organizationInfo(&organization, OrgRoleOwner)
+	return &info, nil
+}
+
+// Rename changes the organization name
+func (s *OrganizationService) Rename(ctx context.Context, ws *Workspace, name string) (*OrganizationInfo, error) {
+	if !ws.Can(OrgActionManageOrganization) {
+		return nil, ErrOrganizationForbidden
+	}
+	name, err := normalizeOrganizationName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var organization models.Organization
+		if err := tx.First(&organization, ws.OrganizationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if err := tx.Model(&organization).Update("name", name).Error; err != nil {
+			return fmt.Errorf("failed to rename organization: %w", err)
+		}
+
+		_, err := s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "update",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(ws.OrganizationID), 10),
+			OldValues:  map[string]interface{}{"name": organization.Name},
+			NewValues:  map[string]interface{}{"name": name},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, ws)
+}
+
+// Delete soft-deletes a shared organization; its resources are no longer reachable
+func (s *OrganizationService) Delete(ctx context.Context, ws *Workspace) error {
+	if !ws.Can(OrgActionManageOrganization) {
+		return ErrOrganizationForbidden
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var organization models.Organization
+		if err := tx.First(&organization, ws.OrganizationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if organization.IsPersonal {
+			return ErrPersonalOrganization
+		}
+		if err := tx.Delete(&organization).Error; err != nil {
+			return fmt.Errorf("failed to delete organization: %w", err)
+		}
+
+		_, err := s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "delete",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(ws.OrganizationID), 10),
+		})
+		return err
+	})
+}
+
+// Members lists the members of the workspace's organization
+func (s *OrganizationService) Members(ctx context.Context, ws *Workspace) ([]OrganizationMemberInfo, error) {
+	if !ws.Can(OrgActionRead) {
+		return nil, ErrOrganizationForbidden
+	}
+
+	var members []models.OrganizationMember
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("organization_id = ?", ws.OrganizationID).
+		Order("joined_at ASC, user_id ASC").Find(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch members: %w", err)
+	}
+
+	infos := make([]OrganizationMemberInfo, 0, len(members))
+	for _, member := range members {
+		infos = append(infos, OrganizationMemberInfo{
+			UserID:    member.UserID,
+			Email:     member.User.Email,
+			FirstName: member.User.FirstName,
+			LastName:  member.User.LastName,
+			Role:      member.Role,
+			JoinedAt:  member.JoinedAt,
+		})
+	}
+	return infos, nil
+}
+
+// AddMember adds an existing user to the organization. Only owners can add owners.
+func (s *OrganizationService) AddMember(ctx context.Context, ws *Workspace, req *AddOrganizationMemberRequest) (*OrganizationMemberInfo, error) {
+	if !ws.Can(OrgActionManageMembers) {
+		return nil, ErrOrganizationForbidden
+	}
+	if !containsString(OrganizationRoles, req.Role) {
+		return nil, ErrInvalidOrganizationRole
+	}
+	if req.Role == OrgRoleOwner && ws.Role != OrgRoleOwner {
+		return nil, ErrOrganizationForbidden
+	}
+
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var organization models.Organization
+		if err := tx.First(&organization, ws.OrganizationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if organization.IsPersonal {
+			return ErrPersonalOrganization
+		}
+
+		var user models.User
+		if err := tx.Where("email = ?", strings.TrimSpace(req.Email)).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+
+		var count int64
+		err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", ws.OrganizationID, user.ID).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if count > 0 {
+			return ErrAlreadyOrganizationMember
+		}
+
+		member = models.OrganizationMember{
+			OrganizationID: ws.OrganizationID,
+			UserID:         user.ID,
+			Role:           req.Role,
+			InvitedBy:      &ws.UserID,
+			User:           user,
+		}
+		if err := tx.Omit("User", "Organization").Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add member: %w", err)
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "add_member",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(ws.OrganizationID), 10),
+			NewValues:  map[string]interface{}{"user_id": user.ID, "role": req.Role},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationMemberInfo{
+		UserID:    member.UserID,
+		Email:     member.User.Email,
+		FirstName: member.User.FirstName,
+		LastName:  member.User.LastName,
+		Role:      member.Role,
+		JoinedAt:  member.JoinedAt,
+	}, nil
+}
+
+// UpdateMemberRole changes a member's role. Only owners can grant or revoke ownership.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, ws *Workspace, userID uint, role string) error {
+	if !ws.Can(OrgActionManageMembers) {
+		return ErrOrganizationForbidden
+	}
+	if !containsString(OrganizationRoles, role) {
+		return ErrInvalidOrganizationRole
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := findMember(tx, ws.OrganizationID, userID)
+		if err != nil {
+			return err
+		}
+		if member.Role == role {
+			return nil
+		}
+		if (role == OrgRoleOwner || member.Role == OrgRoleOwner) && ws.Role != OrgRoleOwner {
+			return ErrOrganizationForbidden
+		}
+		if member.Role == OrgRoleOwner {
+			if err := ensureAnotherOwner(tx, ws.OrganizationID, userID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", ws.OrganizationID, userID).
+			Update("role", role).Error
+		if err != nil {
+			return fmt.Errorf("failed to update member: %w", err)
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "update_member",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(ws.OrganizationID), 10),
+			OldValues:  map[string]interface{}{"user_id": userID, "role": member.Role},
+			NewValues:  map[string]interface{}{"user_id": userID, "role": role},
+		})
+		return err
+	})
+}
+
+// RemoveMember removes a member. Every member may remove themselves to leave the organization.
+func (s *OrganizationService) RemoveMember(ctx context.Context, ws *Workspace, userID uint) error {
+	if userID != ws.UserID && !ws.Can(OrgActionManageMembers) {
+		return ErrOrganizationForbidden
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		member, err := findMember(tx, ws.OrganizationID, userID)
+		if err != nil {
+			return err
+		}
+		if member.Role == OrgRoleOwner {
+			if userID != ws.UserID && ws.Role != OrgRoleOwner {
+				return ErrOrganizationForbidden
+			}
+			if err := ensureAnotherOwner(tx, ws.OrganizationID, userID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Where("organization_id = ? AND user_id = ?", ws.OrganizationID, userID).
+			Delete(&models.OrganizationMember{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     &ws.UserID,
+			Action:     "remove_member",
+			Resource:   "organizations",
+			ResourceID: strconv.FormatUint(uint64(ws.OrganizationID), 10),
+			OldValues:  map[string]interface{}{"user_id": userID, "role": member.Role},
+		})
+		return err
+	})
+}
+
+// Name implements PersonalDataProvider
+func (s *OrganizationService) Name() string {
+	return "organizations"
+}
+
+// Export implements PersonalDataProvider
+func (s *OrganizationService) Export(ctx context.Context, db *gorm.DB, userID uint) (interface{}, error) {
+	return s.ListForUser(ctx, userID)
+}
+
+// Erase implements PersonalDataProvider. The user leaves every organization and
+// the personal workspace is deleted. Where the user was the last owner, the
+// longest-standing remaining member becomes owner so shared resources stay managed.
+func (s *OrganizationService) Erase(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return leaveOrganizations(tx, userID)
+}
+
+// leaveOrganizations removes all memberships of a user who is erased or purged
+func leaveOrganizations(tx *gorm.DB, userID uint) error {
+	var memberships []models.OrganizationMember
+	if err := tx.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return fmt.Errorf("failed to fetch memberships: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.OrganizationMember{}).Error; err != nil {
+		return fmt.Errorf("failed to remove memberships: %w", err)
+	}
+	if err := tx.Where("created_by = ? AND is_personal = ?", userID, true).Delete(&models.Organization{}).Error; err != nil {
+		return fmt.Errorf("failed to delete personal workspace: %w", err)
+	}
+
+	for _, membership := range memberships {
+		if membership.Role != OrgRoleOwner {
+			continue
+		}
+
+		var owners int64
+		err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND role = ?", membership.OrganizationID, OrgRoleOwner).Count(&owners).Error
+		if err != nil {
+			return fmt.Errorf("failed to count owners: %w", err)
+		}
+		if owners > 0 {
+			continue
+		}
+
+		var successor models.OrganizationMember
+		err = tx.Where("organization_id = ?", membership.OrganizationID).
+			Order("joined_at ASC, user_id ASC").First(&successor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find successor: %w", err)
+		}
+		err = tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", successor.OrganizationID, successor.UserID).
+			Update("role", OrgRoleOwner).Error
+		if err != nil {
+			return fmt.Errorf("failed to promote successor: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// defaultOrganizationID returns the workspace a new session starts in: the
+// personal workspace, created on first use, or else the oldest membership
+func defaultOrganizationID(db *gorm.DB, userID uint) (uint, error) {
+	var member models.OrganizationMember
+	err := db.Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.is_personal DESC, organization_members.joined_at ASC, organization_members.organization_id ASC").
+		First(&member).Error
+	if err == nil {
+		return member.OrganizationID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("failed to fetch memberships: %w", err)
+	}
+
+	var organizationID uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		organization := models.Organization{Name: personalOrganizationName, IsPersonal: true, CreatedBy: &userID}
+		if err := tx.Create(&organization).Error; err != nil {
+			return fmt.Errorf("failed to create personal workspace: %w", err)
+		}
+		member := models.OrganizationMember{OrganizationID: organization.ID, UserID: userID, Role: OrgRoleOwner}
+		if err := tx.Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add owner: %w", err)
+		}
+		organizationID = organization.ID
+		return nil
+	})
+	return organizationID, err
+}
+
+// findWorkspace resolves a membership in an organization that has not been deleted
+func findWorkspace(db *gorm.DB, organizationID, userID uint) (*Workspace, error) {
+	var member models.OrganizationMember
+	err := db.Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.organization_id = ? AND organization_members.user_id = ?", organizationID, userID).
+		First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrganizationMember
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &Workspace{OrganizationID: member.OrganizationID, UserID: member.UserID, Role: member.Role}, nil
+}
+
+func findMember(tx *gorm.DB, organizationID, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := tx.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrganizationMember
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &member, nil
+}
+
+func ensureAnotherOwner(tx *gorm.DB, organizationID, userID uint) error {
+	var owners int64
+	err := tx.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", organizationID, OrgRoleOwner, userID).
+		Count(&owners).Error
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners == 0 {
+		return ErrLastOrganizationOwner
+	}
+	return nil
+}
+
+func normalizeOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", ErrInvalidOrganizationName
+	}
+	return name, nil
+}
+
+func organizationInfo(organization *models.Organization, role string) OrganizationInfo {
+	return OrganizationInfo{
+		ID:         organization.ID,
+		Name:       organization.Name,
+		IsPersonal: organization.IsPersonal,
+		Role:       role,
+		CreatedAt:  organization.CreatedAt,
+	}
+}
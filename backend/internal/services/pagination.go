@@ -0,0 +1,52 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Sort orders accepted by list endpoints
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// likeEscaper escapes LIKE wildcards; queries declare '!' as the escape character
+// because backslash escaping differs between MySQL and SQLite
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// pageCursor identifies the last row of a page for keyset pagination.
+// Sort field and order are embedded so a cursor cannot be replayed against a different ordering.
+type pageCursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        uint   `json:"id"`
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded, sortBy, sortOrder string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
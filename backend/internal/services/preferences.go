@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// Timezones are validated against the embedded database so slim images without tzdata work
+	_ "time/tzdata"
+
+	"trader/internal/config"
+	"trader/internal/models"
+	"trader/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification event types a user can route to channels
+const (
+	EventPositionOpened   = "position_opened"
+	EventDCAOrderFilled   = "dca_order_filled"
+	EventTakeProfitFilled = "take_profit_filled"
+	EventPositionClosed   = "position_closed"
+	EventStrategyChanged  = "strategy_changed"
+	EventAPIKeyError      = "api_key_error"
+	EventSecurityAlert    = "security_alert"
+)
+
+// Notification channels
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+// Strategy presets offered as the default for new positions
+const (
+	StrategyPresetConservative = "conservative"
+	StrategyPresetModerate     = "moderate"
+	StrategyPresetAggressive   = "aggressive"
+)
+
+var (
+	NotificationEvents = []string{
+		EventPositionOpened, EventDCAOrderFilled, EventTakeProfitFilled, EventPositionClosed,
+		EventStrategyChanged, EventAPIKeyError, EventSecurityAlert,
+	}
+	NotificationChannels = []string{ChannelEmail, ChannelTelegram}
+	StrategyPresets      = []string{StrategyPresetConservative, StrategyPresetModerate, StrategyPresetAggressive}
+)
+
+// PreferencesSchemaVersion is the layout of the stored preference document.
+// Fields added later are filled from the defaults when older documents are read.
+const PreferencesSchemaVersion = 1
+
+// PreferencesCachePrefix is the Redis key prefix for cached preferences
+const PreferencesCachePrefix = "user_preferences:"
+
+const preferencesCacheTTL = 10 * time.Minute
+
+var (
+	ErrPreferencesConflict = errors.New("preferences were changed by another request")
+	ErrPreferencesVersion  = errors.New("preferences were written by a newer schema version")
+)
+
+// Preferences is the typed preference document
+type Preferences struct {
+	Timezone      string `json:"timezone"`
+	QuoteCurrency string `json:"quote_currency"`
+	// Notifications maps an event type to the channels it is delivered to; an empty list mutes the event
+	Notifications         map[string][]string `json:"notifications"`
+	DefaultStrategyPreset string              `json:"default_strategy_preset"`
+}
+
+// UserPreferences are a user's preferences with the revision required for updates
+type UserPreferences struct {
+	Preferences
+	SchemaVersion int        `json:"schema_version"`
+	Revision      int        `json:"revision"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// UpdatePreferencesRequest changes the given fields and leaves the others untouched
+type UpdatePreferencesRequest struct {
+	Timezone      *string `json:"timezone,omitempty"`
+	QuoteCurrency *string `json:"quote_currency,omitempty"`
+	// Notifications replaces the channels of the listed events only
+	Notifications         map[string][]string `json:"notifications,omitempty"`
+	DefaultStrategyPreset *string             `json:"default_strategy_preset,omitempty"`
+	// Revision, when set, must match the stored revision
+	Revision *int `json:"revision,omitempty"`
+}
+
+type PreferencesService struct {
+	db    *gorm.DB
+	redis *redis.Client
+	cfg   *config.Config
+}
+
+// NewPreferencesService creates the service; redis may be nil to disable caching
+func NewPreferencesService(db *gorm.DB, redis *redis.Client, cfg *config.Config) *PreferencesService {
+	return &PreferencesService{
+		db:    db,
+		redis: redis,
+		cfg:   cfg,
+	}
+}
+
+// DefaultPreferences returns the preferences of a user who never changed them
+func DefaultPreferences() Preferences {
+	notifications := make(map[string][]string, len(NotificationEvents))
+	for _, event := range NotificationEvents {
+		notifications[event] = []string{ChannelEmail}
+	}
+
+	return Preferences{
+		Timezone:              "UTC",
+		QuoteCurrency:         "USDT",
+		Notifications:         notifications,
+		DefaultStrategyPreset: StrategyPresetModerate,
+	}
+}
+
+// Get returns the user's preferences, from the cache when possible
+func (s *PreferencesService) Get(ctx context.Context, userID uint) (*UserPreferences, error) {
+	if s.redis != nil {
+		cached, err := s.redis.Get(ctx, preferencesCacheKey(userID)).Bytes()
+		if err == nil {
+			var prefs UserPreferences
+			if json.Unmarshal(cached, &prefs) == nil {
+				return &prefs, nil
+			}
+		}
+	}
+
+	prefs, err := s.load(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache(ctx, userID, prefs)
+	return prefs, nil
+}
+
+// NotificationChannels returns the channels an event should be delivered to for the user
+func (s *PreferencesService) NotificationChannels(ctx context.Context, userID uint, event string) ([]string, error) {
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return prefs.Notifications[event], nil
+}
+
+// Update validates and stores a partial preference update
+func (s *PreferencesService) Update(ctx context.Context, userID uint, req *UpdatePreferencesRequest) (*UserPreferences, error) {
+	var updated *UserPreferences
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.UserPreference
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&row).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load preferences: %w", err)
+		}
+
+		current := &UserPreferences{Preferences: DefaultPreferences(), SchemaVersion: PreferencesSchemaVersion}
+		if exists {
+			if current, err = decodePreferences(&row); err != nil {
+				return err
+			}
+		}
+
+		if req.Revision != nil && *req.Revision != current.Revision {
+			return ErrPreferencesConflict
+		}
+
+		prefs := applyPreferencesUpdate(current.Preferences, req)
+		if err := s.validate(tx, &prefs); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(prefs)
+		if err != nil {
+			return fmt.Errorf("failed to encode preferences: %w", err)
+		}
+
+		if exists {
+			result := tx.Model(&models.UserPreference{}).
+				Where("user_id = ? AND revision = ?", userID, row.Revision).
+				Updates(map[string]interface{}{
+					"schema_version": PreferencesSchemaVersion,
+					"revision":       row.Revision + 1,
+					"data":           data,
+				})
+			if result.Error != nil {
+				return fmt.Errorf("failed to update preferences: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return ErrPreferencesConflict
+			}
+		} else {
+			row = models.UserPreference{
+				UserID:        userID,
+				SchemaVersion: PreferencesSchemaVersion,
+				Revision:      1,
+				Data:          data,
+			}
+			// A concurrent first write inserts the row between the read and here
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if result.Error != nil {
+				return fmt.Errorf("failed to create preferences: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return ErrPreferencesConflict
+			}
+		}
+
+		updated, err = s.load(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache(ctx, userID, updated)
+	return updated, nil
+}
+
+// Name implements PersonalDataProvider
+func (s *PreferencesService) Name() string {
+	return "preferences"
+}
+
+// Export implements PersonalDataProvider
+func (s *PreferencesService) Export(ctx context.Context, db *gorm.DB, userID uint) (interface{}, error) {
+	return s.load(db, userID)
+}
+
+// Erase implements PersonalDataProvider
+func (s *PreferencesService) Erase(ctx context.Context, tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserPreference{}).Error; err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	if s.redis != nil {
+		s.redis.Del(ctx, preferencesCacheKey(userID))
+	}
+	return nil
+}
+
+func (s *PreferencesService) load(db *gorm.DB, userID uint) (*UserPreferences, error) {
+	var row models.UserPreference
+	err := db.Where("user_id = ?", userID).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &UserPreferences{Preferences: DefaultPreferences(), SchemaVersion: PreferencesSchemaVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+
+	return decodePreferences(&row)
+}
+
+func (s *PreferencesService) cache(ctx context.Context, userID uint, prefs *UserPreferences) {
+	if s.redis == nil {
+		return
+	}
+	if data, err := json.Marshal(prefs); err == nil {
+		s.redis.Set(ctx, preferencesCacheKey(userID), data, preferencesCacheTTL)
+	}
+}
+
+func (s *PreferencesService) validate(db *gorm.DB, prefs *Preferences) error {
+	var errs utils.ValidationErrors
+
+	if prefs.Timezone == "" || prefs.Timezone == "Local" {
+		errs = append(errs, utils.ValidationError{Field: "timezone", Message: "must be an IANA time zone", Value: prefs.Timezone})
+	} else if _, err := time.LoadLocation(prefs.Timezone); err != nil {
+		errs = append(errs, utils.ValidationError{Field: "timezone", Message: "unknown time zone", Value: prefs.Timezone})
+	}
+
+	var coins int64
+	if err := db.Model(&models.Coin{}).Where("symbol = ? AND is_active = ?", prefs.QuoteCurrency, true).Count(&coins).Error; err != nil {
+		return fmt.Errorf("failed to check quote currency: %w", err)
+	}
+	if coins == 0 {
+		errs = append(errs, utils.ValidationError{Field: "quote_currency", Message: "must be an active coin symbol", Value: prefs.QuoteCurrency})
+	}
+
+	events := make([]string, 0, len(prefs.Notifications))
+	for event := range prefs.Notifications {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		field := "notifications." + event
+		if !containsString(NotificationEvents, event) {
+			errs = append(errs, utils.ValidationError{Field: field, Message: "unknown event type"})
+			continue
+		}
+		for _, channel := range prefs.Notifications[event] {
+			if !containsString(NotificationChannels, channel) {
+				errs = append(errs, utils.ValidationError{Field: field, Message: "unknown channel", Value: channel})
+			}
+		}
+	}
+
+	if !containsString(StrategyPresets, prefs.DefaultStrategyPreset) {
+		errs = append(errs, utils.ValidationError{
+			Field:   "default_strategy_preset",
+			Message: "must be one of " + strings.Join(StrategyPresets, ", "),
+			Value:   prefs.DefaultStrategyPreset,
+		})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// applyPreferencesUpdate returns a copy of prefs with the request applied
+func applyPreferencesUpdate(prefs Preferences, req *UpdatePreferencesRequest) Preferences {
+	notifications := make(map[string][]string, len(prefs.Notifications))
+	for event, channels := range prefs.Notifications {
+		notifications[event] = channels
+	}
+	prefs.Notifications = notifications
+
+	if req.Timezone != nil {
+		prefs.Timezone = strings.TrimSpace(*req.Timezone)
+	}
+	if req.QuoteCurrency != nil {
+		prefs.QuoteCurrency = strings.ToUpper(strings.TrimSpace(*req.QuoteCurrency))
+	}
+	if req.DefaultStrategyPreset != nil {
+		prefs.DefaultStrategyPreset = strings.TrimSpace(*req.DefaultStrategyPreset)
+	}
+	for event, channels := range req.Notifications {
+		// Duplicates are dropped, the order of first appearance is kept
+		unique := make([]string, 0, len(channels))
+		for _, channel := range channels {
+			if !containsString(unique, channel) {
+				unique = append(unique, channel)
+			}
+		}
+		prefs.Notifications[event] = unique
+	}
+
+	return prefs
+}
+
+// decodePreferences reads a stored document on top of the defaults
+func decodePreferences(row *models.UserPreference) (*UserPreferences, error) {
+	if row.SchemaVersion > PreferencesSchemaVersion {
+		return nil, ErrPreferencesVersion
+	}
+
+	prefs := DefaultPreferences()
+	if err := json.Unmarshal(row.Data, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to decode preferences: %w", err)
+	}
+
+	updatedAt := row.UpdatedAt
+	return &UserPreferences{
+		Preferences:   prefs,
+		SchemaVersion: PreferencesSchemaVersion,
+		Revision:      row.Revision,
+		UpdatedAt:     &updatedAt,
+	}, nil
+}
+
+func preferencesCacheKey(userID uint) string {
+	return PreferencesCachePrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
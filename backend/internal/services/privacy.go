@@ -0,0 +1,344 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// personalDataFormatVersion is bumped when the export layout changes
+const personalDataFormatVersion = 1
+
+// erasedEmailDomain is a reserved domain (RFC 2606) so pseudonymous addresses can never receive mail
+const erasedEmailDomain = "erased.invalid"
+
+var (
+	ErrUserErased = errors.New("user data has already been erased")
+)
+
+// PersonalDataProvider exports and erases personal data owned by another part
+// of the system, e.g. API keys or trading history. Providers are called inside
+// the erasure transaction.
+type PersonalDataProvider interface {
+	// Name is used as the file name in the export archive
+	Name() string
+	Export(ctx context.Context, db *gorm.DB, userID uint) (interface{}, error)
+	// Erase removes the user's data, or pseudonymizes records that must be kept
+	Erase(ctx context.Context, tx *gorm.DB, userID uint) error
+}
+
+// PrivacyService implements personal data export and account erasure
+type PrivacyService struct {
+	db        *gorm.DB
+	cfg       *config.Config
+	audit     *AuditService
+	providers []PersonalDataProvider
+}
+
+// ErasureResult summarizes an account erasure
+type ErasureResult struct {
+	UserID      uint      `json:"user_id"`
+	Pseudonym   string    `json:"pseudonym"`
+	ErasedAt    time.Time `json:"erased_at"`
+	Roles       int64     `json:"roles_removed"`
+	Permissions int64     `json:"permissions_removed"`
+	Tokens      int64     `json:"tokens_removed"`
+	Providers   []string  `json:"providers"`
+}
+
+type personalDataManifest struct {
+	FormatVersion int       `json:"format_version"`
+	UserID        uint      `json:"user_id"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Files         []string  `json:"files"`
+}
+
+type personalDataProfile struct {
+	ID            uint       `json:"id"`
+	Email         string     `json:"email"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	IsActive      bool       `json:"is_active"`
+	EmailVerified bool       `json:"email_verified"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type personalDataRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func NewPrivacyService(db *gorm.DB, cfg *config.Config, providers ...PersonalDataProvider) *PrivacyService {
+	return &PrivacyService{
+		db:        db,
+		cfg:       cfg,
+		audit:     NewAuditService(db, cfg),
+		providers: providers,
+	}
+}
+
+// RegisterProvider adds a provider for data stored outside the user tables
+func (s *PrivacyService) RegisterProvider(provider PersonalDataProvider) {
+	s.providers = append(s.providers, provider)
+}
+
+// ExportUserData builds a zip archive with one JSON file per data category
+func (s *PrivacyService) ExportUserData(ctx context.Context, userID uint) ([]byte, error) {
+	db := s.db.WithContext(ctx)
+
+	var user models.User
+	err := db.Preload("Roles.Permissions").Preload("Permissions.Permission").
+		Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	roles := make([]personalDataRole, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			permissions = append(permissions, permission.String())
+		}
+		roles = append(roles, personalDataRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: permissions,
+		})
+	}
+
+	permissions := make([]PermissionGrant, 0, len(user.Permissions))
+	for _, userPerm := range user.Permissions {
+		permissions = append(permissions, PermissionGrant{
+			Permission: userPerm.Permission.String(),
+			Allow:      userPerm.Allow,
+		})
+	}
+
+	// Entries performed by the user and entries about the user's account
+	var auditLogs []models.AuditLog
+	err = db.Where("user_id = ? OR (resource = ? AND resource_id = ?)", userID, "users", strconv.FormatUint(uint64(userID), 10)).
+		Order("id ASC").Find(&auditLogs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit logs: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", personalDataProfile{
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			IsActive:      user.IsActive != nil && *user.IsActive,
+			EmailVerified: user.EmailVerified,
+			LastLoginAt:   user.LastLoginAt,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		}},
+		{"roles.json", roles},
+		{"permissions.json", permissions},
+		{"audit_logs.json", auditLogs},
+	}
+
+	for _, provider := range s.providers {
+		data, err := provider.Export(ctx, db, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", provider.Name(), err)
+		}
+		files = append(files, struct {
+			name string
+			data interface{}
+		}{provider.Name() + ".json", data})
+	}
+
+	manifest := personalDataManifest{
+		FormatVersion: personalDataFormatVersion,
+		UserID:        user.ID,
+		GeneratedAt:   time.Now().UTC(),
+	}
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, file.name)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if err := writeZipJSON(archive, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := writeZipJSON(archive, file.name, file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize export archive: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EraseAccount erases the caller's own account after confirming the password
+func (s *PrivacyService) EraseAccount(ctx context.Context, userID uint, password string) (*ErasureResult, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	return s.EraseUser(ctx, userID, &userID)
+}
+
+// EraseUser anonymizes the account and removes data that is not legally required.
+// The user row stays as a pseudonym so financial records keep a valid owner.
+// Audit entries are not rewritten, as that would break the tamper-evident
+// chain; they expire through the retention policy.
+func (s *PrivacyService) EraseUser(ctx context.Context, userID uint, erasedBy *uint) (*ErasureResult, error) {
+	result := &ErasureResult{UserID: userID}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Soft-deleted accounts can be erased as well
+		var user models.User
+		if err := tx.Unscoped().Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if user.ErasedAt != nil {
+			return ErrUserErased
+		}
+
+		pseudonym, err := erasedPseudonym(userID)
+		if err != nil {
+			return err
+		}
+
+		// An unknown random secret makes the hash unusable for login
+		unusable, err := generateInviteToken()
+		if err != nil {
+			return err
+		}
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(unusable), bcrypt.MinCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		now := time.Now()
+		err = tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":          pseudonym + "@" + erasedEmailDomain,
+			"first_name":     "",
+			"last_name":      "",
+			"password_hash":  string(passwordHash),
+			"is_active":      false,
+			"email_verified": false,
+			"last_login_at":  nil,
+			"login_attempts": 0,
+			"locked_until":   nil,
+			"erased_at":      now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+
+		roles := tx.Where("user_id = ?", userID).Delete(&models.UserRole{})
+		if roles.Error != nil {
+			return fmt.Errorf("failed to remove roles: %w", roles.Error)
+		}
+		permissions := tx.Where("user_id = ?", userID).Delete(&models.UserPermission{})
+		if permissions.Error != nil {
+			return fmt.Errorf("failed to remove permissions: %w", permissions.Error)
+		}
+		tokens := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PasswordResetToken{})
+		if tokens.Error != nil {
+			return fmt.Errorf("failed to remove password reset tokens: %w", tokens.Error)
+		}
+
+		for _, provider := range s.providers {
+			if err := provider.Erase(ctx, tx, userID); err != nil {
+				return fmt.Errorf("failed to erase %s: %w", provider.Name(), err)
+			}
+			result.Providers = append(result.Providers, provider.Name())
+		}
+
+		if !user.DeletedAt.Valid {
+			if err := tx.Delete(&models.User{}, userID).Error; err != nil {
+				return fmt.Errorf("failed to delete user: %w", err)
+			}
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     erasedBy,
+			Action:     "erase",
+			Resource:   "users",
+			ResourceID: strconv.FormatUint(uint64(userID), 10),
+			NewValues: map[string]interface{}{
+				"pseudonym": pseudonym,
+				"providers": result.Providers,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Pseudonym = pseudonym
+		result.ErasedAt = now
+		result.Roles = roles.RowsAffected
+		result.Permissions = permissions.RowsAffected
+		result.Tokens = tokens.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func erasedPseudonym(userID uint) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate pseudonym: %w", err)
+	}
+	return fmt.Sprintf("erased-%d-%s", userID, hex.EncodeToString(suffix)), nil
+}
+
+func writeZipJSON(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export archive: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s to export archive: %w", name, err)
+	}
+	return nil
+}
@@ -4,23 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"trader/internal/config"
 	"trader/internal/models"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrEmailExists     = errors.New("user with this email already exists")
 	ErrInvalidPassword = errors.New("invalid current password")
 	ErrWeakPassword    = errors.New("password does not meet requirements")
+	ErrInvalidSort     = errors.New("invalid sort field or order")
+	ErrInvalidCursor   = errors.New("invalid pagination cursor")
 )
 
+// Sort fields accepted by ListUsers
+const (
+	UserSortCreatedAt   = "created_at"
+	UserSortEmail       = "email"
+	UserSortFirstName   = "first_name"
+	UserSortLastName    = "last_name"
+	UserSortLastLoginAt = "last_login_at"
+)
+
+// DefaultUserListLimit is the page size used when none is requested
+const DefaultUserListLimit = 10
+
 type UserService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db    *gorm.DB
+	cfg   *config.Config
+	audit *AuditService
 }
 
 type CreateUserRequest struct {
@@ -49,15 +67,33 @@ type UserListResponse struct {
 }
 
 type Meta struct {
-	Total  int `json:"total"`
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListUsersFilter narrows and orders the user listing. Nil fields are not applied.
+type ListUsersFilter struct {
+	// Search matches every whitespace separated term against email, first and last name
+	Search        string
+	Role          string
+	Active        *bool
+	Locked        *bool
+	EmailVerified *bool
+	LastLoginFrom *time.Time
+	LastLoginTo   *time.Time
+	SortBy        string
+	SortOrder     string
+	Limit         int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
 }
 
 func NewUserService(db *gorm.DB, cfg *config.Config) *UserService {
 	return &UserService{
-		db:  db,
-		cfg: cfg,
+		db:    db,
+		cfg:   cfg,
+		audit: NewAuditService(db, cfg),
 	}
 }
 
@@ -177,36 +213,114 @@ func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*UserIn
 	}, nil
 }
 
-// ListUsers returns paginated list of users
-func (s *UserService) ListUsers(ctx context.Context, limit, offset int, role string, active *bool) (*UserListResponse, error) {
-	query := s.db.Model(&models.User{}).
-		Preload("Roles").
-		Order("created_at DESC")
+// ListUsers returns a page of users matching the filter.
+// Pages are addressed with an opaque cursor so results stay stable while users are added or removed.
+func (s *UserService) ListUsers(ctx context.Context, filter *ListUsersFilter) (*UserListResponse, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = UserSortCreatedAt
+	}
+	sort, ok := userSortColumns[sortBy]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	sortOrder := strings.ToLower(filter.SortOrder)
+	if sortOrder == "" {
+		sortOrder = SortDesc
+	}
+	if sortOrder != SortAsc && sortOrder != SortDesc {
+		return nil, ErrInvalidSort
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultUserListLimit
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.User{})
 
 	// Apply filters
-	if role != "" {
+	if filter.Role != "" {
 		query = query.Joins("JOIN user_roles ON users.id = user_roles.user_id").
 			Joins("JOIN roles ON user_roles.role_id = roles.id").
-			Where("roles.name = ? AND roles.is_active = ?", role, true)
+			Where("roles.name = ? AND roles.is_active = ?", filter.Role, true)
 	}
 
-	if active != nil {
-		query = query.Where("is_active = ?", *active)
+	if filter.Active != nil {
+		query = query.Where("users.is_active = ?", *filter.Active)
 	}
 
-	// Get total count
+	if filter.EmailVerified != nil {
+		query = query.Where("users.email_verified = ?", *filter.EmailVerified)
+	}
+
+	if filter.Locked != nil {
+		now := time.Now()
+		if *filter.Locked {
+			query = query.Where("users.locked_until > ?", now)
+		} else {
+			query = query.Where("users.locked_until IS NULL OR users.locked_until <= ?", now)
+		}
+	}
+
+	if filter.LastLoginFrom != nil {
+		query = query.Where("users.last_login_at >= ?", *filter.LastLoginFrom)
+	}
+	if filter.LastLoginTo != nil {
+		query = query.Where("users.last_login_at <= ?", *filter.LastLoginTo)
+	}
+
+	// Every search term has to match the email or one of the names
+	for _, term := range strings.Fields(filter.Search) {
+		pattern := "%" + escapeLike(term) + "%"
+		query = query.Where(
+			"users.email LIKE ? ESCAPE '!' OR users.first_name LIKE ? ESCAPE '!' OR users.last_name LIKE ? ESCAPE '!'",
+			pattern, pattern, pattern,
+		)
+	}
+
+	// Get total count before the cursor narrows the result
 	var total int64
-	if err := query.Count(&total).Error; err != nil {
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		return nil, fmt.Errorf("failed to count users: %w", err)
 	}
 
-	// Get users with pagination
+	// Continue after the last row of the previous page
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor, sortBy, sortOrder)
+		if err != nil {
+			return nil, err
+		}
+		query, err = sort.after(query, cursor, sortOrder == SortDesc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Fetch one extra row to learn whether another page exists
 	var users []models.User
-	err := query.Limit(limit).Offset(offset).Find(&users).Error
+	err := sort.order(query, sortOrder == SortDesc).
+		Select("users.*").
+		Preload("Roles").
+		Limit(limit + 1).
+		Find(&users).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch users: %w", err)
 	}
 
+	var nextCursor string
+	if len(users) > limit {
+		users = users[:limit]
+		last := &users[len(users)-1]
+		nextCursor = encodeCursor(pageCursor{
+			SortBy:    sortBy,
+			SortOrder: sortOrder,
+			Value:     sort.cursorValue(last),
+			ID:        last.ID,
+		})
+	}
+
 	// Convert to UserInfo
 	userInfos := make([]UserInfo, 0, len(users))
 	for _, user := range users {
@@ -225,9 +339,9 @@ func (s *UserService) ListUsers(ctx context.Context, limit, offset int, role str
 	return &UserListResponse{
 		Users: userInfos,
 		Meta: &Meta{
-			Total:  int(total),
-			Limit:  limit,
-			Offset: offset,
+			Total:      int(total),
+			Limit:      limit,
+			NextCursor: nextCursor,
 		},
 	}, nil
 }
@@ -321,6 +435,99 @@ func (s *UserService) DeleteUser(ctx context.Context, userID uint) error {
 	return nil
 }
 
+// userSortColumn describes how users are ordered and compared for one sort field
+type userSortColumn struct {
+	// expr is the SQL expression sorted on; vars are its placeholders
+	expr   string
+	vars   []interface{}
+	isTime bool
+	value  func(user *models.User) string
+	time   func(user *models.User) time.Time
+}
+
+// neverLoggedIn sorts users without a login before everyone else
+var neverLoggedIn = time.Unix(0, 0).UTC()
+
+var userSortColumns = map[string]userSortColumn{
+	UserSortCreatedAt: {
+		expr:   "users.created_at",
+		isTime: true,
+		time:   func(user *models.User) time.Time { return user.CreatedAt },
+	},
+	UserSortEmail: {
+		expr:  "users.email",
+		value: func(user *models.User) string { return user.Email },
+	},
+	UserSortFirstName: {
+		expr:  "users.first_name",
+		value: func(user *models.User) string { return user.FirstName },
+	},
+	UserSortLastName: {
+		expr:  "users.last_name",
+		value: func(user *models.User) string { return user.LastName },
+	},
+	UserSortLastLoginAt: {
+		// NULL never compares equal, so missing logins are mapped to a fixed instant
+		expr:   "COALESCE(users.last_login_at, ?)",
+		vars:   []interface{}{neverLoggedIn},
+		isTime: true,
+		time: func(user *models.User) time.Time {
+			if user.LastLoginAt == nil {
+				return neverLoggedIn
+			}
+			return *user.LastLoginAt
+		},
+	},
+}
+
+// order sorts by the column with the user ID as tie-breaker
+func (c userSortColumn) order(query *gorm.DB, desc bool) *gorm.DB {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	return query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                c.expr + " " + direction + ", users.id " + direction,
+		Vars:               c.vars,
+		WithoutParentheses: true,
+	}})
+}
+
+// after restricts the query to rows that follow the cursor in sort order
+func (c userSortColumn) after(query *gorm.DB, cursor *pageCursor, desc bool) (*gorm.DB, error) {
+	var value interface{} = cursor.Value
+	if c.isTime {
+		parsed, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = parsed
+	}
+
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	args := append([]interface{}{}, c.vars...)
+	args = append(args, value)
+	args = append(args, c.vars...)
+	args = append(args, value, cursor.ID)
+
+	return query.Where(
+		fmt.Sprintf("(%s %s ? OR (%s = ? AND users.id %s ?))", c.expr, op, c.expr, op),
+		args...,
+	), nil
+}
+
+func (c userSortColumn) cursorValue(user *models.User) string {
+	if c.isTime {
+		return c.time(user).UTC().Format(time.RFC3339Nano)
+	}
+	return c.value(user)
+}
+
 func (s *UserService) getUserRoles(user *models.User) []string {
 	roles := make([]string, 0, len(user.Roles))
 	for _, role := range user.Roles {
@@ -0,0 +1,606 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/config"
+	"trader/internal/mail"
+	"trader/internal/models"
+	"trader/internal/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Import file formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+// Credential modes for imported users
+const (
+	// ImportPasswordRandom generates a password that is returned in the report
+	ImportPasswordRandom = "random"
+	// ImportPasswordInvite emails a link to choose a password
+	ImportPasswordInvite = "invite"
+)
+
+// Import row statuses
+const (
+	ImportStatusCreated = "created"
+	ImportStatusValid   = "valid"
+	ImportStatusInvalid = "invalid"
+	ImportStatusSkipped = "skipped"
+)
+
+const generatedPasswordLength = 16
+
+var (
+	ErrImportInvalid     = errors.New("import contains invalid rows")
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrInvalidImportFile = errors.New("invalid import file")
+)
+
+// UserBulkService imports and exports users in bulk
+type UserBulkService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	mailer mail.Mailer
+	audit  *AuditService
+}
+
+// ImportUserRow is a single user read from an import file
+type ImportUserRow struct {
+	// Line is the file line (CSV) or record number (JSON) used in the report
+	Line      int      `json:"-"`
+	Email     string   `json:"email"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Role      string   `json:"role,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+type ImportOptions struct {
+	PasswordMode string
+	// DefaultRole is assigned to rows that do not list any role
+	DefaultRole string
+	DryRun      bool
+	ImportedBy  *uint
+}
+
+// ImportRowResult reports the outcome of one import row
+type ImportRowResult struct {
+	Line       int      `json:"line"`
+	Email      string   `json:"email"`
+	Status     string   `json:"status"`
+	UserID     uint     `json:"user_id,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	Password   string   `json:"password,omitempty"`
+	InviteSent bool     `json:"invite_sent,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	Rows    []ImportRowResult `json:"rows"`
+	Created int               `json:"created"`
+	Invalid int               `json:"invalid"`
+	DryRun  bool              `json:"dry_run"`
+}
+
+// UserExport is a user with roles and direct permissions as written by ExportUsers
+type UserExport struct {
+	ID            uint              `json:"id"`
+	Email         string            `json:"email"`
+	FirstName     string            `json:"first_name"`
+	LastName      string            `json:"last_name"`
+	IsActive      bool              `json:"is_active"`
+	EmailVerified bool              `json:"email_verified"`
+	LastLoginAt   *time.Time        `json:"last_login_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Roles         []string          `json:"roles"`
+	Permissions   []PermissionGrant `json:"permissions"`
+}
+
+// PermissionGrant is a direct user permission in "resource:action" form
+type PermissionGrant struct {
+	Permission string `json:"permission"`
+	Allow      bool   `json:"allow"`
+}
+
+func NewUserBulkService(db *gorm.DB, cfg *config.Config, mailer mail.Mailer) *UserBulkService {
+	return &UserBulkService{
+		db:     db,
+		cfg:    cfg,
+		mailer: mailer,
+		audit:  NewAuditService(db, cfg),
+	}
+}
+
+// ParseImportRows reads users from CSV (with a header row) or a JSON array
+func ParseImportRows(r io.Reader, format string) ([]ImportUserRow, error) {
+	switch format {
+	case ImportFormatCSV:
+		return parseImportCSV(r)
+	case ImportFormatJSON:
+		return parseImportJSON(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func parseImportCSV(r io.Reader) ([]ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidImportFile, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%w: CSV header must contain an email column", ErrInvalidImportFile)
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []ImportUserRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportUserRow{
+			Line:      line,
+			Email:     field(record, "email"),
+			FirstName: field(record, "first_name"),
+			LastName:  field(record, "last_name"),
+			Role:      field(record, "role"),
+		}
+		// Several roles are separated by semicolons, as written by the export
+		if roles := field(record, "roles"); roles != "" {
+			row.Roles = strings.Split(roles, ";")
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseImportJSON(r io.Reader) ([]ImportUserRow, error) {
+	var rows []ImportUserRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+// ImportUsers validates every row and creates all users in a single transaction.
+// Nothing is written unless all rows are valid; in that case ErrImportInvalid is
+// returned together with the report.
+func (s *UserBulkService) ImportUsers(ctx context.Context, rows []ImportUserRow, opts *ImportOptions) (*ImportReport, error) {
+	if opts.PasswordMode != ImportPasswordRandom && opts.PasswordMode != ImportPasswordInvite {
+		return nil, fmt.Errorf("unknown password mode: %s", opts.PasswordMode)
+	}
+	if opts.PasswordMode == ImportPasswordInvite && s.mailer == nil {
+		return nil, errors.New("invite mode requires a mailer")
+	}
+
+	report := &ImportReport{
+		Rows:   make([]ImportRowResult, len(rows)),
+		DryRun: opts.DryRun,
+	}
+
+	roles, err := s.validateRows(ctx, rows, opts, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Invalid > 0 {
+		for i := range report.Rows {
+			if report.Rows[i].Status != ImportStatusInvalid {
+				report.Rows[i].Status = ImportStatusSkipped
+			}
+		}
+		return report, ErrImportInvalid
+	}
+
+	if opts.DryRun {
+		for i := range report.Rows {
+			report.Rows[i].Status = ImportStatusValid
+		}
+		return report, nil
+	}
+
+	// Hash outside the transaction; bcrypt is deliberately slow
+	passwords := make([]string, len(rows))
+	hashes := make([]string, len(rows))
+	for i := range rows {
+		password, err := generatePassword(generatedPasswordLength)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cfg.Security.BcryptCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwords[i] = password
+		hashes[i] = string(hash)
+	}
+
+	inviteTokens := make([]string, len(rows))
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range rows {
+			result := &report.Rows[i]
+
+			isActive := true
+			user := models.User{
+				Email:        result.Email,
+				FirstName:    strings.TrimSpace(rows[i].FirstName),
+				LastName:     strings.TrimSpace(rows[i].LastName),
+				PasswordHash: hashes[i],
+				IsActive:     &isActive,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user %s: %w", result.Email, err)
+			}
+
+			for _, roleName := range result.Roles {
+				userRole := models.UserRole{
+					UserID:     user.ID,
+					RoleID:     roles[roleName].ID,
+					AssignedBy: opts.ImportedBy,
+					AssignedAt: time.Now(),
+				}
+				if err := tx.Create(&userRole).Error; err != nil {
+					return fmt.Errorf("failed to assign role to %s: %w", result.Email, err)
+				}
+			}
+
+			if opts.PasswordMode == ImportPasswordInvite {
+				token, err := generateInviteToken()
+				if err != nil {
+					return err
+				}
+				invite := models.PasswordResetToken{
+					UserID:    user.ID,
+					Token:     token,
+					ExpiresAt: time.Now().Add(s.cfg.Security.InviteExpiry),
+				}
+				if err := tx.Create(&invite).Error; err != nil {
+					return fmt.Errorf("failed to create invite for %s: %w", result.Email, err)
+				}
+				inviteTokens[i] = token
+			}
+
+			_, err := s.audit.Record(tx, &AuditEntry{
+				UserID:     opts.ImportedBy,
+				Action:     "import",
+				Resource:   "users",
+				ResourceID: strconv.FormatUint(uint64(user.ID), 10),
+				// No email: the audit chain cannot be rewritten when the user is erased
+				NewValues: map[string]interface{}{
+					"roles":         result.Roles,
+					"password_mode": opts.PasswordMode,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			result.UserID = user.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.Rows {
+		result := &report.Rows[i]
+		result.Status = ImportStatusCreated
+		report.Created++
+
+		if opts.PasswordMode == ImportPasswordRandom {
+			result.Password = passwords[i]
+			continue
+		}
+
+		// Users are already committed, so a failed email is reported rather than rolled back
+		if err := s.sendInvite(ctx, result.Email, rows[i].FirstName, inviteTokens[i]); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.InviteSent = true
+	}
+
+	return report, nil
+}
+
+// validateRows normalizes rows into the report and returns the referenced roles by name
+func (s *UserBulkService) validateRows(ctx context.Context, rows []ImportUserRow, opts *ImportOptions, report *ImportReport) (map[string]models.Role, error) {
+	emails := make([]string, 0, len(rows))
+	roleNames := make([]string, 0)
+	seenEmails := make(map[string]int, len(rows))
+
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Line = row.Line
+		result.Email = strings.ToLower(strings.TrimSpace(row.Email))
+		result.Roles = normalizeRoles(row, opts.DefaultRole)
+
+		switch {
+		case result.Email == "":
+			result.Errors = append(result.Errors, "email is required")
+		case !utils.IsValidEmail(result.Email):
+			result.Errors = append(result.Errors, "email is not valid")
+		case seenEmails[result.Email] != 0:
+			result.Errors = append(result.Errors, fmt.Sprintf("duplicate of line %d", seenEmails[result.Email]))
+		default:
+			seenEmails[result.Email] = row.Line
+			emails = append(emails, result.Email)
+		}
+
+		if strings.TrimSpace(row.FirstName) == "" {
+			result.Errors = append(result.Errors, "first name is required")
+		}
+		if strings.TrimSpace(row.LastName) == "" {
+			result.Errors = append(result.Errors, "last name is required")
+		}
+		if len(row.FirstName) > 100 || len(row.LastName) > 100 {
+			result.Errors = append(result.Errors, "names must be at most 100 characters")
+		}
+		if len(result.Roles) == 0 {
+			result.Errors = append(result.Errors, "at least one role is required")
+		}
+		roleNames = append(roleNames, result.Roles...)
+	}
+
+	// Emails of soft-deleted users are free to be claimed again
+	var existing []models.User
+	if len(emails) > 0 {
+		if err := s.db.WithContext(ctx).Where("email IN ?", emails).Find(&existing).Error; err != nil {
+			return nil, fmt.Errorf("failed to check existing users: %w", err)
+		}
+	}
+	existingEmails := make(map[string]bool, len(existing))
+	for _, user := range existing {
+		existingEmails[strings.ToLower(user.Email)] = true
+	}
+
+	var activeRoles []models.Role
+	if len(roleNames) > 0 {
+		if err := s.db.WithContext(ctx).Where("name IN ? AND is_active = ?", roleNames, true).Find(&activeRoles).Error; err != nil {
+			return nil, fmt.Errorf("failed to load roles: %w", err)
+		}
+	}
+	roles := make(map[string]models.Role, len(activeRoles))
+	for _, role := range activeRoles {
+		roles[role.Name] = role
+	}
+
+	for i := range report.Rows {
+		result := &report.Rows[i]
+		if existingEmails[result.Email] {
+			result.Errors = append(result.Errors, "user with this email already exists")
+		}
+		for _, roleName := range result.Roles {
+			if _, ok := roles[roleName]; !ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("role '%s' not found or inactive", roleName))
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			result.Status = ImportStatusInvalid
+			report.Invalid++
+		}
+	}
+
+	return roles, nil
+}
+
+func (s *UserBulkService) sendInvite(ctx context.Context, email, firstName, token string) error {
+	link := strings.TrimRight(s.cfg.Mail.AppURL, "/") + "/reset-password?token=" + token
+
+	body := fmt.Sprintf(
+		"Hello %s,\n\nAn account has been created for you on the trading bot.\n"+
+			"Choose your password using the link below. The link is valid for %d hours.\n\n%s\n",
+		strings.TrimSpace(firstName),
+		int(s.cfg.Security.InviteExpiry.Hours()),
+		link,
+	)
+
+	err := s.mailer.Send(ctx, &mail.Message{
+		To:      email,
+		Subject: "You have been invited to the trading bot",
+		Body:    body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send invite: %w", err)
+	}
+	return nil
+}
+
+// ExportUsers returns all users with their active roles and direct permissions
+func (s *UserBulkService) ExportUsers(ctx context.Context) ([]UserExport, error) {
+	var users []models.User
+	err := s.db.WithContext(ctx).
+		Preload("Roles").
+		Preload("Permissions.Permission").
+		Order("id ASC").
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	exports := make([]UserExport, 0, len(users))
+	for _, user := range users {
+		export := UserExport{
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			IsActive:      user.IsActive != nil && *user.IsActive,
+			EmailVerified: user.EmailVerified,
+			LastLoginAt:   user.LastLoginAt,
+			CreatedAt:     user.CreatedAt,
+			Roles:         make([]string, 0, len(user.Roles)),
+			Permissions:   make([]PermissionGrant, 0, len(user.Permissions)),
+		}
+		for _, role := range user.Roles {
+			if role.IsActive {
+				export.Roles = append(export.Roles, role.Name)
+			}
+		}
+		for _, userPerm := range user.Permissions {
+			export.Permissions = append(export.Permissions, PermissionGrant{
+				Permission: userPerm.Permission.String(),
+				Allow:      userPerm.Allow,
+			})
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, nil
+}
+
+// WriteUsersCSV writes exported users as CSV. Roles are separated by semicolons so
+// the file can be imported again; denied permissions are prefixed with "!".
+func WriteUsersCSV(w io.Writer, users []UserExport) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"id", "email", "first_name", "last_name", "roles", "permissions", "is_active", "email_verified", "last_login_at", "created_at"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	for _, user := range users {
+		permissions := make([]string, 0, len(user.Permissions))
+		for _, grant := range user.Permissions {
+			if grant.Allow {
+				permissions = append(permissions, grant.Permission)
+			} else {
+				permissions = append(permissions, "!"+grant.Permission)
+			}
+		}
+
+		lastLogin := ""
+		if user.LastLoginAt != nil {
+			lastLogin = user.LastLoginAt.UTC().Format(time.RFC3339)
+		}
+
+		record := []string{
+			strconv.FormatUint(uint64(user.ID), 10),
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			strings.Join(user.Roles, ";"),
+			strings.Join(permissions, ";"),
+			strconv.FormatBool(user.IsActive),
+			strconv.FormatBool(user.EmailVerified),
+			lastLogin,
+			user.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func normalizeRoles(row ImportUserRow, defaultRole string) []string {
+	names := append([]string{row.Role}, row.Roles...)
+
+	seen := make(map[string]bool, len(names))
+	roles := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		roles = append(roles, name)
+	}
+
+	if len(roles) == 0 && defaultRole != "" {
+		roles = append(roles, defaultRole)
+	}
+	return roles
+}
+
+// generatePassword returns a random password with upper and lower case letters, digits and symbols
+func generatePassword(length int) (string, error) {
+	classes := []string{
+		"ABCDEFGHJKLMNPQRSTUVWXYZ",
+		"abcdefghijkmnopqrstuvwxyz",
+		"23456789",
+		"!@#$%^&*-_=+",
+	}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, length)
+	for i := range password {
+		// The first characters guarantee one of each class
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = charset[n.Int64()]
+	}
+
+	// Shuffle so the guaranteed classes are not always in front
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+func generateInviteToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"trader/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotDeleted   = errors.New("user is not deleted")
+	ErrPurgeGracePeriod = errors.New("user is still within the restore grace period")
+)
+
+// deletedUserSort orders deleted users by deletion time; it is the only order offered
+const deletedUserSort = "deleted_at"
+
+var deletedAtColumn = userSortColumn{
+	expr:   "users.deleted_at",
+	isTime: true,
+	time:   func(user *models.User) time.Time { return user.DeletedAt.Time },
+}
+
+// DeletedUserInfo describes a soft-deleted user
+type DeletedUserInfo struct {
+	UserInfo
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+	Erased     bool      `json:"erased"`
+}
+
+type DeletedUserListResponse struct {
+	Users []DeletedUserInfo `json:"users"`
+	Meta  *Meta             `json:"meta"`
+}
+
+// ListDeletedUsersFilter narrows the deleted user listing, most recently deleted first
+type ListDeletedUsersFilter struct {
+	Search string
+	// Purgeable only returns users that PurgeExpiredUsers would remove
+	Purgeable bool
+	Limit     int
+	Cursor    string
+}
+
+// ListDeletedUsers returns a page of soft-deleted users
+func (s *UserService) ListDeletedUsers(ctx context.Context, filter *ListDeletedUsersFilter) (*DeletedUserListResponse, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultUserListLimit
+	}
+
+	query := s.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("users.deleted_at IS NOT NULL")
+
+	if filter.Purgeable {
+		query = query.Where("users.deleted_at <= ? AND users.erased_at IS NULL", time.Now().Add(-s.cfg.Security.DeletedUserGracePeriod))
+	}
+
+	for _, term := range strings.Fields(filter.Search) {
+		pattern := "%" + escapeLike(term) + "%"
+		query = query.Where(
+			"users.email LIKE ? ESCAPE '!' OR users.first_name LIKE ? ESCAPE '!' OR users.last_name LIKE ? ESCAPE '!'",
+			pattern, pattern, pattern,
+		)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count deleted users: %w", err)
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor, deletedUserSort, SortDesc)
+		if err != nil {
+			return nil, err
+		}
+		query, err = deletedAtColumn.after(query, cursor, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var users []models.User
+	err := deletedAtColumn.order(query, true).
+		Preload("Roles").
+		Limit(limit + 1).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deleted users: %w", err)
+	}
+
+	var nextCursor string
+	if len(users) > limit {
+		users = users[:limit]
+		last := &users[len(users)-1]
+		nextCursor = encodeCursor(pageCursor{
+			SortBy:    deletedUserSort,
+			SortOrder: SortDesc,
+			Value:     deletedAtColumn.cursorValue(last),
+			ID:        last.ID,
+		})
+	}
+
+	infos := make([]DeletedUserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, s.deletedUserInfo(&user))
+	}
+
+	return &DeletedUserListResponse{
+		Users: infos,
+		Meta: &Meta{
+			Total:      int(total),
+			Limit:      limit,
+			NextCursor: nextCursor,
+		},
+	}, nil
+}
+
+// RestoreUser undeletes a user. Role assignments are kept on soft delete, so they come back with the user.
+// Restoring fails with ErrEmailExists if the email has been registered again in the meantime.
+func (s *UserService) RestoreUser(ctx context.Context, userID uint, restoredBy *uint) (*UserInfo, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := findDeletedUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return ErrUserErased
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&count).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if count > 0 {
+			return ErrEmailExists
+		}
+
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+
+		_, err = s.audit.Record(tx, &AuditEntry{
+			UserID:     restoredBy,
+			Action:     "restore",
+			Resource:   "users",
+			ResourceID: strconv.FormatUint(uint64(userID), 10),
+			OldValues:  map[string]interface{}{"deleted_at": user.DeletedAt.Time.UTC()},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// PurgeUser permanently removes a soft-deleted user with roles, permissions and reset tokens.
+// Unless force is set the grace period must have passed. Audit entries are kept.
+// Erased users are never purged: they remain as the pseudonymous owner of financial records.
+func (s *UserService) PurgeUser(ctx context.Context, userID uint, force bool, purgedBy *uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := findDeletedUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return ErrUserErased
+		}
+		if !force && time.Now().Before(s.purgeAfter(user)) {
+			return ErrPurgeGracePeriod
+		}
+
+		return s.purge(tx, user, purgedBy)
+	})
+}
+
+// PurgeExpiredUsers purges every deleted user whose grace period has passed and returns their IDs
+func (s *UserService) PurgeExpiredUsers(ctx context.Context, purgedBy *uint) ([]uint, error) {
+	cutoff := time.Now().Add(-s.cfg.Security.DeletedUserGracePeriod)
+
+	var users []models.User
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at <= ? AND erased_at IS NULL", cutoff).
+		Order("id ASC").Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch expired users: %w", err)
+	}
+
+	purged := make([]uint, 0, len(users))
+	for i := range users {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return s.purge(tx, &users[i], purgedBy)
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge user %d: %w", users[i].ID, err)
+		}
+		purged = append(purged, users[i].ID)
+	}
+
+	return purged, nil
+}
+
+func (s *UserService) purge(tx *gorm.DB, user *models.User, purgedBy *uint) error {
+	// Assignments made by the purged user stay, without the assigner
+	if err := tx.Model(&models.UserRole{}).Where("assigned_by = ?", user.ID).Update("assigned_by", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach role assignments: %w", err)
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+		return fmt.Errorf("failed to remove roles: %w", err)
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserPermission{}).Error; err != nil {
+		return fmt.Errorf("failed to remove permissions: %w", err)
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		return fmt.Errorf("failed to remove password reset tokens: %w", err)
+	}
+	if err := leaveOrganizations(tx, user.ID); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.OrganizationMember{}).Where("invited_by = ?", user.ID).Update("invited_by", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach invitations: %w", err)
+	}
+	if err := tx.Unscoped().Model(&models.Organization{}).Where("created_by = ?", user.ID).Update("created_by", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach organizations: %w", err)
+	}
+	if err := tx.Unscoped().Model(&models.APIKey{}).Where("created_by = ?", user.ID).Update("created_by", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach api keys: %w", err)
+	}
+	if err := tx.Unscoped().Delete(&models.User{}, user.ID).Error; err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+
+	// The email is not recorded so the audit trail holds no personal data of the purged user
+	_, err := s.audit.Record(tx, &AuditEntry{
+		UserID:     purgedBy,
+		Action:     "purge",
+		Resource:   "users",
+		ResourceID: strconv.FormatUint(uint64(user.ID), 10),
+		OldValues:  map[string]interface{}{"deleted_at": user.DeletedAt.Time.UTC()},
+	})
+	return err
+}
+
+func (s *UserService) purgeAfter(user *models.User) time.Time {
+	return user.DeletedAt.Time.Add(s.cfg.Security.DeletedUserGracePeriod)
+}
+
+func (s *UserService) deletedUserInfo(user *models.User) DeletedUserInfo {
+	return DeletedUserInfo{
+		UserInfo: UserInfo{
+			ID:            user.ID,
+			Email:         user.Email,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			IsActive:      user.IsActive,
+			EmailVerified: user.EmailVerified,
+			LastLoginAt:   user.LastLoginAt,
+			Roles:         s.getUserRoles(user),
+		},
+		DeletedAt:  user.DeletedAt.Time,
+		PurgeAfter: s.purgeAfter(user),
+		Erased:     user.ErasedAt != nil,
+	}
+}
+
+// findDeletedUser loads a soft-deleted user; active users yield ErrUserNotDeleted
+func findDeletedUser(tx *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := tx.Unscoped().Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if !user.DeletedAt.Valid {
+		return nil, ErrUserNotDeleted
+	}
+	return &user, nil
+}
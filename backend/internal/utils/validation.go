@@ -31,6 +31,11 @@ func (ve ValidationErrors) Error() string {
 	return strings.Join(messages, ", ")
 }
 
+// IsValidEmail checks the email format used by the "email" validation rule
+func IsValidEmail(email string) bool {
+	return emailRegex.MatchString(email)
+}
+
 // ValidateStruct validates a struct based on validation tags
 func ValidateStruct(s interface{}) error {
 	v := reflect.ValueOf(s)
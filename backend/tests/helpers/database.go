@@ -43,9 +43,21 @@ func SetupTestDB(t testing.TB) *TestDB {
 		&models.UserPermission{},
 		&models.PasswordResetToken{},
 		&models.AuditLog{},
+		&models.AuditChainHead{},
+		&models.AuditCheckpoint{},
+		&models.UserPreference{},
+		&models.Organization{},
+		&models.OrganizationMember{},
 		&models.Exchange{},
+		&models.APIKey{},
 		&models.Coin{},
 		&models.TradingPair{},
+		&models.PaperAccount{},
+		&models.PaperBalance{},
+		&models.PaperOrder{},
+		&models.FeeSchedule{},
+		&models.BalanceSnapshot{},
+		&models.BalanceEvent{},
 	)
 	require.NoError(t, err)
 
@@ -90,8 +102,9 @@ func (tdb *TestDB) LoadFixtures(t testing.TB) {
 // ClearTables removes all data from tables
 func (tdb *TestDB) ClearTables(t testing.TB) {
 	tables := []string{
-		"user_permissions", "user_roles", "password_reset_tokens", "audit_logs",
-		"users", "roles", "permissions", "exchanges", "coins", "trading_pairs",
+		"user_permissions", "user_roles", "password_reset_tokens", "user_preferences", "balance_events", "balance_snapshots", "api_keys", "fee_schedules", "organization_members", "organizations", "audit_logs",
+		"audit_chain_heads", "audit_checkpoints", "users", "roles", "permissions", "exchanges", "coins", "trading_pairs",
+		"paper_orders", "paper_balances", "paper_accounts",
 	}
 
 	for _, table := range tables {
@@ -203,8 +216,29 @@ func GetTestConfig() *config.Config {
 			Issuer:          "trader-test",
 		},
 		Security: config.SecurityConfig{
-			MaxLoginAttempts: 5,
-			LockoutDuration:  30 * time.Minute, // 30 minutes
+			MaxLoginAttempts:       5,
+			LockoutDuration:        30 * time.Minute, // 30 minutes
+			InviteExpiry:           72 * time.Hour,
+			DeletedUserGracePeriod: 30 * 24 * time.Hour,
+		},
+		Mail: config.MailConfig{
+			From:   "noreply@trader.test",
+			AppURL: "http://localhost:3000",
+		},
+		Audit: config.AuditConfig{
+			CheckpointSecret:   "test-audit-checkpoint-secret-for-testing-only",
+			CheckpointInterval: 5,
+		},
+		Encryption: config.EncryptionConfig{
+			Provider:  "env",
+			MasterKey: "test-encryption-key-32-chars-xyz",
+		},
+		Exchange: config.ExchangeConfig{
+			RequestTimeout: 5 * time.Second,
+		},
+		Balances: config.BalanceConfig{
+			ValuationCurrency: "USDT",
+			DropThreshold:     0.05,
 		},
 	}
 }
@@ -0,0 +1,273 @@
+package unit_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/hitbtc"
+	"trader/internal/models"
+	"trader/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHitBTC verifies HS256 signatures and answers like HitBTC for the rights of each key
+type fakeHitBTC struct {
+	mu      sync.Mutex
+	secrets map[string]string
+	rights  map[string]exchange.Capabilities
+	// down answers every request with a 503
+	down bool
+}
+
+func newFakeHitBTC(t *testing.T) (*fakeHitBTC, *httptest.Server) {
+	fake := &fakeHitBTC{secrets: make(map[string]string), rights: make(map[string]exchange.Capabilities)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeHitBTC) addKey(apiKey, secret string, rights exchange.Capabilities) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[apiKey] = secret
+	f.rights[apiKey] = rights
+}
+
+func (f *fakeHitBTC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		writeHitBTCError(w, http.StatusServiceUnavailable, 500, "Service unavailable")
+		return
+	}
+	apiKey, ok := f.authenticate(r)
+	if !ok {
+		writeHitBTCError(w, http.StatusUnauthorized, 1002, "Authorization failed")
+		return
+	}
+	rights := f.rights[apiKey]
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/3/spot/balance":
+		if !rights.Read {
+			writeHitBTCError(w, http.StatusForbidden, 1003, "Action is forbidden for this API key")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"currency":"BTC","available":"0.5","reserved":"0"}]`))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/3/spot/order/"):
+		if !rights.Trade {
+			writeHitBTCError(w, http.StatusForbidden, 1003, "Action is forbidden for this API key")
+			return
+		}
+		writeHitBTCError(w, http.StatusBadRequest, 20002, "Order not found")
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/3/wallet/crypto/withdraw/"):
+		if !rights.Withdraw {
+			writeHitBTCError(w, http.StatusForbidden, 1003, "Action is forbidden for this API key")
+			return
+		}
+		writeHitBTCError(w, http.StatusBadRequest, 20003, "Withdraw not found")
+	default:
+		writeHitBTCError(w, http.StatusNotFound, 404, "Not found")
+	}
+}
+
+// authenticate checks an "HS256 base64(key:signature:timestamp)" header
+func (f *fakeHitBTC) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "HS256 ")
+	if !ok {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(string(decoded), ":")
+	if len(parts) != 3 {
+		return "", false
+	}
+	secret, ok := f.secrets[parts[0]]
+	if !ok {
+		return "", false
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	message := r.Method + r.URL.Path
+	if r.URL.RawQuery != "" {
+		message += "?" + r.URL.RawQuery
+	}
+	message += string(body) + parts[2]
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return parts[0], hmac.Equal([]byte(parts[1]), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+func writeHitBTCError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": code, "message": message}})
+}
+
+func TestHitBTC_Capabilities(t *testing.T) {
+	fake, server := newFakeHitBTC(t)
+	ctx := context.Background()
+	fake.addKey("read-only", "secret-1", exchange.Capabilities{Read: true})
+	fake.addKey("trader", "secret-2", exchange.Capabilities{Read: true, Trade: true})
+
+	client := hitbtc.New(exchange.Config{BaseURL: server.URL, Credentials: &exchange.Credentials{APIKey: "read-only", APISecret: "secret-1"}})
+	capabilities, err := client.Capabilities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, exchange.Capabilities{Read: true}, *capabilities)
+
+	client = hitbtc.New(exchange.Config{BaseURL: server.URL, Credentials: &exchange.Credentials{APIKey: "trader", APISecret: "secret-2"}})
+	capabilities, err = client.Capabilities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{exchange.PermissionRead, exchange.PermissionTrade}, capabilities.Permissions())
+
+	client = hitbtc.New(exchange.Config{BaseURL: server.URL, Credentials: &exchange.Credentials{APIKey: "trader", APISecret: "wrong"}})
+	_, err = client.Capabilities(ctx)
+	assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+	var apiErr *hitbtc.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 1002, apiErr.Code)
+}
+
+func TestAPIKeyService_Test(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	fake, server := newFakeHitBTC(t)
+	ctx := context.Background()
+
+	require.NoError(t, env.testDB.DB.Model(env.exchange).Update("api_url", server.URL).Error)
+	key := env.createKey(t, "Main")
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		result, err := env.service.Test(ctx, env.ownerWS, key.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, services.APIKeyTestAuthFailed, result.Status)
+		assert.Empty(t, result.Permissions)
+		assert.True(t, result.Key.IsActive)
+	})
+
+	t.Run("trading key", func(t *testing.T) {
+		fake.addKey(testAPIKey, testAPISecret, exchange.Capabilities{Read: true, Trade: true})
+
+		result, err := env.service.Test(ctx, env.ownerWS, key.ID)
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, services.APIKeyTestOK, result.Status)
+		assert.Equal(t, []string{"read", "trade"}, result.Permissions)
+
+		var stored models.APIKey
+		require.NoError(t, env.testDB.DB.First(&stored, key.ID).Error)
+		assert.True(t, stored.CanRead)
+		assert.True(t, stored.CanTrade)
+		assert.False(t, stored.CanWithdraw)
+		assert.Equal(t, services.APIKeyTestOK, stored.TestStatus)
+		require.NotNil(t, stored.TestLatencyMS)
+		require.NotNil(t, stored.LastTestedAt)
+		assert.GreaterOrEqual(t, *stored.TestLatencyMS, 0)
+	})
+
+	t.Run("keys with withdrawal rights are refused", func(t *testing.T) {
+		fake.addKey(testAPIKey, testAPISecret, exchange.Capabilities{Read: true, Trade: true, Withdraw: true})
+
+		result, err := env.service.Test(ctx, env.ownerWS, key.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, services.APIKeyTestWithdrawEnabled, result.Status)
+		assert.Contains(t, result.Permissions, "withdraw")
+		assert.False(t, result.Key.IsActive)
+
+		active := true
+		_, err = env.service.Update(ctx, env.ownerWS, key.ID, &services.UpdateAPIKeyRequest{IsActive: &active})
+		assert.ErrorIs(t, err, services.ErrAPIKeyWithdrawEnabled)
+
+		require.NoError(t, env.testDB.DB.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("is_active", true).Error)
+		_, err = env.service.Credentials(ctx, env.ownerWS, key.ID)
+		assert.ErrorIs(t, err, services.ErrAPIKeyWithdrawEnabled)
+
+		// After the right is removed on the exchange, a new test clears the flag
+		fake.addKey(testAPIKey, testAPISecret, exchange.Capabilities{Read: true, Trade: true})
+		result, err = env.service.Test(ctx, env.ownerWS, key.ID)
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		_, err = env.service.Credentials(ctx, env.ownerWS, key.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("transient errors keep the detected permissions", func(t *testing.T) {
+		fake.mu.Lock()
+		fake.down = true
+		fake.mu.Unlock()
+		defer func() {
+			fake.mu.Lock()
+			fake.down = false
+			fake.mu.Unlock()
+		}()
+
+		result, err := env.service.Test(ctx, env.ownerWS, key.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, services.APIKeyTestError, result.Status)
+
+		var stored models.APIKey
+		require.NoError(t, env.testDB.DB.First(&stored, key.ID).Error)
+		assert.Equal(t, services.APIKeyTestError, stored.TestStatus)
+		assert.NotEmpty(t, stored.TestError)
+		assert.True(t, stored.CanRead, "an outage does not take the key out of the pool")
+		assert.True(t, stored.CanTrade)
+		assert.True(t, stored.IsActive)
+	})
+
+	t.Run("replaced credentials are untested", func(t *testing.T) {
+		newKey, newSecret := "hbtcNEWKEY00112233445566", "hbtcNEWSECRET-0011223344"
+		updated, err := env.service.Update(ctx, env.ownerWS, key.ID, &services.UpdateAPIKeyRequest{APIKey: &newKey, APISecret: &newSecret})
+		require.NoError(t, err)
+		assert.Empty(t, updated.TestStatus)
+		assert.Empty(t, updated.Permissions)
+		assert.Nil(t, updated.LastTestedAt)
+	})
+
+	t.Run("viewers cannot test keys", func(t *testing.T) {
+		viewer := env.testDB.CreateTestUser(t, "viewer-test@example.com", "View", "Er")
+		_, err := env.orgService.AddMember(ctx, env.ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: services.OrgRoleViewer})
+		require.NoError(t, err)
+		viewerWS, err := env.orgService.Workspace(ctx, env.organization.ID, viewer.ID)
+		require.NoError(t, err)
+
+		_, err = env.service.Test(ctx, viewerWS, key.ID)
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+	})
+
+	t.Run("unsupported exchange", func(t *testing.T) {
+		other := &models.Exchange{Name: "Unknown", Code: "unknown", IsActive: true}
+		require.NoError(t, env.testDB.DB.Create(other).Error)
+		unsupported, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+			ExchangeID: other.ID, Name: "Other", APIKey: testAPIKey, APISecret: testAPISecret,
+		})
+		require.NoError(t, err)
+
+		_, err = env.service.Test(ctx, env.ownerWS, unsupported.ID)
+		assert.ErrorIs(t, err, exchange.ErrUnsupportedExchange)
+	})
+
+	var actions []string
+	require.NoError(t, env.testDB.DB.Model(&models.AuditLog{}).Where("resource = ? AND action = ?", "api_keys", "test").Pluck("action", &actions).Error)
+	assert.Len(t, actions, 5)
+}
@@ -0,0 +1,303 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/secrets"
+	"trader/internal/services"
+	"trader/internal/utils"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAPIKey    = "hbtcKEY0123456789abcdefWXYZ"
+	testAPISecret = "hbtcSECRET-do-not-leak-9876543210"
+)
+
+type apiKeyTestEnv struct {
+	service      *services.APIKeyService
+	orgService   *services.OrganizationService
+	testDB       *helpers.TestDB
+	exchange     *models.Exchange
+	owner        *models.User
+	ownerWS      *services.Workspace
+	organization *services.OrganizationInfo
+}
+
+func setupAPIKeyTest(t *testing.T) *apiKeyTestEnv {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+	cfg := helpers.GetTestConfig()
+
+	service, err := services.NewAPIKeyService(testDB.DB, cfg)
+	require.NoError(t, err)
+	orgService := services.NewOrganizationService(testDB.DB, cfg)
+
+	exchange := &models.Exchange{Name: "HitBTC", Code: "hitbtc", IsActive: true}
+	require.NoError(t, testDB.DB.Create(exchange).Error)
+
+	owner := testDB.CreateTestUser(t, "keys@example.com", "Key", "Owner")
+	organization, err := orgService.Create(context.Background(), owner.ID, &services.CreateOrganizationRequest{Name: "Desk"})
+	require.NoError(t, err)
+	ownerWS, err := orgService.Workspace(context.Background(), organization.ID, owner.ID)
+	require.NoError(t, err)
+
+	return &apiKeyTestEnv{
+		service:      service,
+		orgService:   orgService,
+		testDB:       testDB,
+		exchange:     exchange,
+		owner:        owner,
+		ownerWS:      ownerWS,
+		organization: organization,
+	}
+}
+
+func (env *apiKeyTestEnv) createKey(t *testing.T, name string) *services.APIKeyInfo {
+	key, err := env.service.Create(context.Background(), env.ownerWS, &services.CreateAPIKeyRequest{
+		ExchangeID: env.exchange.ID,
+		Name:       name,
+		APIKey:     testAPIKey,
+		APISecret:  testAPISecret,
+	})
+	require.NoError(t, err)
+	return key
+}
+
+func TestEnvelope(t *testing.T) {
+	masterKey, err := secrets.ParseMasterKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123456789abcdef0123456789abcdef"), masterKey)
+
+	_, err = secrets.ParseMasterKey("too-short")
+	assert.ErrorIs(t, err, secrets.ErrInvalidMasterKey)
+
+	envelope, err := secrets.NewEnvelope(masterKey)
+	require.NoError(t, err)
+
+	dataKey, wrapped, err := envelope.NewDataKey()
+	require.NoError(t, err)
+	sealed, err := dataKey.Seal([]byte("secret"), "field")
+	require.NoError(t, err)
+
+	unwrapped, err := envelope.UnwrapDataKey(wrapped)
+	require.NoError(t, err)
+	plaintext, err := unwrapped.Open(sealed, "field")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	_, err = unwrapped.Open(sealed, "other-field")
+	assert.ErrorIs(t, err, secrets.ErrDecryption)
+
+	otherEnvelope, err := secrets.NewEnvelope([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	_, err = otherEnvelope.UnwrapDataKey(wrapped)
+	assert.ErrorIs(t, err, secrets.ErrDecryption)
+}
+
+func TestAPIKeyService_Create(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	key := env.createKey(t, "Main")
+	assert.Equal(t, "hbtc****WXYZ", key.KeyPreview)
+	assert.Equal(t, "hitbtc", key.ExchangeCode)
+	assert.Equal(t, env.organization.ID, key.OrganizationID)
+
+	var stored models.APIKey
+	require.NoError(t, env.testDB.DB.First(&stored, key.ID).Error)
+	assert.NotEmpty(t, stored.WrappedDataKey)
+	assert.False(t, bytes.Contains(stored.EncryptedKey, []byte(testAPIKey)))
+	assert.False(t, bytes.Contains(stored.EncryptedSecret, []byte(testAPISecret)))
+
+	credentials, err := env.service.Credentials(ctx, env.ownerWS, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, testAPIKey, credentials.APIKey)
+	assert.Equal(t, testAPISecret, credentials.APISecret)
+
+	t.Run("secrets never reach the audit trail", func(t *testing.T) {
+		var logs []models.AuditLog
+		require.NoError(t, env.testDB.DB.Where("resource = ?", "api_keys").Find(&logs).Error)
+		require.Len(t, logs, 1)
+		assert.NotContains(t, string(logs[0].NewValues), testAPISecret)
+		assert.NotContains(t, string(logs[0].NewValues), testAPIKey)
+	})
+
+	t.Run("invalid input is rejected without echoing credentials", func(t *testing.T) {
+		_, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+			ExchangeID: env.exchange.ID,
+			APIKey:     "has space",
+		})
+		var validationErrors utils.ValidationErrors
+		require.ErrorAs(t, err, &validationErrors)
+		assert.Len(t, validationErrors, 3)
+		for _, validationError := range validationErrors {
+			assert.Nil(t, validationError.Value)
+		}
+
+		_, err = env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+			ExchangeID: env.exchange.ID + 100,
+			Name:       "Unknown",
+			APIKey:     testAPIKey,
+			APISecret:  testAPISecret,
+		})
+		assert.ErrorIs(t, err, services.ErrExchangeNotFound)
+	})
+
+	t.Run("tampered ciphertexts fail to decrypt", func(t *testing.T) {
+		tampered := append([]byte{}, stored.EncryptedSecret...)
+		tampered[len(tampered)-1] ^= 0xff
+		require.NoError(t, env.testDB.DB.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("encrypted_secret", tampered).Error)
+
+		_, err := env.service.Credentials(ctx, env.ownerWS, key.ID)
+		assert.ErrorIs(t, err, secrets.ErrDecryption)
+	})
+}
+
+func TestAPIKeyService_WorkspaceAccess(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	key := env.createKey(t, "Shared")
+
+	viewer := env.testDB.CreateTestUser(t, "viewer@example.com", "View", "Only")
+	_, err := env.orgService.AddMember(ctx, env.ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: services.OrgRoleViewer})
+	require.NoError(t, err)
+	viewerWS, err := env.orgService.Workspace(ctx, env.organization.ID, viewer.ID)
+	require.NoError(t, err)
+
+	keys, err := env.service.List(ctx, viewerWS)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+
+	_, err = env.service.Create(ctx, viewerWS, &services.CreateAPIKeyRequest{ExchangeID: env.exchange.ID, Name: "x", APIKey: testAPIKey, APISecret: testAPISecret})
+	assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+	assert.ErrorIs(t, env.service.Delete(ctx, viewerWS, key.ID), services.ErrOrganizationForbidden)
+
+	outsider := env.testDB.CreateTestUser(t, "outsider@example.com", "Out", "Sider")
+	other, err := env.orgService.Create(ctx, outsider.ID, &services.CreateOrganizationRequest{Name: "Other desk"})
+	require.NoError(t, err)
+	outsiderWS, err := env.orgService.Workspace(ctx, other.ID, outsider.ID)
+	require.NoError(t, err)
+
+	_, err = env.service.Get(ctx, outsiderWS, key.ID)
+	assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+	_, err = env.service.Credentials(ctx, outsiderWS, key.ID)
+	assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+	keys, err = env.service.List(ctx, outsiderWS)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestAPIKeyService_UpdateAndDelete(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	key := env.createKey(t, "Main")
+	var before models.APIKey
+	require.NoError(t, env.testDB.DB.First(&before, key.ID).Error)
+
+	newKey, newSecret := "replacementKEY00112233", "replacement-secret"
+	inactive := false
+	updated, err := env.service.Update(ctx, env.ownerWS, key.ID, &services.UpdateAPIKeyRequest{
+		Name:      stringPtr("Renamed"),
+		IsActive:  &inactive,
+		APIKey:    &newKey,
+		APISecret: &newSecret,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.False(t, updated.IsActive)
+	assert.Equal(t, "repl****2233", updated.KeyPreview)
+
+	var after models.APIKey
+	require.NoError(t, env.testDB.DB.First(&after, key.ID).Error)
+	assert.NotEqual(t, before.WrappedDataKey, after.WrappedDataKey, "new credentials get a new data key")
+
+	_, err = env.service.Credentials(ctx, env.ownerWS, key.ID)
+	assert.ErrorIs(t, err, services.ErrAPIKeyInactive)
+	credentials, err := env.service.Decrypt(ctx, &after)
+	require.NoError(t, err)
+	assert.Equal(t, newSecret, credentials.APISecret)
+
+	_, err = env.service.Update(ctx, env.ownerWS, key.ID, &services.UpdateAPIKeyRequest{APISecret: &newSecret})
+	var validationErrors utils.ValidationErrors
+	assert.ErrorAs(t, err, &validationErrors)
+
+	require.NoError(t, env.service.Delete(ctx, env.ownerWS, key.ID))
+	_, err = env.service.Get(ctx, env.ownerWS, key.ID)
+	assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+
+	var deleted models.APIKey
+	require.NoError(t, env.testDB.DB.Unscoped().First(&deleted, key.ID).Error)
+	assert.True(t, deleted.DeletedAt.Valid)
+	assert.Empty(t, deleted.WrappedDataKey)
+	assert.Empty(t, deleted.EncryptedSecret)
+}
+
+func TestAPIKeyService_PersonalData(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	shared := env.createKey(t, "Shared")
+
+	// Personal workspaces are created on login; mark one by hand here
+	personalOrg, err := env.orgService.Create(ctx, env.owner.ID, &services.CreateOrganizationRequest{Name: "Personal"})
+	require.NoError(t, err)
+	require.NoError(t, env.testDB.DB.Model(&models.Organization{}).Where("id = ?", personalOrg.ID).Update("is_personal", true).Error)
+	personalWS, err := env.orgService.Workspace(ctx, personalOrg.ID, env.owner.ID)
+	require.NoError(t, err)
+	personal, err := env.service.Create(ctx, personalWS, &services.CreateAPIKeyRequest{
+		ExchangeID: env.exchange.ID, Name: "Personal", APIKey: testAPIKey, APISecret: testAPISecret,
+	})
+	require.NoError(t, err)
+
+	// Paper accounts of erased keys go with them
+	paperAccounts := make(map[uint]*models.PaperAccount)
+	for _, key := range []*services.APIKeyInfo{shared, personal} {
+		account := &models.PaperAccount{OrganizationID: key.OrganizationID, APIKeyID: key.ID}
+		require.NoError(t, env.testDB.DB.Create(account).Error)
+		require.NoError(t, env.testDB.DB.Create(&models.PaperBalance{AccountID: account.ID, Currency: "USDT", Available: 100}).Error)
+		paperAccounts[key.ID] = account
+	}
+
+	privacyService := services.NewPrivacyService(env.testDB.DB, helpers.GetTestConfig(), env.orgService, env.service)
+	archive, err := privacyService.ExportUserData(ctx, env.owner.ID)
+	require.NoError(t, err)
+	files := readZipFiles(t, archive)
+	require.Contains(t, files, "api_keys.json")
+	assert.Contains(t, string(files["api_keys.json"]), "hbtc****WXYZ")
+	assert.NotContains(t, string(files["api_keys.json"]), testAPISecret)
+
+	_, err = privacyService.EraseUser(ctx, env.owner.ID, nil)
+	require.NoError(t, err)
+
+	var remaining models.APIKey
+	require.NoError(t, env.testDB.DB.First(&remaining, shared.ID).Error)
+	assert.Nil(t, remaining.CreatedBy)
+	assert.NotEmpty(t, remaining.WrappedDataKey, "shared keys stay usable")
+
+	var erased models.APIKey
+	require.NoError(t, env.testDB.DB.Unscoped().First(&erased, personal.ID).Error)
+	assert.True(t, erased.DeletedAt.Valid)
+	assert.Empty(t, erased.WrappedDataKey)
+
+	var accounts []models.PaperAccount
+	require.NoError(t, env.testDB.DB.Unscoped().Find(&accounts).Error)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, shared.ID, accounts[0].APIKeyID)
+	var balances int64
+	require.NoError(t, env.testDB.DB.Model(&models.PaperBalance{}).Where("account_id = ?", paperAccounts[personal.ID].ID).Count(&balances).Error)
+	assert.Zero(t, balances)
+}
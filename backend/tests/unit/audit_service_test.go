@@ -0,0 +1,184 @@
+package unit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAuditServiceTest(t *testing.T) (*services.AuditService, *helpers.TestDB) {
+	testDB := helpers.SetupTestDB(t)
+	auditService := services.NewAuditService(testDB.DB, helpers.GetTestConfig())
+	return auditService, testDB
+}
+
+func writeAuditEntries(t *testing.T, auditService *services.AuditService, count int) []*models.AuditLog {
+	logs := make([]*models.AuditLog, 0, count)
+	for i := 0; i < count; i++ {
+		auditLog, err := auditService.Log(context.Background(), &services.AuditEntry{
+			Action:     "update",
+			Resource:   "users",
+			ResourceID: fmt.Sprintf("%d", i+1),
+			OldValues:  map[string]interface{}{"first_name": "Old", "is_active": true},
+			NewValues:  map[string]interface{}{"first_name": "New", "is_active": false},
+			IPAddress:  "127.0.0.1",
+		})
+		require.NoError(t, err)
+		logs = append(logs, auditLog)
+	}
+	return logs
+}
+
+// expectedCheckpoints counts automatic checkpoints for the test config interval
+func expectedCheckpoints(logs []*models.AuditLog) int {
+	interval := uint(helpers.GetTestConfig().Audit.CheckpointInterval)
+	count := 0
+	for _, auditLog := range logs {
+		if auditLog.ID%interval == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAuditService_Chain(t *testing.T) {
+	auditService, testDB := setupAuditServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+
+	ctx := context.Background()
+
+	t.Run("entries are linked to their predecessor", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 3)
+
+		assert.Len(t, logs[0].PrevHash, 64)
+		assert.Equal(t, logs[0].Hash, logs[1].PrevHash)
+		assert.Equal(t, logs[1].Hash, logs[2].PrevHash)
+		assert.NotEqual(t, logs[1].Hash, logs[2].Hash)
+	})
+
+	t.Run("intact chain verifies", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 12)
+
+		result, err := auditService.VerifyChain(ctx, 4)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, 12, result.Checked)
+		assert.Equal(t, expectedCheckpoints(logs), result.Checkpoints)
+		assert.Nil(t, result.BrokenAt)
+	})
+
+	t.Run("legacy entries without hash are skipped", func(t *testing.T) {
+		testDB.ClearTables(t)
+		require.NoError(t, testDB.DB.Create(&models.AuditLog{Action: "login", Resource: "auth"}).Error)
+		writeAuditEntries(t, auditService, 2)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, 1, result.Legacy)
+		assert.Equal(t, 2, result.Checked)
+	})
+
+	t.Run("modified entry is reported", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 4)
+
+		err := testDB.DB.Model(&models.AuditLog{}).Where("id = ?", logs[2].ID).
+			Update("action", "delete").Error
+		require.NoError(t, err)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.BrokenAt)
+		assert.Equal(t, logs[2].ID, *result.BrokenAt)
+		assert.Contains(t, result.Reason, "content")
+	})
+
+	t.Run("deleted entry is reported", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 4)
+
+		require.NoError(t, testDB.DB.Delete(&models.AuditLog{}, logs[1].ID).Error)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.BrokenAt)
+		assert.Equal(t, logs[2].ID, *result.BrokenAt)
+		assert.Contains(t, result.Reason, "previous hash")
+	})
+
+	t.Run("truncated tail is reported", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 3)
+
+		require.NoError(t, testDB.DB.Delete(&models.AuditLog{}, logs[2].ID).Error)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.BrokenAt)
+		assert.Equal(t, logs[2].ID, *result.BrokenAt)
+		assert.Contains(t, result.Reason, "chain head")
+	})
+
+	t.Run("rewritten chain is caught by signed checkpoint", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 5)
+
+		// Rebuild a consistent chain without knowing the checkpoint secret
+		forgerCfg := helpers.GetTestConfig()
+		forgerCfg.Audit.CheckpointSecret = "guessed-secret"
+		forger := services.NewAuditService(testDB.DB, forgerCfg)
+		require.NoError(t, testDB.DB.Exec("DELETE FROM audit_logs").Error)
+		require.NoError(t, testDB.DB.Exec("DELETE FROM audit_chain_heads").Error)
+		for range logs {
+			_, err := forger.Log(ctx, &services.AuditEntry{Action: "forged", Resource: "users"})
+			require.NoError(t, err)
+		}
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Reason, "checkpoint")
+	})
+
+	t.Run("forged checkpoint signature is reported", func(t *testing.T) {
+		testDB.ClearTables(t)
+		writeAuditEntries(t, auditService, 5)
+
+		err := testDB.DB.Model(&models.AuditCheckpoint{}).Where("1 = 1").
+			Update("signature", "0000000000000000000000000000000000000000000000000000000000000000").Error
+		require.NoError(t, err)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Reason, "invalid signature")
+	})
+
+	t.Run("manual checkpoint signs the current head", func(t *testing.T) {
+		testDB.ClearTables(t)
+		logs := writeAuditEntries(t, auditService, 2)
+
+		checkpoint, err := auditService.CreateCheckpoint(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, logs[1].ID, checkpoint.LastLogID)
+		assert.Equal(t, logs[1].Hash, checkpoint.Hash)
+
+		result, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, expectedCheckpoints(logs)+1, result.Checkpoints)
+	})
+}
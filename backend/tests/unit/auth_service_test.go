@@ -370,6 +370,51 @@ func TestAuthService_Logout(t *testing.T) {
 	})
 }
 
+func TestAuthService_ResetPassword(t *testing.T) {
+	authService, testDB, redisServer := setupAuthServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	defer redisServer.Close()
+
+	ctx := context.Background()
+
+	createToken := func(t *testing.T, user *models.User, token string, expiresAt time.Time) {
+		require.NoError(t, testDB.DB.Create(&models.PasswordResetToken{UserID: user.ID, Token: token, ExpiresAt: expiresAt}).Error)
+	}
+
+	t.Run("sets the password and unlocks the account", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := createTestUserWithPassword(t, testDB, "reset@example.com", "password123", true)
+		lockedUntil := time.Now().Add(time.Hour)
+		require.NoError(t, testDB.DB.Model(user).Updates(map[string]interface{}{"login_attempts": 5, "locked_until": lockedUntil}).Error)
+		createToken(t, user, "reset-token", time.Now().Add(time.Hour))
+
+		require.NoError(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "reset-token", Password: "new-password"}))
+
+		_, err := authService.Login(ctx, &services.LoginRequest{Email: "reset@example.com", Password: "new-password"})
+		require.NoError(t, err)
+		assert.ErrorIs(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "reset-token", Password: "new-password"}), services.ErrInvalidResetToken)
+	})
+
+	t.Run("expired tokens are rejected", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := createTestUserWithPassword(t, testDB, "expired@example.com", "password123", true)
+		createToken(t, user, "expired-token", time.Now().Add(-time.Minute))
+
+		err := authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "expired-token", Password: "new-password"})
+		assert.ErrorIs(t, err, services.ErrInvalidResetToken)
+		assert.ErrorIs(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "unknown-token", Password: "new-password"}), services.ErrInvalidResetToken)
+	})
+
+	t.Run("short passwords are rejected", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := createTestUserWithPassword(t, testDB, "short@example.com", "password123", true)
+		createToken(t, user, "short-token", time.Now().Add(time.Hour))
+
+		assert.ErrorIs(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "short-token", Password: "short"}), services.ErrWeakPassword)
+		require.NoError(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: "short-token", Password: "long-enough"}), "the token is kept")
+	})
+}
+
 func TestAuthService_GetCurrentUser(t *testing.T) {
 	authService, testDB, redisServer := setupAuthServiceTest(t)
 	defer testDB.TeardownTestDB(t)
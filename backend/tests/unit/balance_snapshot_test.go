@@ -0,0 +1,238 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/keypool"
+	"trader/internal/market"
+	"trader/internal/models"
+	"trader/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrices(t *testing.T) {
+	symbols := []exchange.Symbol{
+		{Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT", Active: true},
+		{Symbol: "ETHBTC", Base: "ETH", Quote: "BTC", Active: true},
+		{Symbol: "USDTTRY", Base: "USDT", Quote: "TRY", Active: true},
+		{Symbol: "ETHUSDT", Base: "ETH", Quote: "USDT", Active: true},
+		{Symbol: "XEMETH", Base: "XEM", Quote: "ETH", Active: true},
+		{Symbol: "NEWBTC", Base: "NEW", Quote: "BTC", Active: true},
+		{Symbol: "OLDBTC", Base: "OLD", Quote: "BTC", Active: false},
+	}
+	tickers := []exchange.Ticker{
+		{Symbol: "BTCUSDT", Last: 30000},
+		{Symbol: "ETHBTC", Last: 0.06},
+		{Symbol: "USDTTRY", Last: 25},
+		{Symbol: "ETHUSDT", Last: 1810},
+		{Symbol: "XEMETH", Last: 0.0001},
+		{Symbol: "NEWBTC", Bid: 0.0009, Ask: 0.0011},
+		{Symbol: "OLDBTC", Last: 0.01},
+	}
+	prices := market.NewPrices("USDT", symbols, tickers)
+
+	tests := []struct {
+		currency string
+		want     float64
+		ok       bool
+	}{
+		{"USDT", 1, true},
+		{"BTC", 30000, true},
+		{"TRY", 0.04, true},
+		// The direct pair is preferred to going through BTC
+		{"ETH", 1810, true},
+		{"XEM", 0.181, true},
+		{"NEW", 30, true},
+		{"OLD", 0, false},
+		{"DOGE", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			price, ok := prices.Price(tt.currency)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.want, price, 1e-9)
+		})
+	}
+}
+
+func TestAPIKeyService_SnapshotBalances(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	_, server := newFakeBinance(t)
+	ctx := context.Background()
+	db := env.testDB.DB
+
+	binance := &models.Exchange{Name: "Binance", Code: "binance", IsActive: true, APIUrl: server.URL}
+	require.NoError(t, db.Create(binance).Error)
+	key, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+		ExchangeID: binance.ID,
+		Name:       "Binance",
+		APIKey:     testAPIKey,
+		APISecret:  testAPISecret,
+	})
+	require.NoError(t, err)
+
+	// 0.6 BTC at 30080 and 1500.25 USDT
+	const value = 19548.25
+
+	loadSnapshots := func(t *testing.T) []models.BalanceSnapshot {
+		var snapshots []models.BalanceSnapshot
+		require.NoError(t, db.Where("api_key_id = ?", key.ID).Order("id").Find(&snapshots).Error)
+		return snapshots
+	}
+	// setPrevious rewrites the last snapshot as if the account had held the balances
+	setPrevious := func(t *testing.T, balances []services.BalanceAmount) {
+		snapshots := loadSnapshots(t)
+		data, err := json.Marshal(balances)
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&snapshots[len(snapshots)-1]).Update("balances", data).Error)
+	}
+
+	t.Run("stores the non-zero balances with their value", func(t *testing.T) {
+		result, err := env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Taken: 1}, result)
+
+		snapshots := loadSnapshots(t)
+		require.Len(t, snapshots, 1)
+		require.NotNil(t, snapshots[0].Value)
+		assert.InDelta(t, value, *snapshots[0].Value, 1e-8)
+		assert.Equal(t, "USDT", snapshots[0].ValuationCurrency)
+
+		var balances []services.BalanceAmount
+		require.NoError(t, json.Unmarshal(snapshots[0].Balances, &balances))
+		require.Len(t, balances, 2, "empty balances are left out")
+		assert.Equal(t, "BTC", balances[0].Currency)
+		assert.InDelta(t, 0.5, balances[0].Available, 1e-12)
+		assert.InDelta(t, 0.1, balances[0].Reserved, 1e-12)
+		assert.InDelta(t, 0.6, balances[0].Total, 1e-12)
+		require.NotNil(t, balances[0].Value)
+		assert.InDelta(t, 18048, *balances[0].Value, 1e-8)
+		assert.Equal(t, "USDT", balances[1].Currency)
+	})
+
+	t.Run("unchanged balances extend the last snapshot", func(t *testing.T) {
+		before := loadSnapshots(t)[0]
+
+		result, err := env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Unchanged: 1}, result)
+
+		snapshots := loadSnapshots(t)
+		require.Len(t, snapshots, 1)
+		assert.False(t, snapshots[0].LastSeenAt.Before(before.LastSeenAt))
+		assert.True(t, snapshots[0].TakenAt.Equal(before.TakenAt))
+	})
+
+	t.Run("trades are not drops", func(t *testing.T) {
+		// Before buying 0.05 BTC at the current price
+		setPrevious(t, []services.BalanceAmount{
+			{Currency: "BTC", Available: 0.45, Reserved: 0.1, Total: 0.55},
+			{Currency: "USDT", Available: 1500.25 + 0.05*30080, Total: 1500.25 + 0.05*30080},
+		})
+
+		result, err := env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Taken: 1}, result)
+		assert.Len(t, loadSnapshots(t), 2)
+
+		var events int64
+		require.NoError(t, db.Model(&models.BalanceEvent{}).Count(&events).Error)
+		assert.Zero(t, events)
+	})
+
+	t.Run("a withdrawal is an unexpected drop", func(t *testing.T) {
+		setPrevious(t, []services.BalanceAmount{
+			{Currency: "BNB", Available: 2, Total: 2},
+			{Currency: "BTC", Available: 1.5, Reserved: 0.1, Total: 1.6},
+			{Currency: "USDT", Available: 1500.25, Total: 1500.25},
+		})
+
+		result, err := env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Taken: 1, Drops: 1}, result)
+
+		snapshots := loadSnapshots(t)
+		require.Len(t, snapshots, 3)
+		var event models.BalanceEvent
+		require.NoError(t, db.Where("api_key_id = ?", key.ID).First(&event).Error)
+		assert.Equal(t, services.BalanceEventUnexpectedDrop, event.Type)
+		assert.Equal(t, snapshots[2].ID, event.SnapshotID)
+		assert.Equal(t, "USDT", event.ValuationCurrency)
+		// BNB has no price on the exchange and does not count
+		assert.InDelta(t, value+30080, event.ValueBefore, 1e-8)
+		assert.InDelta(t, value, event.ValueAfter, 1e-8)
+		assert.InDelta(t, 60.6106, event.DropPercent, 1e-4)
+
+		var changes []services.BalanceChange
+		require.NoError(t, json.Unmarshal(event.Changes, &changes))
+		assert.Equal(t, []services.BalanceChange{
+			{Currency: "BNB", Before: 2, After: 0},
+			{Currency: "BTC", Before: 1.6, After: 0.6},
+		}, changes)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		balances, err := env.service.Balances(ctx, env.ownerWS, key.ID, &services.BalanceHistoryFilter{})
+		require.NoError(t, err)
+		require.NotNil(t, balances.Current)
+		require.NotNil(t, balances.Current.Value)
+		assert.InDelta(t, value, *balances.Current.Value, 1e-8)
+		assert.Len(t, balances.Current.Balances, 2)
+		require.Len(t, balances.History, 3)
+		assert.Equal(t, balances.Current.ID, balances.History[0].ID, "newest first")
+		assert.Len(t, balances.Events, 1)
+
+		limited, err := env.service.Balances(ctx, env.ownerWS, key.ID, &services.BalanceHistoryFilter{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, limited.History, 1)
+
+		since := time.Now().Add(time.Hour)
+		later, err := env.service.Balances(ctx, env.ownerWS, key.ID, &services.BalanceHistoryFilter{Since: &since})
+		require.NoError(t, err)
+		assert.Empty(t, later.History)
+		assert.Empty(t, later.Events)
+		require.NotNil(t, later.Current, "the current balances do not depend on since")
+		assert.Equal(t, balances.Current.ID, later.Current.ID)
+
+		_, err = env.service.Balances(ctx, env.ownerWS, key.ID+100, &services.BalanceHistoryFilter{})
+		assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+	})
+
+	t.Run("a key that fails does not stop the others", func(t *testing.T) {
+		rejected, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+			ExchangeID: binance.ID,
+			Name:       "Rejected",
+			APIKey:     "unknown-key-0123456789",
+			APISecret:  testAPISecret,
+		})
+		require.NoError(t, err)
+
+		result, err := env.service.SnapshotBalances(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+		assert.Equal(t, &services.BalanceSnapshotResult{Unchanged: 1, Failed: 1}, result)
+
+		info, err := env.service.Get(ctx, env.ownerWS, rejected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, keypool.StateOpen, info.Health.State, "the pool sees the rejection")
+
+		// Keys whose breaker is open are not tried until it half-opens
+		result, err = env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Unchanged: 1, Skipped: 1}, result)
+
+		// Keys rejected by their last test are not tried again
+		tested, err := env.service.Test(ctx, env.ownerWS, rejected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, services.APIKeyTestAuthFailed, tested.Status)
+		result, err = env.service.SnapshotBalances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.BalanceSnapshotResult{Unchanged: 1}, result)
+	})
+}
@@ -0,0 +1,569 @@
+package unit_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/binance"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBinance replays responses recorded from Binance, stored in
+// testdata/binance. It checks API keys and signatures, reports the used
+// request weight and serves user data streams at /ws/<listen key>.
+type fakeBinance struct {
+	t   *testing.T
+	ctx context.Context
+
+	mu         sync.Mutex
+	queries    map[string]url.Values
+	weight     int
+	retryAfter int
+	listenKeys []string
+	closedKeys []string
+	stream     func(conn *fakeStreamConn, listenKey string)
+}
+
+func newFakeBinance(t *testing.T) (*fakeBinance, *httptest.Server) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeBinance{t: t, ctx: ctx, queries: make(map[string]url.Values)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Cleanup(cancel)
+	return fake, server
+}
+
+func (f *fakeBinance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if listenKey, ok := strings.CutPrefix(r.URL.Path, "/ws/"); ok {
+		f.serveStream(w, r, listenKey)
+		return
+	}
+
+	route := r.Method + " " + r.URL.Path
+	query := r.URL.Query()
+	f.mu.Lock()
+	f.queries[route] = query
+	f.weight += 2
+	weight, retryAfter := f.weight, f.retryAfter
+	f.mu.Unlock()
+
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", strconv.Itoa(weight))
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeBinanceError(w, http.StatusTooManyRequests, -1003, "Too many requests; current limit is 6000 request weight per 1 MINUTE.")
+		return
+	}
+
+	signed := r.URL.Path == "/api/v3/account" || r.URL.Path == "/api/v3/order" || strings.HasPrefix(r.URL.Path, "/sapi/")
+	if signed || r.URL.Path == "/api/v3/userDataStream" {
+		if r.Header.Get("X-MBX-APIKEY") != testAPIKey {
+			writeBinanceError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+			return
+		}
+	}
+	if signed && !validBinanceSignature(r.URL.RawQuery) {
+		writeBinanceError(w, http.StatusBadRequest, -1022, "Signature for this request is not valid.")
+		return
+	}
+
+	switch route {
+	case "POST /api/v3/userDataStream":
+		f.mu.Lock()
+		listenKey := fmt.Sprintf("listen-key-%d", len(f.listenKeys)+1)
+		f.listenKeys = append(f.listenKeys, listenKey)
+		f.mu.Unlock()
+		writeJSON(w, map[string]string{"listenKey": listenKey})
+		return
+	case "PUT /api/v3/userDataStream":
+		writeJSON(w, map[string]string{})
+		return
+	case "DELETE /api/v3/userDataStream":
+		f.mu.Lock()
+		f.closedKeys = append(f.closedKeys, query.Get("listenKey"))
+		f.mu.Unlock()
+		writeJSON(w, map[string]string{})
+		return
+	}
+
+	recording := map[string]string{
+		"GET /api/v3/exchangeInfo":             "exchangeInfo.json",
+		"GET /api/v3/depth":                    "depth_BTCUSDT.json",
+		"GET /api/v3/klines":                   "klines_BTCUSDT.json",
+		"GET /api/v3/account":                  "account.json",
+		"GET /sapi/v1/account/apiRestrictions": "apiRestrictions.json",
+		"GET /sapi/v1/account/info":            "accountInfo.json",
+		"GET /sapi/v1/bnbBurn":                 "bnbBurn.json",
+		"POST /api/v3/order":                   "order_new.json",
+	}[route]
+	switch route {
+	case "GET /api/v3/ticker/24hr":
+		switch query.Get("symbol") {
+		case "BTCUSDT":
+			recording = "ticker_BTCUSDT.json"
+		case "":
+			recording = "tickers.json"
+		default:
+			writeBinanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+			return
+		}
+	case "POST /api/v3/order":
+		if query.Get("price") == "31000" {
+			writeBinanceError(w, http.StatusBadRequest, -2010, "Order would immediately match and take.")
+			return
+		}
+	case "GET /api/v3/order":
+		recording = map[string]string{
+			recordedOrderID:                    "order_new.json",
+			"0ab1e7c3d2e64bbf8d3f1b4e66a5c210": "order_filled.json",
+		}[query.Get("origClientOrderId")]
+		if recording == "" {
+			writeBinanceError(w, http.StatusBadRequest, -2013, "Order does not exist.")
+			return
+		}
+	case "DELETE /api/v3/order":
+		if query.Get("origClientOrderId") != recordedOrderID {
+			writeBinanceError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+			return
+		}
+		recording = "order_canceled.json"
+	}
+	if recording == "" {
+		writeBinanceError(w, http.StatusNotFound, -1000, "Not found")
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join("testdata", "binance", recording))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(content)
+}
+
+func (f *fakeBinance) serveStream(w http.ResponseWriter, r *http.Request, listenKey string) {
+	f.mu.Lock()
+	script := f.stream
+	f.mu.Unlock()
+	if script == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.CloseNow()
+	script(&fakeStreamConn{t: f.t, ws: ws, ctx: f.ctx}, listenKey)
+}
+
+func (f *fakeBinance) query(route string) url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries[route]
+}
+
+// validBinanceSignature checks the signature, which is the last parameter and
+// signs the query before it
+func validBinanceSignature(rawQuery string) bool {
+	payload, signature, ok := strings.Cut(rawQuery, "&signature=")
+	if !ok {
+		return false
+	}
+	query, err := url.ParseQuery(payload)
+	if err != nil || query.Get("timestamp") == "" || query.Get("recvWindow") == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(testAPISecret))
+	mac.Write([]byte(payload))
+	return hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+func writeBinanceError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": message})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func binanceClient(server *httptest.Server, credentials *exchange.Credentials) *binance.Client {
+	return binance.New(exchange.Config{
+		BaseURL:           server.URL,
+		StreamURL:         "ws" + strings.TrimPrefix(server.URL, "http"),
+		Credentials:       credentials,
+		Timeout:           time.Second,
+		Heartbeat:         50 * time.Millisecond,
+		MaxReconnectDelay: 20 * time.Millisecond,
+	})
+}
+
+func TestBinance_MarketData(t *testing.T) {
+	fake, server := newFakeBinance(t)
+	ctx := context.Background()
+	// Market data needs no credentials
+	var client exchange.Connector = binanceClient(server, nil)
+
+	t.Run("symbols", func(t *testing.T) {
+		symbols, err := client.Symbols(ctx)
+		require.NoError(t, err)
+		require.Len(t, symbols, 3)
+
+		assert.Equal(t, "BCCBTC", symbols[0].Symbol)
+		assert.False(t, symbols[0].Active, "symbols on break are inactive")
+		assert.Equal(t, 0, symbols[0].QuantityPrecision)
+		assert.Equal(t, exchange.Symbol{
+			Symbol:            "BTCUSDT",
+			Base:              "BTC",
+			Quote:             "USDT",
+			Active:            true,
+			PriceTick:         0.01,
+			QuantityStep:      0.00001,
+			PricePrecision:    2,
+			QuantityPrecision: 5,
+			MinQuantity:       0.00001,
+			MaxQuantity:       9000,
+			MinNotional:       5,
+		}, symbols[1], "NOTIONAL sets the minimum notional")
+		assert.Equal(t, "ETHBTC", symbols[2].Symbol)
+		assert.Equal(t, 0.0001, symbols[2].MinNotional, "MIN_NOTIONAL sets the minimum notional")
+		assert.Equal(t, 4, symbols[2].QuantityPrecision)
+	})
+
+	t.Run("ticker", func(t *testing.T) {
+		ticker, err := client.Ticker(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, "BTCUSDT", ticker.Symbol)
+		assert.Equal(t, 30079.51, ticker.Bid)
+		assert.Equal(t, 30080.88, ticker.Ask)
+		assert.Equal(t, 30080.0, ticker.Last)
+		assert.Equal(t, 46002710.4121, ticker.QuoteVolume)
+		assert.Equal(t, time.Date(2023, 6, 1, 12, 0, 0, 123000000, time.UTC), ticker.Timestamp)
+
+		_, err = client.Ticker(ctx, "NOPE")
+		var apiErr *binance.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, -1121, apiErr.Code)
+		assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+		assert.ErrorIs(t, err, exchange.ErrInvalidSymbol)
+	})
+
+	t.Run("order book", func(t *testing.T) {
+		book, err := client.OrderBook(ctx, "BTCUSDT", 5)
+		require.NoError(t, err)
+		assert.Equal(t, "5", fake.query("GET /api/v3/depth").Get("limit"))
+		assert.Equal(t, exchange.PriceLevel{Price: 30079.51, Quantity: 0.05}, book.Bids[0])
+		assert.Equal(t, exchange.PriceLevel{Price: 30080.88, Quantity: 0.12}, book.Asks[0])
+	})
+
+	t.Run("candles", func(t *testing.T) {
+		from := time.Date(2023, 6, 1, 11, 30, 0, 0, time.UTC)
+		candles, err := client.Candles(ctx, "BTCUSDT", exchange.CandleQuery{Interval: exchange.Interval15m, From: from, Limit: 2})
+		require.NoError(t, err)
+
+		query := fake.query("GET /api/v3/klines")
+		assert.Equal(t, "15m", query.Get("interval"))
+		assert.Equal(t, "1685619000000", query.Get("startTime"))
+		assert.Equal(t, "2", query.Get("limit"))
+
+		require.Len(t, candles, 2)
+		assert.Equal(t, exchange.Candle{
+			OpenTime:    time.Date(2023, 6, 1, 11, 45, 0, 0, time.UTC),
+			Open:        30000,
+			High:        30060,
+			Low:         29990,
+			Close:       30050,
+			Volume:      12.5,
+			QuoteVolume: 375312.5,
+		}, candles[1])
+
+		_, err = client.Candles(ctx, "BTCUSDT", exchange.CandleQuery{Interval: "2h"})
+		assert.Error(t, err)
+	})
+}
+
+func TestBinance_Account(t *testing.T) {
+	fake, server := newFakeBinance(t)
+	ctx := context.Background()
+	var client exchange.Connector = binanceClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret})
+
+	t.Run("capabilities", func(t *testing.T) {
+		capabilities, err := client.Capabilities(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &exchange.Capabilities{Read: true, Trade: true}, capabilities)
+	})
+
+	t.Run("balances", func(t *testing.T) {
+		balances, err := client.Balances(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "true", fake.query("GET /api/v3/account").Get("omitZeroBalances"))
+		assert.Equal(t, []exchange.Balance{
+			{Currency: "BTC", Available: 0.5, Reserved: 0.1},
+			{Currency: "USDT", Available: 1500.25},
+		}, balances)
+	})
+
+	t.Run("fees", func(t *testing.T) {
+		fees, err := client.(exchange.FeeReporter).Fees(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &exchange.AccountFees{Tier: "vip1", Maker: 0.001, Taker: 0.001, FeeCurrency: "BNB"}, fees)
+	})
+
+	t.Run("post-only orders are limit makers", func(t *testing.T) {
+		order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol:        "BTCUSDT",
+			Side:          exchange.OrderSideBuy,
+			Type:          exchange.OrderTypeLimit,
+			Quantity:      0.01,
+			Price:         29500,
+			PostOnly:      true,
+			ClientOrderID: recordedOrderID,
+		})
+		require.NoError(t, err)
+
+		query := fake.query("POST /api/v3/order")
+		assert.Equal(t, recordedOrderID, query.Get("newClientOrderId"))
+		assert.Equal(t, "BUY", query.Get("side"))
+		assert.Equal(t, "LIMIT_MAKER", query.Get("type"))
+		assert.Equal(t, "0.01", query.Get("quantity"))
+		assert.Equal(t, "29500", query.Get("price"))
+		assert.Empty(t, query.Get("timeInForce"), "limit makers take no time in force")
+
+		assert.Equal(t, "28457901234", order.ID)
+		assert.Equal(t, exchange.OrderTypeLimit, order.Type)
+		assert.Equal(t, exchange.OrderStatusNew, order.Status)
+		assert.Equal(t, time.Date(2023, 6, 1, 12, 0, 0, 123000000, time.UTC), order.CreatedAt)
+		assert.True(t, order.Open())
+	})
+
+	t.Run("limit orders default to GTC", func(t *testing.T) {
+		_, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 0.01, Price: 29500,
+		})
+		require.NoError(t, err)
+
+		query := fake.query("POST /api/v3/order")
+		assert.Equal(t, "LIMIT", query.Get("type"))
+		assert.Equal(t, "GTC", query.Get("timeInForce"))
+		assert.Len(t, query.Get("newClientOrderId"), 32)
+	})
+
+	t.Run("rejected orders are invalid", func(t *testing.T) {
+		_, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 0.01, Price: 31000, PostOnly: true,
+		})
+		assert.ErrorIs(t, err, exchange.ErrInvalidOrder)
+		assert.ErrorIs(t, err, exchange.ErrWouldTriggerImmediately)
+	})
+
+	t.Run("get order", func(t *testing.T) {
+		order, err := client.GetOrder(ctx, "BTCUSDT", "0ab1e7c3d2e64bbf8d3f1b4e66a5c210")
+		require.NoError(t, err)
+		assert.Equal(t, "BTCUSDT", fake.query("GET /api/v3/order").Get("symbol"))
+		assert.Equal(t, exchange.OrderTypeMarket, order.Type)
+		assert.Equal(t, exchange.OrderSideSell, order.Side)
+		assert.Equal(t, exchange.OrderStatusFilled, order.Status)
+		assert.Equal(t, 0.02, order.FilledQuantity)
+		assert.Equal(t, 30081.25, order.AveragePrice)
+		assert.False(t, order.Open())
+
+		_, err = client.GetOrder(ctx, "BTCUSDT", "unknown")
+		assert.ErrorIs(t, err, exchange.ErrOrderNotFound)
+	})
+
+	t.Run("cancel order", func(t *testing.T) {
+		order, err := client.CancelOrder(ctx, "BTCUSDT", recordedOrderID)
+		require.NoError(t, err)
+		assert.Equal(t, recordedOrderID, order.ClientOrderID, "the cancel's own id is not the order's")
+		assert.Equal(t, exchange.OrderStatusCanceled, order.Status)
+		assert.Equal(t, 0.004, order.FilledQuantity)
+		assert.Equal(t, 29500.0, order.AveragePrice)
+
+		_, err = client.CancelOrder(ctx, "BTCUSDT", "unknown")
+		assert.ErrorIs(t, err, exchange.ErrOrderNotFound)
+	})
+
+	t.Run("private calls need valid credentials", func(t *testing.T) {
+		_, err := binanceClient(server, nil).Balances(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+
+		_, err = binanceClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: "wrong"}).Balances(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+
+		_, err = binanceClient(server, &exchange.Credentials{APIKey: "unknown", APISecret: testAPISecret}).Balances(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+	})
+}
+
+func TestBinance_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		want    error
+	}{
+		{-2015, "Invalid API-key, IP, or permissions for action.", exchange.ErrAuthFailed},
+		{-1022, "Signature for this request is not valid.", exchange.ErrAuthFailed},
+		{-1003, "Too much request weight used; current limit is 6000 request weight per 1 MINUTE.", exchange.ErrRateLimited},
+		{-1015, "Too many new orders; current limit is 50 orders per 10 SECOND.", exchange.ErrRateLimited},
+		{-1021, "Timestamp for this request is outside of the recvWindow.", exchange.ErrClockSkew},
+		{-1121, "Invalid symbol.", exchange.ErrInvalidSymbol},
+		{-1013, "Filter failure: LOT_SIZE", exchange.ErrInvalidOrder},
+		{-2010, "Account has insufficient balance for requested action.", exchange.ErrInsufficientBalance},
+		{-2010, "Order would trigger immediately.", exchange.ErrWouldTriggerImmediately},
+		{-2010, "Order would immediately match and take.", exchange.ErrWouldTriggerImmediately},
+		{-2010, "Market is closed.", exchange.ErrInvalidOrder},
+		{-2013, "Order does not exist.", exchange.ErrOrderNotFound},
+		{-2011, "Unknown order sent.", exchange.ErrOrderNotFound},
+	}
+	for _, tt := range tests {
+		err := error(&binance.APIError{Status: http.StatusBadRequest, Code: tt.code, Message: tt.message})
+		assert.ErrorIs(t, err, tt.want, "%d %s", tt.code, tt.message)
+	}
+
+	err := error(&binance.APIError{Status: http.StatusBadRequest, Code: -2011, Message: "Order was not canceled due to cancel restrictions."})
+	assert.NotErrorIs(t, err, exchange.ErrOrderNotFound)
+	assert.ErrorIs(t, &binance.APIError{Code: -2010, Message: "Account has insufficient balance for requested action."}, exchange.ErrInvalidOrder,
+		"rejections also match ErrInvalidOrder")
+}
+
+func TestBinance_RequestWeight(t *testing.T) {
+	fake, server := newFakeBinance(t)
+	ctx := context.Background()
+	client := binanceClient(server, nil)
+	var limited exchange.RateLimited = client
+
+	assert.Equal(t, exchange.RateLimitUsage{Limit: 6000}, limited.RateLimitUsage())
+
+	_, err := client.Symbols(ctx)
+	require.NoError(t, err)
+	_, err = client.Ticker(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	usage := limited.RateLimitUsage()
+	assert.Equal(t, 4, usage.Used, "the used weight comes from the response header")
+	assert.Equal(t, 6000, usage.Limit)
+	assert.True(t, usage.ResetAt.After(time.Now()))
+	assert.WithinDuration(t, time.Now(), usage.ResetAt, time.Minute)
+
+	fake.mu.Lock()
+	fake.retryAfter = 30
+	fake.mu.Unlock()
+	_, err = client.Ticker(ctx, "BTCUSDT")
+	var apiErr *binance.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.Status)
+	assert.ErrorIs(t, err, exchange.ErrRateLimited)
+
+	usage = limited.RateLimitUsage()
+	assert.Equal(t, usage.Limit, usage.Used, "a 429 uses up the budget")
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), usage.ResetAt, 2*time.Second)
+}
+
+func TestBinanceStream_OrderReports(t *testing.T) {
+	fake, server := newFakeBinance(t)
+	fake.stream = func(conn *fakeStreamConn, listenKey string) {
+		switch listenKey {
+		case "listen-key-1":
+			conn.sendRaw(`{"e":"outboundAccountPosition","E":1685620800100,"u":1685620800100,"B":[{"a":"USDT","f":"1205.25","l":"295.00"}]}`)
+			conn.sendRaw(`{"e":"executionReport","E":1685620800123,"s":"BTCUSDT","c":"` + recordedOrderID + `","S":"BUY","o":"LIMIT_MAKER","f":"GTC","q":"0.01000000","p":"29500.00000000","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"NEW","X":"NEW","r":"NONE","i":28457901234,"l":"0.00000000","z":"0.00000000","L":"0.00000000","n":"0","N":null,"T":1685620800123,"t":-1,"I":61234567,"w":true,"m":false,"M":false,"O":1685620800123,"Z":"0.00000000","Y":"0.00000000","Q":"0.00000000","W":1685620800123,"V":"EXPIRE_MAKER"}`)
+			conn.sendRaw(`{"e":"executionReport","E":1685620850000,"s":"BTCUSDT","c":"` + recordedOrderID + `","S":"BUY","o":"LIMIT_MAKER","f":"GTC","q":"0.01000000","p":"29500.00000000","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE","i":28457901234,"l":"0.00400000","z":"0.00400000","L":"29500.00000000","n":"0.00000400","N":"BTC","T":1685620850000,"t":3126412099,"I":61234590,"w":false,"m":true,"M":true,"O":1685620800123,"Z":"118.00000000","Y":"118.00000000","Q":"0.00000000","W":1685620800123,"V":"EXPIRE_MAKER"}`)
+			conn.sendRaw(`{"e":"listenKeyExpired","E":1685620860000,"listenKey":"listen-key-1"}`)
+		case "listen-key-2":
+			conn.sendRaw(`{"e":"executionReport","E":1685620900456,"s":"BTCUSDT","c":"Y4gRbTyWOu9yK6QKsPcJ2v","S":"BUY","o":"LIMIT_MAKER","f":"GTC","q":"0.01000000","p":"29500.00000000","P":"0.00000000","F":"0.00000000","g":-1,"C":"` + recordedOrderID + `","x":"CANCELED","X":"CANCELED","r":"NONE","i":28457901234,"l":"0.00000000","z":"0.00400000","L":"0.00000000","n":"0","N":null,"T":1685620900456,"t":-1,"I":61234612,"w":false,"m":false,"M":false,"O":1685620800123,"Z":"118.00000000","Y":"0.00000000","Q":"0.00000000","W":1685620800123,"V":"EXPIRE_MAKER"}`)
+		}
+		conn.drain()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := binanceClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret})
+	events, err := client.Stream(ctx, exchange.Subscription{Orders: true})
+	require.NoError(t, err)
+	waitForStatus(t, events, exchange.StreamConnected)
+
+	placed, ok := nextEvent(t, events).(exchange.OrderReport)
+	require.True(t, ok)
+	assert.Equal(t, recordedOrderID, placed.Order.ClientOrderID)
+	assert.Equal(t, "28457901234", placed.Order.ID)
+	assert.Equal(t, exchange.OrderSideBuy, placed.Order.Side)
+	assert.Equal(t, exchange.OrderTypeLimit, placed.Order.Type)
+	assert.Equal(t, exchange.OrderStatusNew, placed.Order.Status)
+	assert.Equal(t, 29500.0, placed.Order.Price)
+	assert.Nil(t, placed.Fill)
+
+	filled, ok := nextEvent(t, events).(exchange.OrderReport)
+	require.True(t, ok)
+	assert.Equal(t, exchange.OrderStatusPartiallyFilled, filled.Order.Status)
+	assert.Equal(t, 0.004, filled.Order.FilledQuantity)
+	assert.Equal(t, 29500.0, filled.Order.AveragePrice)
+	require.NotNil(t, filled.Fill)
+	assert.Equal(t, exchange.Fill{
+		TradeID:   "3126412099",
+		Price:     29500,
+		Quantity:  0.004,
+		Fee:       0.000004,
+		Taker:     false,
+		Timestamp: time.Date(2023, 6, 1, 12, 0, 50, 0, time.UTC),
+	}, *filled.Fill)
+
+	// The expired listen key is replaced and the stream reconnects
+	status := waitForStatus(t, events, exchange.StreamDisconnected)
+	assert.Error(t, status.Err)
+	waitForStatus(t, events, exchange.StreamConnected)
+
+	canceled, ok := nextEvent(t, events).(exchange.OrderReport)
+	require.True(t, ok)
+	assert.Equal(t, recordedOrderID, canceled.Order.ClientOrderID, "cancellations report the order's id in C")
+	assert.Equal(t, exchange.OrderStatusCanceled, canceled.Order.Status)
+	assert.Nil(t, canceled.Fill)
+
+	cancel()
+	for range events {
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	assert.Equal(t, []string{"listen-key-1", "listen-key-2"}, fake.listenKeys)
+	assert.Equal(t, []string{"listen-key-1", "listen-key-2"}, fake.closedKeys, "listen keys are closed after use")
+}
+
+func TestBinanceStream_Subscriptions(t *testing.T) {
+	_, server := newFakeBinance(t)
+	ctx := context.Background()
+
+	_, err := binanceClient(server, nil).Stream(ctx, exchange.Subscription{Orders: true})
+	assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+
+	client := binanceClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret})
+	_, err = client.Stream(ctx, exchange.Subscription{Tickers: []string{"BTCUSDT"}})
+	assert.Error(t, err, "market data streams are not supported")
+
+	// A rejected key ends the stream instead of retrying
+	rejected := binanceClient(server, &exchange.Credentials{APIKey: "unknown", APISecret: testAPISecret})
+	events, err := rejected.Stream(ctx, exchange.Subscription{Orders: true})
+	require.NoError(t, err)
+	status := waitForStatus(t, events, exchange.StreamDisconnected)
+	assert.ErrorIs(t, status.Err, exchange.ErrAuthFailed)
+	_, open := <-events
+	assert.False(t, open)
+}
@@ -0,0 +1,223 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/binance"
+	"trader/internal/exchange/cassette"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadCassette replays testdata/cassettes/<name>.json. The test fails on
+// requests the cassette has no recording for.
+func loadCassette(t *testing.T, name string) *cassette.Player {
+	t.Helper()
+	recording, err := cassette.Load(filepath.Join("testdata", "cassettes", name+".json"))
+	require.NoError(t, err)
+	player := cassette.NewPlayer(recording, cassette.DefaultSanitizer)
+	t.Cleanup(func() {
+		assert.Empty(t, player.Unmatched(), "requests missing from the %s cassette", name)
+	})
+	return player
+}
+
+// serveCassette serves a player, with the stream URL of a connector
+func serveCassette(t *testing.T, handler http.Handler) (*httptest.Server, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	fake, upstream := newFakeBinance(t)
+	fake.stream = func(conn *fakeStreamConn, listenKey string) {
+		conn.sendRaw(`{"e":"outboundAccountPosition","E":1685620801000,"u":1685620801000,"B":[{"a":"USDT","f":"1000.00","l":"0.00"}]}`)
+		conn.drain()
+	}
+	credentials := &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret}
+	sanitizer := cassette.DefaultSanitizer
+	sanitizer.Secrets = []string{testAPIKey, testAPISecret}
+
+	// Record a session through the proxy
+	recorder := cassette.NewRecorder(upstream.URL, "ws"+strings.TrimPrefix(upstream.URL, "http"), sanitizer)
+	recordServer, recordStreamURL := serveCassette(t, recorder)
+	client := binance.New(exchange.Config{BaseURL: recordServer.URL, StreamURL: recordStreamURL, Credentials: credentials})
+
+	recordedTicker, err := client.Ticker(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	recordedBalances, err := client.Balances(ctx)
+	require.NoError(t, err)
+	_, err = client.Ticker(ctx, "NOPE")
+	assert.ErrorIs(t, err, exchange.ErrInvalidSymbol)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	events, err := client.Stream(streamCtx, exchange.Subscription{Orders: true})
+	require.NoError(t, err)
+	waitForStatus(t, events, exchange.StreamConnected)
+	cancel()
+	for range events {
+	}
+
+	path := filepath.Join(t.TempDir(), "binance.json")
+	require.Eventually(t, func() bool {
+		return len(recorder.Cassette().Streams) == 1 && len(recorder.Cassette().Streams[0].Frames) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, recorder.Save(path))
+
+	t.Run("secrets are stripped", func(t *testing.T) {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		listenKey := fake.listenKeys[0]
+		for _, secret := range []string{testAPIKey, testAPISecret, listenKey} {
+			assert.NotContains(t, string(content), secret)
+		}
+		assert.NotContains(t, strings.ToLower(string(content)), "x-mbx-apikey")
+
+		recording, err := cassette.Load(path)
+		require.NoError(t, err)
+		var account cassette.Request
+		for _, interaction := range recording.Interactions {
+			if interaction.Request.Path == "/api/v3/account" {
+				account = interaction.Request
+			}
+		}
+		assert.Contains(t, account.Query, "signature=REDACTED")
+		assert.Contains(t, account.Query, "timestamp=REDACTED")
+		require.Len(t, recording.Streams, 1)
+		assert.Equal(t, "/ws/REDACTED", recording.Streams[0].Path, "listen keys from responses are redacted in paths")
+
+		var body map[string]interface{}
+		for _, interaction := range recording.Interactions {
+			if interaction.Request.Path == "/api/v3/ticker/24hr" && interaction.Response.Status == http.StatusOK {
+				require.NoError(t, json.Unmarshal(interaction.Response.Body, &body))
+			}
+		}
+		assert.Equal(t, "BTCUSDT", body["symbol"], "JSON bodies are stored as JSON")
+	})
+
+	t.Run("replays offline", func(t *testing.T) {
+		recording, err := cassette.Load(path)
+		require.NoError(t, err)
+		player := cassette.NewPlayer(recording, sanitizer)
+		server, streamURL := serveCassette(t, player)
+		replay := binance.New(exchange.Config{BaseURL: server.URL, StreamURL: streamURL, Credentials: credentials})
+
+		ticker, err := replay.Ticker(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, recordedTicker, ticker)
+		balances, err := replay.Balances(ctx)
+		require.NoError(t, err, "signed requests match despite a new timestamp and signature")
+		assert.Equal(t, recordedBalances, balances)
+		_, err = replay.Ticker(ctx, "NOPE")
+		assert.ErrorIs(t, err, exchange.ErrInvalidSymbol)
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events, err := replay.Stream(streamCtx, exchange.Subscription{Orders: true})
+		require.NoError(t, err)
+		waitForStatus(t, events, exchange.StreamConnected)
+		cancel()
+		for range events {
+		}
+
+		assert.Empty(t, player.Unmatched())
+		_, err = replay.Symbols(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, []string{"GET /api/v3/exchangeInfo?"}, player.Unmatched())
+	})
+}
+
+func TestCassette_Player(t *testing.T) {
+	recording := &cassette.Cassette{
+		Interactions: []cassette.Interaction{
+			{
+				Request:  cassette.Request{Method: http.MethodGet, Path: "/status", Query: "b=2&a=1"},
+				Response: cassette.Response{Status: http.StatusOK, Body: cassette.Body(`{"state":"starting"}`)},
+			},
+			{
+				Request:  cassette.Request{Method: http.MethodGet, Path: "/status", Query: "a=1&b=2"},
+				Response: cassette.Response{Status: http.StatusOK, Body: cassette.Body(`{"state":"running"}`)},
+			},
+		},
+		Streams: []cassette.Stream{{
+			Path: "/stream",
+			Frames: []cassette.Frame{
+				{Direction: cassette.Received, Message: cassette.Body(`{"hello":true}`)},
+				{Direction: cassette.Sent, Message: cassette.Body(`{"subscribe":"ticker"}`)},
+				{Direction: cassette.Received, Message: cassette.Body(`{"ticker":"BTCUSDT"}`)},
+			},
+		}},
+	}
+	player := cassette.NewPlayer(recording, cassette.DefaultSanitizer)
+	server, streamURL := serveCassette(t, player)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("repeated requests replay in order", func(t *testing.T) {
+		_, body := get("/status?a=1&b=2")
+		assert.JSONEq(t, `{"state":"starting"}`, body)
+		_, body = get("/status?b=2&a=1")
+		assert.JSONEq(t, `{"state":"running"}`, body)
+		_, body = get("/status?a=1&b=2")
+		assert.JSONEq(t, `{"state":"running"}`, body, "the last response repeats")
+	})
+
+	t.Run("unrecorded requests fail", func(t *testing.T) {
+		status, _ := get("/status?a=2")
+		assert.Equal(t, http.StatusNotImplemented, status)
+		assert.Equal(t, []string{"GET /status?a=2"}, player.Unmatched())
+	})
+
+	t.Run("streams wait for the client's messages", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, _, err := websocket.Dial(ctx, streamURL+"/stream", nil)
+		require.NoError(t, err)
+		defer conn.CloseNow()
+
+		_, message, err := conn.Read(ctx)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"hello":true}`, string(message))
+
+		readCtx, readCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		_, _, err = conn.Read(readCtx)
+		readCancel()
+		assert.Error(t, err, "nothing is sent before the client subscribes")
+	})
+
+	t.Run("streams replay", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, _, err := websocket.Dial(ctx, streamURL+"/stream", nil)
+		require.NoError(t, err)
+		defer conn.CloseNow()
+
+		_, _, err = conn.Read(ctx)
+		require.NoError(t, err)
+		require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(`{"subscribe":"ticker"}`)))
+		_, message, err := conn.Read(ctx)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ticker":"BTCUSDT"}`, string(message))
+	})
+}
@@ -0,0 +1,61 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/resilience"
+	"trader/internal/handlers"
+	"trader/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeErrorResponses(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("%w: USDT balance, 5 available, 10 needed", exchange.ErrInsufficientBalance), http.StatusUnprocessableEntity, "INSUFFICIENT_BALANCE"},
+		{fmt.Errorf("%w: unknown symbol DOGEUSDT", exchange.ErrInvalidSymbol), http.StatusBadRequest, "INVALID_SYMBOL"},
+		{exchange.ErrWouldTriggerImmediately, http.StatusUnprocessableEntity, "ORDER_WOULD_TRIGGER_IMMEDIATELY"},
+		{fmt.Errorf("%w: quantity must be positive", exchange.ErrInvalidOrder), http.StatusUnprocessableEntity, "INVALID_ORDER"},
+		{exchange.ErrOrderNotFound, http.StatusNotFound, "ORDER_NOT_FOUND"},
+		{exchange.ErrUnknownOrderStatus, http.StatusBadGateway, "UNKNOWN_ORDER_STATUS"},
+		{exchange.ErrAuthFailed, http.StatusUnprocessableEntity, "EXCHANGE_AUTH_FAILED"},
+		{exchange.ErrRateLimited, http.StatusTooManyRequests, "EXCHANGE_RATE_LIMITED"},
+		{resilience.ErrCircuitOpen, http.StatusServiceUnavailable, "EXCHANGE_UNAVAILABLE"},
+		{fmt.Errorf("%w: every key is rate limited or failing", services.ErrNoAPIKeyAvailable), http.StatusServiceUnavailable, "NO_API_KEY_AVAILABLE"},
+		{errors.New("connection reset by peer"), http.StatusBadGateway, "EXCHANGE_ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return handlers.ExchangeError(c, tt.err)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+
+			var body handlers.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Code)
+			assert.Equal(t, tt.err.Error(), body.Details)
+		})
+	}
+
+	assert.True(t, handlers.IsExchangeError(fmt.Errorf("failed to place order: %w", exchange.ErrInsufficientBalance)))
+	assert.False(t, handlers.IsExchangeError(errors.New("database is down")))
+}
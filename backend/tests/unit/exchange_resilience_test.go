@@ -0,0 +1,286 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/resilience"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedConnector answers calls with the errors queued in fail, then
+// succeeds. Methods it does not override panic through the nil embedded
+// connector.
+type scriptedConnector struct {
+	exchange.Connector
+
+	mu    sync.Mutex
+	calls map[string]int
+	fail  []error
+	// serverOffset is how far the exchange's clock is ahead; orders stamped
+	// by a clock more than a second off are rejected with ErrClockSkew
+	serverOffset time.Duration
+	clock        *exchange.Clock
+}
+
+func newScriptedConnector(fail ...error) *scriptedConnector {
+	return &scriptedConnector{calls: make(map[string]int), fail: fail}
+}
+
+func (c *scriptedConnector) next(operation string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[operation]++
+	if len(c.fail) == 0 {
+		return nil
+	}
+	err := c.fail[0]
+	c.fail = c.fail[1:]
+	return err
+}
+
+func (c *scriptedConnector) count(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[operation]
+}
+
+func (c *scriptedConnector) Ticker(ctx context.Context, symbol string) (*exchange.Ticker, error) {
+	if err := c.next(resilience.OpTicker); err != nil {
+		return nil, err
+	}
+	return &exchange.Ticker{Symbol: symbol, Last: 100}, nil
+}
+
+func (c *scriptedConnector) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	if err := c.next(resilience.OpBalances); err != nil {
+		return nil, err
+	}
+	if c.clock != nil {
+		skew := c.clock.Now().Sub(time.Now().Add(c.serverOffset))
+		if skew > time.Second || skew < -time.Second {
+			return nil, exchange.ErrClockSkew
+		}
+	}
+	return []exchange.Balance{{Currency: "USDT", Available: 10}}, nil
+}
+
+func (c *scriptedConnector) PlaceOrder(ctx context.Context, req *exchange.OrderRequest) (*exchange.Order, error) {
+	if err := c.next(resilience.OpPlaceOrder); err != nil {
+		return nil, err
+	}
+	return &exchange.Order{Symbol: req.Symbol, Side: req.Side}, nil
+}
+
+func (c *scriptedConnector) ServerTime(ctx context.Context) (time.Time, error) {
+	c.mu.Lock()
+	c.calls["server_time"]++
+	c.mu.Unlock()
+	return time.Now().Add(c.serverOffset), nil
+}
+
+// serverError is an API error with an HTTP status, as the connectors return
+type serverError struct{ status int }
+
+func (e *serverError) Error() string   { return "server error" }
+func (e *serverError) HTTPStatus() int { return e.status }
+
+func newTestRegistry(cfg resilience.Config) *resilience.Registry {
+	if cfg.RetryBaseDelay == 0 {
+		cfg.RetryBaseDelay = time.Millisecond
+	}
+	return resilience.NewRegistry(cfg)
+}
+
+func TestResilienceRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("idempotent calls are retried after server errors", func(t *testing.T) {
+		fake := newScriptedConnector(&serverError{status: 502}, &serverError{status: 503})
+		connector := newTestRegistry(resilience.Config{}).Wrap(fake, "test", "retry-1", resilience.PublicKey)
+
+		ticker, err := connector.Ticker(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, "BTCUSDT", ticker.Symbol)
+		assert.Equal(t, 3, fake.count(resilience.OpTicker))
+	})
+
+	t.Run("retries stop after the configured attempts", func(t *testing.T) {
+		fake := newScriptedConnector(&serverError{status: 500}, &serverError{status: 500}, &serverError{status: 500})
+		connector := newTestRegistry(resilience.Config{RetryAttempts: 2}).Wrap(fake, "test", "retry-2", resilience.PublicKey)
+
+		_, err := connector.Ticker(ctx, "BTCUSDT")
+		require.Error(t, err)
+		assert.Equal(t, 2, fake.count(resilience.OpTicker))
+	})
+
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		fake := newScriptedConnector(exchange.ErrAuthFailed, &serverError{status: 400})
+		connector := newTestRegistry(resilience.Config{}).Wrap(fake, "test", "retry-3", resilience.PublicKey)
+
+		_, err := connector.Ticker(ctx, "BTCUSDT")
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+		assert.Equal(t, 1, fake.count(resilience.OpTicker))
+
+		_, err = connector.Ticker(ctx, "BTCUSDT")
+		require.Error(t, err)
+		assert.Equal(t, 2, fake.count(resilience.OpTicker))
+	})
+
+	t.Run("orders are never retried", func(t *testing.T) {
+		fake := newScriptedConnector(&serverError{status: 504})
+		connector := newTestRegistry(resilience.Config{}).Wrap(fake, "test", "retry-4", resilience.PublicKey)
+
+		_, err := connector.PlaceOrder(ctx, &exchange.OrderRequest{Symbol: "BTCUSDT", Side: exchange.OrderSideBuy})
+		require.Error(t, err)
+		assert.Equal(t, 1, fake.count(resilience.OpPlaceOrder))
+	})
+}
+
+func TestResilienceCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var nowMu sync.Mutex
+	clock := func() time.Time {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		now = now.Add(d)
+	}
+
+	registry := newTestRegistry(resilience.Config{
+		RetryAttempts:    1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+		Now:              clock,
+	})
+	down := newScriptedConnector(errors.New("connection refused"), errors.New("connection refused"), errors.New("connection refused"))
+	connector := registry.Wrap(down, "test", "breaker", "key:a")
+	other := registry.Wrap(newScriptedConnector(), "test", "breaker", "key:b")
+
+	for range 2 {
+		_, err := connector.Ticker(ctx, "BTCUSDT")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, resilience.ErrCircuitOpen)
+	}
+
+	_, err := connector.Ticker(ctx, "BTCUSDT")
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, 2, down.count(resilience.OpTicker), "an open breaker does not call the exchange")
+
+	_, err = other.Ticker(ctx, "BTCUSDT")
+	require.NoError(t, err, "breakers are per key")
+
+	health := registry.Health()
+	require.Len(t, health, 1)
+	assert.Equal(t, resilience.StateDegraded, health[0].State)
+	require.Len(t, health[0].Breakers, 2)
+	assert.Equal(t, "key:a", health[0].Breakers[0].Key)
+	assert.Equal(t, resilience.StateOpen, health[0].Breakers[0].State)
+	assert.Equal(t, resilience.StateClosed, health[0].Breakers[1].State)
+
+	// A failed trial call opens the breaker again
+	advance(time.Minute)
+	_, err = connector.Ticker(ctx, "BTCUSDT")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, resilience.ErrCircuitOpen)
+	_, err = connector.Ticker(ctx, "BTCUSDT")
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+
+	// A successful one closes it
+	advance(time.Minute)
+	_, err = connector.Ticker(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, resilience.StateHealthy, registry.Health()[0].State)
+}
+
+func TestResilienceBreakerIgnoresRejections(t *testing.T) {
+	registry := newTestRegistry(resilience.Config{BreakerThreshold: 1})
+	fake := newScriptedConnector(exchange.ErrInvalidOrder, &serverError{status: 429}, exchange.ErrOrderNotFound)
+	connector := registry.Wrap(fake, "test", "rejections", resilience.PublicKey)
+
+	for range 3 {
+		_, err := connector.PlaceOrder(context.Background(), &exchange.OrderRequest{Symbol: "BTCUSDT"})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, resilience.ErrCircuitOpen)
+	}
+	assert.Equal(t, resilience.StateHealthy, registry.Health()[0].State)
+}
+
+func TestResilienceRateLimit(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(resilience.Config{
+		Limits:  map[string]resilience.Limit{"test": {Weight: 10, Per: 100 * time.Millisecond}},
+		Weights: map[string]map[string]int{"test": {resilience.OpBalances: 10}},
+		MaxWait: 500 * time.Millisecond,
+	})
+	connector := registry.Wrap(newScriptedConnector(), "test", "limit", resilience.PublicKey)
+
+	start := time.Now()
+	_, err := connector.Balances(ctx)
+	require.NoError(t, err)
+	_, err = connector.Balances(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond, "the second call waits for the bucket to refill")
+
+	health := registry.Health()
+	require.NotNil(t, health[0].RateLimit)
+	assert.Equal(t, 10, health[0].RateLimit.Capacity)
+
+	t.Run("calls that would wait too long fail", func(t *testing.T) {
+		registry := newTestRegistry(resilience.Config{
+			Limits:  map[string]resilience.Limit{"test": {Weight: 10, Per: time.Minute}},
+			Weights: map[string]map[string]int{"test": {resilience.OpTicker: 10}},
+			MaxWait: 10 * time.Millisecond,
+		})
+		fake := newScriptedConnector()
+		connector := registry.Wrap(fake, "test", "limit", resilience.PublicKey)
+
+		_, err := connector.Ticker(ctx, "BTCUSDT")
+		require.NoError(t, err)
+		_, err = connector.Ticker(ctx, "BTCUSDT")
+		assert.ErrorIs(t, err, exchange.ErrRateLimited)
+		assert.Equal(t, 1, fake.count(resilience.OpTicker), "a rate limited call is not retried")
+	})
+}
+
+func TestResilienceClockSkew(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(resilience.Config{ClockSyncInterval: time.Hour})
+
+	fake := newScriptedConnector()
+	fake.serverOffset = 5 * time.Second
+	fake.clock = registry.Clock("test", "clock")
+	connector := registry.Wrap(fake, "test", "clock", "key:a")
+
+	_, err := connector.Balances(ctx)
+	require.NoError(t, err, "signed calls are stamped with the exchange's time")
+	assert.Equal(t, 1, fake.count("server_time"))
+	assert.InDelta(t, 5000, fake.clock.Offset().Milliseconds(), 100)
+
+	// The exchange's clock jumps; the rejected call syncs and is repeated
+	fake.serverOffset = -3 * time.Second
+	_, err = connector.Balances(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.count("server_time"))
+	assert.Equal(t, 3, fake.count(resilience.OpBalances))
+
+	health := registry.Health()
+	assert.InDelta(t, -3000, health[0].ClockOffsetMs, 100)
+	assert.NotNil(t, health[0].ClockSyncedAt)
+
+	// Public calls do not sync the clock
+	_, err = connector.Ticker(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.count("server_time"))
+}
@@ -0,0 +1,140 @@
+package unit_test
+
+import (
+	"testing"
+
+	"trader/internal/exchange"
+	"trader/internal/fees"
+	"trader/internal/models"
+	"trader/internal/positions"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeSchedule_Charge(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule fees.Schedule
+		side     string
+		taker    bool
+		want     fees.Fee
+	}{
+		{"maker fee in quote", fees.Schedule{Maker: 0.001, Taker: 0.0025, Currency: fees.InQuote}, exchange.OrderSideBuy, false,
+			fees.Fee{Currency: fees.InQuote, Amount: 0.2, Value: 0.2}},
+		{"taker fee in quote", fees.Schedule{Maker: 0.001, Taker: 0.0025, Currency: fees.InQuote}, exchange.OrderSideSell, true,
+			fees.Fee{Currency: fees.InQuote, Amount: 0.5, Value: 0.5}},
+		{"fee in base", fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InBase}, exchange.OrderSideSell, true,
+			fees.Fee{Currency: fees.InBase, Amount: 0.002, Value: 0.2}},
+		{"received on buys is base", fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InReceived}, exchange.OrderSideBuy, true,
+			fees.Fee{Currency: fees.InBase, Amount: 0.002, Value: 0.2}},
+		{"received on sells is quote", fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InReceived}, exchange.OrderSideSell, true,
+			fees.Fee{Currency: fees.InQuote, Amount: 0.2, Value: 0.2}},
+		{"native token at a discount", fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InNative, NativeCurrency: "BNB", NativeDiscount: 0.25}, exchange.OrderSideBuy, true,
+			fees.Fee{Currency: fees.InNative, Value: 0.15}},
+		{"maker rebate", fees.Schedule{Maker: -0.0001, Taker: 0.001, Currency: fees.InQuote}, exchange.OrderSideBuy, false,
+			fees.Fee{Currency: fees.InQuote, Amount: -0.02, Value: -0.02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := tt.schedule.Charge(tt.side, tt.taker, 2, 100)
+			assert.Equal(t, tt.want.Currency, fee.Currency)
+			assert.InDelta(t, tt.want.Amount, fee.Amount, 1e-12)
+			assert.InDelta(t, tt.want.Value, fee.Value, 1e-12)
+		})
+	}
+
+	t.Run("from a stored schedule", func(t *testing.T) {
+		schedule := fees.FromModel(&models.FeeSchedule{MakerRate: 0.001, TakerRate: 0.001, FeeCurrency: fees.InNative, NativeCurrency: "BNB", NativeDiscount: 0.25})
+		assert.InDelta(t, 0.00075, schedule.Rate(true), 1e-12)
+	})
+}
+
+func TestPosition(t *testing.T) {
+	t.Run("fees raise the break-even above the average price", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InQuote})
+		position.Buy(1, 100, true)
+		position.Buy(1, 80, true)
+
+		assert.InDelta(t, 2, position.Quantity, 1e-12)
+		assert.InDelta(t, 180.18, position.Cost, 1e-9)
+		assert.InDelta(t, 90.09, position.AverageCost(), 1e-9)
+		assert.InDelta(t, 180.18/(2*0.999), position.BreakEven(true), 1e-9)
+		assert.InDelta(t, 0.18, position.FeesPaid, 1e-9)
+
+		// 90.1 is above the average fill price of 90 but a loss after fees
+		assert.False(t, position.Profitable(90.1, true))
+		assert.False(t, position.Profitable(90.18, true))
+		assert.True(t, position.Profitable(90.19, true))
+		assert.Less(t, position.UnrealizedPnL(90.1, true), 0.0)
+	})
+
+	t.Run("take profit is net of fees", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InQuote})
+		position.Buy(1, 100, true)
+		position.Buy(1, 80, true)
+		cost := position.Cost
+
+		price := position.TakeProfitPrice(8, true)
+		assert.InDelta(t, position.BreakEven(true)*1.08, price, 1e-9)
+		assert.InDelta(t, cost*0.08, position.UnrealizedPnL(price, true), 1e-9)
+
+		// Selling half at the level makes 8% on that half
+		fee, err := position.Sell(1, price, true)
+		require.NoError(t, err)
+		assert.Equal(t, fees.InQuote, fee.Currency)
+		assert.InDelta(t, cost/2*0.08, position.Realized, 1e-9)
+		assert.InDelta(t, 1, position.Quantity, 1e-12)
+		assert.InDelta(t, cost/2, position.Cost, 1e-9)
+		assert.InDelta(t, cost*0.08, position.PnL(price, true), 1e-9)
+	})
+
+	t.Run("fees in the received currency", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InReceived})
+		fee := position.Buy(1, 100, true)
+		assert.Equal(t, fees.InBase, fee.Currency)
+		assert.InDelta(t, 0.999, position.Quantity, 1e-12)
+		assert.InDelta(t, 100, position.Cost, 1e-12)
+
+		breakEven := position.BreakEven(true)
+		assert.InDelta(t, 100/(0.999*0.999), breakEven, 1e-9)
+		_, err := position.Sell(position.Quantity, breakEven, true)
+		require.NoError(t, err)
+		assert.InDelta(t, 0, position.Realized, 1e-9)
+		assert.Zero(t, position.Quantity)
+		assert.Zero(t, position.Cost)
+	})
+
+	t.Run("sell fees in the base currency need part of the position", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InBase})
+		position.Buy(1, 100, true)
+
+		_, err := position.Sell(position.Quantity, 110, true)
+		assert.ErrorIs(t, err, positions.ErrInsufficientQuantity)
+
+		sellable := position.Quantity / 1.001
+		breakEven := position.BreakEven(true)
+		assert.InDelta(t, 100/(0.999/1.001), breakEven, 1e-9)
+		fee, err := position.Sell(sellable, breakEven, true)
+		require.NoError(t, err)
+		assert.Equal(t, fees.InBase, fee.Currency)
+		assert.InDelta(t, 0, position.Realized, 1e-9)
+		assert.Zero(t, position.Quantity)
+	})
+
+	t.Run("native fees add to the cost", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InNative, NativeCurrency: "BNB", NativeDiscount: 0.25})
+		fee := position.Buy(1, 100, false)
+		assert.Equal(t, fees.InNative, fee.Currency)
+		assert.InDelta(t, 1, position.Quantity, 1e-12)
+		assert.InDelta(t, 100.075, position.Cost, 1e-9)
+		assert.InDelta(t, 100.075/0.99925, position.BreakEven(false), 1e-9)
+	})
+
+	t.Run("empty position", func(t *testing.T) {
+		position := positions.New(fees.Schedule{Maker: 0.001, Taker: 0.001, Currency: fees.InQuote})
+		assert.Zero(t, position.BreakEven(true))
+		assert.Zero(t, position.AverageCost())
+		assert.False(t, position.Profitable(100, true))
+	})
+}
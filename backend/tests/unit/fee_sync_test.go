@@ -0,0 +1,217 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/keypool"
+	"trader/internal/fees"
+	"trader/internal/market"
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeSync(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+
+	_, server := newRecordedHitBTC(t)
+	ctx := context.Background()
+	service := market.NewService(testDB.DB, helpers.GetTestConfig())
+
+	setup := func(t *testing.T) *models.Exchange {
+		testDB.ClearTables(t)
+		hitbtc := &models.Exchange{Name: "HitBTC", Code: "hitbtc", IsActive: true, APIUrl: server.URL}
+		require.NoError(t, testDB.DB.Create(hitbtc).Error)
+
+		schedules := []*models.FeeSchedule{
+			// Exchange-wide, as seeded
+			{ExchangeID: hitbtc.ID, Tier: fees.DefaultTier, MakerRate: 0.0025, TakerRate: 0.0025, FeeCurrency: fees.InQuote, Source: fees.SourceManual},
+			{ExchangeID: hitbtc.ID, Tier: fees.DefaultTier, Symbol: "BTCUSDT", MakerRate: 0.002, TakerRate: 0.0025, FeeCurrency: fees.InQuote, Source: fees.SourceSynced},
+			{ExchangeID: hitbtc.ID, Tier: fees.DefaultTier, Symbol: "ETHBTC", MakerRate: 0.0005, TakerRate: 0.0005, FeeCurrency: fees.InBase, Source: fees.SourceManual},
+			// No longer listed
+			{ExchangeID: hitbtc.ID, Tier: fees.DefaultTier, Symbol: "DOGEBTC", MakerRate: 0.001, TakerRate: 0.0025, FeeCurrency: fees.InQuote, Source: fees.SourceSynced},
+		}
+		for _, schedule := range schedules {
+			require.NoError(t, testDB.DB.Create(schedule).Error)
+		}
+		return hitbtc
+	}
+
+	loadSchedules := func(t *testing.T, exchangeID uint) map[string]models.FeeSchedule {
+		var schedules []models.FeeSchedule
+		require.NoError(t, testDB.DB.Where("exchange_id = ?", exchangeID).Find(&schedules).Error)
+		bySymbol := make(map[string]models.FeeSchedule, len(schedules))
+		for _, schedule := range schedules {
+			bySymbol[schedule.Tier+"/"+schedule.Symbol] = schedule
+		}
+		return bySymbol
+	}
+
+	t.Run("SyncStoresSymbolFees", func(t *testing.T) {
+		hitbtc := setup(t)
+
+		results, err := service.SyncFees(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, market.FeeSyncResult{Exchange: "hitbtc", Created: 1, Updated: 1, Removed: 1, Manual: 1}, results[0])
+
+		schedules := loadSchedules(t, hitbtc.ID)
+		assert.Len(t, schedules, 4)
+
+		btcusdt := schedules["default/BTCUSDT"]
+		assert.Equal(t, 0.001, btcusdt.MakerRate)
+		assert.Equal(t, 0.0025, btcusdt.TakerRate)
+		assert.Equal(t, fees.InQuote, btcusdt.FeeCurrency)
+		assert.NotNil(t, btcusdt.SyncedAt)
+
+		xembtc := schedules["default/XEMBTC"]
+		assert.Equal(t, fees.SourceSynced, xembtc.Source)
+		assert.Equal(t, fees.InQuote, xembtc.FeeCurrency, "HitBTC charges its BTC pairs in BTC")
+
+		ethbtc := schedules["default/ETHBTC"]
+		assert.Equal(t, 0.0005, ethbtc.MakerRate, "manual schedules are not synced")
+		assert.Equal(t, fees.InBase, ethbtc.FeeCurrency)
+
+		assert.NotContains(t, schedules, "default/DOGEBTC")
+		assert.Equal(t, 0.0025, schedules["default/"].MakerRate, "the exchange-wide schedule is left alone")
+
+		results, err = service.SyncFees(ctx, "hitbtc")
+		require.NoError(t, err)
+		assert.Equal(t, market.FeeSyncResult{Exchange: "hitbtc", Manual: 1, Unchanged: 2}, results[0])
+	})
+
+	t.Run("UnknownExchange", func(t *testing.T) {
+		setup(t)
+		_, err := service.SyncFees(ctx, "nope")
+		assert.ErrorIs(t, err, market.ErrUnknownExchange)
+	})
+
+	t.Run("LookupPrefersTheAccountTier", func(t *testing.T) {
+		hitbtc := setup(t)
+		vip := &models.FeeSchedule{ExchangeID: hitbtc.ID, Tier: "vip1", MakerRate: 0.0012, TakerRate: 0.002, FeeCurrency: fees.InQuote, Source: fees.SourceManual}
+		require.NoError(t, testDB.DB.Create(vip).Error)
+
+		schedule, err := service.FeeSchedule(ctx, hitbtc.ID, "vip1", "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, "vip1", schedule.Tier)
+
+		schedule, err = service.FeeSchedule(ctx, hitbtc.ID, "vip2", "BTCUSDT")
+		require.NoError(t, err)
+		assert.Equal(t, fees.DefaultTier, schedule.Tier)
+		assert.Equal(t, "BTCUSDT", schedule.Symbol)
+
+		schedule, err = service.FeeSchedule(ctx, hitbtc.ID, "", "LTCBTC")
+		require.NoError(t, err)
+		assert.Equal(t, fees.DefaultTier, schedule.Tier)
+		assert.Empty(t, schedule.Symbol)
+
+		_, err = service.FeeSchedule(ctx, hitbtc.ID+1, "", "BTCUSDT")
+		assert.ErrorIs(t, err, market.ErrNoFeeSchedule)
+	})
+
+	t.Run("AccountFeesFollowTheStandardSchedule", func(t *testing.T) {
+		setup(t)
+		binance := &models.Exchange{Name: "Binance", Code: "binance", IsActive: true}
+		require.NoError(t, testDB.DB.Create(binance).Error)
+		standard := &models.FeeSchedule{ExchangeID: binance.ID, Tier: fees.DefaultTier, MakerRate: 0.001, TakerRate: 0.001,
+			FeeCurrency: fees.InReceived, NativeCurrency: "BNB", NativeDiscount: 0.25, Source: fees.SourceManual}
+		require.NoError(t, testDB.DB.Create(standard).Error)
+
+		tier, err := service.StoreAccountFees(ctx, binance.ID, &exchange.AccountFees{Tier: "vip1", Maker: 0.0009, Taker: 0.001})
+		require.NoError(t, err)
+		assert.Equal(t, "vip1", tier)
+		vip := loadSchedules(t, binance.ID)["vip1/"]
+		assert.Equal(t, 0.0009, vip.MakerRate)
+		assert.Equal(t, fees.InReceived, vip.FeeCurrency)
+		assert.Equal(t, 0.25, vip.NativeDiscount)
+		assert.Equal(t, fees.SourceSynced, vip.Source)
+
+		_, err = service.StoreAccountFees(ctx, binance.ID, &exchange.AccountFees{Tier: "vip1", Maker: 0.0009, Taker: 0.001, FeeCurrency: "BNB"})
+		require.NoError(t, err)
+		vip = loadSchedules(t, binance.ID)["vip1/"]
+		assert.Equal(t, fees.InNative, vip.FeeCurrency)
+		assert.Equal(t, "BNB", vip.NativeCurrency)
+		assert.InDelta(t, 0.000675, fees.FromModel(&vip).Rate(false), 1e-12)
+
+		tier, err = service.StoreAccountFees(ctx, binance.ID, &exchange.AccountFees{Maker: 0.0005, Taker: 0.0005})
+		require.NoError(t, err)
+		assert.Equal(t, fees.DefaultTier, tier, "fees without a tier are not stored")
+		assert.Len(t, loadSchedules(t, binance.ID), 2)
+	})
+}
+
+func TestAPIKeyService_SyncFeeTiers(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	_, server := newFakeBinance(t)
+	ctx := context.Background()
+
+	binance := &models.Exchange{Name: "Binance", Code: "binance", IsActive: true, APIUrl: server.URL}
+	require.NoError(t, env.testDB.DB.Create(binance).Error)
+	binanceKey, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+		ExchangeID: binance.ID,
+		Name:       "Binance",
+		APIKey:     testAPIKey,
+		APISecret:  testAPISecret,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fees.DefaultTier, binanceKey.FeeTier)
+	// HitBTC does not report account fees
+	hitbtcKey := env.createKey(t, "HitBTC")
+
+	result, err := env.service.SyncFeeTiers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &services.FeeTierSyncResult{Synced: 1, Unreported: 1}, result)
+
+	key, err := env.service.Get(ctx, env.ownerWS, binanceKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "vip1", key.FeeTier)
+	key, err = env.service.Get(ctx, env.ownerWS, hitbtcKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fees.DefaultTier, key.FeeTier)
+
+	var schedule models.FeeSchedule
+	require.NoError(t, env.testDB.DB.Where("exchange_id = ? AND tier = ?", binance.ID, "vip1").First(&schedule).Error)
+	assert.Equal(t, 0.001, schedule.TakerRate)
+	assert.Equal(t, fees.InNative, schedule.FeeCurrency)
+
+	t.Run("tiers can be set by hand", func(t *testing.T) {
+		tier := " vip3 "
+		updated, err := env.service.Update(ctx, env.ownerWS, hitbtcKey.ID, &services.UpdateAPIKeyRequest{FeeTier: &tier})
+		require.NoError(t, err)
+		assert.Equal(t, "vip3", updated.FeeTier)
+
+		empty := ""
+		_, err = env.service.Update(ctx, env.ownerWS, hitbtcKey.ID, &services.UpdateAPIKeyRequest{FeeTier: &empty})
+		assert.Error(t, err)
+	})
+
+	t.Run("rejected keys are reported to the pool and skipped after their test", func(t *testing.T) {
+		rejected, err := env.service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+			ExchangeID: binance.ID,
+			Name:       "Rejected",
+			APIKey:     "unknown-key-0123456789",
+			APISecret:  testAPISecret,
+		})
+		require.NoError(t, err)
+
+		result, err := env.service.SyncFeeTiers(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+		assert.Equal(t, &services.FeeTierSyncResult{Synced: 1, Unreported: 1, Failed: 1}, result)
+		info, err := env.service.Get(ctx, env.ownerWS, rejected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, keypool.StateOpen, info.Health.State)
+
+		tested, err := env.service.Test(ctx, env.ownerWS, rejected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, services.APIKeyTestAuthFailed, tested.Status)
+		result, err = env.service.SyncFeeTiers(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &services.FeeTierSyncResult{Synced: 1, Unreported: 1}, result)
+	})
+}
@@ -0,0 +1,430 @@
+package unit_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/hitbtc"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamRequest struct {
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+	ID     int64                  `json:"id"`
+}
+
+// fakeHitBTCStream accepts WebSocket connections and hands each one to the
+// test's script for its endpoint
+type fakeHitBTCStream struct {
+	t *testing.T
+	// ctx ends the connections when the test ends; hijacked connections
+	// outlive their request context
+	ctx     context.Context
+	mu      sync.Mutex
+	scripts map[string]func(conn *fakeStreamConn)
+	count   map[string]int
+}
+
+type fakeStreamConn struct {
+	t     *testing.T
+	ws    *websocket.Conn
+	ctx   context.Context
+	index int
+}
+
+func newFakeHitBTCStream(t *testing.T) (*fakeHitBTCStream, *httptest.Server) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeHitBTCStream{t: t, ctx: ctx, scripts: make(map[string]func(*fakeStreamConn)), count: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Cleanup(cancel)
+	return fake, server
+}
+
+func (f *fakeHitBTCStream) handle(endpoint string, script func(conn *fakeStreamConn)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts[endpoint] = script
+}
+
+func (f *fakeHitBTCStream) connections(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count[endpoint]
+}
+
+func (f *fakeHitBTCStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/3/ws/")
+	f.mu.Lock()
+	script, ok := f.scripts[endpoint]
+	f.count[endpoint]++
+	index := f.count[endpoint]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.CloseNow()
+	script(&fakeStreamConn{t: f.t, ws: ws, ctx: f.ctx, index: index})
+}
+
+// expect reads the next request and checks its method
+func (c *fakeStreamConn) expect(method string) streamRequest {
+	var request streamRequest
+	require.NoError(c.t, wsjson.Read(c.ctx, c.ws, &request))
+	require.Equal(c.t, method, request.Method)
+	return request
+}
+
+// reply answers a request with a result
+func (c *fakeStreamConn) reply(request streamRequest, result interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "result": result, "id": request.ID})
+}
+
+func (c *fakeStreamConn) send(message interface{}) {
+	_ = wsjson.Write(c.ctx, c.ws, message)
+}
+
+func (c *fakeStreamConn) sendRaw(message string) {
+	_ = c.ws.Write(c.ctx, websocket.MessageText, []byte(message))
+}
+
+// drain keeps reading, which answers pings, until the client disconnects
+func (c *fakeStreamConn) drain() []streamRequest {
+	var requests []streamRequest
+	for {
+		var request streamRequest
+		if err := wsjson.Read(c.ctx, c.ws, &request); err != nil {
+			return requests
+		}
+		requests = append(requests, request)
+	}
+}
+
+func streamClient(server *httptest.Server, credentials *exchange.Credentials) *hitbtc.Client {
+	return hitbtc.New(exchange.Config{
+		StreamURL:         "ws" + strings.TrimPrefix(server.URL, "http") + "/api/3/ws",
+		Credentials:       credentials,
+		Timeout:           time.Second,
+		Heartbeat:         50 * time.Millisecond,
+		MaxReconnectDelay: 20 * time.Millisecond,
+	})
+}
+
+// nextEvent returns the next event that is not a connection status
+func nextEvent(t *testing.T, events <-chan exchange.StreamEvent) exchange.StreamEvent {
+	t.Helper()
+	for {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "stream closed")
+			if status, ok := event.(exchange.StreamStatus); ok && status.State != exchange.StreamResyncing {
+				continue
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a stream event")
+			return nil
+		}
+	}
+}
+
+// waitForStatus skips events until a status with the state arrives
+func waitForStatus(t *testing.T, events <-chan exchange.StreamEvent, state string) exchange.StreamStatus {
+	t.Helper()
+	for {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "stream closed")
+			if status, ok := event.(exchange.StreamStatus); ok && status.State == state {
+				return status
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for stream state %s", state)
+		}
+	}
+}
+
+func TestHitBTCStream_MarketData(t *testing.T) {
+	fake, server := newFakeHitBTCStream(t)
+	resubscribed := make(chan streamRequest, 1)
+	fake.handle("public", func(conn *fakeStreamConn) {
+		ticker := conn.expect("subscribe")
+		assert.Equal(t, "ticker/1s", ticker.Params["ch"])
+		conn.reply(ticker, map[string]interface{}{"ch": "ticker/1s", "subscriptions": []string{"BTCUSDT"}})
+		trades := conn.expect("subscribe")
+		assert.Equal(t, "trades", trades.Params["ch"])
+		conn.reply(trades, map[string]interface{}{"ch": "trades", "subscriptions": []string{"BTCUSDT"}})
+		book := conn.expect("subscribe")
+		assert.Equal(t, "orderbook/full", book.Params["ch"])
+		assert.Equal(t, []interface{}{"ETHBTC"}, book.Params["symbols"])
+		conn.reply(book, map[string]interface{}{"ch": "orderbook/full", "subscriptions": []string{"ETHBTC"}})
+
+		conn.sendRaw(`{"ch":"ticker/1s","data":{"BTCUSDT":{"t":1685620800123,"a":"30080.88","A":"0.12","b":"30079.51","B":"0.05","c":"30080.00","o":"29900.00","h":"30569.14","l":"29350.00","v":"1532.87101","q":"46002710.4121","p":"180.00","P":"0.6","L":1182694927}}}`)
+		conn.sendRaw(`{"ch":"trades","snapshot":{"BTCUSDT":[{"t":1685620799000,"i":1555634969,"p":"30079.90","q":"0.01000","s":"buy"}]}}`)
+		conn.sendRaw(`{"ch":"orderbook/full","snapshot":{"ETHBTC":{"t":1685620800000,"s":10,"a":[["0.063412","1.5"],["0.063420","2"]],"b":[["0.063401","0.8"],["0.063390","3"]]}}}`)
+		conn.sendRaw(`{"ch":"orderbook/full","update":{"ETHBTC":{"t":1685620800100,"s":11,"a":[["0.063412","0"]],"b":[["0.063405","0.4"]]}}}`)
+		// Sequence 12 is lost
+		conn.sendRaw(`{"ch":"orderbook/full","update":{"ETHBTC":{"t":1685620800300,"s":13,"a":[["0.063500","1"]],"b":[]}}}`)
+		conn.sendRaw(`{"ch":"orderbook/full","update":{"ETHBTC":{"t":1685620800400,"s":14,"a":[["0.063600","1"]],"b":[]}}}`)
+
+		unsubscribe := conn.expect("unsubscribe")
+		conn.reply(unsubscribe, map[string]interface{}{"ch": "orderbook/full", "subscriptions": []string{}})
+		resubscribe := conn.expect("subscribe")
+		resubscribed <- resubscribe
+		conn.reply(resubscribe, map[string]interface{}{"ch": "orderbook/full", "subscriptions": []string{"ETHBTC"}})
+		conn.sendRaw(`{"ch":"orderbook/full","snapshot":{"ETHBTC":{"t":1685620800500,"s":20,"a":[["0.063420","2"],["0.063500","1"]],"b":[["0.063405","0.4"]]}}}`)
+		conn.sendRaw(`{"ch":"orderbook/full","update":{"ETHBTC":{"t":1685620800600,"s":21,"a":[],"b":[["0.063401","1.1"]]}}}`)
+		conn.drain()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := streamClient(server, nil).Stream(ctx, exchange.Subscription{
+		Tickers:    []string{"BTCUSDT"},
+		Trades:     []string{"BTCUSDT"},
+		OrderBooks: []string{"ETHBTC"},
+	})
+	require.NoError(t, err)
+	waitForStatus(t, events, exchange.StreamConnected)
+
+	ticker, ok := nextEvent(t, events).(exchange.TickerUpdate)
+	require.True(t, ok)
+	assert.Equal(t, "BTCUSDT", ticker.Symbol)
+	assert.Equal(t, 30079.51, ticker.Bid)
+	assert.Equal(t, 30080.0, ticker.Last)
+	assert.Equal(t, time.Date(2023, 6, 1, 12, 0, 0, 123000000, time.UTC), ticker.Timestamp)
+
+	trades, ok := nextEvent(t, events).(exchange.TradeUpdate)
+	require.True(t, ok)
+	assert.Equal(t, []exchange.Trade{{
+		ID: "1555634969", Price: 30079.9, Quantity: 0.01, Side: "buy",
+		Timestamp: time.Date(2023, 6, 1, 11, 59, 59, 0, time.UTC),
+	}}, trades.Trades)
+
+	var book exchange.OrderBook
+	snapshot, ok := nextEvent(t, events).(exchange.OrderBookUpdate)
+	require.True(t, ok)
+	assert.True(t, snapshot.Snapshot)
+	book.Apply(&snapshot)
+	update, ok := nextEvent(t, events).(exchange.OrderBookUpdate)
+	require.True(t, ok)
+	assert.Equal(t, int64(11), update.Sequence)
+	book.Apply(&update)
+	assert.Equal(t, []exchange.PriceLevel{{Price: 0.06342, Quantity: 2}}, book.Asks)
+	assert.Equal(t, []exchange.PriceLevel{{Price: 0.063405, Quantity: 0.4}, {Price: 0.063401, Quantity: 0.8}, {Price: 0.06339, Quantity: 3}}, book.Bids)
+
+	// The gap triggers a resync; updates until the new snapshot are dropped
+	status, ok := nextEvent(t, events).(exchange.StreamStatus)
+	require.True(t, ok)
+	assert.Equal(t, exchange.StreamResyncing, status.State)
+	assert.Equal(t, "orderbook/full/ETHBTC", status.Channel)
+	assert.Contains(t, status.Err.Error(), "from sequence 11 to 13")
+	select {
+	case request := <-resubscribed:
+		assert.Equal(t, []interface{}{"ETHBTC"}, request.Params["symbols"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("the book was not resubscribed")
+	}
+
+	snapshot, ok = nextEvent(t, events).(exchange.OrderBookUpdate)
+	require.True(t, ok)
+	assert.True(t, snapshot.Snapshot)
+	assert.Equal(t, int64(20), snapshot.Sequence)
+	book.Apply(&snapshot)
+	update, ok = nextEvent(t, events).(exchange.OrderBookUpdate)
+	require.True(t, ok)
+	book.Apply(&update)
+	assert.Equal(t, []exchange.PriceLevel{{Price: 0.06342, Quantity: 2}, {Price: 0.0635, Quantity: 1}}, book.Asks)
+	assert.Equal(t, []exchange.PriceLevel{{Price: 0.063405, Quantity: 0.4}, {Price: 0.063401, Quantity: 1.1}}, book.Bids)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestHitBTCStream_Reconnect(t *testing.T) {
+	fake, server := newFakeHitBTCStream(t)
+	fake.handle("public", func(conn *fakeStreamConn) {
+		request := conn.expect("subscribe")
+		conn.reply(request, map[string]interface{}{"ch": "ticker/1s", "subscriptions": []string{"BTCUSDT"}})
+		conn.sendRaw(`{"ch":"ticker/1s","data":{"BTCUSDT":{"t":1685620800000,"c":"` + strconv.Itoa(30000+conn.index) + `"}}}`)
+
+		switch conn.index {
+		case 1:
+			// Dropped by the exchange
+			_ = conn.ws.Close(websocket.StatusGoingAway, "restart")
+		case 2:
+			// Stops reading, so pings go unanswered and the client drops the connection
+			time.Sleep(500 * time.Millisecond)
+		default:
+			conn.drain()
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := streamClient(server, nil).Stream(ctx, exchange.Subscription{Tickers: []string{"BTCUSDT"}})
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		waitForStatus(t, events, exchange.StreamConnected)
+		ticker, ok := nextEvent(t, events).(exchange.TickerUpdate)
+		require.True(t, ok)
+		assert.Equal(t, float64(30000+i), ticker.Last, "subscriptions are restored")
+		if i < 3 {
+			status := waitForStatus(t, events, exchange.StreamDisconnected)
+			assert.Error(t, status.Err)
+		}
+	}
+	assert.Equal(t, 3, fake.connections("public"))
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestHitBTCStream_OrderReports(t *testing.T) {
+	fake, server := newFakeHitBTCStream(t)
+	fake.handle("trading", func(conn *fakeStreamConn) {
+		login := conn.expect("login")
+		assert.Equal(t, "HS256", login.Params["type"])
+		assert.Equal(t, testAPIKey, login.Params["api_key"])
+		mac := hmac.New(sha256.New, []byte(testAPISecret))
+		mac.Write([]byte(strconv.FormatInt(int64(login.Params["timestamp"].(float64)), 10)))
+		if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(login.Params["signature"].(string))) {
+			conn.send(map[string]interface{}{"jsonrpc": "2.0", "error": map[string]interface{}{"code": 1002, "message": "Authorization is required or has been failed"}, "id": login.ID})
+			return
+		}
+		conn.reply(login, true)
+		subscribe := conn.expect("spot_subscribe")
+		conn.reply(subscribe, map[string]interface{}{"result": true})
+
+		conn.sendRaw(`{"jsonrpc":"2.0","method":"spot_orders","params":[{"id":828680665,"client_order_id":"f4307c6e507e49019907c917b6d7a084","symbol":"BTCUSDT","side":"buy","status":"new","type":"limit","time_in_force":"GTC","quantity":"0.01000","quantity_cumulative":"0","price":"29500.00","post_only":true,"created_at":"2023-06-01T12:00:01.185Z","updated_at":"2023-06-01T12:00:01.185Z","report_type":"status"}]}`)
+		conn.sendRaw(`{"jsonrpc":"2.0","method":"spot_order","params":{"id":828680665,"client_order_id":"f4307c6e507e49019907c917b6d7a084","symbol":"BTCUSDT","side":"buy","status":"partiallyFilled","type":"limit","time_in_force":"GTC","quantity":"0.01000","quantity_cumulative":"0.00400","price":"29500.00","price_average":"29500.00","post_only":true,"created_at":"2023-06-01T12:00:01.185Z","updated_at":"2023-06-01T12:02:00.042Z","report_type":"trade","trade_id":1361977606,"trade_quantity":"0.00400","trade_price":"29500.00","trade_fee":"0.118","trade_taker":false}}`)
+		conn.drain()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("reports and fills", func(t *testing.T) {
+		events, err := streamClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret}).Stream(ctx, exchange.Subscription{Orders: true})
+		require.NoError(t, err)
+		waitForStatus(t, events, exchange.StreamConnected)
+
+		report, ok := nextEvent(t, events).(exchange.OrderReport)
+		require.True(t, ok)
+		assert.Equal(t, exchange.OrderStatusNew, report.Order.Status)
+		assert.Nil(t, report.Fill)
+
+		report, ok = nextEvent(t, events).(exchange.OrderReport)
+		require.True(t, ok)
+		assert.Equal(t, exchange.OrderStatusPartiallyFilled, report.Order.Status)
+		assert.Equal(t, 0.004, report.Order.FilledQuantity)
+		require.NotNil(t, report.Fill)
+		assert.Equal(t, exchange.Fill{
+			TradeID:   "1361977606",
+			Price:     29500,
+			Quantity:  0.004,
+			Fee:       0.118,
+			Taker:     false,
+			Timestamp: time.Date(2023, 6, 1, 12, 2, 0, 42000000, time.UTC),
+		}, *report.Fill)
+	})
+
+	t.Run("rejected credentials are not retried", func(t *testing.T) {
+		events, err := streamClient(server, &exchange.Credentials{APIKey: testAPIKey, APISecret: "wrong"}).Stream(ctx, exchange.Subscription{Orders: true})
+		require.NoError(t, err)
+
+		status := waitForStatus(t, events, exchange.StreamDisconnected)
+		assert.ErrorIs(t, status.Err, exchange.ErrAuthFailed)
+		select {
+		case _, ok := <-events:
+			assert.False(t, ok, "the stream ends")
+		case <-time.After(5 * time.Second):
+			t.Fatal("the stream kept reconnecting")
+		}
+	})
+
+	t.Run("order reports need credentials", func(t *testing.T) {
+		_, err := streamClient(server, nil).Stream(ctx, exchange.Subscription{Orders: true})
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+	})
+}
+
+func TestOrderBook_Apply(t *testing.T) {
+	book := exchange.OrderBook{}
+	book.Apply(&exchange.OrderBookUpdate{
+		Symbol:   "ETHBTC",
+		Snapshot: true,
+		Bids:     []exchange.PriceLevel{{Price: 1, Quantity: 1}, {Price: 2, Quantity: 1}},
+		Asks:     []exchange.PriceLevel{{Price: 4, Quantity: 1}, {Price: 3, Quantity: 1}},
+	})
+	assert.Equal(t, 2.0, book.Bids[0].Price, "bids are sorted best first")
+	assert.Equal(t, 3.0, book.Asks[0].Price, "asks are sorted best first")
+
+	book.Apply(&exchange.OrderBookUpdate{
+		Symbol: "ETHBTC",
+		Bids:   []exchange.PriceLevel{{Price: 2, Quantity: 0}, {Price: 1, Quantity: 5}},
+		Asks:   []exchange.PriceLevel{{Price: 2.5, Quantity: 1}},
+	})
+	assert.Equal(t, []exchange.PriceLevel{{Price: 1, Quantity: 5}}, book.Bids)
+	assert.Equal(t, []exchange.PriceLevel{{Price: 2.5, Quantity: 1}, {Price: 3, Quantity: 1}, {Price: 4, Quantity: 1}}, book.Asks)
+
+	book.Apply(&exchange.OrderBookUpdate{Symbol: "ETHBTC", Snapshot: true})
+	assert.Empty(t, book.Bids, "a snapshot replaces the book")
+}
+
+func TestHitBTCStream_RecordedOrderReports(t *testing.T) {
+	_, streamURL := serveCassette(t, loadCassette(t, "hitbtc_trading"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := hitbtc.New(exchange.Config{
+		StreamURL:   streamURL,
+		Credentials: &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret},
+		Timeout:     time.Second,
+	})
+	events, err := client.Stream(ctx, exchange.Subscription{Orders: true})
+	require.NoError(t, err)
+	waitForStatus(t, events, exchange.StreamConnected)
+
+	var statuses []string
+	var filled float64
+	for range 3 {
+		report, ok := nextEvent(t, events).(exchange.OrderReport)
+		require.True(t, ok)
+		assert.Equal(t, recordedOrderID, report.Order.ClientOrderID)
+		statuses = append(statuses, report.Order.Status)
+		if report.Fill != nil {
+			filled += report.Fill.Quantity
+		}
+	}
+	assert.Equal(t, []string{exchange.OrderStatusNew, exchange.OrderStatusPartiallyFilled, exchange.OrderStatusFilled}, statuses)
+	assert.InDelta(t, 0.01, filled, 1e-12, "the fills add up to the order")
+
+	cancel()
+	for range events {
+	}
+}
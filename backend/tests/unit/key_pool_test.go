@@ -0,0 +1,226 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/keypool"
+	"trader/internal/models"
+	"trader/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPool(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	newPool := func() *keypool.Pool {
+		return keypool.New(keypool.Config{
+			ErrorWindow:     time.Minute,
+			BreakerCooldown: time.Minute,
+			Now:             func() time.Time { return now },
+		})
+	}
+	authErr := fmt.Errorf("hitbtc: 1002 Authorization failed: %w", exchange.ErrAuthFailed)
+
+	t.Run("equal keys take turns", func(t *testing.T) {
+		pool := newPool()
+		var chosen []uint
+		for i := 0; i < 4; i++ {
+			id, err := pool.Select([]uint{1, 2}, 1)
+			require.NoError(t, err)
+			chosen = append(chosen, id)
+			now = now.Add(time.Second)
+		}
+		assert.Equal(t, []uint{1, 2, 1, 2}, chosen)
+	})
+
+	t.Run("keys with more budget left are preferred", func(t *testing.T) {
+		pool := newPool()
+		pool.UpdateBudget(1, keypool.Budget{Used: 900, Limit: 1000, ResetAt: now.Add(time.Minute)})
+		pool.UpdateBudget(2, keypool.Budget{Used: 100, Limit: 1000, ResetAt: now.Add(time.Minute)})
+
+		id, err := pool.Select([]uint{1, 2}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), id)
+
+		_, err = pool.Select([]uint{1}, 200)
+		assert.ErrorIs(t, err, keypool.ErrNoKeyAvailable, "not enough weight left")
+
+		now = now.Add(time.Minute)
+		_, err = pool.Select([]uint{1}, 200)
+		assert.NoError(t, err, "the budget resets with the exchange's window")
+	})
+
+	t.Run("keys with recent errors are avoided", func(t *testing.T) {
+		pool := newPool()
+		pool.Report(1, 1, errors.New("timeout"))
+		pool.Report(1, 1, errors.New("timeout"))
+		pool.Report(1, 1, nil)
+		pool.Report(2, 1, nil)
+
+		id, err := pool.Select([]uint{1, 2}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), id)
+		assert.Equal(t, keypool.StateDegraded, pool.Health(1).State)
+
+		now = now.Add(2 * time.Minute)
+		assert.Equal(t, keypool.StateHealthy, pool.Health(1).State, "errors expire with the window")
+	})
+
+	t.Run("auth errors trip the breaker", func(t *testing.T) {
+		pool := newPool()
+		pool.Report(1, 1, authErr)
+
+		health := pool.Health(1)
+		assert.Equal(t, keypool.StateOpen, health.State)
+		require.NotNil(t, health.OpenUntil)
+		id, err := pool.Select([]uint{1, 2}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), id)
+		_, err = pool.Select([]uint{1}, 1)
+		assert.ErrorIs(t, err, keypool.ErrNoKeyAvailable)
+
+		// After the cooldown a single trial request is let through
+		now = now.Add(time.Minute)
+		assert.Equal(t, keypool.StateHalfOpen, pool.Health(1).State)
+		id, err = pool.Select([]uint{1}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), id)
+		_, err = pool.Select([]uint{1}, 1)
+		assert.ErrorIs(t, err, keypool.ErrNoKeyAvailable, "only one trial at a time")
+
+		// A failed trial doubles the cooldown
+		pool.Report(1, 1, authErr)
+		now = now.Add(time.Minute)
+		_, err = pool.Select([]uint{1}, 1)
+		assert.ErrorIs(t, err, keypool.ErrNoKeyAvailable)
+		now = now.Add(time.Minute)
+		_, err = pool.Select([]uint{1}, 1)
+		require.NoError(t, err)
+
+		pool.Report(1, 1, nil)
+		assert.Equal(t, keypool.StateHealthy, pool.Health(1).State)
+	})
+
+	t.Run("healthy keys win over trials", func(t *testing.T) {
+		pool := newPool()
+		pool.Report(1, 1, authErr)
+		now = now.Add(time.Minute)
+
+		id, err := pool.Select([]uint{1, 2}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), id)
+	})
+}
+
+func TestAPIKeyService_Acquire(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	markTested := func(id uint, trade bool) {
+		require.NoError(t, env.testDB.DB.Model(&models.APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"can_read": true, "can_trade": trade, "test_status": services.APIKeyTestOK,
+		}).Error)
+	}
+	untested := env.createKey(t, "Untested")
+	reader := env.createKey(t, "Reader")
+	traderA := env.createKey(t, "Trader A")
+	traderB := env.createKey(t, "Trader B")
+	markTested(reader.ID, false)
+	markTested(traderA.ID, true)
+	markTested(traderB.ID, true)
+
+	acquire := func(req services.AcquireAPIKeyRequest) (*services.APIKeyLease, error) {
+		req.ExchangeID = env.exchange.ID
+		return env.service.Acquire(ctx, env.ownerWS, &req)
+	}
+
+	t.Run("trading needs a key tested with trade rights", func(t *testing.T) {
+		seen := map[uint]bool{}
+		for i := 0; i < 4; i++ {
+			lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade, Weight: 1})
+			require.NoError(t, err)
+			assert.Equal(t, testAPISecret, lease.Credentials.APISecret)
+			assert.Equal(t, "hitbtc", lease.Exchange.Code)
+			seen[lease.KeyID] = true
+			lease.Report(nil)
+		}
+		assert.Equal(t, map[uint]bool{traderA.ID: true, traderB.ID: true}, seen)
+
+		var stored models.APIKey
+		require.NoError(t, env.testDB.DB.First(&stored, traderA.ID).Error)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("untested keys can read", func(t *testing.T) {
+		seen := map[uint]bool{}
+		for i := 0; i < 8; i++ {
+			lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionRead, Weight: 1})
+			require.NoError(t, err)
+			seen[lease.KeyID] = true
+		}
+		assert.True(t, seen[untested.ID])
+		assert.True(t, seen[reader.ID])
+	})
+
+	t.Run("rejected keys are skipped", func(t *testing.T) {
+		lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade, PinnedKeyID: &traderA.ID})
+		require.NoError(t, err)
+		lease.Report(fmt.Errorf("rejected: %w", exchange.ErrAuthFailed))
+
+		for i := 0; i < 3; i++ {
+			lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade})
+			require.NoError(t, err)
+			assert.Equal(t, traderB.ID, lease.KeyID)
+		}
+
+		info, err := env.service.Get(ctx, env.ownerWS, traderA.ID)
+		require.NoError(t, err)
+		require.NotNil(t, info.Health)
+		assert.Equal(t, keypool.StateOpen, info.Health.State)
+	})
+
+	t.Run("pinned keys are not replaced", func(t *testing.T) {
+		_, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade, PinnedKeyID: &traderA.ID})
+		assert.ErrorIs(t, err, services.ErrNoAPIKeyAvailable)
+
+		_, err = acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade, PinnedKeyID: &reader.ID})
+		assert.ErrorIs(t, err, services.ErrNoAPIKeyAvailable, "the pinned key has no trade rights")
+
+		lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade, PinnedKeyID: &traderB.ID})
+		require.NoError(t, err)
+		assert.Equal(t, traderB.ID, lease.KeyID)
+	})
+
+	t.Run("inactive and withdrawal keys are never chosen", func(t *testing.T) {
+		require.NoError(t, env.testDB.DB.Model(&models.APIKey{}).Where("id = ?", traderB.ID).Update("can_withdraw", true).Error)
+		_, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionTrade})
+		assert.ErrorIs(t, err, services.ErrNoAPIKeyAvailable)
+
+		inactive := false
+		_, err = env.service.Update(ctx, env.ownerWS, reader.ID, &services.UpdateAPIKeyRequest{IsActive: &inactive})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			lease, err := acquire(services.AcquireAPIKeyRequest{Permission: exchange.PermissionRead})
+			require.NoError(t, err)
+			assert.Equal(t, untested.ID, lease.KeyID)
+		}
+	})
+
+	t.Run("viewers cannot trade", func(t *testing.T) {
+		viewer := env.testDB.CreateTestUser(t, "pool-viewer@example.com", "Pool", "Viewer")
+		_, err := env.orgService.AddMember(ctx, env.ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: services.OrgRoleViewer})
+		require.NoError(t, err)
+		viewerWS, err := env.orgService.Workspace(ctx, env.organization.ID, viewer.ID)
+		require.NoError(t, err)
+
+		_, err = env.service.Acquire(ctx, viewerWS, &services.AcquireAPIKeyRequest{ExchangeID: env.exchange.ID, Permission: exchange.PermissionTrade})
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+	})
+}
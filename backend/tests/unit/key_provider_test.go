@@ -0,0 +1,374 @@
+package unit_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/secrets"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeVaultToken = "s.test-vault-token"
+	fakeVaultKey   = "trader-api-keys"
+)
+
+// fakeVault implements the transit engine endpoints used by secrets.VaultTransit
+type fakeVault struct {
+	mu            sync.Mutex
+	keys          map[int]cipher.AEAD
+	minDecryption int
+	requests      map[string]int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	vault := &fakeVault{keys: make(map[int]cipher.AEAD), minDecryption: 1, requests: make(map[string]int)}
+	vault.rotate(t)
+
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func (v *fakeVault) rotate(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[len(v.keys)+1] = aead
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != fakeVaultToken {
+		v.reply(w, http.StatusForbidden, nil, "permission denied")
+		return
+	}
+	operation, ok := strings.CutPrefix(r.URL.Path, "/v1/transit/")
+	if !ok || !strings.HasSuffix(operation, "/"+fakeVaultKey) {
+		v.reply(w, http.StatusNotFound, nil, "no handler for route")
+		return
+	}
+	operation = strings.TrimSuffix(operation, "/"+fakeVaultKey)
+	v.requests[operation]++
+
+	var body map[string]string
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			v.reply(w, http.StatusBadRequest, nil, "invalid json")
+			return
+		}
+	}
+
+	switch operation {
+	case "keys":
+		versions := make(map[string]int64, len(v.keys))
+		for version := range v.keys {
+			versions[strconv.Itoa(version)] = 1700000000
+		}
+		v.reply(w, http.StatusOK, map[string]interface{}{
+			"latest_version":         len(v.keys),
+			"min_decryption_version": v.minDecryption,
+			"keys":                   versions,
+		}, "")
+	case "encrypt":
+		plaintext, err := base64.StdEncoding.DecodeString(body["plaintext"])
+		if err != nil {
+			v.reply(w, http.StatusBadRequest, nil, "plaintext must be base64")
+			return
+		}
+		v.reply(w, http.StatusOK, map[string]interface{}{"ciphertext": v.encrypt(plaintext), "key_version": len(v.keys)}, "")
+	case "decrypt", "rewrap":
+		plaintext, err := v.decrypt(body["ciphertext"])
+		if err != nil {
+			v.reply(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		if operation == "decrypt" {
+			v.reply(w, http.StatusOK, map[string]interface{}{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}, "")
+			return
+		}
+		v.reply(w, http.StatusOK, map[string]interface{}{"ciphertext": v.encrypt(plaintext)}, "")
+	default:
+		v.reply(w, http.StatusNotFound, nil, "unsupported operation")
+	}
+}
+
+func (v *fakeVault) encrypt(plaintext []byte) string {
+	version := len(v.keys)
+	aead := v.keys[version]
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(sealed))
+}
+
+func (v *fakeVault) decrypt(ciphertext string) ([]byte, error) {
+	var version int
+	var encoded string
+	if _, err := fmt.Sscanf(strings.Replace(ciphertext, ":", " ", 2), "vault v%d %s", &version, &encoded); err != nil {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	aead, ok := v.keys[version]
+	if !ok || version < v.minDecryption {
+		return nil, fmt.Errorf("ciphertext or signature version is disallowed by policy (too old)")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cipher: message authentication failed")
+	}
+	return plaintext, nil
+}
+
+func (v *fakeVault) reply(w http.ResponseWriter, status int, data interface{}, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if message != "" {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{message}})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func TestKeyProvider_File(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := helpers.GetTestConfig()
+	cfg.Encryption.Provider = "file"
+
+	t.Run("single key is version 1", func(t *testing.T) {
+		cfg.Encryption.KeysFile = filepath.Join(dir, "single")
+		require.NoError(t, os.WriteFile(cfg.Encryption.KeysFile, []byte(masterKeyV2+"\n"), 0600))
+
+		provider, err := secrets.NewKeyProvider(cfg.Encryption)
+		require.NoError(t, err)
+		versions, err := provider.Versions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, versions)
+	})
+
+	t.Run("key ring lines", func(t *testing.T) {
+		cfg.Encryption.KeysFile = filepath.Join(dir, "ring")
+		content := fmt.Sprintf("# rotated 2026-10\n1:%s\n2:%s\n", masterKeyV1, masterKeyV2)
+		require.NoError(t, os.WriteFile(cfg.Encryption.KeysFile, []byte(content), 0600))
+
+		provider, err := secrets.NewKeyProvider(cfg.Encryption)
+		require.NoError(t, err)
+		active, err := provider.ActiveVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, active)
+
+		// Keys wrapped by the env provider stay readable from the file
+		envCfg := helpers.GetTestConfig()
+		envProvider, err := secrets.NewKeyProvider(envCfg.Encryption)
+		require.NoError(t, err)
+		_, wrapped, version, err := secrets.NewDataKey(ctx, envProvider)
+		require.NoError(t, err)
+		_, err = secrets.OpenDataKey(ctx, provider, wrapped, version)
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		cfg.Encryption.KeysFile = filepath.Join(dir, "missing")
+		_, err := secrets.NewKeyProvider(cfg.Encryption)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestKeyProvider_Keystore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	passphrase := []byte("correct horse battery staple")
+
+	keys, err := secrets.ParseMasterKeys(fmt.Sprintf("1:%s,2:%s", masterKeyV1, masterKeyV2))
+	require.NoError(t, err)
+	require.NoError(t, secrets.WriteKeystore(path, passphrase, keys))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), masterKeyV1)
+	assert.NotContains(t, string(content), masterKeyV2)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, append(passphrase, '\n'), 0600))
+
+	cfg := helpers.GetTestConfig()
+	cfg.Encryption.Provider = "keystore"
+	cfg.Encryption.KeystorePath = path
+	cfg.Encryption.KeystorePassphrase = "ignored when the file is set"
+	cfg.Encryption.KeystorePassphraseFile = passphraseFile
+
+	provider, err := secrets.NewKeyProvider(cfg.Encryption)
+	require.NoError(t, err)
+	versions, err := provider.Versions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions)
+
+	ring, err := secrets.ParseKeyRing(fmt.Sprintf("1:%s,2:%s", masterKeyV1, masterKeyV2), 0)
+	require.NoError(t, err)
+	_, wrapped, version, err := secrets.NewDataKey(ctx, ring)
+	require.NoError(t, err)
+	_, err = secrets.OpenDataKey(ctx, provider, wrapped, version)
+	assert.NoError(t, err, "the keystore holds the same keys")
+
+	_, err = secrets.ReadKeystore(path, []byte("wrong passphrase"))
+	assert.ErrorIs(t, err, secrets.ErrDecryption)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"format":2}`), 0600))
+	_, err = secrets.ReadKeystore(path, passphrase)
+	assert.ErrorIs(t, err, secrets.ErrInvalidKeystore)
+}
+
+func TestKeyProvider_VaultTransit(t *testing.T) {
+	vault, server := newFakeVault(t)
+	ctx := context.Background()
+
+	cfg := helpers.GetTestConfig()
+	cfg.Encryption.Provider = "vault"
+	cfg.Encryption.VaultAddress = server.URL
+	cfg.Encryption.VaultToken = fakeVaultToken
+	cfg.Encryption.VaultMount = "transit"
+	cfg.Encryption.VaultKeyName = fakeVaultKey
+
+	provider, err := secrets.NewKeyProvider(cfg.Encryption)
+	require.NoError(t, err)
+
+	dataKey, wrapped, version, err := secrets.NewDataKey(ctx, provider)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.True(t, strings.HasPrefix(string(wrapped), "vault:v1:"))
+
+	sealed, err := dataKey.Seal([]byte("secret"), "field")
+	require.NoError(t, err)
+	opened, err := secrets.OpenDataKey(ctx, provider, wrapped, version)
+	require.NoError(t, err)
+	plaintext, err := opened.Open(sealed, "field")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	t.Run("stored version must match the ciphertext", func(t *testing.T) {
+		_, err := secrets.OpenDataKey(ctx, provider, wrapped, 2)
+		assert.ErrorIs(t, err, secrets.ErrUnknownKeyVersion)
+	})
+
+	t.Run("tampered ciphertexts fail to decrypt", func(t *testing.T) {
+		tampered := []byte("vault:v1:" + base64.StdEncoding.EncodeToString(make([]byte, 40)))
+		_, err := secrets.OpenDataKey(ctx, provider, tampered, 1)
+		assert.ErrorIs(t, err, secrets.ErrDecryption)
+	})
+
+	t.Run("rotation in vault", func(t *testing.T) {
+		vault.rotate(t)
+
+		active, err := provider.ActiveVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, active)
+
+		rewrapped, newVersion, err := provider.RewrapKey(ctx, wrapped, version)
+		require.NoError(t, err)
+		assert.Equal(t, 2, newVersion)
+		_, err = secrets.OpenDataKey(ctx, provider, rewrapped, newVersion)
+		require.NoError(t, err)
+
+		vault.mu.Lock()
+		vault.minDecryption = 2
+		vault.mu.Unlock()
+		versions, err := provider.Versions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []int{2}, versions)
+		_, err = secrets.OpenDataKey(ctx, provider, wrapped, version)
+		assert.ErrorIs(t, err, secrets.ErrDecryption)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("s.revoked\n"), 0600))
+		invalidCfg := cfg.Encryption
+		invalidCfg.VaultTokenFile = tokenFile
+
+		invalid, err := secrets.NewKeyProvider(invalidCfg)
+		require.NoError(t, err)
+		_, _, _, err = secrets.NewDataKey(ctx, invalid)
+		assert.ErrorIs(t, err, secrets.ErrVaultRequest)
+		assert.Contains(t, err.Error(), "permission denied")
+	})
+}
+
+func TestAPIKeyService_VaultProvider(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	vault, server := newFakeVault(t)
+	ctx := context.Background()
+
+	cfg := helpers.GetTestConfig()
+	cfg.Encryption.Provider = "vault"
+	cfg.Encryption.VaultAddress = server.URL
+	cfg.Encryption.VaultToken = fakeVaultToken
+	cfg.Encryption.VaultKeyName = fakeVaultKey
+	service, err := services.NewAPIKeyService(env.testDB.DB, cfg)
+	require.NoError(t, err)
+
+	key, err := service.Create(ctx, env.ownerWS, &services.CreateAPIKeyRequest{
+		ExchangeID: env.exchange.ID,
+		Name:       "Vault",
+		APIKey:     testAPIKey,
+		APISecret:  testAPISecret,
+	})
+	require.NoError(t, err)
+
+	credentials, err := service.Credentials(ctx, env.ownerWS, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, testAPISecret, credentials.APISecret)
+
+	vault.rotate(t)
+	result, err := service.RotateMasterKey(ctx, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TargetVersion)
+	assert.Equal(t, 1, result.Rewrapped)
+	assert.Equal(t, 1, vault.requests["rewrap"])
+
+	var stored models.APIKey
+	require.NoError(t, env.testDB.DB.First(&stored, key.ID).Error)
+	assert.Equal(t, 2, stored.MasterKeyVersion)
+	credentials, err = service.Decrypt(ctx, &stored)
+	require.NoError(t, err)
+	assert.Equal(t, testAPIKey, credentials.APIKey)
+
+	status, err := service.RotationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, status.LoadedVersions)
+	assert.Zero(t, status.Pending)
+}
@@ -0,0 +1,138 @@
+package unit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/secrets"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	masterKeyV1 = "test-encryption-key-32-chars-xyz"
+	masterKeyV2 = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+)
+
+func TestKeyRing(t *testing.T) {
+	ctx := context.Background()
+	ring, err := secrets.ParseKeyRing(fmt.Sprintf("1:%s, 2:%s", masterKeyV1, masterKeyV2), 0)
+	require.NoError(t, err)
+	active, err := ring.ActiveVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, active)
+	versions, err := ring.Versions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions)
+
+	pinned, err := secrets.ParseKeyRing(fmt.Sprintf("1:%s,2:%s", masterKeyV1, masterKeyV2), 1)
+	require.NoError(t, err)
+	active, err = pinned.ActiveVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, active)
+
+	_, wrapped, version, err := secrets.NewDataKey(ctx, pinned)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	rewrapped, newVersion, err := ring.RewrapKey(ctx, wrapped, version)
+	require.NoError(t, err)
+	assert.Equal(t, 2, newVersion)
+	_, err = secrets.OpenDataKey(ctx, ring, rewrapped, newVersion)
+	require.NoError(t, err)
+	_, err = secrets.OpenDataKey(ctx, ring, rewrapped, version)
+	assert.ErrorIs(t, err, secrets.ErrDecryption)
+
+	for _, spec := range []string{"", "1", "x:" + masterKeyV1, "1:" + masterKeyV1 + ",1:" + masterKeyV2, "1:short"} {
+		_, err := secrets.ParseKeyRing(spec, 0)
+		assert.Error(t, err, spec)
+	}
+	_, err = secrets.ParseKeyRing("1:"+masterKeyV1, 3)
+	assert.ErrorIs(t, err, secrets.ErrUnknownKeyVersion)
+}
+
+func TestAPIKeyService_RotateMasterKey(t *testing.T) {
+	env := setupAPIKeyTest(t)
+	defer env.testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	ids := make([]uint, 0, 5)
+	for i := 0; i < 5; i++ {
+		ids = append(ids, env.createKey(t, fmt.Sprintf("Key %d", i)).ID)
+	}
+	require.NoError(t, env.service.Delete(ctx, env.ownerWS, ids[4]))
+
+	cfg := helpers.GetTestConfig()
+	cfg.Encryption.MasterKeys = fmt.Sprintf("1:%s,2:%s", masterKeyV1, masterKeyV2)
+	rotated, err := services.NewAPIKeyService(env.testDB.DB, cfg)
+	require.NoError(t, err)
+
+	status, err := rotated.RotationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.ActiveVersion)
+	assert.Equal(t, int64(4), status.Pending, "shredded keys are not rotated")
+
+	t.Run("interrupted rotation resumes", func(t *testing.T) {
+		interrupted, cancel := context.WithCancel(ctx)
+		result, err := rotated.RotateMasterKey(interrupted, 2, func(*services.KeyRotationResult) { cancel() })
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, result.Batches)
+		assert.Equal(t, 2, result.Rewrapped)
+
+		status, err := rotated.RotationStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), status.Pending)
+
+		result, err = rotated.RotateMasterKey(ctx, 2, nil)
+		require.NoError(t, err)
+		assert.True(t, result.Resumed)
+		assert.Equal(t, 2, result.Rewrapped)
+		assert.Empty(t, result.Failed)
+	})
+
+	t.Run("keys decrypt with their new version only", func(t *testing.T) {
+		var key models.APIKey
+		require.NoError(t, env.testDB.DB.First(&key, ids[0]).Error)
+		assert.Equal(t, 2, key.MasterKeyVersion)
+
+		credentials, err := rotated.Decrypt(ctx, &key)
+		require.NoError(t, err)
+		assert.Equal(t, testAPISecret, credentials.APISecret)
+
+		_, err = env.service.Decrypt(ctx, &key)
+		assert.ErrorIs(t, err, secrets.ErrUnknownKeyVersion)
+	})
+
+	t.Run("progress is recorded in the audit log", func(t *testing.T) {
+		var actions []string
+		err := env.testDB.DB.Model(&models.AuditLog{}).Where("resource = ? AND resource_id = ?", "master_keys", "2").
+			Order("id ASC").Pluck("action", &actions).Error
+		require.NoError(t, err)
+		assert.Equal(t, []string{"rotate_start", "rotate_batch", "rotate_start", "rotate_batch", "rotate_complete"}, actions)
+
+		result, err := rotated.RotateMasterKey(ctx, 2, nil)
+		require.NoError(t, err)
+		assert.False(t, result.Resumed)
+		assert.Zero(t, result.Batches)
+	})
+
+	t.Run("keys that cannot be unwrapped are reported", func(t *testing.T) {
+		require.NoError(t, env.testDB.DB.Model(&models.APIKey{}).Where("id = ?", ids[1]).
+			UpdateColumns(map[string]interface{}{"master_key_version": 7}).Error)
+
+		result, err := rotated.RotateMasterKey(ctx, 2, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Failed, 1)
+		assert.Equal(t, ids[1], result.Failed[0].APIKeyID)
+		assert.Equal(t, 7, result.Failed[0].Version)
+
+		status, err := rotated.RotationStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), status.KeysByVersion[7])
+	})
+}
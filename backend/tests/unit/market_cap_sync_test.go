@@ -0,0 +1,218 @@
+package unit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"trader/internal/config"
+	"trader/internal/market"
+	"trader/internal/models"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var coinGeckoFixture = filepath.Join("testdata", "coingecko", "markets.json")
+
+// staticMarketCaps is a MarketCapProvider with fixed market caps
+type staticMarketCaps []market.MarketCap
+
+func (s staticMarketCaps) MarketCaps(_ context.Context, limit int) ([]market.MarketCap, error) {
+	return s[:min(limit, len(s))], nil
+}
+
+func TestMarketCapProviders(t *testing.T) {
+	ctx := context.Background()
+	expected := []market.MarketCap{
+		{Symbol: "BTC", Name: "Bitcoin", MarketCap: 1321456789012, Rank: 1},
+		{Symbol: "ETH", Name: "Ethereum", MarketCap: 415678901234, Rank: 2},
+		{Symbol: "USDT", Name: "Tether", MarketCap: 112345678901, Rank: 3},
+		{Symbol: "BNB", Name: "BNB", MarketCap: 86789012345, Rank: 4},
+		{Symbol: "SOL", Name: "Solana", MarketCap: 67890123456, Rank: 5},
+		{Symbol: "ETH", Name: "Bridged Ether (StarkGate)", MarketCap: 345678901, Rank: 120},
+	}
+
+	t.Run("CoinGecko", func(t *testing.T) {
+		var query url.Values
+		var apiKey string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v3/coins/markets" {
+				http.NotFound(w, r)
+				return
+			}
+			query = r.URL.Query()
+			apiKey = r.Header.Get("x-cg-demo-api-key")
+			http.ServeFile(w, r, coinGeckoFixture)
+		}))
+		defer server.Close()
+
+		provider := market.NewCoinGeckoProvider(server.URL+"/api/v3/", "demo-key")
+		caps, err := provider.MarketCaps(ctx, 250)
+		require.NoError(t, err)
+		assert.Equal(t, expected, caps)
+		assert.Equal(t, "usd", query.Get("vs_currency"))
+		assert.Equal(t, "market_cap_desc", query.Get("order"))
+		assert.Equal(t, "250", query.Get("per_page"))
+		assert.Equal(t, "1", query.Get("page"))
+		assert.Equal(t, "demo-key", apiKey)
+
+		caps, err = provider.MarketCaps(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, expected[:2], caps)
+	})
+
+	t.Run("CoinGeckoError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"status":{"error_code":429,"error_message":"You've exceeded the Rate Limit"}}`))
+		}))
+		defer server.Close()
+
+		_, err := market.NewCoinGeckoProvider(server.URL, "").MarketCaps(ctx, 100)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "429")
+	})
+
+	t.Run("File", func(t *testing.T) {
+		caps, err := market.NewFileProvider(coinGeckoFixture).MarketCaps(ctx, 100)
+		require.NoError(t, err)
+		assert.Equal(t, expected, caps)
+
+		_, err = market.NewFileProvider(filepath.Join(t.TempDir(), "missing.json")).MarketCaps(ctx, 100)
+		assert.Error(t, err)
+	})
+
+	t.Run("FromConfig", func(t *testing.T) {
+		provider, err := market.NewMarketCapProvider(config.MarketConfig{MarketCapProvider: market.ProviderFile, MarketCapFile: coinGeckoFixture})
+		require.NoError(t, err)
+		assert.IsType(t, &market.FileProvider{}, provider)
+
+		provider, err = market.NewMarketCapProvider(config.MarketConfig{MarketCapProvider: market.ProviderCoinGecko, MarketCapURL: "https://api.coingecko.com/api/v3"})
+		require.NoError(t, err)
+		assert.IsType(t, &market.CoinGeckoProvider{}, provider)
+
+		_, err = market.NewMarketCapProvider(config.MarketConfig{MarketCapProvider: market.ProviderFile})
+		assert.Error(t, err)
+		_, err = market.NewMarketCapProvider(config.MarketConfig{MarketCapProvider: "coinmarketcap"})
+		assert.Error(t, err)
+	})
+}
+
+func TestMarketCapSync(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	ctx := context.Background()
+
+	cfg := helpers.GetTestConfig()
+	cfg.Market.TopCoins = 3
+
+	setup := func(t *testing.T) {
+		testDB.ClearTables(t)
+		coins := []*models.Coin{
+			{Symbol: "BTC", Name: "Bitcoin", IsActive: true, TopRanked: true, SortOrder: 1},
+			// Created by the pair sync, which names coins after their symbol
+			{Symbol: "ETH", Name: "ETH", IsActive: true},
+			{Symbol: "BNB", Name: "Binance Coin", IsActive: true, TopRanked: true, SortOrder: 3},
+			{Symbol: "SOL", Name: "Solana", IsActive: true, SortOrder: 6},
+			{Symbol: "XEM", Name: "NEM", IsActive: true, TopRanked: true, MarketCap: 250000000, SortOrder: 2},
+		}
+		for _, coin := range coins {
+			require.NoError(t, testDB.DB.Create(coin).Error)
+		}
+	}
+
+	loadCoins := func(t *testing.T) map[string]models.Coin {
+		var coins []models.Coin
+		require.NoError(t, testDB.DB.Find(&coins).Error)
+		bySymbol := make(map[string]models.Coin, len(coins))
+		for _, coin := range coins {
+			bySymbol[coin.Symbol] = coin
+		}
+		return bySymbol
+	}
+
+	t.Run("RefreshesRanking", func(t *testing.T) {
+		setup(t)
+		service := market.NewService(testDB.DB, cfg)
+
+		result, err := service.SyncMarketCaps(ctx, market.NewFileProvider(coinGeckoFixture))
+		require.NoError(t, err)
+		assert.Equal(t, 4, result.Ranked)
+		assert.Equal(t, []string{"XEM"}, result.Unranked)
+		assert.Equal(t, []string{"ETH"}, result.Entered)
+		assert.Equal(t, []string{"BNB", "XEM"}, result.DroppedOut)
+
+		coins := loadCoins(t)
+		assert.Equal(t, int64(1321456789012), coins["BTC"].MarketCap)
+		assert.Equal(t, 1, coins["BTC"].SortOrder)
+		assert.True(t, coins["BTC"].TopRanked)
+		assert.Nil(t, coins["BTC"].DroppedOutAt)
+		require.NotNil(t, coins["BTC"].MarketCapUpdatedAt)
+
+		// The largest token with the symbol wins and fills in the name
+		assert.Equal(t, 2, coins["ETH"].MarketCapRank)
+		assert.Equal(t, "Ethereum", coins["ETH"].Name)
+		assert.True(t, coins["ETH"].TopRanked)
+
+		assert.Equal(t, 4, coins["BNB"].MarketCapRank)
+		assert.Equal(t, "Binance Coin", coins["BNB"].Name)
+		assert.False(t, coins["BNB"].TopRanked)
+		require.NotNil(t, coins["BNB"].DroppedOutAt)
+
+		assert.Equal(t, 5, coins["SOL"].SortOrder)
+		assert.False(t, coins["SOL"].TopRanked)
+		assert.Nil(t, coins["SOL"].DroppedOutAt)
+
+		// Unranked coins sort after the ranked ones
+		assert.Equal(t, 0, coins["XEM"].MarketCapRank)
+		assert.Equal(t, int64(0), coins["XEM"].MarketCap)
+		assert.Equal(t, 121, coins["XEM"].SortOrder)
+		assert.False(t, coins["XEM"].TopRanked)
+		require.NotNil(t, coins["XEM"].DroppedOutAt)
+
+		// Nothing enters or leaves on the next refresh
+		result, err = service.SyncMarketCaps(ctx, market.NewFileProvider(coinGeckoFixture))
+		require.NoError(t, err)
+		assert.Empty(t, result.Entered)
+		assert.Empty(t, result.DroppedOut)
+		assert.Equal(t, coins["BNB"].DroppedOutAt.Unix(), loadCoins(t)["BNB"].DroppedOutAt.Unix())
+	})
+
+	t.Run("ReenteringClearsFlag", func(t *testing.T) {
+		setup(t)
+		service := market.NewService(testDB.DB, cfg)
+		_, err := service.SyncMarketCaps(ctx, market.NewFileProvider(coinGeckoFixture))
+		require.NoError(t, err)
+
+		result, err := service.SyncMarketCaps(ctx, staticMarketCaps{
+			{Symbol: "BTC", Name: "Bitcoin", MarketCap: 1000, Rank: 1},
+			{Symbol: "BNB", Name: "BNB", MarketCap: 900, Rank: 2},
+			{Symbol: "ETH", Name: "Ethereum", MarketCap: 800, Rank: 3},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"BNB"}, result.Entered)
+		assert.Empty(t, result.DroppedOut)
+
+		coins := loadCoins(t)
+		assert.True(t, coins["BNB"].TopRanked)
+		assert.Nil(t, coins["BNB"].DroppedOutAt)
+		assert.Equal(t, 4, coins["SOL"].SortOrder)
+	})
+
+	t.Run("EmptyResponseKeepsRanking", func(t *testing.T) {
+		setup(t)
+		service := market.NewService(testDB.DB, cfg)
+
+		emptyFile := filepath.Join(t.TempDir(), "markets.json")
+		require.NoError(t, os.WriteFile(emptyFile, []byte("[]"), 0o600))
+
+		_, err := service.SyncMarketCaps(ctx, market.NewFileProvider(emptyFile))
+		assert.ErrorIs(t, err, market.ErrNoMarketCaps)
+		assert.True(t, loadCoins(t)["BTC"].TopRanked)
+	})
+}
@@ -0,0 +1,188 @@
+package unit_test
+
+import (
+	"errors"
+	"testing"
+
+	"trader/internal/exchange"
+	"trader/internal/models"
+	"trader/internal/orders"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderSizing(t *testing.T) {
+	// BTCUSDT as Binance lists it
+	btcusdt := orders.FiltersFromSymbol(exchange.Symbol{
+		Symbol:            "BTCUSDT",
+		PriceTick:         0.01,
+		QuantityStep:      0.00001,
+		PricePrecision:    2,
+		QuantityPrecision: 5,
+		MinQuantity:       0.00001,
+		MaxQuantity:       9000,
+		MinNotional:       5,
+	})
+
+	tests := []struct {
+		name     string
+		filters  orders.Filters
+		intent   orders.Intent
+		quantity float64
+		price    float64
+		reason   string
+	}{
+		{
+			name:     "LimitBuyByNotional",
+			filters:  btcusdt,
+			intent:   orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 100, Price: 41234.567},
+			price:    41234.56,
+			quantity: 0.00242,
+		},
+		{
+			name:     "LimitSellRoundsPriceUp",
+			filters:  btcusdt,
+			intent:   orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 0.123456789, Price: 41234.561},
+			price:    41234.57,
+			quantity: 0.12345,
+		},
+		{
+			name:     "PriceOnTickIsKept",
+			filters:  btcusdt,
+			intent:   orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 20, Price: 0.3},
+			price:    0.3,
+			quantity: 20,
+		},
+		{
+			name:     "MarketBuyUsesReferencePrice",
+			filters:  btcusdt,
+			intent:   orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeMarket, Notional: 50, Price: 40000},
+			quantity: 0.00125,
+		},
+		{
+			name:     "QuantityStepWithoutFloatNoise",
+			filters:  orders.Filters{QuantityStep: 0.1, QuantityPrecision: 1, PriceTick: 0.01, PricePrecision: 2},
+			intent:   orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 0.3, Price: 1},
+			price:    1,
+			quantity: 0.3,
+		},
+		{
+			name:     "StoredPairFilters",
+			filters:  orders.FiltersFromPair(&models.TradingPair{PricePrecision: 6, QuantityPrecision: 4, MinOrderSize: 0.001}),
+			intent:   orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 0.01, Price: 0.0523459},
+			price:    0.052345,
+			quantity: 0.191,
+		},
+		{
+			name:    "BelowMinNotional",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 4.99, Price: 40000},
+			reason:  orders.RejectBelowMinNotional,
+		},
+		{
+			name:    "RoundsToZero",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 0.000009, Price: 40000},
+			reason:  orders.RejectBelowStep,
+		},
+		{
+			name:    "BelowMinQuantity",
+			filters: orders.Filters{QuantityStep: 0.001, QuantityPrecision: 3, MinQuantity: 0.01},
+			intent:  orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeMarket, Quantity: 0.0099, Price: 100},
+			reason:  orders.RejectBelowMinQuantity,
+		},
+		{
+			name:    "AboveMaxQuantity",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 9000.5, Price: 40000},
+			reason:  orders.RejectAboveMaxQuantity,
+		},
+		{
+			name:    "PriceBelowTick",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 0.001},
+			reason:  orders.RejectInvalidPrice,
+		},
+		{
+			name:    "MissingPrice",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeMarket, Notional: 100},
+			reason:  orders.RejectInvalidPrice,
+		},
+		{
+			name:    "NotionalAndQuantity",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 100, Quantity: 1, Price: 40000},
+			reason:  orders.RejectInvalidSize,
+		},
+		{
+			name:    "InvalidSide",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: "long", Type: exchange.OrderTypeLimit, Notional: 100, Price: 40000},
+			reason:  orders.RejectInvalidSide,
+		},
+		{
+			name:    "InvalidType",
+			filters: btcusdt,
+			intent:  orders.Intent{Side: exchange.OrderSideBuy, Type: "stop", Notional: 100, Price: 40000},
+			reason:  orders.RejectInvalidType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := orders.Size(tt.filters, tt.intent)
+			if tt.reason != "" {
+				var rejection *orders.Rejection
+				require.True(t, errors.As(err, &rejection), "expected a rejection, got %v", err)
+				assert.Equal(t, tt.reason, rejection.Reason)
+				assert.ErrorIs(t, err, exchange.ErrInvalidOrder)
+				assert.Nil(t, request)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.intent.Side, request.Side)
+			assert.Equal(t, tt.intent.Type, request.Type)
+			assert.Equal(t, tt.quantity, request.Quantity)
+			assert.Equal(t, tt.price, request.Price)
+		})
+	}
+
+	t.Run("BuyNeverExceedsNotional", func(t *testing.T) {
+		for _, price := range []float64{0.13, 1.1, 3.3, 19.99, 41234.56, 99999.99} {
+			request, err := orders.Size(btcusdt, orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 1000, Price: price})
+			require.NoError(t, err)
+			assert.LessOrEqual(t, request.Quantity*request.Price, 1000.0+1e-9, "price %v", price)
+		}
+	})
+
+	t.Run("RejectionExplains", func(t *testing.T) {
+		_, err := orders.Size(btcusdt, orders.Intent{Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Notional: 4.99, Price: 40000})
+		assert.EqualError(t, err, "order rejected: order value 4.8 is below the minimum order value 5")
+	})
+
+	t.Run("PassesOrderOptions", func(t *testing.T) {
+		request, err := orders.Size(btcusdt, orders.Intent{
+			Symbol:        "BTCUSDT",
+			Side:          exchange.OrderSideBuy,
+			Type:          exchange.OrderTypeLimit,
+			Notional:      100,
+			Price:         40000,
+			TimeInForce:   exchange.TimeInForceGTC,
+			PostOnly:      true,
+			ClientOrderID: "dca-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &exchange.OrderRequest{
+			Symbol:        "BTCUSDT",
+			Side:          exchange.OrderSideBuy,
+			Type:          exchange.OrderTypeLimit,
+			Quantity:      0.0025,
+			Price:         40000,
+			TimeInForce:   exchange.TimeInForceGTC,
+			PostOnly:      true,
+			ClientOrderID: "dca-1",
+		}, request)
+	})
+}
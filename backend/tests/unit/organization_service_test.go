@@ -0,0 +1,231 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOrganizationTest(t *testing.T) (*services.OrganizationService, *helpers.TestDB) {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+	return services.NewOrganizationService(testDB.DB, helpers.GetTestConfig()), testDB
+}
+
+func TestOrganizationService_Members(t *testing.T) {
+	organizationService, testDB := setupOrganizationTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	owner := testDB.CreateTestUser(t, "owner@example.com", "Owner", "User")
+	admin := testDB.CreateTestUser(t, "admin@example.com", "Admin", "User")
+	viewer := testDB.CreateTestUser(t, "viewer@example.com", "Viewer", "User")
+
+	organization, err := organizationService.Create(ctx, owner.ID, &services.CreateOrganizationRequest{Name: "  Desk  "})
+	require.NoError(t, err)
+	assert.Equal(t, "Desk", organization.Name)
+	assert.Equal(t, services.OrgRoleOwner, organization.Role)
+
+	ownerWS, err := organizationService.Workspace(ctx, organization.ID, owner.ID)
+	require.NoError(t, err)
+
+	_, err = organizationService.AddMember(ctx, ownerWS, &services.AddOrganizationMemberRequest{Email: admin.Email, Role: services.OrgRoleAdmin})
+	require.NoError(t, err)
+	_, err = organizationService.AddMember(ctx, ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: services.OrgRoleViewer})
+	require.NoError(t, err)
+
+	_, err = organizationService.AddMember(ctx, ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: services.OrgRoleTrader})
+	assert.ErrorIs(t, err, services.ErrAlreadyOrganizationMember)
+	_, err = organizationService.AddMember(ctx, ownerWS, &services.AddOrganizationMemberRequest{Email: "nobody@example.com", Role: services.OrgRoleTrader})
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+	_, err = organizationService.AddMember(ctx, ownerWS, &services.AddOrganizationMemberRequest{Email: viewer.Email, Role: "janitor"})
+	assert.ErrorIs(t, err, services.ErrInvalidOrganizationRole)
+
+	members, err := organizationService.Members(ctx, ownerWS)
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+
+	t.Run("roles limit what members can do", func(t *testing.T) {
+		viewerWS, err := organizationService.Workspace(ctx, organization.ID, viewer.ID)
+		require.NoError(t, err)
+		assert.True(t, viewerWS.Can(services.OrgActionRead))
+		assert.False(t, viewerWS.Can(services.OrgActionWrite))
+
+		err = organizationService.UpdateMemberRole(ctx, viewerWS, viewer.ID, services.OrgRoleAdmin)
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+
+		adminWS, err := organizationService.Workspace(ctx, organization.ID, admin.ID)
+		require.NoError(t, err)
+		require.NoError(t, organizationService.UpdateMemberRole(ctx, adminWS, viewer.ID, services.OrgRoleTrader))
+
+		// Admins manage members but not owners
+		err = organizationService.UpdateMemberRole(ctx, adminWS, viewer.ID, services.OrgRoleOwner)
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+		err = organizationService.RemoveMember(ctx, adminWS, owner.ID)
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+		_, err = organizationService.Rename(ctx, adminWS, "Taken over")
+		assert.ErrorIs(t, err, services.ErrOrganizationForbidden)
+	})
+
+	t.Run("the last owner cannot leave", func(t *testing.T) {
+		err := organizationService.RemoveMember(ctx, ownerWS, owner.ID)
+		assert.ErrorIs(t, err, services.ErrLastOrganizationOwner)
+		err = organizationService.UpdateMemberRole(ctx, ownerWS, owner.ID, services.OrgRoleAdmin)
+		assert.ErrorIs(t, err, services.ErrLastOrganizationOwner)
+	})
+
+	t.Run("members can leave", func(t *testing.T) {
+		viewerWS, err := organizationService.Workspace(ctx, organization.ID, viewer.ID)
+		require.NoError(t, err)
+		require.NoError(t, organizationService.RemoveMember(ctx, viewerWS, viewer.ID))
+
+		_, err = organizationService.Workspace(ctx, organization.ID, viewer.ID)
+		assert.ErrorIs(t, err, services.ErrNotOrganizationMember)
+	})
+
+	t.Run("deleted organizations grant no access", func(t *testing.T) {
+		require.NoError(t, organizationService.Delete(ctx, ownerWS))
+
+		_, err := organizationService.Workspace(ctx, organization.ID, admin.ID)
+		assert.ErrorIs(t, err, services.ErrNotOrganizationMember)
+
+		var count int64
+		testDB.DB.Model(&models.AuditLog{}).Where("resource = ?", "organizations").Count(&count)
+		assert.Equal(t, int64(6), count)
+	})
+}
+
+func TestOrganizationService_WorkspaceScope(t *testing.T) {
+	organizationService, testDB := setupOrganizationTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	user := testDB.CreateTestUser(t, "scope@example.com", "Scope", "User")
+	first, err := organizationService.Create(ctx, user.ID, &services.CreateOrganizationRequest{Name: "First"})
+	require.NoError(t, err)
+	second, err := organizationService.Create(ctx, user.ID, &services.CreateOrganizationRequest{Name: "Second"})
+	require.NoError(t, err)
+
+	ws, err := organizationService.Workspace(ctx, second.ID, user.ID)
+	require.NoError(t, err)
+
+	var ids []uint
+	err = testDB.DB.Model(&models.OrganizationMember{}).Scopes(ws.Scope).Pluck("organization_id", &ids).Error
+	require.NoError(t, err)
+	assert.Equal(t, []uint{second.ID}, ids)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestAuthService_Workspaces(t *testing.T) {
+	authService, testDB, redisServer := setupAuthServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	defer redisServer.Close()
+	testDB.ClearTables(t)
+	ctx := context.Background()
+
+	organizationService := services.NewOrganizationService(testDB.DB, helpers.GetTestConfig())
+	user := createTestUserWithPassword(t, testDB, "workspace@example.com", "password123", true)
+
+	login, err := authService.Login(ctx, &services.LoginRequest{Email: user.Email, Password: "password123"})
+	require.NoError(t, err)
+	require.NotZero(t, login.OrganizationID)
+
+	claims, err := authService.ValidateToken(login.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, login.OrganizationID, claims.OrganizationID)
+
+	organizations, err := organizationService.ListForUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, organizations, 1)
+	assert.True(t, organizations[0].IsPersonal)
+	assert.Equal(t, login.OrganizationID, organizations[0].ID)
+
+	t.Run("personal workspace is reused and cannot be shared", func(t *testing.T) {
+		again, err := authService.Login(ctx, &services.LoginRequest{Email: user.Email, Password: "password123"})
+		require.NoError(t, err)
+		assert.Equal(t, login.OrganizationID, again.OrganizationID)
+
+		ws, err := organizationService.Workspace(ctx, login.OrganizationID, user.ID)
+		require.NoError(t, err)
+		_, err = organizationService.AddMember(ctx, ws, &services.AddOrganizationMemberRequest{Email: "other@example.com", Role: services.OrgRoleViewer})
+		assert.ErrorIs(t, err, services.ErrPersonalOrganization)
+		assert.ErrorIs(t, organizationService.Delete(ctx, ws), services.ErrPersonalOrganization)
+	})
+
+	t.Run("switch and refresh keep the workspace while a member", func(t *testing.T) {
+		shared, err := organizationService.Create(ctx, user.ID, &services.CreateOrganizationRequest{Name: "Shared"})
+		require.NoError(t, err)
+
+		// Only the caller's own refresh token can be revoked
+		stranger := createTestUserWithPassword(t, testDB, "stranger@example.com", "password123", true)
+		strangerLogin, err := authService.Login(ctx, &services.LoginRequest{Email: stranger.Email, Password: "password123"})
+		require.NoError(t, err)
+		for _, token := range []string{strangerLogin.RefreshToken, "not-a-token"} {
+			_, err = authService.SwitchOrganization(ctx, user.ID, shared.ID, token)
+			assert.ErrorIs(t, err, services.ErrRefreshTokenOwner)
+		}
+		assert.False(t, authService.IsTokenBlacklisted(ctx, strangerLogin.RefreshToken))
+
+		switched, err := authService.SwitchOrganization(ctx, user.ID, shared.ID, login.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, shared.ID, switched.OrganizationID)
+		assert.True(t, authService.IsTokenBlacklisted(ctx, login.RefreshToken))
+
+		refreshed, err := authService.RefreshToken(ctx, &services.RefreshTokenRequest{RefreshToken: switched.RefreshToken})
+		require.NoError(t, err)
+		assert.Equal(t, shared.ID, refreshed.OrganizationID)
+
+		owner := testDB.CreateTestUser(t, "owner@example.com", "Other", "Owner")
+		ws, err := organizationService.Workspace(ctx, shared.ID, user.ID)
+		require.NoError(t, err)
+		_, err = organizationService.AddMember(ctx, ws, &services.AddOrganizationMemberRequest{Email: owner.Email, Role: services.OrgRoleOwner})
+		require.NoError(t, err)
+		require.NoError(t, organizationService.RemoveMember(ctx, ws, user.ID))
+
+		refreshed, err = authService.RefreshToken(ctx, &services.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+		require.NoError(t, err)
+		assert.Equal(t, login.OrganizationID, refreshed.OrganizationID)
+
+		_, err = authService.SwitchOrganization(ctx, user.ID, shared.ID, "")
+		assert.ErrorIs(t, err, services.ErrNotOrganizationMember)
+	})
+}
+
+func TestOrganizationService_Erase(t *testing.T) {
+	organizationService, testDB := setupOrganizationTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	owner := testDB.CreateTestUser(t, "owner@example.com", "Owner", "User")
+	trader := testDB.CreateTestUser(t, "trader@example.com", "Trader", "User")
+
+	organization, err := organizationService.Create(ctx, owner.ID, &services.CreateOrganizationRequest{Name: "Desk"})
+	require.NoError(t, err)
+	ws, err := organizationService.Workspace(ctx, organization.ID, owner.ID)
+	require.NoError(t, err)
+	_, err = organizationService.AddMember(ctx, ws, &services.AddOrganizationMemberRequest{Email: trader.Email, Role: services.OrgRoleTrader})
+	require.NoError(t, err)
+
+	privacyService := services.NewPrivacyService(testDB.DB, helpers.GetTestConfig(), organizationService)
+	archive, err := privacyService.ExportUserData(ctx, owner.ID)
+	require.NoError(t, err)
+	files := readZipFiles(t, archive)
+	require.Contains(t, files, "organizations.json")
+	assert.Contains(t, string(files["organizations.json"]), "Desk")
+
+	_, err = privacyService.EraseUser(ctx, owner.ID, nil)
+	require.NoError(t, err)
+
+	_, err = organizationService.Workspace(ctx, organization.ID, owner.ID)
+	assert.ErrorIs(t, err, services.ErrNotOrganizationMember)
+
+	successor, err := organizationService.Workspace(ctx, organization.ID, trader.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrgRoleOwner, successor.Role)
+}
@@ -0,0 +1,173 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+
+	"trader/internal/market"
+	"trader/internal/models"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPairSync(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+
+	_, server := newRecordedHitBTC(t)
+	ctx := context.Background()
+
+	setup := func(t *testing.T) *models.Exchange {
+		testDB.ClearTables(t)
+		hitbtc := &models.Exchange{Name: "HitBTC", Code: "hitbtc", IsActive: true, APIUrl: server.URL}
+		require.NoError(t, testDB.DB.Create(hitbtc).Error)
+
+		btc := &models.Coin{Symbol: "BTC", Name: "Bitcoin", IsActive: true}
+		eth := &models.Coin{Symbol: "ETH", Name: "Ethereum", IsActive: true}
+		ltc := &models.Coin{Symbol: "LTC", Name: "Litecoin", IsActive: true}
+		doge := &models.Coin{Symbol: "DOGE", Name: "Dogecoin", IsActive: true}
+		for _, coin := range []*models.Coin{btc, eth, ltc, doge} {
+			require.NoError(t, testDB.DB.Create(coin).Error)
+		}
+
+		pairs := []*models.TradingPair{
+			{ExchangeID: hitbtc.ID, BaseCoinID: eth.ID, QuoteCoinID: btc.ID, Symbol: "ETHBTC", IsActive: true, MinOrderSize: 0.001, PricePrecision: 6, QuantityPrecision: 4},
+			{ExchangeID: hitbtc.ID, BaseCoinID: ltc.ID, QuoteCoinID: btc.ID, Symbol: "LTCBTC", IsActive: true, MinOrderSize: 0.01, PricePrecision: 6, QuantityPrecision: 2},
+			{ExchangeID: hitbtc.ID, BaseCoinID: doge.ID, QuoteCoinID: btc.ID, Symbol: "DOGEBTC", IsActive: true, MinOrderSize: 1, PricePrecision: 10, QuantityPrecision: 0},
+		}
+		for _, pair := range pairs {
+			require.NoError(t, testDB.DB.Create(pair).Error)
+		}
+		// Deleted by hand, so the sync leaves it alone
+		require.NoError(t, testDB.DB.Delete(pairs[2]).Error)
+		return hitbtc
+	}
+
+	loadPairs := func(t *testing.T, exchangeID uint) map[string]models.TradingPair {
+		var pairs []models.TradingPair
+		require.NoError(t, testDB.DB.Unscoped().Where("exchange_id = ?", exchangeID).Find(&pairs).Error)
+		bySymbol := make(map[string]models.TradingPair, len(pairs))
+		for _, pair := range pairs {
+			bySymbol[pair.Symbol] = pair
+		}
+		return bySymbol
+	}
+
+	t.Run("SyncReportsDiff", func(t *testing.T) {
+		hitbtc := setup(t)
+		service := market.NewService(testDB.DB, helpers.GetTestConfig())
+
+		results, err := service.SyncPairs(ctx, "", false)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		result := results[0]
+		assert.Equal(t, "hitbtc", result.Exchange)
+		assert.Empty(t, result.Error)
+		assert.Equal(t, []string{"USDT", "XEM"}, result.CoinsCreated)
+		assert.Equal(t, 2, result.Count(market.PairCreated))
+		assert.Equal(t, 1, result.Count(market.PairUpdated))
+		assert.Equal(t, 1, result.Count(market.PairDeactivated))
+		assert.Equal(t, 0, result.Unchanged)
+
+		lines := make([]string, 0, len(result.Changes))
+		for _, change := range result.Changes {
+			lines = append(lines, change.String())
+		}
+		assert.Equal(t, []string{
+			"+ BTCUSDT min_order_size=0.00001, max_order_size=0, price_precision=2, quantity_precision=5",
+			"~ ETHBTC min_order_size: 0.001 → 0.0001",
+			"- LTCBTC (delisted) is_active: true → false",
+			"+ XEMBTC is_active=false, min_order_size=1, max_order_size=0, price_precision=10, quantity_precision=0",
+		}, lines)
+
+		pairs := loadPairs(t, hitbtc.ID)
+		require.Len(t, pairs, 5)
+		assert.True(t, pairs["BTCUSDT"].IsActive)
+		assert.Equal(t, 5, pairs["BTCUSDT"].QuantityPrecision)
+		assert.InDelta(t, 0.0001, pairs["ETHBTC"].MinOrderSize, 1e-12)
+		assert.False(t, pairs["LTCBTC"].IsActive)
+		assert.True(t, pairs["DOGEBTC"].DeletedAt.Valid)
+		assert.True(t, pairs["DOGEBTC"].IsActive)
+
+		// Suspended symbols are stored inactive, zero precision included
+		assert.False(t, pairs["XEMBTC"].IsActive)
+		assert.Equal(t, 0, pairs["XEMBTC"].QuantityPrecision)
+
+		var xem models.Coin
+		require.NoError(t, testDB.DB.Where("symbol = ?", "XEM").First(&xem).Error)
+		assert.Equal(t, xem.ID, pairs["XEMBTC"].BaseCoinID)
+		assert.True(t, xem.IsActive)
+
+		// A second sync finds nothing to change
+		results, err = service.SyncPairs(ctx, "hitbtc", false)
+		require.NoError(t, err)
+		assert.Empty(t, results[0].Changes)
+		assert.Empty(t, results[0].CoinsCreated)
+		assert.Equal(t, 3, results[0].Unchanged)
+	})
+
+	t.Run("HaltedPairIsDeactivated", func(t *testing.T) {
+		hitbtc := setup(t)
+		service := market.NewService(testDB.DB, helpers.GetTestConfig())
+		_, err := service.SyncPairs(ctx, "", false)
+		require.NoError(t, err)
+
+		// XEMBTC resumes trading locally; the exchange still has it suspended
+		require.NoError(t, testDB.DB.Model(&models.TradingPair{}).
+			Where("exchange_id = ? AND symbol = ?", hitbtc.ID, "XEMBTC").
+			Update("is_active", true).Error)
+
+		results, err := service.SyncPairs(ctx, "", false)
+		require.NoError(t, err)
+		require.Len(t, results[0].Changes, 1)
+		assert.Equal(t, "- XEMBTC (halted) is_active: true → false", results[0].Changes[0].String())
+		assert.False(t, loadPairs(t, hitbtc.ID)["XEMBTC"].IsActive)
+	})
+
+	t.Run("DryRunChangesNothing", func(t *testing.T) {
+		hitbtc := setup(t)
+		service := market.NewService(testDB.DB, helpers.GetTestConfig())
+
+		results, err := service.SyncPairs(ctx, "hitbtc", true)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].DryRun)
+		assert.Len(t, results[0].Changes, 4)
+		assert.Equal(t, []string{"USDT", "XEM"}, results[0].CoinsCreated)
+
+		pairs := loadPairs(t, hitbtc.ID)
+		assert.Len(t, pairs, 3)
+		assert.True(t, pairs["LTCBTC"].IsActive)
+		assert.InDelta(t, 0.001, pairs["ETHBTC"].MinOrderSize, 1e-12)
+
+		var coins int64
+		require.NoError(t, testDB.DB.Model(&models.Coin{}).Count(&coins).Error)
+		assert.Equal(t, int64(4), coins)
+	})
+
+	t.Run("FailingExchangeDoesNotStopOthers", func(t *testing.T) {
+		setup(t)
+		require.NoError(t, testDB.DB.Create(&models.Exchange{Name: "Unknown", Code: "unknown", IsActive: true}).Error)
+		service := market.NewService(testDB.DB, helpers.GetTestConfig())
+
+		results, err := service.SyncPairs(ctx, "", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown")
+		require.Len(t, results, 2)
+		assert.Equal(t, "hitbtc", results[0].Exchange)
+		assert.Empty(t, results[0].Error)
+		assert.Len(t, results[0].Changes, 4)
+		assert.Equal(t, "unknown", results[1].Exchange)
+		assert.NotEmpty(t, results[1].Error)
+	})
+
+	t.Run("UnknownExchange", func(t *testing.T) {
+		setup(t)
+		service := market.NewService(testDB.DB, helpers.GetTestConfig())
+
+		_, err := service.SyncPairs(ctx, "kraken", false)
+		assert.ErrorIs(t, err, market.ErrUnknownExchange)
+	})
+}
@@ -0,0 +1,265 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trader/internal/exchange"
+	"trader/internal/exchange/connectors"
+	"trader/internal/exchange/paper"
+	"trader/internal/models"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func paperCandles() map[string][]exchange.Candle {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	candle := func(i int, high, low, closing float64) exchange.Candle {
+		return exchange.Candle{OpenTime: start.Add(time.Duration(i) * time.Minute), Open: closing, High: high, Low: low, Close: closing, Volume: 10}
+	}
+	return map[string][]exchange.Candle{
+		"BTCUSDT": {
+			candle(0, 101, 99, 100),
+			candle(1, 100, 96, 98),
+			candle(2, 97, 94, 95),
+			candle(3, 96, 93, 94),
+			candle(4, 110, 100, 108),
+		},
+	}
+}
+
+// assertBalances compares balances with a tolerance for float rounding
+func assertBalances(t *testing.T, expected map[string][2]float64, balances []exchange.Balance) {
+	t.Helper()
+	actual := make(map[string][2]float64, len(balances))
+	for _, balance := range balances {
+		actual[balance.Currency] = [2]float64{balance.Available, balance.Reserved}
+	}
+	require.Len(t, actual, len(expected), "balances %v", actual)
+	for currency, amounts := range expected {
+		assert.InDelta(t, amounts[0], actual[currency][0], 1e-9, "available %s", currency)
+		assert.InDelta(t, amounts[1], actual[currency][1], 1e-9, "reserved %s", currency)
+	}
+}
+
+func TestPaperExchange(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+	ctx := context.Background()
+
+	feed := paper.NewCandleFeed(paperCandles())
+	cfg := paper.Config{
+		Symbols: []exchange.Symbol{
+			{Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT", Active: true},
+			{Symbol: "LUNAUSDT", Base: "LUNA", Quote: "USDT"},
+		},
+		Feed:            feed,
+		InitialBalances: map[string]float64{"USDT": 10000},
+		MakerFee:        0.0005,
+		TakerFee:        0.001,
+		Slippage:        paper.FixedSlippage(0.01),
+		Fill:            paper.RatioFill(0.5),
+	}
+	credentials := &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret, KeyID: 1, OrganizationID: 1}
+	var client exchange.Connector = paper.New(testDB.DB, credentials, cfg)
+
+	balances := func(t *testing.T) []exchange.Balance {
+		t.Helper()
+		balances, err := client.Balances(ctx)
+		require.NoError(t, err)
+		return balances
+	}
+
+	t.Run("accounts open on first use", func(t *testing.T) {
+		capabilities, err := client.Capabilities(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &exchange.Capabilities{Read: true, Trade: true}, capabilities)
+		assertBalances(t, map[string][2]float64{"USDT": {10000, 0}}, balances(t))
+
+		// The same credentials stored by another workspace open another account
+		other := paper.New(testDB.DB, &exchange.Credentials{APIKey: testAPIKey, APISecret: "other", KeyID: 2, OrganizationID: 2}, cfg)
+		_, err = other.Capabilities(ctx)
+		require.NoError(t, err)
+		var accounts int64
+		require.NoError(t, testDB.DB.Model(&models.PaperAccount{}).Count(&accounts).Error)
+		assert.Equal(t, int64(2), accounts)
+
+		_, err = paper.New(testDB.DB, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret}, cfg).Balances(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed, "credentials without a stored key")
+		_, err = paper.New(testDB.DB, nil, cfg).Capabilities(ctx)
+		assert.ErrorIs(t, err, exchange.ErrAuthFailed)
+
+		symbols, err := client.Symbols(ctx)
+		require.NoError(t, err)
+		require.Len(t, symbols, 2)
+		assert.Equal(t, 0.001, symbols[0].TakerFee, "symbols carry the paper fees")
+	})
+
+	t.Run("market orders fill completely with slippage and taker fee", func(t *testing.T) {
+		order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeMarket, Quantity: 2,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusFilled, order.Status)
+		assert.Equal(t, 2.0, order.FilledQuantity)
+		assert.InDelta(t, 101, order.AveragePrice, 1e-9, "1% above the close")
+		assert.Len(t, order.ClientOrderID, 32)
+
+		// 202 USDT plus 0.1% fee
+		assertBalances(t, map[string][2]float64{"USDT": {9797.798, 0}, "BTC": {2, 0}}, balances(t))
+	})
+
+	t.Run("rejected orders change nothing", func(t *testing.T) {
+		requests := []struct {
+			req  exchange.OrderRequest
+			want error
+		}{
+			// The ask of 100 would fill it right away
+			{exchange.OrderRequest{Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 100.5, PostOnly: true}, exchange.ErrWouldTriggerImmediately},
+			{exchange.OrderRequest{Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 20000}, exchange.ErrInsufficientBalance},
+			{exchange.OrderRequest{Symbol: "BTCUSDT", Side: exchange.OrderSideSell, Type: exchange.OrderTypeMarket, Quantity: 3}, exchange.ErrInsufficientBalance},
+			{exchange.OrderRequest{Symbol: "LUNAUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 1}, exchange.ErrInvalidOrder},
+			{exchange.OrderRequest{Symbol: "DOGEUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 1}, exchange.ErrInvalidSymbol},
+		}
+		for _, tt := range requests {
+			_, err := client.PlaceOrder(ctx, &tt.req)
+			assert.ErrorIs(t, err, tt.want, "%+v", tt.req)
+			assert.ErrorIs(t, err, exchange.ErrInvalidOrder, "%+v", tt.req)
+		}
+		assertBalances(t, map[string][2]float64{"USDT": {9797.798, 0}, "BTC": {2, 0}}, balances(t))
+	})
+
+	t.Run("resting orders fill in parts as maker", func(t *testing.T) {
+		order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 95, ClientOrderID: "paper-buy",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusNew, order.Status)
+		assert.Equal(t, exchange.TimeInForceGTC, order.TimeInForce)
+		// 95 USDT plus the higher fee are reserved
+		assertBalances(t, map[string][2]float64{"USDT": {9702.703, 95.095}, "BTC": {2, 0}}, balances(t))
+
+		// The low of 96 does not reach the order
+		require.True(t, feed.Step())
+		order, err = client.GetOrder(ctx, "BTCUSDT", "paper-buy")
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusNew, order.Status)
+
+		require.True(t, feed.Step())
+		for i := 0; i < 2; i++ {
+			order, err = client.GetOrder(ctx, "BTCUSDT", "paper-buy")
+			require.NoError(t, err)
+			assert.Equal(t, exchange.OrderStatusPartiallyFilled, order.Status)
+			assert.Equal(t, 0.5, order.FilledQuantity, "one fill per quote")
+			assert.Equal(t, 95.0, order.AveragePrice)
+		}
+		// Half the reservation is freed; 47.5 USDT plus 0.05% fee are spent
+		assertBalances(t, map[string][2]float64{"USDT": {9702.72675, 47.5475}, "BTC": {2.5, 0}}, balances(t))
+
+		require.True(t, feed.Step())
+		order, err = client.GetOrder(ctx, "BTCUSDT", "paper-buy")
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusFilled, order.Status)
+		assertBalances(t, map[string][2]float64{"USDT": {9702.7505, 0}, "BTC": {3, 0}}, balances(t))
+
+		_, err = client.CancelOrder(ctx, "BTCUSDT", "paper-buy")
+		assert.ErrorIs(t, err, exchange.ErrOrderNotFound, "filled orders cannot be cancelled")
+	})
+
+	t.Run("crossing limit orders take within their price", func(t *testing.T) {
+		require.True(t, feed.Step())
+		// The bid of 108 less 1% slippage is below the limit, which caps the price
+		order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 107.5, ClientOrderID: "paper-sell",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusPartiallyFilled, order.Status)
+		assert.Equal(t, 0.5, order.FilledQuantity)
+		assert.Equal(t, 107.5, order.AveragePrice)
+		assertBalances(t, map[string][2]float64{"USDT": {9702.7505 + 53.75 - 0.05375, 0}, "BTC": {2, 0.5}}, balances(t))
+
+		order, err = client.CancelOrder(ctx, "BTCUSDT", "paper-sell")
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusCanceled, order.Status)
+		assertBalances(t, map[string][2]float64{"USDT": {9756.44675, 0}, "BTC": {2.5, 0}}, balances(t))
+	})
+
+	t.Run("IOC and FOK orders do not rest", func(t *testing.T) {
+		order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 200, TimeInForce: exchange.TimeInForceIOC,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusExpired, order.Status)
+
+		// The fill model only fills half, which a fill-or-kill order refuses
+		order, err = client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 1, Price: 100, TimeInForce: exchange.TimeInForceFOK,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, exchange.OrderStatusExpired, order.Status)
+		assert.Zero(t, order.FilledQuantity)
+
+		assertBalances(t, map[string][2]float64{"USDT": {9756.44675, 0}, "BTC": {2.5, 0}}, balances(t))
+		assert.False(t, feed.Step(), "the candles are used up")
+	})
+
+	t.Run("client order ids are unique per account", func(t *testing.T) {
+		_, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+			Symbol: "BTCUSDT", Side: exchange.OrderSideSell, Type: exchange.OrderTypeLimit, Quantity: 0.1, Price: 200, ClientOrderID: "paper-buy",
+		})
+		assert.ErrorIs(t, err, exchange.ErrInvalidOrder)
+
+		_, err = client.GetOrder(ctx, "BTCUSDT", "unknown")
+		assert.ErrorIs(t, err, exchange.ErrOrderNotFound)
+	})
+}
+
+func TestPaperExchange_ParseBalances(t *testing.T) {
+	balances, err := paper.ParseBalances(" usdt:10000, BTC:0.5 ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"USDT": 10000, "BTC": 0.5}, balances)
+
+	for _, invalid := range []string{"USDT", "USDT:lots", "USDT:-1"} {
+		_, err := paper.ParseBalances(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPaperExchange_LiveTickers(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+	_, server := newRecordedHitBTC(t)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.DB.Create(&models.Exchange{Name: "HitBTC", Code: "hitbtc", IsActive: true, APIUrl: server.URL}).Error)
+	paperExchange := &models.Exchange{Name: "Paper Trading", Code: paper.Code, IsActive: true}
+	require.NoError(t, testDB.DB.Create(paperExchange).Error)
+
+	cfg := helpers.GetTestConfig().Exchange
+	cfg.PaperMarketExchange = "hitbtc"
+	cfg.PaperInitialBalances = "USDT:50000"
+	cfg.PaperTakerFee = 0.001
+	cfg.PaperSlippage = 0
+	cfg.PaperFillRatio = 1
+	client, err := connectors.New(cfg, testDB.DB, paperExchange, &exchange.Credentials{APIKey: testAPIKey, APISecret: testAPISecret, KeyID: 1, OrganizationID: 1})
+	require.NoError(t, err)
+
+	// Buys take the recorded ask
+	order, err := client.PlaceOrder(ctx, &exchange.OrderRequest{
+		Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Type: exchange.OrderTypeMarket, Quantity: 0.1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, exchange.OrderStatusFilled, order.Status)
+	assert.InDelta(t, 30080.88, order.AveragePrice, 1e-9)
+
+	balances, err := client.Balances(ctx)
+	require.NoError(t, err)
+	assertBalances(t, map[string][2]float64{"USDT": {50000 - 3008.088 - 3.008088, 0}, "BTC": {0.1, 0}}, balances)
+
+	cfg.PaperMarketExchange = paper.Code
+	_, err = connectors.New(cfg, testDB.DB, paperExchange, nil)
+	assert.Error(t, err)
+}
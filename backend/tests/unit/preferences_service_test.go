@@ -0,0 +1,201 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/internal/utils"
+	"trader/tests/helpers"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupPreferencesTest(t *testing.T) (*services.PreferencesService, *helpers.TestDB, *miniredis.Miniredis) {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+
+	for _, symbol := range []string{"USDT", "BTC"} {
+		require.NoError(t, testDB.DB.Create(&models.Coin{Symbol: symbol, Name: symbol, IsActive: true}).Error)
+	}
+
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	return services.NewPreferencesService(testDB.DB, redisClient, helpers.GetTestConfig()), testDB, redisServer
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestPreferencesService_Get(t *testing.T) {
+	preferencesService, testDB, redisServer := setupPreferencesTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	user := testDB.CreateTestUser(t, "prefs@example.com", "Pref", "User")
+
+	prefs, err := preferencesService.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.DefaultPreferences(), prefs.Preferences)
+	assert.Zero(t, prefs.Revision)
+	assert.True(t, redisServer.Exists(fmt.Sprintf("%s%d", services.PreferencesCachePrefix, user.ID)))
+
+	t.Run("cached value is served without the database", func(t *testing.T) {
+		_, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{Timezone: stringPtr("Europe/Berlin")})
+		require.NoError(t, err)
+
+		require.NoError(t, testDB.DB.Model(&models.UserPreference{}).Where("user_id = ?", user.ID).
+			Update("data", json.RawMessage(`{"timezone":"Asia/Tokyo"}`)).Error)
+
+		prefs, err := preferencesService.Get(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Europe/Berlin", prefs.Timezone)
+
+		redisServer.FlushAll()
+		prefs, err = preferencesService.Get(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Asia/Tokyo", prefs.Timezone)
+		// Fields missing from the stored document fall back to the defaults
+		assert.Equal(t, "USDT", prefs.QuoteCurrency)
+		assert.Equal(t, []string{services.ChannelEmail}, prefs.Notifications[services.EventSecurityAlert])
+	})
+
+	t.Run("documents from a newer schema are rejected", func(t *testing.T) {
+		redisServer.FlushAll()
+		require.NoError(t, testDB.DB.Model(&models.UserPreference{}).Where("user_id = ?", user.ID).
+			Update("schema_version", services.PreferencesSchemaVersion+1).Error)
+
+		_, err := preferencesService.Get(ctx, user.ID)
+		assert.ErrorIs(t, err, services.ErrPreferencesVersion)
+	})
+}
+
+func TestPreferencesService_Update(t *testing.T) {
+	preferencesService, testDB, _ := setupPreferencesTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	user := testDB.CreateTestUser(t, "prefs@example.com", "Pref", "User")
+
+	prefs, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{
+		Timezone:      stringPtr("America/New_York"),
+		QuoteCurrency: stringPtr("btc"),
+		Notifications: map[string][]string{
+			services.EventTakeProfitFilled: {services.ChannelTelegram, services.ChannelEmail, services.ChannelTelegram},
+			services.EventDCAOrderFilled:   {},
+		},
+		DefaultStrategyPreset: stringPtr(services.StrategyPresetAggressive),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, prefs.Revision)
+	assert.Equal(t, "America/New_York", prefs.Timezone)
+	assert.Equal(t, "BTC", prefs.QuoteCurrency)
+	assert.Equal(t, []string{services.ChannelTelegram, services.ChannelEmail}, prefs.Notifications[services.EventTakeProfitFilled])
+	assert.Empty(t, prefs.Notifications[services.EventDCAOrderFilled])
+	assert.Equal(t, []string{services.ChannelEmail}, prefs.Notifications[services.EventPositionOpened])
+
+	channels, err := preferencesService.NotificationChannels(ctx, user.ID, services.EventTakeProfitFilled)
+	require.NoError(t, err)
+	assert.Equal(t, []string{services.ChannelTelegram, services.ChannelEmail}, channels)
+
+	t.Run("revision guards concurrent updates", func(t *testing.T) {
+		stale := 0
+		_, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{
+			Timezone: stringPtr("UTC"),
+			Revision: &stale,
+		})
+		assert.ErrorIs(t, err, services.ErrPreferencesConflict)
+
+		current := 1
+		prefs, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{
+			Timezone: stringPtr("UTC"),
+			Revision: &current,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, prefs.Revision)
+		assert.Equal(t, "BTC", prefs.QuoteCurrency)
+	})
+
+	t.Run("a concurrent first write is a conflict", func(t *testing.T) {
+		other := testDB.CreateTestUser(t, "racer@example.com", "Race", "User")
+
+		// The competing request creates the row after this one found none
+		const callback = "test:concurrent_preferences"
+		require.NoError(t, testDB.DB.Callback().Create().Before("gorm:create").Register(callback, func(tx *gorm.DB) {
+			if tx.Statement.Table != "user_preferences" {
+				return
+			}
+			tx.Session(&gorm.Session{NewDB: true}).Exec(
+				"INSERT INTO user_preferences (user_id, schema_version, revision, data, created_at, updated_at) VALUES (?, ?, 1, '{}', ?, ?)",
+				other.ID, services.PreferencesSchemaVersion, time.Now(), time.Now(),
+			)
+		}))
+		_, err := preferencesService.Update(ctx, other.ID, &services.UpdatePreferencesRequest{Timezone: stringPtr("UTC")})
+		require.NoError(t, testDB.DB.Callback().Create().Remove(callback))
+		assert.ErrorIs(t, err, services.ErrPreferencesConflict)
+	})
+
+	t.Run("invalid values are reported per field", func(t *testing.T) {
+		_, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{
+			Timezone:      stringPtr("Mars/Olympus"),
+			QuoteCurrency: stringPtr("XYZ"),
+			Notifications: map[string][]string{
+				"moon_landing":               {services.ChannelEmail},
+				services.EventPositionClosed: {"pigeon"},
+			},
+			DefaultStrategyPreset: stringPtr("yolo"),
+		})
+
+		var validationErrors utils.ValidationErrors
+		require.ErrorAs(t, err, &validationErrors)
+		fields := make([]string, 0, len(validationErrors))
+		for _, validationError := range validationErrors {
+			fields = append(fields, validationError.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"timezone", "quote_currency", "notifications.moon_landing",
+			"notifications.position_closed", "default_strategy_preset",
+		}, fields)
+
+		prefs, err := preferencesService.Get(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, prefs.Revision)
+	})
+}
+
+func TestPreferencesService_PersonalData(t *testing.T) {
+	preferencesService, testDB, redisServer := setupPreferencesTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	user := testDB.CreateTestUser(t, "prefs@example.com", "Pref", "User")
+	_, err := preferencesService.Update(ctx, user.ID, &services.UpdatePreferencesRequest{Timezone: stringPtr("Europe/Paris")})
+	require.NoError(t, err)
+
+	privacyService := services.NewPrivacyService(testDB.DB, helpers.GetTestConfig(), preferencesService)
+	archive, err := privacyService.ExportUserData(ctx, user.ID)
+	require.NoError(t, err)
+
+	files := readZipFiles(t, archive)
+	require.Contains(t, files, "preferences.json")
+	assert.Contains(t, string(files["preferences.json"]), "Europe/Paris")
+
+	_, err = privacyService.EraseUser(ctx, user.ID, nil)
+	require.NoError(t, err)
+
+	var count int64
+	testDB.DB.Model(&models.UserPreference{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Zero(t, count)
+	assert.Empty(t, redisServer.Keys())
+}
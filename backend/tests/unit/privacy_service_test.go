@@ -0,0 +1,189 @@
+package unit_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeDataProvider records calls made by the privacy service
+type fakeDataProvider struct {
+	exported []uint
+	erased   []uint
+}
+
+func (p *fakeDataProvider) Name() string {
+	return "trading"
+}
+
+func (p *fakeDataProvider) Export(ctx context.Context, db *gorm.DB, userID uint) (interface{}, error) {
+	p.exported = append(p.exported, userID)
+	return []map[string]string{{"symbol": "BTCUSDT"}}, nil
+}
+
+func (p *fakeDataProvider) Erase(ctx context.Context, tx *gorm.DB, userID uint) error {
+	p.erased = append(p.erased, userID)
+	return nil
+}
+
+func readZipFiles(t *testing.T, archive []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = data
+	}
+	return files
+}
+
+func TestPrivacyService_ExportUserData(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	defer testDB.TeardownTestDB(t)
+	testDB.ClearTables(t)
+	testDB.CreateTestRole(t, "trader", "Trader")
+
+	provider := &fakeDataProvider{}
+	privacyService := services.NewPrivacyService(testDB.DB, helpers.GetTestConfig(), provider)
+	auditService := services.NewAuditService(testDB.DB, helpers.GetTestConfig())
+	ctx := context.Background()
+
+	user := testDB.CreateTestUser(t, "export@example.com", "Ex", "Port", "trader")
+	other := testDB.CreateTestUser(t, "other@example.com", "Other", "User")
+
+	_, err := auditService.Log(ctx, &services.AuditEntry{UserID: &user.ID, Action: "login", Resource: "auth"})
+	require.NoError(t, err)
+	_, err = auditService.Log(ctx, &services.AuditEntry{UserID: &other.ID, Action: "login", Resource: "auth"})
+	require.NoError(t, err)
+
+	archive, err := privacyService.ExportUserData(ctx, user.ID)
+	require.NoError(t, err)
+
+	files := readZipFiles(t, archive)
+	for _, name := range []string{"manifest.json", "profile.json", "roles.json", "permissions.json", "audit_logs.json", "trading.json"} {
+		assert.Contains(t, files, name)
+	}
+
+	var profile map[string]interface{}
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "export@example.com", profile["email"])
+	assert.NotContains(t, profile, "password_hash")
+
+	var roles []map[string]interface{}
+	require.NoError(t, json.Unmarshal(files["roles.json"], &roles))
+	require.Len(t, roles, 1)
+	assert.Equal(t, "trader", roles[0]["name"])
+
+	var auditLogs []models.AuditLog
+	require.NoError(t, json.Unmarshal(files["audit_logs.json"], &auditLogs))
+	require.Len(t, auditLogs, 1)
+	assert.Equal(t, user.ID, *auditLogs[0].UserID)
+
+	assert.Equal(t, []uint{user.ID}, provider.exported)
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := privacyService.ExportUserData(ctx, 99999)
+		assert.ErrorIs(t, err, services.ErrUserNotFound)
+	})
+}
+
+func TestPrivacyService_EraseUser(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*services.PrivacyService, *fakeDataProvider, *models.User) {
+		testDB.ClearTables(t)
+		testDB.CreateTestRole(t, "trader", "Trader")
+
+		provider := &fakeDataProvider{}
+		privacyService := services.NewPrivacyService(testDB.DB, helpers.GetTestConfig(), provider)
+
+		user := createTestUserWithPassword(t, testDB, "erase@example.com", "Password123!", true, "trader")
+		permission := testDB.CreateTestPermission(t, "positions", "read", "Read positions")
+		testDB.AssignUserPermission(t, user.ID, permission.ID, true)
+		require.NoError(t, testDB.DB.Create(&models.PasswordResetToken{UserID: user.ID, Token: "reset-token"}).Error)
+
+		return privacyService, provider, user
+	}
+
+	t.Run("anonymizes the account and removes credentials", func(t *testing.T) {
+		privacyService, provider, user := setup(t)
+
+		result, err := privacyService.EraseUser(ctx, user.ID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Roles)
+		assert.Equal(t, int64(1), result.Permissions)
+		assert.Equal(t, int64(1), result.Tokens)
+		assert.Equal(t, []string{"trading"}, result.Providers)
+		assert.Equal(t, []uint{user.ID}, provider.erased)
+
+		var erased models.User
+		require.NoError(t, testDB.DB.Unscoped().First(&erased, user.ID).Error)
+		assert.True(t, strings.HasPrefix(erased.Email, result.Pseudonym))
+		assert.True(t, strings.HasSuffix(erased.Email, "@erased.invalid"))
+		assert.Empty(t, erased.FirstName)
+		assert.Empty(t, erased.LastName)
+		assert.False(t, *erased.IsActive)
+		assert.NotNil(t, erased.ErasedAt)
+		assert.True(t, erased.DeletedAt.Valid)
+
+		var count int64
+		testDB.DB.Model(&models.UserRole{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+		testDB.DB.Model(&models.UserPermission{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+		testDB.DB.Unscoped().Model(&models.PasswordResetToken{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+
+		// The original address can be registered again
+		testDB.CreateTestUser(t, "erase@example.com", "New", "Owner")
+
+		verification, err := services.NewAuditService(testDB.DB, helpers.GetTestConfig()).VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.True(t, verification.Valid)
+
+		var auditLog models.AuditLog
+		require.NoError(t, testDB.DB.Where("action = ? AND resource = ?", "erase", "users").First(&auditLog).Error)
+		assert.NotContains(t, string(auditLog.NewValues), "erase@example.com")
+	})
+
+	t.Run("second erasure is rejected", func(t *testing.T) {
+		privacyService, _, user := setup(t)
+
+		_, err := privacyService.EraseUser(ctx, user.ID, nil)
+		require.NoError(t, err)
+
+		_, err = privacyService.EraseUser(ctx, user.ID, nil)
+		assert.ErrorIs(t, err, services.ErrUserErased)
+	})
+
+	t.Run("self-service erasure requires the password", func(t *testing.T) {
+		privacyService, provider, user := setup(t)
+
+		_, err := privacyService.EraseAccount(ctx, user.ID, "WrongPassword1!")
+		assert.ErrorIs(t, err, services.ErrInvalidPassword)
+		assert.Empty(t, provider.erased)
+
+		result, err := privacyService.EraseAccount(ctx, user.ID, "Password123!")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, result.UserID)
+	})
+}
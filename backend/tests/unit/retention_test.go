@@ -0,0 +1,273 @@
+package unit_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"trader/internal/models"
+	"trader/internal/retention"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type retentionTestEnv struct {
+	service      *retention.Service
+	auditService *services.AuditService
+	testDB       *helpers.TestDB
+	redisServer  *miniredis.Miniredis
+	redisClient  *redis.Client
+}
+
+func setupRetentionTest(t *testing.T, auditMaxAge time.Duration) *retentionTestEnv {
+	testDB := helpers.SetupTestDB(t)
+
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisServer.Addr(),
+	})
+
+	cfg := helpers.GetTestConfig()
+	cfg.Retention.BatchSize = 4
+	cfg.Retention.AuditLogsMaxAge = auditMaxAge
+	cfg.Retention.PasswordResetMaxAge = 24 * time.Hour
+
+	auditService := services.NewAuditService(testDB.DB, cfg)
+	service := retention.NewService(testDB.DB, redisClient, retention.NewLocalStore(t.TempDir()), auditService, cfg)
+
+	return &retentionTestEnv{
+		service:      service,
+		auditService: auditService,
+		testDB:       testDB,
+		redisServer:  redisServer,
+		redisClient:  redisClient,
+	}
+}
+
+// readArchive decodes every NDJSON line of a gzip archive file
+func readArchive(t *testing.T, path string) []map[string]interface{} {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	defer gz.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func blacklistToken(t *testing.T, expiresAt time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	signed, err := token.SignedString([]byte("retention-test-secret"))
+	require.NoError(t, err)
+	return signed
+}
+
+func TestRetentionService_AuditLogs(t *testing.T) {
+	// A nanosecond max age expires every entry written before the run
+	env := setupRetentionTest(t, time.Nanosecond)
+	defer env.testDB.TeardownTestDB(t)
+
+	ctx := context.Background()
+	env.testDB.ClearTables(t)
+	logs := writeAuditEntries(t, env.auditService, 10)
+	time.Sleep(time.Second)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		results, err := env.service.Run(ctx, retention.PolicyAuditLogs, true)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, 10, results[0].Archived)
+		assert.Empty(t, results[0].Files)
+
+		var count int64
+		env.testDB.DB.Model(&models.AuditLog{}).Count(&count)
+		assert.Equal(t, int64(10), count)
+	})
+
+	t.Run("expired entries are archived and deleted in batches", func(t *testing.T) {
+		results, err := env.service.Run(ctx, retention.PolicyAuditLogs, false)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		result := results[0]
+		assert.Equal(t, 10, result.Archived)
+		assert.Equal(t, 10, result.Deleted)
+		require.Len(t, result.Files, 3)
+
+		archived := 0
+		for _, file := range result.Files {
+			archived += len(readArchive(t, file))
+		}
+		assert.Equal(t, 10, archived)
+
+		first := readArchive(t, result.Files[0])
+		assert.Equal(t, float64(logs[0].ID), first[0]["id"])
+		assert.Equal(t, logs[0].Hash, first[0]["hash"])
+
+		// Only the audit entries describing each archived batch remain
+		var remaining []models.AuditLog
+		require.NoError(t, env.testDB.DB.Order("id ASC").Find(&remaining).Error)
+		require.Len(t, remaining, 3)
+		for _, entry := range remaining {
+			assert.Equal(t, "archive", entry.Action)
+			assert.Equal(t, retention.PolicyAuditLogs, entry.Resource)
+		}
+
+		// The last batch records the anchor the chain now starts from
+		var values map[string]interface{}
+		require.NoError(t, json.Unmarshal(remaining[2].NewValues, &values))
+		assert.Equal(t, float64(logs[9].ID), values["last_id"])
+		assert.Equal(t, logs[9].Hash, values["last_hash"])
+	})
+
+	t.Run("chain still verifies after archiving", func(t *testing.T) {
+		writeAuditEntries(t, env.auditService, 2)
+
+		result, err := env.auditService.VerifyChain(ctx, 2)
+		require.NoError(t, err)
+		assert.True(t, result.Valid, result.Reason)
+		assert.Equal(t, 5, result.Checked)
+	})
+
+	t.Run("an anchor moved without the secret is detected", func(t *testing.T) {
+		var head models.AuditChainHead
+		require.NoError(t, env.testDB.DB.First(&head).Error)
+		var first models.AuditLog
+		require.NoError(t, env.testDB.DB.Order("id ASC").First(&first).Error)
+
+		require.NoError(t, env.testDB.DB.Delete(&first).Error)
+		require.NoError(t, env.testDB.DB.Model(&head).Updates(map[string]interface{}{
+			"archived_log_id": first.ID,
+			"archived_hash":   first.Hash,
+		}).Error)
+
+		result, err := env.auditService.VerifyChain(ctx, 2)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, "archive anchor has an invalid signature", result.Reason)
+	})
+
+	t.Run("an anchor must be recorded by an archive entry", func(t *testing.T) {
+		// A validly signed anchor that no archive entry in the chain describes
+		var first models.AuditLog
+		require.NoError(t, env.testDB.DB.Order("id ASC").First(&first).Error)
+		err := env.testDB.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&first).Error; err != nil {
+				return err
+			}
+			return env.auditService.MarkArchived(tx, first.ID, first.Hash)
+		})
+		require.NoError(t, err)
+
+		result, err := env.auditService.VerifyChain(ctx, 2)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, "archive anchor is not recorded in the chain", result.Reason)
+	})
+}
+
+func TestRetentionService_PasswordResetTokens(t *testing.T) {
+	env := setupRetentionTest(t, 90*24*time.Hour)
+	defer env.testDB.TeardownTestDB(t)
+
+	ctx := context.Background()
+	env.testDB.ClearTables(t)
+
+	user := createTestUserWithPassword(t, env.testDB, "retention@example.com", "Password123!", true)
+
+	expired := models.PasswordResetToken{
+		UserID:    user.ID,
+		Token:     "expired-reset-token-value",
+		ExpiresAt: time.Now().Add(-48 * time.Hour),
+	}
+	recent := models.PasswordResetToken{
+		UserID:    user.ID,
+		Token:     "recent-reset-token-value",
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, env.testDB.DB.Create(&expired).Error)
+	require.NoError(t, env.testDB.DB.Create(&recent).Error)
+
+	results, err := env.service.Run(ctx, retention.PolicyPasswordResetTokens, false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].Deleted)
+	require.Len(t, results[0].Files, 1)
+
+	t.Run("archive omits the token value", func(t *testing.T) {
+		records := readArchive(t, results[0].Files[0])
+		require.Len(t, records, 1)
+		assert.Equal(t, float64(expired.ID), records[0]["id"])
+		assert.Equal(t, float64(user.ID), records[0]["user_id"])
+		assert.NotContains(t, records[0], "token")
+	})
+
+	t.Run("tokens within the retention window are kept", func(t *testing.T) {
+		var tokens []models.PasswordResetToken
+		require.NoError(t, env.testDB.DB.Unscoped().Find(&tokens).Error)
+		require.Len(t, tokens, 1)
+		assert.Equal(t, recent.ID, tokens[0].ID)
+	})
+}
+
+func TestRetentionService_TokenBlacklist(t *testing.T) {
+	env := setupRetentionTest(t, 90*24*time.Hour)
+	defer env.testDB.TeardownTestDB(t)
+
+	ctx := context.Background()
+
+	expiredKey := services.TokenBlacklistPrefix + blacklistToken(t, time.Now().Add(-time.Hour))
+	activeKey := services.TokenBlacklistPrefix + blacklistToken(t, time.Now().Add(time.Hour))
+	garbageKey := services.TokenBlacklistPrefix + "not-a-jwt"
+	ttlKey := services.TokenBlacklistPrefix + blacklistToken(t, time.Now().Add(-2*time.Hour))
+
+	for _, key := range []string{expiredKey, activeKey, garbageKey} {
+		require.NoError(t, env.redisClient.Set(ctx, key, "1", 0).Err())
+	}
+	require.NoError(t, env.redisClient.Set(ctx, ttlKey, "1", time.Minute).Err())
+
+	results, err := env.service.Run(ctx, retention.PolicyTokenBlacklist, false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 2, results[0].Deleted)
+
+	assert.False(t, env.redisServer.Exists(expiredKey))
+	assert.False(t, env.redisServer.Exists(garbageKey))
+	assert.True(t, env.redisServer.Exists(ttlKey))
+
+	require.True(t, env.redisServer.Exists(activeKey))
+	ttl := env.redisServer.TTL(activeKey)
+	assert.Greater(t, ttl, time.Duration(0))
+	assert.LessOrEqual(t, ttl, time.Hour)
+}
+
+func TestRetentionService_UnknownPolicy(t *testing.T) {
+	env := setupRetentionTest(t, 90*24*time.Hour)
+	defer env.testDB.TeardownTestDB(t)
+
+	_, err := env.service.Run(context.Background(), "sessions", false)
+	assert.Error(t, err)
+}
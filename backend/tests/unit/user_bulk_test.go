@@ -0,0 +1,265 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"trader/internal/mail"
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recordingMailer keeps sent messages in memory and can be told to fail
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []*mail.Message
+	err      error
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func setupUserBulkTest(t *testing.T) (*services.UserBulkService, *recordingMailer, *helpers.TestDB) {
+	testDB := helpers.SetupTestDB(t)
+	testDB.ClearTables(t)
+	testDB.CreateTestRole(t, "admin", "Administrator")
+	testDB.CreateTestRole(t, "trader", "Trader")
+	testDB.CreateTestRole(t, "viewer", "Viewer")
+
+	mailer := &recordingMailer{}
+	bulkService := services.NewUserBulkService(testDB.DB, helpers.GetTestConfig(), mailer)
+	return bulkService, mailer, testDB
+}
+
+func TestParseImportRows(t *testing.T) {
+	t.Run("CSV with header in any order", func(t *testing.T) {
+		input := "last_name,email,first_name,roles\n" +
+			"Anderson,alice@example.com,Alice,admin;trader\n" +
+			"Brown,bob@example.com,Bob,\n"
+
+		rows, err := services.ParseImportRows(strings.NewReader(input), services.ImportFormatCSV)
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Equal(t, "alice@example.com", rows[0].Email)
+		assert.Equal(t, "Alice", rows[0].FirstName)
+		assert.Equal(t, []string{"admin", "trader"}, rows[0].Roles)
+		assert.Equal(t, 3, rows[1].Line)
+		assert.Empty(t, rows[1].Roles)
+	})
+
+	t.Run("CSV without email column", func(t *testing.T) {
+		_, err := services.ParseImportRows(strings.NewReader("name\nAlice\n"), services.ImportFormatCSV)
+		assert.ErrorIs(t, err, services.ErrInvalidImportFile)
+	})
+
+	t.Run("JSON array", func(t *testing.T) {
+		input := `[{"email":"alice@example.com","first_name":"Alice","last_name":"Anderson","role":"admin"}]`
+
+		rows, err := services.ParseImportRows(strings.NewReader(input), services.ImportFormatJSON)
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, 1, rows[0].Line)
+		assert.Equal(t, "admin", rows[0].Role)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := services.ParseImportRows(strings.NewReader(""), "xlsx")
+		assert.ErrorIs(t, err, services.ErrUnsupportedFormat)
+	})
+}
+
+func TestUserBulkService_ImportUsers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates users with random passwords and roles", func(t *testing.T) {
+		bulkService, mailer, testDB := setupUserBulkTest(t)
+		defer testDB.TeardownTestDB(t)
+
+		rows := []services.ImportUserRow{
+			{Line: 2, Email: " Alice@Example.com ", FirstName: "Alice", LastName: "Anderson", Roles: []string{"admin", "trader"}},
+			{Line: 3, Email: "bob@example.com", FirstName: "Bob", LastName: "Brown"},
+		}
+
+		report, err := bulkService.ImportUsers(ctx, rows, &services.ImportOptions{
+			PasswordMode: services.ImportPasswordRandom,
+			DefaultRole:  "viewer",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Created)
+		assert.Empty(t, mailer.messages)
+
+		var alice models.User
+		require.NoError(t, testDB.DB.Preload("Roles").Where("email = ?", "alice@example.com").First(&alice).Error)
+		assert.Len(t, alice.Roles, 2)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(alice.PasswordHash), []byte(report.Rows[0].Password)))
+		assert.Len(t, report.Rows[0].Password, 16)
+
+		var bob models.User
+		require.NoError(t, testDB.DB.Preload("Roles").Where("email = ?", "bob@example.com").First(&bob).Error)
+		require.Len(t, bob.Roles, 1)
+		assert.Equal(t, "viewer", bob.Roles[0].Name)
+
+		var auditCount int64
+		testDB.DB.Model(&models.AuditLog{}).Where("action = ? AND resource = ?", "import", "users").Count(&auditCount)
+		assert.Equal(t, int64(2), auditCount)
+	})
+
+	t.Run("invalid rows abort the whole import", func(t *testing.T) {
+		bulkService, _, testDB := setupUserBulkTest(t)
+		defer testDB.TeardownTestDB(t)
+
+		testDB.CreateTestUser(t, "taken@example.com", "Taken", "User")
+
+		rows := []services.ImportUserRow{
+			{Line: 2, Email: "good@example.com", FirstName: "Good", LastName: "User", Role: "trader"},
+			{Line: 3, Email: "not-an-email", FirstName: "Bad", LastName: "Email", Role: "trader"},
+			{Line: 4, Email: "taken@example.com", FirstName: "Taken", LastName: "Again", Role: "trader"},
+			{Line: 5, Email: "good@example.com", FirstName: "Good", LastName: "Twice", Role: "trader"},
+			{Line: 6, Email: "role@example.com", FirstName: "No", LastName: "Role", Role: "superuser"},
+			{Line: 7, Email: "name@example.com", Role: "trader"},
+		}
+
+		report, err := bulkService.ImportUsers(ctx, rows, &services.ImportOptions{PasswordMode: services.ImportPasswordRandom})
+		assert.ErrorIs(t, err, services.ErrImportInvalid)
+		require.NotNil(t, report)
+		assert.Equal(t, 5, report.Invalid)
+		assert.Equal(t, 0, report.Created)
+
+		assert.Equal(t, services.ImportStatusSkipped, report.Rows[0].Status)
+		assert.Contains(t, report.Rows[1].Errors, "email is not valid")
+		assert.Contains(t, report.Rows[2].Errors, "user with this email already exists")
+		assert.Contains(t, report.Rows[3].Errors, "duplicate of line 2")
+		assert.Contains(t, report.Rows[4].Errors, "role 'superuser' not found or inactive")
+		assert.Contains(t, report.Rows[5].Errors, "first name is required")
+
+		var count int64
+		testDB.DB.Model(&models.User{}).Where("email = ?", "good@example.com").Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("dry run validates without writing", func(t *testing.T) {
+		bulkService, _, testDB := setupUserBulkTest(t)
+		defer testDB.TeardownTestDB(t)
+
+		rows := []services.ImportUserRow{
+			{Line: 1, Email: "dry@example.com", FirstName: "Dry", LastName: "Run", Role: "trader"},
+		}
+
+		report, err := bulkService.ImportUsers(ctx, rows, &services.ImportOptions{
+			PasswordMode: services.ImportPasswordRandom,
+			DryRun:       true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, services.ImportStatusValid, report.Rows[0].Status)
+		assert.Empty(t, report.Rows[0].Password)
+
+		var count int64
+		testDB.DB.Model(&models.User{}).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("invite mode emails a password link", func(t *testing.T) {
+		bulkService, mailer, testDB := setupUserBulkTest(t)
+		defer testDB.TeardownTestDB(t)
+
+		rows := []services.ImportUserRow{
+			{Line: 1, Email: "invitee@example.com", FirstName: "Ivy", LastName: "Invitee", Role: "trader"},
+		}
+
+		report, err := bulkService.ImportUsers(ctx, rows, &services.ImportOptions{PasswordMode: services.ImportPasswordInvite})
+		require.NoError(t, err)
+		assert.True(t, report.Rows[0].InviteSent)
+		assert.Empty(t, report.Rows[0].Password)
+
+		var token models.PasswordResetToken
+		require.NoError(t, testDB.DB.Where("user_id = ?", report.Rows[0].UserID).First(&token).Error)
+
+		require.Len(t, mailer.messages, 1)
+		assert.Equal(t, "invitee@example.com", mailer.messages[0].To)
+		assert.Contains(t, mailer.messages[0].Body, "/reset-password?token="+token.Token)
+
+		var entry models.AuditLog
+		require.NoError(t, testDB.DB.Where("action = ? AND resource = ?", "import", "users").First(&entry).Error)
+		assert.NotContains(t, string(entry.NewValues), "invitee@example.com")
+
+		// The invitee chooses a password with the link
+		authService := services.NewAuthService(testDB.DB, nil, helpers.GetTestConfig())
+		require.NoError(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: token.Token, Password: "chosen-password"}))
+
+		var invitee models.User
+		require.NoError(t, testDB.DB.First(&invitee, report.Rows[0].UserID).Error)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(invitee.PasswordHash), []byte("chosen-password")))
+
+		var tokens int64
+		testDB.DB.Unscoped().Model(&models.PasswordResetToken{}).Where("user_id = ?", invitee.ID).Count(&tokens)
+		assert.Zero(t, tokens, "the token is used up")
+		assert.ErrorIs(t, authService.ResetPassword(ctx, &services.ResetPasswordRequest{Token: token.Token, Password: "another-password"}), services.ErrInvalidResetToken)
+	})
+
+	t.Run("failed invite email is reported per row", func(t *testing.T) {
+		bulkService, mailer, testDB := setupUserBulkTest(t)
+		defer testDB.TeardownTestDB(t)
+		mailer.err = errors.New("smtp unavailable")
+
+		rows := []services.ImportUserRow{
+			{Line: 1, Email: "nomail@example.com", FirstName: "No", LastName: "Mail", Role: "trader"},
+		}
+
+		report, err := bulkService.ImportUsers(ctx, rows, &services.ImportOptions{PasswordMode: services.ImportPasswordInvite})
+		require.NoError(t, err)
+		assert.Equal(t, services.ImportStatusCreated, report.Rows[0].Status)
+		assert.False(t, report.Rows[0].InviteSent)
+		require.Len(t, report.Rows[0].Errors, 1)
+		assert.Contains(t, report.Rows[0].Errors[0], "smtp unavailable")
+	})
+}
+
+func TestUserBulkService_ExportUsers(t *testing.T) {
+	bulkService, _, testDB := setupUserBulkTest(t)
+	defer testDB.TeardownTestDB(t)
+
+	user := testDB.CreateTestUser(t, "export@example.com", "Ex", "Port", "admin", "trader")
+	allowed := testDB.CreateTestPermission(t, "positions", "read", "Read positions")
+	denied := testDB.CreateTestPermission(t, "positions", "delete", "Delete positions")
+	testDB.AssignUserPermission(t, user.ID, allowed.ID, true)
+	testDB.AssignUserPermission(t, user.ID, denied.ID, false)
+
+	users, err := bulkService.ExportUsers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.ElementsMatch(t, []string{"admin", "trader"}, users[0].Roles)
+	assert.ElementsMatch(t, []services.PermissionGrant{
+		{Permission: "positions:read", Allow: true},
+		{Permission: "positions:delete", Allow: false},
+	}, users[0].Permissions)
+
+	t.Run("CSV export can be imported again", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, services.WriteUsersCSV(&buf, users))
+		assert.Contains(t, buf.String(), "!positions:delete")
+
+		rows, err := services.ParseImportRows(&buf, services.ImportFormatCSV)
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, "export@example.com", rows[0].Email)
+		assert.ElementsMatch(t, []string{"admin", "trader"}, rows[0].Roles)
+	})
+}
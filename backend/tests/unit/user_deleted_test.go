@@ -0,0 +1,195 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteUserAt soft-deletes a user with a fixed deletion time
+func deleteUserAt(t *testing.T, testDB *helpers.TestDB, userID uint, deletedAt time.Time) {
+	err := testDB.DB.Unscoped().Model(&models.User{}).Where("id = ?", userID).Update("deleted_at", deletedAt).Error
+	require.NoError(t, err)
+}
+
+func TestUserService_DeletedEmailIsFreed(t *testing.T) {
+	userService, testDB := setupUserServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	testDB.ClearTables(t)
+	ctx := context.Background()
+
+	testDB.CreateTestUser(t, "active@example.com", "Active", "User")
+	duplicate := &models.User{Email: "active@example.com", PasswordHash: "x"}
+	assert.Error(t, testDB.DB.Create(duplicate).Error, "active emails stay unique")
+
+	old := testDB.CreateTestUser(t, "reuse@example.com", "Old", "Owner")
+	require.NoError(t, userService.DeleteUser(ctx, old.ID))
+
+	created, err := userService.CreateUser(ctx, &services.CreateUserRequest{
+		Email:     "reuse@example.com",
+		FirstName: "New",
+		LastName:  "Owner",
+		Password:  "Password123!",
+	}, 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, old.ID, created.ID)
+
+	t.Run("restore conflicts with the new owner", func(t *testing.T) {
+		_, err := userService.RestoreUser(ctx, old.ID, nil)
+		assert.ErrorIs(t, err, services.ErrEmailExists)
+	})
+}
+
+func TestUserService_ListDeletedUsers(t *testing.T) {
+	userService, testDB := setupUserServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	testDB.ClearTables(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	testDB.CreateTestUser(t, "alive@example.com", "Alive", "User")
+	recent := testDB.CreateTestUser(t, "recent@example.com", "Recent", "User")
+	old := testDB.CreateTestUser(t, "old@example.com", "Old", "User")
+	older := testDB.CreateTestUser(t, "older@example.com", "Older", "User")
+	deleteUserAt(t, testDB, recent.ID, now.Add(-time.Hour))
+	deleteUserAt(t, testDB, old.ID, now.Add(-40*24*time.Hour))
+	deleteUserAt(t, testDB, older.ID, now.Add(-50*24*time.Hour))
+
+	first, err := userService.ListDeletedUsers(ctx, &services.ListDeletedUsersFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, first.Meta.Total)
+	require.Len(t, first.Users, 2)
+	assert.Equal(t, recent.ID, first.Users[0].ID)
+	assert.Equal(t, old.ID, first.Users[1].ID)
+	assert.WithinDuration(t, now.Add(-time.Hour+30*24*time.Hour), first.Users[0].PurgeAfter, time.Second)
+	require.NotEmpty(t, first.Meta.NextCursor)
+
+	second, err := userService.ListDeletedUsers(ctx, &services.ListDeletedUsersFilter{Limit: 2, Cursor: first.Meta.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, second.Users, 1)
+	assert.Equal(t, older.ID, second.Users[0].ID)
+	assert.Empty(t, second.Meta.NextCursor)
+
+	purgeable, err := userService.ListDeletedUsers(ctx, &services.ListDeletedUsersFilter{Purgeable: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, purgeable.Meta.Total)
+
+	_, err = userService.ListDeletedUsers(ctx, &services.ListDeletedUsersFilter{Cursor: "bogus"})
+	assert.ErrorIs(t, err, services.ErrInvalidCursor)
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	userService, testDB := setupUserServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	t.Run("restores the user with its roles", func(t *testing.T) {
+		testDB.ClearTables(t)
+		testDB.CreateTestRole(t, "trader", "Trader")
+		admin := testDB.CreateTestUser(t, "admin@example.com", "Admin", "User")
+		user := testDB.CreateTestUser(t, "restore@example.com", "Restore", "Me", "trader")
+		require.NoError(t, userService.DeleteUser(ctx, user.ID))
+
+		restored, err := userService.RestoreUser(ctx, user.ID, &admin.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "restore@example.com", restored.Email)
+		assert.Equal(t, []string{"trader"}, restored.Roles)
+
+		var auditLog models.AuditLog
+		require.NoError(t, testDB.DB.Where("action = ? AND resource = ?", "restore", "users").First(&auditLog).Error)
+		assert.Equal(t, admin.ID, *auditLog.UserID)
+	})
+
+	t.Run("active user", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := testDB.CreateTestUser(t, "active@example.com", "Active", "User")
+
+		_, err := userService.RestoreUser(ctx, user.ID, nil)
+		assert.ErrorIs(t, err, services.ErrUserNotDeleted)
+	})
+
+	t.Run("erased user", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := testDB.CreateTestUser(t, "erased@example.com", "Erased", "User")
+		privacyService := services.NewPrivacyService(testDB.DB, helpers.GetTestConfig())
+		_, err := privacyService.EraseUser(ctx, user.ID, nil)
+		require.NoError(t, err)
+
+		_, err = userService.RestoreUser(ctx, user.ID, nil)
+		assert.ErrorIs(t, err, services.ErrUserErased)
+	})
+}
+
+func TestUserService_PurgeUser(t *testing.T) {
+	userService, testDB := setupUserServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+	ctx := context.Background()
+
+	t.Run("grace period is enforced unless forced", func(t *testing.T) {
+		testDB.ClearTables(t)
+		testDB.CreateTestRole(t, "trader", "Trader")
+		user := testDB.CreateTestUser(t, "purge@example.com", "Purge", "Me", "trader")
+		require.NoError(t, userService.DeleteUser(ctx, user.ID))
+
+		err := userService.PurgeUser(ctx, user.ID, false, nil)
+		assert.ErrorIs(t, err, services.ErrPurgeGracePeriod)
+
+		require.NoError(t, userService.PurgeUser(ctx, user.ID, true, nil))
+
+		var count int64
+		testDB.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+		testDB.DB.Model(&models.UserRole{}).Where("user_id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("active user cannot be purged", func(t *testing.T) {
+		testDB.ClearTables(t)
+		user := testDB.CreateTestUser(t, "active@example.com", "Active", "User")
+
+		err := userService.PurgeUser(ctx, user.ID, true, nil)
+		assert.ErrorIs(t, err, services.ErrUserNotDeleted)
+	})
+
+	t.Run("expired users are purged and the audit chain stays intact", func(t *testing.T) {
+		testDB.ClearTables(t)
+		auditService := services.NewAuditService(testDB.DB, helpers.GetTestConfig())
+
+		expired := testDB.CreateTestUser(t, "expired@example.com", "Expired", "User")
+		recent := testDB.CreateTestUser(t, "recent@example.com", "Recent", "User")
+		erased := testDB.CreateTestUser(t, "erased@example.com", "Erased", "User")
+
+		_, err := auditService.Log(ctx, &services.AuditEntry{UserID: &expired.ID, Action: "login", Resource: "auth"})
+		require.NoError(t, err)
+		_, err = services.NewPrivacyService(testDB.DB, helpers.GetTestConfig()).EraseUser(ctx, erased.ID, nil)
+		require.NoError(t, err)
+
+		deleteUserAt(t, testDB, expired.ID, time.Now().Add(-31*24*time.Hour))
+		deleteUserAt(t, testDB, recent.ID, time.Now().Add(-time.Hour))
+		deleteUserAt(t, testDB, erased.ID, time.Now().Add(-31*24*time.Hour))
+
+		purged, err := userService.PurgeExpiredUsers(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{expired.ID}, purged)
+
+		var count int64
+		testDB.DB.Unscoped().Model(&models.User{}).Count(&count)
+		assert.Equal(t, int64(2), count)
+
+		var loginLog models.AuditLog
+		require.NoError(t, testDB.DB.Where("action = ?", "login").First(&loginLog).Error)
+		require.NotNil(t, loginLog.UserID)
+		assert.Equal(t, expired.ID, *loginLog.UserID)
+
+		verification, err := auditService.VerifyChain(ctx, 0)
+		require.NoError(t, err)
+		assert.True(t, verification.Valid)
+	})
+}
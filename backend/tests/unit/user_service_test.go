@@ -0,0 +1,169 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trader/internal/models"
+	"trader/internal/services"
+	"trader/tests/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUserServiceTest(t *testing.T) (*services.UserService, *helpers.TestDB) {
+	testDB := helpers.SetupTestDB(t)
+	userService := services.NewUserService(testDB.DB, helpers.GetTestConfig())
+	return userService, testDB
+}
+
+func listedEmails(users []services.UserInfo) []string {
+	emails := make([]string, 0, len(users))
+	for _, user := range users {
+		emails = append(emails, user.Email)
+	}
+	return emails
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestUserService_ListUsers(t *testing.T) {
+	userService, testDB := setupUserServiceTest(t)
+	defer testDB.TeardownTestDB(t)
+
+	ctx := context.Background()
+	testDB.ClearTables(t)
+	testDB.CreateTestRole(t, "admin", "Administrator")
+	testDB.CreateTestRole(t, "trader", "Trader")
+
+	alice := testDB.CreateTestUser(t, "alice@example.com", "Alice", "Anderson", "admin")
+	bob := testDB.CreateTestUser(t, "bob@example.com", "Bob", "Brown", "trader")
+	carol := testDB.CreateTestUser(t, "carol@example.com", "Carol", "Anderson", "trader")
+	dave := testDB.CreateTestUser(t, "dave@test.org", "Dave", "Davis", "trader")
+	erin := testDB.CreateTestUser(t, "erin_ops@example.com", "Erin", "Evans")
+
+	now := time.Now().UTC()
+	lockedUntil := now.Add(time.Hour)
+	lastWeek := now.AddDate(0, 0, -7)
+	yesterday := now.AddDate(0, 0, -1)
+
+	require.NoError(t, testDB.DB.Model(bob).Updates(map[string]interface{}{"locked_until": lockedUntil, "last_login_at": lastWeek}).Error)
+	require.NoError(t, testDB.DB.Model(carol).Updates(map[string]interface{}{"email_verified": true, "last_login_at": yesterday}).Error)
+	require.NoError(t, testDB.DB.Model(alice).Updates(map[string]interface{}{"email_verified": true, "last_login_at": now}).Error)
+	require.NoError(t, testDB.DB.Model(&models.User{}).Where("id = ?", dave.ID).Update("is_active", false).Error)
+
+	t.Run("search matches email and names", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{Search: "anderson", SortBy: "email", SortOrder: "asc"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice@example.com", "carol@example.com"}, listedEmails(response.Users))
+
+		response, err = userService.ListUsers(ctx, &services.ListUsersFilter{Search: "test.org"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dave@test.org"}, listedEmails(response.Users))
+	})
+
+	t.Run("every search term must match", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{Search: "carol anderson"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"carol@example.com"}, listedEmails(response.Users))
+	})
+
+	t.Run("wildcards in search are literal", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{Search: "_"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{erin.Email}, listedEmails(response.Users))
+	})
+
+	t.Run("filters combine", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{Locked: boolPtr(true)})
+		require.NoError(t, err)
+		assert.Equal(t, []string{bob.Email}, listedEmails(response.Users))
+
+		response, err = userService.ListUsers(ctx, &services.ListUsersFilter{
+			Role:          "trader",
+			Active:        boolPtr(true),
+			EmailVerified: boolPtr(false),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{bob.Email}, listedEmails(response.Users))
+		assert.Equal(t, 1, response.Meta.Total)
+
+		from := now.AddDate(0, 0, -3)
+		response, err = userService.ListUsers(ctx, &services.ListUsersFilter{
+			LastLoginFrom: &from,
+			SortBy:        services.UserSortLastLoginAt,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{alice.Email, carol.Email}, listedEmails(response.Users))
+	})
+
+	t.Run("role filter returns user fields", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{Role: "admin"})
+		require.NoError(t, err)
+		require.Len(t, response.Users, 1)
+		assert.Equal(t, alice.ID, response.Users[0].ID)
+		assert.Equal(t, []string{"admin"}, response.Users[0].Roles)
+	})
+
+	t.Run("cursor walks every page exactly once", func(t *testing.T) {
+		for _, sortBy := range []string{
+			services.UserSortCreatedAt,
+			services.UserSortEmail,
+			services.UserSortLastName,
+			services.UserSortLastLoginAt,
+		} {
+			for _, order := range []string{services.SortAsc, services.SortDesc} {
+				filter := &services.ListUsersFilter{SortBy: sortBy, SortOrder: order, Limit: 2}
+
+				var seen []string
+				for page := 0; page < 5; page++ {
+					response, err := userService.ListUsers(ctx, filter)
+					require.NoError(t, err)
+					assert.Equal(t, 5, response.Meta.Total)
+					seen = append(seen, listedEmails(response.Users)...)
+
+					if response.Meta.NextCursor == "" {
+						break
+					}
+					filter.Cursor = response.Meta.NextCursor
+				}
+
+				assert.Len(t, seen, 5, "sort %s %s", sortBy, order)
+				assert.ElementsMatch(t, []string{alice.Email, bob.Email, carol.Email, dave.Email, erin.Email}, seen)
+			}
+		}
+	})
+
+	t.Run("last login sort orders never logged in users first", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{
+			SortBy:    services.UserSortLastLoginAt,
+			SortOrder: services.SortAsc,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{dave.Email, erin.Email, bob.Email, carol.Email, alice.Email}, listedEmails(response.Users))
+	})
+
+	t.Run("invalid sort is rejected", func(t *testing.T) {
+		_, err := userService.ListUsers(ctx, &services.ListUsersFilter{SortBy: "password_hash"})
+		assert.ErrorIs(t, err, services.ErrInvalidSort)
+
+		_, err = userService.ListUsers(ctx, &services.ListUsersFilter{SortOrder: "sideways"})
+		assert.ErrorIs(t, err, services.ErrInvalidSort)
+	})
+
+	t.Run("cursor is bound to its sort order", func(t *testing.T) {
+		response, err := userService.ListUsers(ctx, &services.ListUsersFilter{SortBy: services.UserSortEmail, Limit: 1})
+		require.NoError(t, err)
+		require.NotEmpty(t, response.Meta.NextCursor)
+
+		_, err = userService.ListUsers(ctx, &services.ListUsersFilter{Cursor: response.Meta.NextCursor})
+		assert.ErrorIs(t, err, services.ErrInvalidCursor)
+
+		_, err = userService.ListUsers(ctx, &services.ListUsersFilter{Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, services.ErrInvalidCursor)
+	})
+}